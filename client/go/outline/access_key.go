@@ -0,0 +1,35 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import "strings"
+
+const accessKeyScheme = "ss://"
+
+// isAccessKey reports whether the client config is a bare Shadowsocks access key,
+// rather than a YAML config.
+func isAccessKey(clientConfigText string) bool {
+	text := strings.TrimSpace(clientConfigText)
+	return len(text) >= len(accessKeyScheme) &&
+		strings.EqualFold(text[:len(accessKeyScheme)], accessKeyScheme) &&
+		!strings.ContainsAny(text, "\r\n")
+}
+
+// parseAccessKey translates a Shadowsocks access key into the equivalent [ClientConfig]. The
+// config parser handles the SIP002 (https://shadowsocks.org/doc/sip002.html) and legacy formats
+// of the key, and warns about the parameters it ignores, such as plugin.
+func parseAccessKey(accessKey string) *ClientConfig {
+	return &ClientConfig{Transport: strings.TrimSpace(accessKey)}
+}
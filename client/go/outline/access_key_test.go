@@ -0,0 +1,100 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_NewClient_AccessKey(t *testing.T) {
+	legacy := base64.StdEncoding.EncodeToString([]byte("chacha20-ietf-poly1305:pa+ss/w?rd@example.com:4321"))
+	tests := []struct {
+		name      string
+		accessKey string
+	}{
+		{"SIP002", "ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpTRUNSRVQ@example.com:4321/"},
+		{"SIP002 with name", "ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpTRUNSRVQ@example.com:4321/#My%20Server"},
+		{"SIP002 percent-encoded", "ss://chacha20-ietf-poly1305:SECRET@example.com:4321"},
+		{"SIP002 with prefix", "ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpTRUNSRVQ@example.com:4321/?prefix=%16%03%01"},
+		{"surrounding spaces", "  ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpTRUNSRVQ@example.com:4321/\n"},
+		{"legacy", "ss://" + legacy},
+		{"legacy with name", "ss://" + legacy + "#My%20Server"},
+		{"legacy unpadded", "ss://" + base64.RawURLEncoding.EncodeToString([]byte("chacha20-ietf-poly1305:SECRET@example.com:4321"))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := NewClient(tt.accessKey)
+			require.Nil(t, result.Error, "Got %v", result.Error)
+			require.Equal(t, "example.com:4321", result.Client.sd.FirstHop)
+			require.Equal(t, "example.com:4321", result.Client.pl.FirstHop)
+		})
+	}
+}
+
+func Test_NewClient_AccessKey_Invalid(t *testing.T) {
+	tests := []struct {
+		name      string
+		accessKey string
+	}{
+		{"empty", "ss://"},
+		{"not base64", "ss://not_base64!"},
+		{"legacy without host", "ss://" + base64.RawURLEncoding.EncodeToString([]byte("chacha20-ietf-poly1305:SECRET"))},
+		{"legacy without port", "ss://" + base64.RawURLEncoding.EncodeToString([]byte("chacha20-ietf-poly1305:SECRET@example.com"))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := NewClient(tt.accessKey)
+			require.Nil(t, result.Client)
+			require.NotNil(t, result.Error)
+			require.Equal(t, platerrors.InvalidConfig, result.Error.Code)
+		})
+	}
+}
+
+func Test_NewClient_AccessKey_Warnings(t *testing.T) {
+	result := NewClient("ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpTRUNSRVQ@example.com:4321/?plugin=obfs-local%3Bobfs%3Dhttp")
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.Equal(t, []string{`Shadowsocks URL parameter "plugin" is not supported and is ignored`}, result.Warnings)
+
+	legacy := base64.RawURLEncoding.EncodeToString([]byte("chacha20-ietf-poly1305:SECRET@example.com:4321"))
+	result = NewClient("ss://" + legacy)
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.Equal(t, []string{"Shadowsocks URL uses the deprecated legacy base64 format, use the SIP002 format instead"}, result.Warnings)
+}
+
+func Test_NewClient_PluginIgnoredWithWarning(t *testing.T) {
+	const pluginKey = "ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpTRUNSRVQ@example.com:4321/?plugin=obfs-local%3Bobfs-host%3Dsecret.example"
+	const warning = `Shadowsocks URL parameter "plugin" is not supported and is ignored`
+	for name, newClient := range map[string]func() *NewClientResult{
+		"access key": func() *NewClientResult { return NewClient(pluginKey) },
+		"YAML":       func() *NewClientResult { return NewClient("transport: " + pluginKey) },
+		"JSON":       func() *NewClientResult { return NewClientFromJSON(`{"transport": "` + pluginKey + `"}`) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			result := newClient()
+			require.Nil(t, result.Error, "Got %v", result.Error)
+			require.NotNil(t, result.Client)
+			require.Contains(t, result.Warnings, warning)
+			// The plugin options may hold secrets.
+			for _, w := range result.Warnings {
+				require.NotContains(t, w, "secret.example")
+			}
+		})
+	}
+}
@@ -0,0 +1,1308 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/connectivity"
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/Jigsaw-Code/outline-sdk/dns"
+	"golang.org/x/net/http/httpguts"
+)
+
+// BandwidthTestResult represents the results of bandwidth and latency testing
+type BandwidthTestResult struct {
+	DownloadSpeedKBps int64 // Download speed in KB/s
+	UploadSpeedKBps   int64 // Upload speed in KB/s
+	LatencyMs         int64 // Round-trip latency in milliseconds
+	// Error is the failure of the first phase that failed, if any. Its code tells whether the
+	// speed test server or the proxy is at fault, and its cause is the failure of the phase.
+	Error *platerrors.PlatformError
+	// LatencyError, DownloadError and UploadError are the failures of each phase. The value of
+	// a failed phase is -1, but the values of the other phases are still valid.
+	LatencyError, DownloadError, UploadError *platerrors.PlatformError
+	// MaxBytesReached is true if the download or the upload stopped early because it transferred
+	// [BandwidthTestConfig.MaxBytes].
+	MaxBytesReached bool
+	// LikelyRateLimited is true if the download plateaued at a round rate, which suggests that a
+	// server or a plan throttles the connection rather than the network being slow. See
+	// [likelyRateLimited] for the heuristic, which only flags clear cases.
+	LikelyRateLimited bool
+}
+
+// LatencyResult is the result of [Client.MeasureLatency].
+//
+// We use a struct instead of a tuple to preserve a strongly typed error that gobind recognizes.
+type LatencyResult struct {
+	LatencyMs int64 // Round-trip latency in milliseconds, or -1 if the test failed
+	Error     *platerrors.PlatformError
+}
+
+// SpeedResult is the result of [Client.MeasureDownloadSpeed] and [Client.MeasureUploadSpeed].
+//
+// We use a struct instead of a tuple to preserve a strongly typed error that gobind recognizes.
+type SpeedResult struct {
+	SpeedKBps int64 // Speed in KB/s, or -1 if the test failed
+	Capped    bool  // Whether the test stopped because it transferred its maximum number of bytes
+	// Method is how the speed of a download was computed, [SpeedMethodContentLength] or
+	// [SpeedMethodTimed]. It's empty for uploads, and for downloads that failed or were canceled
+	// before the response.
+	Method string
+	Error  *platerrors.PlatformError
+}
+
+// Methods of [SpeedResult.Method] and [DetailedSpeedResult.Method].
+const (
+	// SpeedMethodContentLength means the download received the whole response, whose length the
+	// server announced, so the speed is its length over the time from the response headers to its
+	// last byte, which leaves out the request setup.
+	SpeedMethodContentLength = "content-length"
+	// SpeedMethodTimed means the speed is the data received over the time of the test, because
+	// the length of the response was unknown, the response was compressed, or the download
+	// stopped before its end.
+	SpeedMethodTimed = "timed"
+)
+
+// TestLatency measures the round-trip time to a test server through the proxy.
+//
+// It returns -1 if the request fails, or 0 if ctx is canceled before a response is received.
+// Use [Client.MeasureLatency] to get the cause of failures.
+func (c *Client) TestLatency(ctx context.Context, testURL string) int64 {
+	return c.MeasureLatency(ctx, testURL).LatencyMs
+}
+
+// MeasureLatency is like [Client.TestLatency], but also returns why the request failed.
+func (c *Client) MeasureLatency(ctx context.Context, testURL string) *LatencyResult {
+	return c.newSpeedTester().latency(ctx, testURL)
+}
+
+// maxLatencySamples caps the number of probes of [Client.TestLatencyAveraged].
+const maxLatencySamples = 100
+
+// AveragedLatencyResult is the result of [Client.TestLatencyAveraged].
+//
+// We use a struct instead of a tuple to preserve a strongly typed error that gobind recognizes.
+type AveragedLatencyResult struct {
+	AverageMs float64 // Average round-trip time, without the slowest sample if there are several
+	MinMs     int64   // Fastest round-trip time
+	MaxMs     int64   // Slowest round-trip time, including the discarded outlier
+	Samples   int     // Number of successful probes
+	Error     *platerrors.PlatformError
+}
+
+// TestLatencyAveraged measures the round-trip time to a test server through the proxy with
+// up to samples sequential requests, which gives a steadier figure than [Client.TestLatency].
+//
+// The slowest sample is discarded as an outlier before averaging. It fails right away if the
+// first request fails, and later failed requests are left out of the result. If ctx is canceled,
+// it stops and reports the samples taken so far, or an [platerrors.OperationCanceled] error if
+// there are none.
+func (c *Client) TestLatencyAveraged(ctx context.Context, testURL string, samples int) *AveragedLatencyResult {
+	if samples < 1 || samples > maxLatencySamples {
+		return &AveragedLatencyResult{Error: &platerrors.PlatformError{
+			Code:    platerrors.InvalidConfig,
+			Message: fmt.Sprintf("latency samples must be between 1 and %d", maxLatencySamples),
+			Details: platerrors.ErrorDetails{"samples": samples},
+		}}
+	}
+	tester := c.newSpeedTester()
+	var rtts []int64
+	for i := 0; i < samples && ctx.Err() == nil; i++ {
+		latency := tester.latency(ctx, testURL)
+		if latency.Error != nil {
+			if i == 0 {
+				return &AveragedLatencyResult{Error: latency.Error}
+			}
+			continue
+		}
+		if ctx.Err() != nil {
+			break // The request was canceled, so its value is not a sample.
+		}
+		rtts = append(rtts, latency.LatencyMs)
+	}
+	if len(rtts) == 0 {
+		return &AveragedLatencyResult{Error: &platerrors.PlatformError{
+			Code:    platerrors.OperationCanceled,
+			Message: "latency test was canceled",
+		}}
+	}
+	return summarizeLatencySamples(rtts)
+}
+
+// summarizeLatencySamples returns the stats of the non-empty rtts.
+func summarizeLatencySamples(rtts []int64) *AveragedLatencyResult {
+	result := &AveragedLatencyResult{
+		MinMs:   slices.Min(rtts),
+		MaxMs:   slices.Max(rtts),
+		Samples: len(rtts),
+	}
+	total := int64(0)
+	for _, rtt := range rtts {
+		total += rtt
+	}
+	count := len(rtts)
+	if count > 1 {
+		total -= result.MaxMs
+		count--
+	}
+	result.AverageMs = float64(total) / float64(count)
+	return result
+}
+
+// maxConcurrentLatencyProbes caps the number of requests of [Client.TestLatencyMulti] in flight,
+// so that it doesn't open too many connections through the proxy at once.
+const maxConcurrentLatencyProbes = 4
+
+// TestLatencyMulti measures the round-trip time to each of urls through the proxy, several at a
+// time, to find the nearest of a set of measurement servers.
+//
+// It returns the latency of each URL, which is -1 if its request failed, or 0 if ctx is canceled
+// before a response is received. Use [Client.MeasureLatency] to get the cause of a failure.
+func (c *Client) TestLatencyMulti(ctx context.Context, urls []string) map[string]int64 {
+	tester := c.newSpeedTester()
+	results := make(map[string]int64, len(urls))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxConcurrentLatencyProbes)
+	probed := make(map[string]bool, len(urls))
+	for _, testURL := range urls {
+		if probed[testURL] {
+			continue
+		}
+		probed[testURL] = true
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			latency := tester.latency(ctx, testURL)
+			mu.Lock()
+			results[testURL] = latency.LatencyMs
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// speedTester makes the HTTP requests of the bandwidth tests.
+type speedTester struct {
+	// httpTransport makes the connections of the requests.
+	httpTransport *http.Transport
+	// headers are set on every request, replacing the defaults.
+	headers map[string]string
+	// readBufferKB is the size of the buffer downloads read into, in KB, or zero for
+	// [defaultReadBufferKB].
+	readBufferKB int
+	// uploadProtocol is how uploads send their data, or nil for [UploadProtocolChunked].
+	uploadProtocol uploadProtocol
+}
+
+// Bounds of [BandwidthTestConfig.ReadBufferKB].
+const (
+	defaultReadBufferKB = 128
+	minReadBufferKB     = 4
+	maxReadBufferKB     = 4096
+)
+
+// newReadBuffer returns a buffer for downloads to read into.
+func (t *speedTester) newReadBuffer() []byte {
+	sizeKB := t.readBufferKB
+	if sizeKB == 0 {
+		sizeKB = defaultReadBufferKB
+	}
+	return make([]byte, sizeKB*1024)
+}
+
+// defaultUserAgent is a browser-like User-Agent, since some test endpoints block the Go default.
+const defaultUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36"
+
+// newRequest creates a request with the default and configured headers.
+func (t *speedTester) newRequest(ctx context.Context, method, testURL string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, testURL, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", defaultUserAgent)
+	// Ask for the raw bytes, since compressed responses would skew the measurements.
+	req.Header.Set("Accept-Encoding", "identity")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+	for name, value := range t.headers {
+		req.Header.Set(name, value)
+	}
+	return req, nil
+}
+
+// newSpeedTester returns a speedTester that sends its requests through c with no extra headers.
+func (c *Client) newSpeedTester() *speedTester {
+	return &speedTester{httpTransport: c.proxyHTTPTransport()}
+}
+
+// newHTTPClient returns an HTTP client that uses t.httpTransport.
+func (t *speedTester) newHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{Transport: t.httpTransport, Timeout: timeout}
+}
+
+// latency implements [Client.TestLatency].
+func (t *speedTester) latency(ctx context.Context, testURL string) *LatencyResult {
+	httpClient := t.newHTTPClient(10 * time.Second)
+
+	req, err := t.newRequest(ctx, http.MethodHead, testURL, nil)
+	if err != nil {
+		return &LatencyResult{LatencyMs: -1, Error: invalidTestURLError(testURL, err)}
+	}
+
+	start := time.Now()
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return &LatencyResult{} // Canceled, not a failure.
+		}
+		return &LatencyResult{LatencyMs: -1, Error: speedTestError(err, platerrors.ProxyServerUnreachable, "latency request failed")}
+	}
+	defer resp.Body.Close()
+
+	return &LatencyResult{LatencyMs: time.Since(start).Milliseconds()}
+}
+
+// TestDownloadSpeed measures download speed by downloading data through the proxy.
+//
+// The test stops early if ctx is canceled, in which case the speed is computed from the bytes
+// received so far. It returns -1 only if no data could be downloaded.
+// Use [Client.MeasureDownloadSpeed] to get the cause of failures.
+func (c *Client) TestDownloadSpeed(ctx context.Context, testURL string, durationSeconds int) int64 {
+	return c.MeasureDownloadSpeed(ctx, testURL, durationSeconds).SpeedKBps
+}
+
+// MeasureDownloadSpeed is like [Client.TestDownloadSpeed], but also returns why the download failed.
+func (c *Client) MeasureDownloadSpeed(ctx context.Context, testURL string, durationSeconds int) *SpeedResult {
+	result := c.TestDownloadSpeedDetailed(ctx, testURL, durationSeconds)
+	return &SpeedResult{SpeedKBps: result.SpeedKBps, Capped: result.Capped, Method: result.Method, Error: result.Error}
+}
+
+// ThroughputSample is the amount of data transferred during one interval of a speed test.
+type ThroughputSample struct {
+	OffsetMs   int64 // Start of the interval, relative to the start of the test
+	DurationMs int64 // Length of the interval, usually one second
+	Bytes      int64 // Bytes transferred during the interval
+}
+
+// DetailedSpeedResult is the result of a speed test, broken down into per-second samples.
+type DetailedSpeedResult struct {
+	SpeedKBps  int64 // Average speed in KB/s, or -1 if the test failed
+	TotalBytes int64 // Bytes transferred during the whole test
+	DurationMs int64 // Duration of the whole test
+	Samples    []ThroughputSample
+	Protocol   string                    // HTTP protocol of the response, such as "HTTP/1.1", if any
+	Capped     bool                      // Whether the test stopped because it received its maximum number of bytes
+	Method     string                    // How SpeedKBps was computed, as in [SpeedResult.Method]
+	Error      *platerrors.PlatformError // Why the test failed, if it did
+}
+
+// sampleInterval is the length of the intervals reported in [DetailedSpeedResult.Samples].
+const sampleInterval = time.Second
+
+// TestDownloadSpeedDetailed is like [Client.TestDownloadSpeed], but also reports the throughput
+// of each second of the test, which reveals ramp-up and mid-stream throttling.
+func (c *Client) TestDownloadSpeedDetailed(ctx context.Context, testURL string, durationSeconds int) *DetailedSpeedResult {
+	limits := transferLimits{duration: time.Duration(durationSeconds) * time.Second}
+	return c.newSpeedTester().download(ctx, testURL, limits, nil)
+}
+
+// TestDownloadSpeedBytes measures download speed by downloading exactly byteCount bytes through
+// the proxy, which gives numbers that don't depend on the link speed.
+//
+// The test stops early if ctx is done or the response ends, in which case the speed is computed
+// from the bytes received so far, as reported in the result.
+func (c *Client) TestDownloadSpeedBytes(ctx context.Context, testURL string, byteCount int64) *DetailedSpeedResult {
+	if byteCount <= 0 {
+		return &DetailedSpeedResult{SpeedKBps: -1, Error: &platerrors.PlatformError{
+			Code:    platerrors.InvalidConfig,
+			Message: "byte count must be positive",
+			Details: platerrors.ErrorDetails{"byteCount": byteCount},
+		}}
+	}
+	return c.newSpeedTester().download(ctx, testURL, transferLimits{maxBytes: byteCount}, nil)
+}
+
+// maxDownloadStreams is the maximum number of streams of [Client.TestDownloadSpeedMultiStream].
+const maxDownloadStreams = 16
+
+// MultiStreamSpeedResult is the result of [Client.TestDownloadSpeedMultiStream] and
+// [Client.TestDownloadSpeedMultiplexed].
+type MultiStreamSpeedResult struct {
+	SpeedKBps     int64                     // Combined speed of all streams in KB/s, or -1 if the test failed
+	TotalBytes    int64                     // Bytes received by all streams
+	DurationMs    int64                     // Duration of the longest stream
+	FailedStreams int                       // Number of streams that received no data
+	Protocol      string                    // HTTP protocol of the responses, "HTTP/1.1" or "HTTP/2.0"
+	Error         *platerrors.PlatformError // Why the test failed, if all streams failed
+}
+
+// TestDownloadSpeedMultiStream measures download speed by downloading testURL over streams
+// parallel connections through the proxy, and combining their throughput.
+//
+// A single connection underestimates fast links because of its congestion window. Using several,
+// like browser-based speed tests do, gives numbers closer to the capacity of the link.
+func (c *Client) TestDownloadSpeedMultiStream(ctx context.Context, testURL string, streams int, durationSeconds int) *MultiStreamSpeedResult {
+	if err := validateStreams(streams, durationSeconds); err != nil {
+		return &MultiStreamSpeedResult{SpeedKBps: -1, Error: err}
+	}
+	// The HTTP client doesn't use HTTP/2, so each stream gets a connection of its own.
+	limits := transferLimits{duration: time.Duration(durationSeconds) * time.Second}
+	return c.newSpeedTester().downloadStreams(ctx, testURL, streams, limits, false)
+}
+
+// TestDownloadSpeedMultiplexed is like [Client.TestDownloadSpeedMultiStream], but the streams
+// share a single HTTP/2 connection if the server supports it, which estimates the capacity of
+// the link without opening many sockets. Otherwise it falls back to one HTTP/1.1 connection per
+// stream. The protocol used is reported in the result.
+func (c *Client) TestDownloadSpeedMultiplexed(ctx context.Context, testURL string, streams int, durationSeconds int) *MultiStreamSpeedResult {
+	if err := validateStreams(streams, durationSeconds); err != nil {
+		return &MultiStreamSpeedResult{SpeedKBps: -1, Error: err}
+	}
+	tester := c.newSpeedTester()
+	tester.httpTransport = newMultiplexedHTTPTransport(c)
+	defer tester.httpTransport.CloseIdleConnections()
+	limits := transferLimits{duration: time.Duration(durationSeconds) * time.Second}
+	return tester.downloadStreams(ctx, testURL, streams, limits, true)
+}
+
+func validateStreams(streams int, durationSeconds int) *platerrors.PlatformError {
+	if streams < 1 || streams > maxDownloadStreams || durationSeconds <= 0 {
+		return &platerrors.PlatformError{
+			Code:    platerrors.InvalidConfig,
+			Message: fmt.Sprintf("streams must be between 1 and %d, and the duration must be positive", maxDownloadStreams),
+			Details: platerrors.ErrorDetails{"streams": streams, "durationSeconds": durationSeconds},
+		}
+	}
+	return nil
+}
+
+// downloadStreams implements [Client.TestDownloadSpeedMultiStream] and
+// [Client.TestDownloadSpeedMultiplexed]. If multiplexed is set, the other streams start once
+// the first one gets a response, so that they can reuse its HTTP/2 connection instead of racing
+// to open their own.
+func (t *speedTester) downloadStreams(ctx context.Context, testURL string, streams int, limits transferLimits, multiplexed bool) *MultiStreamSpeedResult {
+	firstResponse := make(chan struct{})
+	var firstResponseOnce sync.Once
+	signalFirstResponse := func() { firstResponseOnce.Do(func() { close(firstResponse) }) }
+
+	var totalBytes atomic.Int64
+	streamResults := make([]*DetailedSpeedResult, streams)
+	var wg sync.WaitGroup
+	for i := range streamResults {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			streamCtx := ctx
+			if i == 0 {
+				defer signalFirstResponse()
+				streamCtx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{GotFirstResponseByte: signalFirstResponse})
+			} else if multiplexed {
+				<-firstResponse
+			}
+			streamResults[i] = t.download(streamCtx, testURL, limits, nil)
+			totalBytes.Add(streamResults[i].TotalBytes)
+		}()
+	}
+	wg.Wait()
+
+	result := &MultiStreamSpeedResult{TotalBytes: totalBytes.Load()}
+	for _, streamResult := range streamResults {
+		if streamResult.SpeedKBps < 0 {
+			result.FailedStreams++
+			if result.Error == nil {
+				result.Error = streamResult.Error
+			}
+		}
+		if result.Protocol == "" {
+			result.Protocol = streamResult.Protocol
+		}
+		result.DurationMs = max(result.DurationMs, streamResult.DurationMs)
+	}
+	if result.FailedStreams == streams {
+		result.SpeedKBps = -1
+		return result
+	}
+	result.Error = nil
+	result.SpeedKBps = speedKBps(result.TotalBytes, time.Duration(result.DurationMs)*time.Millisecond)
+	return result
+}
+
+// transferLimits bounds the measurement of a download or upload test.
+type transferLimits struct {
+	// duration is the length of the measurement, or zero for no limit.
+	duration time.Duration
+	// maxBytes is the number of bytes to transfer, including the warm-up, or zero for no limit.
+	// The warm-up transfers half of them at most, see [transferLimits.warmupMaxBytes].
+	maxBytes int64
+	// warmup is the time spent transferring data before the measurement starts, to exclude
+	// TCP slow-start from it.
+	warmup time.Duration
+}
+
+// warmupMaxBytes returns the number of bytes the warm-up may transfer, or zero for no limit. It's
+// half of maxBytes, so that a cap that the warm-up would reach still leaves data to measure.
+func (l transferLimits) warmupMaxBytes() int64 {
+	if l.maxBytes <= 0 {
+		return 0
+	}
+	return max(l.maxBytes/2, 1)
+}
+
+// timeout returns the time budget of the whole request, or zero if it's unlimited.
+func (l transferLimits) timeout() time.Duration {
+	if l.duration == 0 {
+		return 0
+	}
+	return l.warmup + l.duration + 5*time.Second
+}
+
+// maxTransferSetupTime is the part of the time budget of a transfer that [transferLimits.fitWithin]
+// leaves for the request setup, or a quarter of the budget if that's less.
+const maxTransferSetupTime = 2 * time.Second
+
+// fitWithin returns l with the warm-up and the measurement shortened in proportion, if needed,
+// so that they end within budget, after the time for the request setup.
+func (l transferLimits) fitWithin(budget time.Duration) transferLimits {
+	available := budget - min(budget/4, maxTransferSetupTime)
+	if l.duration == 0 || l.warmup+l.duration <= available {
+		return l
+	}
+	scale := float64(available) / float64(l.warmup+l.duration)
+	l.warmup = time.Duration(float64(l.warmup) * scale)
+	l.duration = max(time.Duration(float64(l.duration)*scale), time.Millisecond)
+	return l
+}
+
+// warmUp calls transfer until warmup has passed, maxBytes bytes have been transferred or ctx
+// is done. transfer is passed the number of bytes it may transfer, or zero for no limit. warmUp
+// returns the number of bytes transferred and the first error of transfer.
+func warmUp(ctx context.Context, warmup time.Duration, maxBytes int64, transfer func(limit int64) (int, error), progress *progressReporter) (int64, error) {
+	var total int64
+	end := time.Now().Add(warmup)
+	for time.Now().Before(end) && ctx.Err() == nil {
+		limit := int64(0)
+		if maxBytes > 0 {
+			limit = maxBytes - total
+			if limit <= 0 {
+				break
+			}
+		}
+		n, err := transfer(limit)
+		total += int64(n)
+		progress.add(int64(n))
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// limitBuffer returns the first limit bytes of buffer, or buffer if limit is zero.
+func limitBuffer(buffer []byte, limit int64) []byte {
+	if limit == 0 {
+		return buffer
+	}
+	return buffer[:min(int64(len(buffer)), limit)]
+}
+
+// download implements [Client.TestDownloadSpeedDetailed] and [Client.TestDownloadSpeedBytes],
+// reporting the bytes received to progress, which may be nil.
+func (t *speedTester) download(ctx context.Context, testURL string, limits transferLimits, progress *progressReporter) *DetailedSpeedResult {
+	httpClient := t.newHTTPClient(limits.timeout())
+
+	req, err := t.newRequest(ctx, http.MethodGet, testURL, nil)
+	if err != nil {
+		return &DetailedSpeedResult{SpeedKBps: -1, Error: invalidTestURLError(testURL, err)}
+	}
+
+	start := time.Now()
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return &DetailedSpeedResult{} // Canceled before any data was received.
+		}
+		return &DetailedSpeedResult{SpeedKBps: -1, Error: speedTestError(err, platerrors.ProxyServerUnreachable, "download request failed")}
+	}
+	headersReceived := time.Now()
+	// The response is replaced when the download resumes.
+	defer func() { resp.Body.Close() }()
+
+	result := &DetailedSpeedResult{Protocol: resp.Proto}
+	buffer := t.newReadBuffer()
+
+	var readErr error
+	var warmupBytes int64
+	var warmupDuration time.Duration
+	retries := 0
+	done := false
+	if limits.warmup > 0 {
+		var err error
+		warmupBytes, err = warmUp(ctx, limits.warmup, limits.warmupMaxBytes(), func(limit int64) (int, error) {
+			return resp.Body.Read(limitBuffer(buffer, limit))
+		}, progress)
+		if err != nil {
+			// The download ended or failed before the measurement started.
+			done = true
+			if err != io.EOF {
+				readErr = err
+			}
+		}
+		warmupDuration = time.Since(headersReceived)
+		start = time.Now()
+	}
+
+	sampleStart := start
+	var sampleBytes int64
+	addSample := func(now time.Time) {
+		result.Samples = append(result.Samples, ThroughputSample{
+			OffsetMs:   sampleStart.Sub(start).Milliseconds(),
+			DurationMs: now.Sub(sampleStart).Milliseconds(),
+			Bytes:      sampleBytes,
+		})
+		sampleStart = now
+		sampleBytes = 0
+	}
+
+	for !done && ctx.Err() == nil {
+		if limits.duration > 0 && time.Since(start) >= limits.duration {
+			break
+		}
+		remaining := int64(0)
+		if limits.maxBytes > 0 {
+			remaining = limits.maxBytes - warmupBytes - result.TotalBytes
+			if remaining <= 0 {
+				result.Capped = true
+				break
+			}
+		}
+		n, err := resp.Body.Read(limitBuffer(buffer, remaining))
+		result.TotalBytes += int64(n)
+		sampleBytes += int64(n)
+		progress.add(int64(n))
+		if now := time.Now(); now.Sub(sampleStart) >= sampleInterval {
+			addSample(now)
+		}
+		if err != nil {
+			// Covers io.EOF, read errors and cancellation, which also fails the read.
+			if err == io.EOF {
+				break
+			}
+			if retries < maxDownloadRetries && isRetryableReadError(err) && ctx.Err() == nil &&
+				(limits.duration == 0 || time.Since(start) < limits.duration) {
+				retries++
+				logger().Debug("resuming the download after a read error", "retry", retries)
+				if resumed := t.resumeDownload(ctx, httpClient, testURL, warmupBytes+result.TotalBytes); resumed != nil {
+					resp.Body.Close()
+					resp = resumed
+					continue
+				}
+			}
+			readErr = err
+			break
+		}
+	}
+
+	end := time.Now()
+	if sampleBytes > 0 {
+		addSample(end)
+	}
+	progress.finish()
+	result.DurationMs = end.Sub(start).Milliseconds()
+	if readErr != nil && result.TotalBytes == 0 && ctx.Err() == nil {
+		result.SpeedKBps = -1
+		result.Error = speedTestError(readErr, platerrors.ProxyServerReadFailed, "failed to read the download")
+		return result
+	}
+	result.Method = SpeedMethodTimed
+	if result.TotalBytes == 0 && done && ctx.Err() == nil {
+		// The warm-up received the whole download, so measure over the warm-up instead.
+		if warmupBytes == 0 {
+			result.SpeedKBps = -1
+			result.Error = &platerrors.PlatformError{
+				Code:    platerrors.ProxyServerReadFailed,
+				Message: "payload too small for warm-up",
+				Details: platerrors.ErrorDetails{"url": testURL},
+			}
+			return result
+		}
+		result.TotalBytes = warmupBytes
+		result.DurationMs = warmupDuration.Milliseconds()
+		result.SpeedKBps = speedKBps(warmupBytes, warmupDuration)
+		return result
+	}
+	result.SpeedKBps = speedKBps(result.TotalBytes, end.Sub(start))
+	if limits.warmup == 0 && retries == 0 && readErr == nil && isCompleteResponse(resp, result.TotalBytes) {
+		result.Method = SpeedMethodContentLength
+		result.SpeedKBps = speedKBps(resp.ContentLength, end.Sub(headersReceived))
+	}
+	return result
+}
+
+// isCompleteResponse returns whether received is the announced length of the uncompressed body
+// of resp.
+func isCompleteResponse(resp *http.Response, received int64) bool {
+	return resp.ContentLength > 0 && received == resp.ContentLength &&
+		!resp.Uncompressed && resp.Header.Get("Content-Encoding") == ""
+}
+
+// maxDownloadRetries is the maximum number of times a download resumes after a read error.
+const maxDownloadRetries = 3
+
+// isRetryableReadError returns whether err, which failed a read of a download, is likely
+// transient, such as a connection reset on a lossy link, so that the download can resume on a
+// new connection.
+func isRetryableReadError(err error) bool {
+	return errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNABORTED) || errors.Is(err, syscall.EPIPE)
+}
+
+// resumeDownload requests testURL again from offset, and returns the response, or nil if the
+// request failed. Servers that ignore the range send the data from the start, which is as good
+// for the measurement.
+func (t *speedTester) resumeDownload(ctx context.Context, httpClient *http.Client, testURL string, offset int64) *http.Response {
+	req, err := t.newRequest(ctx, http.MethodGet, testURL, nil)
+	if err != nil {
+		return nil
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		resp.Body.Close()
+		return nil
+	}
+	return resp
+}
+
+// TestUploadSpeed measures upload speed by uploading data through the proxy.
+//
+// The data is streamed as the body of a single request, and only the time spent streaming the
+// body counts toward the measurement, not the connection setup.
+// The test stops early if ctx is canceled, in which case the speed is computed from the bytes
+// sent so far. It returns -1 only if no data could be uploaded due to a transport failure.
+// Use [Client.MeasureUploadSpeed] to get the cause of failures.
+func (c *Client) TestUploadSpeed(ctx context.Context, testURL string, durationSeconds int) int64 {
+	return c.MeasureUploadSpeed(ctx, testURL, durationSeconds).SpeedKBps
+}
+
+// MeasureUploadSpeed is like [Client.TestUploadSpeed], but also returns why the upload failed.
+func (c *Client) MeasureUploadSpeed(ctx context.Context, testURL string, durationSeconds int) *SpeedResult {
+	limits := transferLimits{duration: time.Duration(durationSeconds) * time.Second}
+	return c.newSpeedTester().upload(ctx, testURL, limits, nil)
+}
+
+// upload implements [Client.MeasureUploadSpeed] with t.uploadProtocol, reporting the bytes sent
+// to progress, which may be nil.
+func (t *speedTester) upload(ctx context.Context, testURL string, limits transferLimits, progress *progressReporter) *SpeedResult {
+	protocol := t.uploadProtocol
+	if protocol == nil {
+		protocol = chunkedUpload{}
+	}
+	return protocol.upload(ctx, t, testURL, limits, progress)
+}
+
+// uploadStream implements [UploadProtocolChunked]: it streams the data as the body of a single
+// request with chunked encoding.
+func (t *speedTester) uploadStream(ctx context.Context, testURL string, limits transferLimits, progress *progressReporter) *SpeedResult {
+	httpClient := t.newHTTPClient(limits.timeout())
+
+	// Create test data. Chunks are kept small so we can stop close to the deadline.
+	data := make([]byte, 32*1024)
+	rand.Read(data)
+
+	pr, pw := io.Pipe()
+	body := &uploadBody{PipeReader: pr, started: make(chan time.Time, 1)}
+	req, err := t.newRequest(ctx, http.MethodPost, testURL, body)
+	if err != nil {
+		return &SpeedResult{SpeedKBps: -1, Error: invalidTestURLError(testURL, err)}
+	}
+	req.ContentLength = -1 // Stream with chunked encoding.
+
+	doneCh := make(chan error, 1)
+	go func() {
+		resp, err := httpClient.Do(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		doneCh <- err
+	}()
+
+	var start time.Time
+	select {
+	case start = <-body.started:
+	case err := <-doneCh:
+		// The request completed without ever reading the body.
+		if err != nil && ctx.Err() == nil {
+			return &SpeedResult{SpeedKBps: -1, Error: speedTestError(err, platerrors.ProxyServerUnreachable, "upload request failed")}
+		}
+		return &SpeedResult{}
+	}
+
+	done := false
+	var warmupBytes int64
+	var warmupDuration time.Duration
+	if limits.warmup > 0 {
+		var err error
+		warmupBytes, err = warmUp(ctx, limits.warmup, limits.warmupMaxBytes(), func(limit int64) (int, error) {
+			return pw.Write(limitBuffer(data, limit))
+		}, progress)
+		done = err != nil
+		warmupDuration = time.Since(start)
+		start = time.Now()
+	}
+
+	var totalBytes int64
+	capped := false
+	for !done && time.Since(start) < limits.duration && ctx.Err() == nil {
+		remaining := int64(0)
+		if limits.maxBytes > 0 {
+			remaining = limits.maxBytes - warmupBytes - totalBytes
+			if remaining <= 0 {
+				capped = true
+				break
+			}
+		}
+		n, err := pw.Write(limitBuffer(data, remaining))
+		totalBytes += int64(n)
+		progress.add(int64(n))
+		if err != nil {
+			break
+		}
+	}
+	elapsed := time.Since(start)
+	pw.Close()
+	progress.finish()
+
+	if err := <-doneCh; err != nil && totalBytes+warmupBytes == 0 && ctx.Err() == nil {
+		return &SpeedResult{SpeedKBps: -1, Error: speedTestError(err, platerrors.ProxyServerWriteFailed, "failed to send the upload")}
+	}
+	if totalBytes == 0 && done && warmupBytes > 0 && ctx.Err() == nil {
+		// The upload ended during the warm-up, so measure over the warm-up instead.
+		return &SpeedResult{SpeedKBps: speedKBps(warmupBytes, warmupDuration), Capped: capped}
+	}
+	return &SpeedResult{SpeedKBps: speedKBps(totalBytes, elapsed), Capped: capped}
+}
+
+// uploadBody is a request body that records when the HTTP transport first reads it,
+// which marks the end of the connection setup.
+type uploadBody struct {
+	*io.PipeReader
+	once    sync.Once
+	started chan time.Time
+}
+
+func (b *uploadBody) Read(p []byte) (int, error) {
+	b.once.Do(func() { b.started <- time.Now() })
+	return b.PipeReader.Read(p)
+}
+
+// invalidTestURLError returns the error for a test URL that cannot be requested.
+func invalidTestURLError(testURL string, err error) *platerrors.PlatformError {
+	return &platerrors.PlatformError{
+		Code:    platerrors.InvalidConfig,
+		Message: "test URL is not valid",
+		Details: platerrors.ErrorDetails{"url": testURL},
+		Cause:   platerrors.ToPlatformError(err),
+	}
+}
+
+// speedTestError converts err, which made a test request fail, into a [platerrors.PlatformError].
+// Timeouts and DNS failures get their own codes, other failures get code.
+func speedTestError(err error, code platerrors.ErrorCode, message string) *platerrors.PlatformError {
+	var netErr net.Error
+	var dnsErr *net.DNSError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()):
+		code = platerrors.Timeout
+	case errors.As(err, &dnsErr):
+		code = platerrors.ResolveIPFailed
+	}
+	return &platerrors.PlatformError{
+		Code:    code,
+		Message: message,
+		Cause:   platerrors.ToPlatformError(err),
+	}
+}
+
+// speedKBps converts a byte count transferred over duration d into KB/s.
+func speedKBps(totalBytes int64, d time.Duration) int64 {
+	// Microseconds keep transfers shorter than a millisecond, such as whole responses on fast
+	// links, measurable.
+	us := d.Microseconds()
+	if us == 0 {
+		return 0
+	}
+	return totalBytes * 1_000_000 / us / 1024
+}
+
+// Use speed.cloudflare.com for testing by default - it's designed for bandwidth testing.
+const (
+	defaultDownloadURL     = "https://speed.cloudflare.com/__down?bytes=2097152" // 2MB download
+	defaultUploadURL       = "https://speed.cloudflare.com/__up"                 // POST endpoint
+	defaultLatencyURL      = "https://speed.cloudflare.com/__ping"               // Simple HEAD request
+	defaultDurationSeconds = 10
+)
+
+// BandwidthTestConfig specifies the endpoints and durations used by
+// [Client.PerformBandwidthTestWithConfig].
+type BandwidthTestConfig struct {
+	// DownloadURL is fetched with GET to measure the download speed.
+	DownloadURL string
+	// UploadURL receives POST requests to measure the upload speed.
+	UploadURL string
+	// LatencyURL receives a HEAD request to measure the round-trip time.
+	LatencyURL string
+	// DurationSeconds is the duration of each of the download and upload tests.
+	// Zero means the default of 10 seconds.
+	DurationSeconds int
+	// WarmupSeconds is the time spent transferring data before each of the download and upload
+	// measurements start, so that they reflect the steady-state speed after TCP slow-start.
+	// It's added to the test time, and defaults to zero.
+	WarmupSeconds int
+	// Parallel runs the download and upload tests at the same time, each on its own connection,
+	// which roughly halves the total test time. A failure in one does not stop the other.
+	Parallel bool
+	// ResolveThroughProxy resolves the hostnames of the test URLs with DNS-over-TCP queries to a
+	// public resolver sent through the proxy, and then connects to the resolved IP addresses.
+	// Otherwise the hostnames are passed to the proxy as is.
+	ResolveThroughProxy bool
+	// Headers are set on every test request, replacing the defaults. In particular, they can
+	// replace the default browser-like User-Agent.
+	Headers map[string]string
+	// BaselineURL is fetched through the proxy when a test fails, to tell a blocked or down test
+	// server from a failing proxy. Empty means http://example.com.
+	BaselineURL string
+	// ReadBufferKB is the size of the buffer the download test reads into, in KB, between 4 and
+	// 4096. Larger buffers take fewer reads, and so less CPU, which can make a difference on very
+	// fast links, but each download holds its own buffer, which matters on devices with little
+	// memory. Zero means the default of 128 KB, which suits most links.
+	ReadBufferKB int
+	// UploadProtocol is how the upload test sends its data, which must match what the server of
+	// UploadURL expects: [UploadProtocolChunked], [UploadProtocolRaw] or
+	// [UploadProtocolCloudflare]. Empty means [UploadProtocolChunked].
+	UploadProtocol string
+	// MaxBytes caps the data transferred by each of the download and upload tests, including the
+	// warm-up, to protect metered connections. A test that reaches it stops early, even if its
+	// duration hasn't passed, and sets [BandwidthTestResult.MaxBytesReached]. The warm-up ends
+	// early once it has transferred half of it, so that the rest is measured. Zero means no cap.
+	MaxBytes int64
+}
+
+// proxyResolverAddress is the DNS resolver used when [BandwidthTestConfig.ResolveThroughProxy] is set.
+const proxyResolverAddress = "1.1.1.1:53"
+
+// diagnosisTimeout bounds the probes that find out why a bandwidth test failed.
+const diagnosisTimeout = 5 * time.Second
+
+// maxParallelPhases caps the number of bandwidth test phases running at the same time, so that
+// parallel phases don't starve each other of bandwidth.
+const maxParallelPhases = 2
+
+// NewBandwidthTestConfig returns a [BandwidthTestConfig] with the default endpoints,
+// which can be customized before being passed to [Client.PerformBandwidthTestWithConfig].
+func NewBandwidthTestConfig() *BandwidthTestConfig {
+	return &BandwidthTestConfig{
+		DownloadURL:     defaultDownloadURL,
+		UploadURL:       defaultUploadURL,
+		LatencyURL:      defaultLatencyURL,
+		DurationSeconds: defaultDurationSeconds,
+		UploadProtocol:  UploadProtocolCloudflare,
+	}
+}
+
+// validate returns an [platerrors.InvalidConfig] error if the config is not usable.
+func (cfg *BandwidthTestConfig) validate() error {
+	if cfg == nil {
+		return platerrors.PlatformError{
+			Code:    platerrors.InvalidConfig,
+			Message: "bandwidth test config is missing",
+		}
+	}
+	urls := []struct{ field, value string }{
+		{"DownloadURL", cfg.DownloadURL},
+		{"UploadURL", cfg.UploadURL},
+		{"LatencyURL", cfg.LatencyURL},
+	}
+	for _, u := range urls {
+		if err := validateTestURL(u.field, u.value); err != nil {
+			return err
+		}
+	}
+	if cfg.BaselineURL != "" {
+		if err := validateTestURL("BaselineURL", cfg.BaselineURL); err != nil {
+			return err
+		}
+	}
+	for name, value := range cfg.Headers {
+		if !httpguts.ValidHeaderFieldName(name) || !httpguts.ValidHeaderFieldValue(value) {
+			return platerrors.PlatformError{
+				Code:    platerrors.InvalidConfig,
+				Message: "bandwidth test header is not valid",
+				Details: platerrors.ErrorDetails{"header": name},
+			}
+		}
+	}
+	if cfg.WarmupSeconds < 0 {
+		return platerrors.PlatformError{
+			Code:    platerrors.InvalidConfig,
+			Message: "bandwidth test warm-up must not be negative",
+			Details: platerrors.ErrorDetails{"warmupSeconds": cfg.WarmupSeconds},
+		}
+	}
+	if cfg.DurationSeconds < 0 {
+		return platerrors.PlatformError{
+			Code:    platerrors.InvalidConfig,
+			Message: "bandwidth test duration must not be negative",
+			Details: platerrors.ErrorDetails{"durationSeconds": cfg.DurationSeconds},
+		}
+	}
+	if _, ok := uploadProtocols[cfg.UploadProtocol]; !ok && cfg.UploadProtocol != "" {
+		return platerrors.PlatformError{
+			Code:    platerrors.InvalidConfig,
+			Message: "bandwidth test upload protocol is not supported",
+			Details: platerrors.ErrorDetails{"uploadProtocol": cfg.UploadProtocol},
+		}
+	}
+	if cfg.MaxBytes < 0 {
+		return platerrors.PlatformError{
+			Code:    platerrors.InvalidConfig,
+			Message: "bandwidth test byte cap must not be negative",
+			Details: platerrors.ErrorDetails{"maxBytes": cfg.MaxBytes},
+		}
+	}
+	if cfg.ReadBufferKB != 0 && (cfg.ReadBufferKB < minReadBufferKB || cfg.ReadBufferKB > maxReadBufferKB) {
+		return platerrors.PlatformError{
+			Code:    platerrors.InvalidConfig,
+			Message: fmt.Sprintf("bandwidth test read buffer must be between %d and %d KB", minReadBufferKB, maxReadBufferKB),
+			Details: platerrors.ErrorDetails{"readBufferKB": cfg.ReadBufferKB},
+		}
+	}
+	return nil
+}
+
+func validateTestURL(field, rawURL string) error {
+	if rawURL == "" {
+		return platerrors.PlatformError{
+			Code:    platerrors.InvalidConfig,
+			Message: fmt.Sprintf("bandwidth test %s must not be empty", field),
+		}
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return platerrors.PlatformError{
+			Code:    platerrors.InvalidConfig,
+			Message: fmt.Sprintf("bandwidth test %s is not a valid URL", field),
+			Details: platerrors.ErrorDetails{"url": rawURL},
+			Cause:   platerrors.ToPlatformError(err),
+		}
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return platerrors.PlatformError{
+			Code:    platerrors.InvalidConfig,
+			Message: fmt.Sprintf("bandwidth test %s must be an absolute http(s) URL", field),
+			Details: platerrors.ErrorDetails{"url": rawURL},
+		}
+	}
+	return nil
+}
+
+// PerformBandwidthTest runs comprehensive bandwidth and latency tests against the default endpoints.
+func (c *Client) PerformBandwidthTest(ctx context.Context) *BandwidthTestResult {
+	return c.PerformBandwidthTestWithConfig(ctx, NewBandwidthTestConfig())
+}
+
+// PerformBandwidthTestWithConfig runs bandwidth and latency tests against the endpoints in cfg.
+//
+// If ctx has a deadline, the phases split the time left before it and the download and upload
+// measurements are shortened as needed, so that the test returns before the deadline. Phases
+// that run out of time before measuring anything fail with [platerrors.Timeout].
+//
+// It returns an [platerrors.InvalidConfig] error without running any test if cfg is not valid.
+func (c *Client) PerformBandwidthTestWithConfig(ctx context.Context, cfg *BandwidthTestConfig) *BandwidthTestResult {
+	return c.performBandwidthTest(ctx, cfg, nil)
+}
+
+// performBandwidthTest implements [Client.PerformBandwidthTestWithConfig], reporting the progress
+// of each phase to progress, which may be nil.
+func (c *Client) performBandwidthTest(ctx context.Context, cfg *BandwidthTestConfig, progress *progressSink) *BandwidthTestResult {
+	if err := cfg.validate(); err != nil {
+		return &BandwidthTestResult{Error: platerrors.ToPlatformError(err)}
+	}
+	durationSeconds := cfg.DurationSeconds
+	if durationSeconds == 0 {
+		durationSeconds = defaultDurationSeconds
+	}
+	limits := transferLimits{
+		duration: time.Duration(durationSeconds) * time.Second,
+		warmup:   time.Duration(cfg.WarmupSeconds) * time.Second,
+		maxBytes: cfg.MaxBytes,
+	}
+
+	tester := c.newSpeedTester()
+	tester.headers = cfg.Headers
+	tester.readBufferKB = cfg.ReadBufferKB
+	tester.uploadProtocol = uploadProtocols[cfg.UploadProtocol]
+	if cfg.ResolveThroughProxy {
+		sd, err := dns.NewStreamDialer(dns.NewTCPResolver(c, proxyResolverAddress), c)
+		if err != nil {
+			return &BandwidthTestResult{Error: &platerrors.PlatformError{
+				Code:    platerrors.InternalError,
+				Message: "failed to create the DNS resolver",
+				Cause:   platerrors.ToPlatformError(err),
+			}}
+		}
+		// The pool of the client connects to hostnames as is, so this needs its own pool.
+		tester.httpTransport = newProxyHTTPTransport(sd)
+		defer tester.httpTransport.CloseIdleConnections()
+	}
+
+	result := &BandwidthTestResult{}
+
+	// Test latency (quick test)
+	logger().Debug("bandwidth test phase started", "phase", BandwidthPhaseLatency)
+	latencyProgress := progress.startPhase(BandwidthPhaseLatency)
+	latencyCtx, cancelLatency := latencyPhaseContext(ctx)
+	latency := tester.latency(latencyCtx, cfg.LatencyURL)
+	if phaseBudgetExceeded(latencyCtx, ctx) && latency.Error == nil && latency.LatencyMs == 0 {
+		latency = &LatencyResult{LatencyMs: -1, Error: phaseTimeoutError(BandwidthPhaseLatency)}
+	}
+	cancelLatency()
+	result.LatencyMs = latency.LatencyMs
+	latencyProgress.finish()
+
+	// Test download and upload speed. Run one after the other, the download shares the time left
+	// with the upload, which then gets what the download left.
+	downloadShare := 2
+	if cfg.Parallel {
+		downloadShare = 1
+	}
+	var download *DetailedSpeedResult
+	var upload *SpeedResult
+	runPhases(cfg.Parallel,
+		func() {
+			logger().Debug("bandwidth test phase started", "phase", BandwidthPhaseDownload)
+			phaseProgress := progress.startPhase(BandwidthPhaseDownload)
+			phaseCtx, phaseLimits, cancel := transferPhaseContext(ctx, limits, downloadShare)
+			defer cancel()
+			download = tester.download(phaseCtx, cfg.DownloadURL, phaseLimits, phaseProgress)
+			if phaseBudgetExceeded(phaseCtx, ctx) && download.Error == nil && download.TotalBytes == 0 {
+				download = &DetailedSpeedResult{SpeedKBps: -1, Error: phaseTimeoutError(BandwidthPhaseDownload)}
+			}
+		},
+		func() {
+			logger().Debug("bandwidth test phase started", "phase", BandwidthPhaseUpload)
+			phaseProgress := progress.startPhase(BandwidthPhaseUpload)
+			phaseCtx, phaseLimits, cancel := transferPhaseContext(ctx, limits, 1)
+			defer cancel()
+			upload = tester.upload(phaseCtx, cfg.UploadURL, phaseLimits, phaseProgress)
+			if phaseBudgetExceeded(phaseCtx, ctx) && upload.Error == nil && upload.SpeedKBps == 0 {
+				upload = &SpeedResult{SpeedKBps: -1, Error: phaseTimeoutError(BandwidthPhaseUpload)}
+			}
+		},
+	)
+	result.DownloadSpeedKBps = download.SpeedKBps
+	result.UploadSpeedKBps = upload.SpeedKBps
+	result.LatencyError = latency.Error
+	result.DownloadError = download.Error
+	result.UploadError = upload.Error
+	result.MaxBytesReached = download.Capped || upload.Capped
+	result.LikelyRateLimited = download.Error == nil && likelyRateLimited(download.Samples)
+
+	// Report the first failure, if any.
+	phaseErrors := []struct {
+		phase, url string
+		err        *platerrors.PlatformError
+	}{
+		{BandwidthPhaseLatency, cfg.LatencyURL, latency.Error},
+		{BandwidthPhaseDownload, cfg.DownloadURL, download.Error},
+		{BandwidthPhaseUpload, cfg.UploadURL, upload.Error},
+	}
+	for _, pe := range phaseErrors {
+		if pe.err != nil {
+			baselineURL := cfg.BaselineURL
+			if baselineURL == "" {
+				baselineURL = fallbackProbeURL
+			}
+			result.Error = c.diagnoseBandwidthFailure(ctx, pe.url, baselineURL, pe.err)
+			result.Error.Details = platerrors.ErrorDetails{"phase": pe.phase, "url": pe.url}
+			break
+		}
+	}
+
+	logger().Info("bandwidth test done",
+		"latencyMs", result.LatencyMs, "downloadSpeedKBps", result.DownloadSpeedKBps, "uploadSpeedKBps", result.UploadSpeedKBps,
+		"error", errorCode(result.Error))
+	return result
+}
+
+// diagnoseBandwidthFailure returns the error to report for cause, the failure of the test
+// phase that requested testURL. It tells the failures of the test server from the failures of
+// the proxy by checking whether the host of testURL and baselineURL can be reached through it:
+//   - [platerrors.SpeedTestServerFailed] if the test host is reachable.
+//   - [platerrors.SpeedTestServerUnreachable] if only baselineURL is reachable.
+//   - [platerrors.ProxyServerUnreachable] if neither is reachable.
+func (c *Client) diagnoseBandwidthFailure(ctx context.Context, testURL, baselineURL string, cause *platerrors.PlatformError) *platerrors.PlatformError {
+	perr := &platerrors.PlatformError{
+		Code:    platerrors.InternalError,
+		Message: "bandwidth test failed",
+		Cause:   cause,
+	}
+	if cause.Code == platerrors.InvalidConfig {
+		perr.Code = platerrors.InvalidConfig
+		return perr
+	}
+	if ctx.Err() != nil {
+		// The probes can't run, and the failure is likely due to the cancellation anyway.
+		return perr
+	}
+	address, err := testURLAddress(testURL)
+	if err != nil {
+		return perr
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, diagnosisTimeout)
+	defer cancel()
+	switch {
+	case c.CheckReachability(ctx, address) == nil:
+		perr.Code = platerrors.SpeedTestServerFailed
+		perr.Message = "bandwidth test failed, but the speed test server is reachable"
+	case connectivity.CheckTCPConnectivityWithHTTPContext(ctx, c, baselineURL) == nil:
+		perr.Code = platerrors.SpeedTestServerUnreachable
+		perr.Message = "speed test server is unreachable through the proxy"
+	case ctx.Err() == nil || errors.Is(ctx.Err(), context.DeadlineExceeded):
+		perr.Code = platerrors.ProxyServerUnreachable
+		perr.Message = "bandwidth test failed because the proxy is not working"
+	}
+	return perr
+}
+
+// testURLAddress returns the host:port address that is dialed to request testURL.
+func testURLAddress(testURL string) (string, error) {
+	parsed, err := url.Parse(testURL)
+	if err != nil {
+		return "", err
+	}
+	port := parsed.Port()
+	if port == "" {
+		port = "80"
+		if parsed.Scheme == "https" {
+			port = "443"
+		}
+	}
+	return net.JoinHostPort(parsed.Hostname(), port), nil
+}
+
+// The phases of [Client.PerformBandwidthTestWithConfig] derive their time budgets from the
+// deadline of its context, if any, so that the test returns before it. A tenth of the time left
+// is kept to wrap up the test.
+const (
+	// maxLatencyPhaseTimeout bounds the latency phase, which is also bounded by the timeout of
+	// its request.
+	maxLatencyPhaseTimeout = 10 * time.Second
+	// latencyPhaseShare is the fraction of the time left that the latency phase may use.
+	latencyPhaseShare = 10
+)
+
+// phaseTimeLeft returns the time left to run the phases before the deadline of ctx, and whether
+// ctx has a deadline.
+func phaseTimeLeft(ctx context.Context) (time.Duration, bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0, false
+	}
+	return max(time.Until(deadline)*9/10, 0), true
+}
+
+// latencyPhaseContext returns the context of the latency phase, which ends after its share of
+// the time left before the deadline of ctx, if any.
+func latencyPhaseContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	left, ok := phaseTimeLeft(ctx)
+	if !ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, min(maxLatencyPhaseTimeout, left/latencyPhaseShare))
+}
+
+// transferPhaseContext returns the context and the limits of a download or upload phase that
+// gets 1/share of the time left before the deadline of ctx, if any. The limits are shortened to
+// fit in that time.
+func transferPhaseContext(ctx context.Context, limits transferLimits, share int) (context.Context, transferLimits, context.CancelFunc) {
+	left, ok := phaseTimeLeft(ctx)
+	if !ok {
+		ctx, cancel := context.WithCancel(ctx)
+		return ctx, limits, cancel
+	}
+	budget := left / time.Duration(share)
+	ctx, cancel := context.WithTimeout(ctx, budget)
+	return ctx, limits.fitWithin(budget), cancel
+}
+
+// phaseBudgetExceeded returns whether the phase with context phaseCtx ran out of its time
+// budget, while the test with context ctx goes on.
+func phaseBudgetExceeded(phaseCtx, ctx context.Context) bool {
+	return errors.Is(phaseCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil
+}
+
+// phaseTimeoutError is the error of a phase that ran out of its time budget before it could
+// measure anything.
+func phaseTimeoutError(phase string) *platerrors.PlatformError {
+	return &platerrors.PlatformError{
+		Code:    platerrors.Timeout,
+		Message: "bandwidth test phase ran out of time before the deadline of the test",
+		Details: platerrors.ErrorDetails{"phase": phase},
+	}
+}
+
+// runPhases runs the given test phases, either one after the other or concurrently with at most
+// [maxParallelPhases] running at once. It returns once all phases are done.
+func runPhases(parallel bool, phases ...func()) {
+	if !parallel {
+		for _, phase := range phases {
+			phase()
+		}
+		return
+	}
+	sem := make(chan struct{}, maxParallelPhases)
+	var wg sync.WaitGroup
+	for _, phase := range phases {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			phase()
+		}()
+	}
+	wg.Wait()
+}
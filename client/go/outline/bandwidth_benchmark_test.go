@@ -0,0 +1,57 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const benchmarkDownloadBytes = 64 * 1024 * 1024
+
+// BenchmarkSpeedTester_DownloadReadBuffer downloads from a local server, which is faster than
+// any real link, so that the cost of the reads dominates the measured speed.
+func BenchmarkSpeedTester_DownloadReadBuffer(b *testing.B) {
+	chunk := make([]byte, 1024*1024)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for sent := 0; sent < benchmarkDownloadBytes; sent += len(chunk) {
+			if _, err := w.Write(chunk); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	for _, sizeKB := range []int{minReadBufferKB, 32, defaultReadBufferKB, 1024, maxReadBufferKB} {
+		b.Run(fmt.Sprintf("%dKB", sizeKB), func(b *testing.B) {
+			httpTransport := newProxyHTTPTransport(newDirectTestClient())
+			defer httpTransport.CloseIdleConnections()
+			tester := &speedTester{httpTransport: httpTransport, readBufferKB: sizeKB}
+			b.SetBytes(benchmarkDownloadBytes)
+			var totalKBps int64
+			for i := 0; i < b.N; i++ {
+				result := tester.download(context.Background(), server.URL, transferLimits{maxBytes: benchmarkDownloadBytes}, nil)
+				if result.Error != nil {
+					b.Fatal(result.Error)
+				}
+				totalKBps += result.SpeedKBps
+			}
+			b.ReportMetric(float64(totalKBps)/float64(b.N), "KBps")
+		})
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+)
+
+const (
+	// estimatePayloadBytes is the size of the download of [Client.EstimateBandwidth].
+	estimatePayloadBytes = 256 * 1024
+	// defaultEstimateURL serves estimatePayloadBytes bytes.
+	defaultEstimateURL = "https://speed.cloudflare.com/__down?bytes=262144"
+	// estimateTimeout is the time budget of [Client.EstimateBandwidth].
+	estimateTimeout = 15 * time.Second
+	// minConfidentEstimateDuration is the shortest download that gives a
+	// [EstimateConfidenceMedium] estimate.
+	minConfidentEstimateDuration = 500 * time.Millisecond
+)
+
+// Confidence levels of [BandwidthEstimate].
+const (
+	// EstimateConfidenceLow means the download was too short to reflect the speed of the link,
+	// which is likely much faster than estimated.
+	EstimateConfidenceLow = "low"
+	// EstimateConfidenceMedium means the download lasted long enough to give a rough idea of the
+	// speed of the link, but still less than a full speed test.
+	EstimateConfidenceMedium = "medium"
+)
+
+// BandwidthEstimate is the result of [Client.EstimateBandwidth].
+//
+// We use a struct instead of a tuple to preserve a strongly typed error that gobind recognizes.
+type BandwidthEstimate struct {
+	// SpeedKBps is the estimated download speed in KB/s, or -1 if the download failed.
+	SpeedKBps int64
+	// TotalBytes is the amount of data downloaded.
+	TotalBytes int64
+	// DurationMs is how long the download took, including the connection setup.
+	DurationMs int64
+	// Confidence tells how much the estimate can be trusted, [EstimateConfidenceLow] or
+	// [EstimateConfidenceMedium]. It's empty if the download failed.
+	Confidence string
+	Error      *platerrors.PlatformError
+}
+
+// EstimateBandwidth gives a rough estimate of the download speed through the proxy with a single
+// 256 KB download, which costs a fraction of the data of [Client.PerformBandwidthTest], for
+// example on metered connections.
+//
+// The result is an estimate, not a measurement: the download includes the connection setup and
+// is too short for TCP to reach its full speed, so it underestimates fast links. Confidence tells
+// how rough the estimate is.
+func (c *Client) EstimateBandwidth(ctx context.Context) *BandwidthEstimate {
+	return c.estimateBandwidth(ctx, defaultEstimateURL)
+}
+
+// estimateBandwidth implements [Client.EstimateBandwidth] with a download from testURL.
+func (c *Client) estimateBandwidth(ctx context.Context, testURL string) *BandwidthEstimate {
+	ctx, cancel := context.WithTimeout(ctx, estimateTimeout)
+	defer cancel()
+	download := c.newSpeedTester().download(ctx, testURL, transferLimits{maxBytes: estimatePayloadBytes}, nil)
+	if download.Error != nil {
+		return &BandwidthEstimate{SpeedKBps: -1, Error: download.Error}
+	}
+	if download.TotalBytes == 0 {
+		perr := &platerrors.PlatformError{Code: platerrors.SpeedTestServerFailed, Message: "bandwidth estimate received no data"}
+		switch {
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			perr = &platerrors.PlatformError{Code: platerrors.Timeout, Message: "bandwidth estimate timed out"}
+		case ctx.Err() != nil:
+			perr = &platerrors.PlatformError{Code: platerrors.OperationCanceled, Message: "bandwidth estimate was canceled"}
+		}
+		return &BandwidthEstimate{SpeedKBps: -1, Error: perr}
+	}
+	estimate := &BandwidthEstimate{
+		SpeedKBps:  download.SpeedKBps,
+		TotalBytes: download.TotalBytes,
+		DurationMs: download.DurationMs,
+		Confidence: EstimateConfidenceMedium,
+	}
+	if time.Duration(download.DurationMs)*time.Millisecond < minConfidentEstimateDuration {
+		estimate.Confidence = EstimateConfidenceLow
+	}
+	return estimate
+}
@@ -0,0 +1,70 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_EstimateBandwidth_FastLink(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(make([]byte, 1024*1024))
+	}))
+	defer server.Close()
+
+	estimate := newDirectTestClient().estimateBandwidth(context.Background(), server.URL)
+	require.Nil(t, estimate.Error, "Got %v", estimate.Error)
+	// Only the fixed payload is downloaded, even if the server sends more.
+	require.Equal(t, int64(estimatePayloadBytes), estimate.TotalBytes)
+	require.Equal(t, EstimateConfidenceLow, estimate.Confidence)
+}
+
+func Test_EstimateBandwidth_SlowLink(t *testing.T) {
+	// The server sends 1KB every 10ms, so the payload takes a few seconds.
+	server := newSlowServer(t)
+
+	estimate := newDirectTestClient().estimateBandwidth(context.Background(), server.URL)
+	require.Nil(t, estimate.Error, "Got %v", estimate.Error)
+	require.Equal(t, int64(estimatePayloadBytes), estimate.TotalBytes)
+	require.Positive(t, estimate.SpeedKBps)
+	require.Equal(t, EstimateConfidenceMedium, estimate.Confidence)
+}
+
+func Test_EstimateBandwidth_Errors(t *testing.T) {
+	estimate := newUnreachableTestClient("127.0.0.1:4321").EstimateBandwidth(context.Background())
+	require.Equal(t, int64(-1), estimate.SpeedKBps)
+	require.Empty(t, estimate.Confidence)
+	require.NotNil(t, estimate.Error)
+	require.Equal(t, platerrors.ProxyServerUnreachable, estimate.Error.Code)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	estimate = newDirectTestClient().EstimateBandwidth(ctx)
+	require.Equal(t, int64(-1), estimate.SpeedKBps)
+	require.NotNil(t, estimate.Error)
+	require.Equal(t, platerrors.OperationCanceled, estimate.Error.Code)
+
+	emptyServer := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer emptyServer.Close()
+	estimate = newDirectTestClient().estimateBandwidth(context.Background(), emptyServer.URL)
+	require.NotNil(t, estimate.Error)
+	require.Equal(t, platerrors.SpeedTestServerFailed, estimate.Error.Code)
+}
@@ -0,0 +1,128 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Phases reported in [BandwidthProgress.Phase].
+const (
+	BandwidthPhaseLatency  = "latency"
+	BandwidthPhaseDownload = "download"
+	BandwidthPhaseUpload   = "upload"
+)
+
+// BandwidthProgress is a snapshot of a running bandwidth test phase.
+type BandwidthProgress struct {
+	// Phase is one of [BandwidthPhaseLatency], [BandwidthPhaseDownload] or [BandwidthPhaseUpload].
+	Phase string
+	// BytesTransferred is the number of bytes transferred so far in this phase.
+	BytesTransferred int64
+	// ElapsedMs is the time since the phase started.
+	ElapsedMs int64
+	// SpeedKBps is the speed since the previous report of this phase.
+	SpeedKBps int64
+}
+
+// BandwidthProgressListener receives progress reports from [Client.PerformBandwidthTestWithProgress].
+//
+// We use an interface instead of a func type so that it can be implemented by the platform code
+// through gobind. Calls are never concurrent, even when the phases run in parallel.
+type BandwidthProgressListener interface {
+	OnProgress(progress *BandwidthProgress)
+}
+
+// progressInterval is the minimum time between two progress reports of the same phase.
+const progressInterval = 250 * time.Millisecond
+
+// PerformBandwidthTestWithProgress is like [Client.PerformBandwidthTest], but periodically reports
+// the progress of each phase to listener, which may be nil.
+func (c *Client) PerformBandwidthTestWithProgress(ctx context.Context, listener BandwidthProgressListener) *BandwidthTestResult {
+	return c.performBandwidthTest(ctx, NewBandwidthTestConfig(), newProgressSink(listener))
+}
+
+// progressSink serializes the reports of all phases to a single listener.
+type progressSink struct {
+	mu       sync.Mutex
+	listener BandwidthProgressListener
+}
+
+// newProgressSink returns a sink for listener, or nil if listener is nil.
+func newProgressSink(listener BandwidthProgressListener) *progressSink {
+	if listener == nil {
+		return nil
+	}
+	return &progressSink{listener: listener}
+}
+
+func (s *progressSink) report(p *BandwidthProgress) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listener.OnProgress(p)
+}
+
+// startPhase returns a reporter for a phase starting now. It returns nil if the sink is nil,
+// and all methods of a nil reporter are no-ops.
+func (s *progressSink) startPhase(phase string) *progressReporter {
+	if s == nil {
+		return nil
+	}
+	now := time.Now()
+	return &progressReporter{sink: s, phase: phase, start: now, lastReport: now}
+}
+
+// progressReporter tracks the bytes transferred by a single phase and reports them at most
+// every [progressInterval].
+type progressReporter struct {
+	sink       *progressSink
+	phase      string
+	start      time.Time
+	total      int64
+	lastReport time.Time
+	lastTotal  int64
+}
+
+// add records n more bytes and reports the progress if enough time has passed.
+func (r *progressReporter) add(n int64) {
+	if r == nil {
+		return
+	}
+	r.total += n
+	if now := time.Now(); now.Sub(r.lastReport) >= progressInterval {
+		r.report(now)
+	}
+}
+
+// finish reports the final progress of the phase.
+func (r *progressReporter) finish() {
+	if r == nil {
+		return
+	}
+	r.report(time.Now())
+}
+
+func (r *progressReporter) report(now time.Time) {
+	r.sink.report(&BandwidthProgress{
+		Phase:            r.phase,
+		BytesTransferred: r.total,
+		ElapsedMs:        now.Sub(r.start).Milliseconds(),
+		SpeedKBps:        speedKBps(r.total-r.lastTotal, now.Sub(r.lastReport)),
+	})
+	r.lastReport = now
+	r.lastTotal = r.total
+}
@@ -0,0 +1,90 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// recordingProgressListener records every report it receives. It is not safe for concurrent use,
+// so the race detector flags reports that are not serialized.
+type recordingProgressListener struct {
+	reports []BandwidthProgress
+}
+
+func (l *recordingProgressListener) OnProgress(progress *BandwidthProgress) {
+	l.reports = append(l.reports, *progress)
+}
+
+// byPhase returns the reports of the given phase, in the order they were received.
+func (l *recordingProgressListener) byPhase(phase string) []BandwidthProgress {
+	var reports []BandwidthProgress
+	for _, r := range l.reports {
+		if r.Phase == phase {
+			reports = append(reports, r)
+		}
+	}
+	return reports
+}
+
+func Test_performBandwidthTest_ReportsProgress(t *testing.T) {
+	for _, parallel := range []bool{false, true} {
+		server := newSlowServer(t)
+		cfg := &BandwidthTestConfig{
+			DownloadURL:     server.URL,
+			UploadURL:       server.URL,
+			LatencyURL:      server.URL,
+			DurationSeconds: 1,
+			Parallel:        parallel,
+		}
+		listener := &recordingProgressListener{}
+
+		result := newDirectTestClient().performBandwidthTest(context.Background(), cfg, newProgressSink(listener))
+		require.Nil(t, result.Error, "Got %v", result.Error)
+
+		require.Len(t, listener.byPhase(BandwidthPhaseLatency), 1)
+		for _, phase := range []string{BandwidthPhaseDownload, BandwidthPhaseUpload} {
+			reports := listener.byPhase(phase)
+			// One second at one report every 250ms, plus the final report.
+			require.GreaterOrEqual(t, len(reports), 4, "phase %v", phase)
+			for i := 1; i < len(reports); i++ {
+				require.GreaterOrEqual(t, reports[i].BytesTransferred, reports[i-1].BytesTransferred)
+				require.GreaterOrEqual(t, reports[i].ElapsedMs, reports[i-1].ElapsedMs)
+			}
+			last := reports[len(reports)-1]
+			require.Greater(t, last.BytesTransferred, int64(0))
+			require.GreaterOrEqual(t, last.ElapsedMs, int64(1000))
+		}
+	}
+}
+
+func Test_PerformBandwidthTestWithProgress_NilListener(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// Must not panic without a listener.
+	result := newDirectTestClient().PerformBandwidthTestWithProgress(ctx, nil)
+	require.NotNil(t, result)
+}
+
+func Test_progressReporter_Nil(t *testing.T) {
+	var sink *progressSink
+	reporter := sink.startPhase(BandwidthPhaseDownload)
+	require.Nil(t, reporter)
+	reporter.add(10)
+	reporter.finish()
+}
@@ -0,0 +1,1163 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/config"
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/Jigsaw-Code/outline-sdk/transport"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// newDirectTestClient returns a [Client] that dials destinations directly, without a proxy.
+func newDirectTestClient() *Client {
+	tcpDialer := &transport.TCPDialer{}
+	return &Client{
+		sd: &config.Dialer[transport.StreamConn]{Dial: tcpDialer.DialStream},
+		pl: &config.PacketListener{PacketListener: &transport.UDPListener{}},
+	}
+}
+
+// newSlowServer returns a server that trickles data until the request or the test is done.
+func newSlowServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(serveSlowly))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// serveSlowly trickles data until the request is done.
+func serveSlowly(w http.ResponseWriter, r *http.Request) {
+	io.Copy(io.Discard, r.Body)
+	if r.Method == http.MethodHead {
+		return
+	}
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+		if _, err := w.Write(make([]byte, 1024)); err != nil {
+			return
+		}
+		w.(http.Flusher).Flush()
+	}
+}
+
+// newPipeTestClient returns a [Client] whose connections are served by handler over in-memory pipes.
+// Pipes have no buffering, so the handler fully controls the transfer rate.
+func newPipeTestClient(t *testing.T, handler http.Handler) *Client {
+	listener := &pipeListener{conns: make(chan net.Conn), done: make(chan struct{})}
+	server := &http.Server{Handler: handler}
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+
+	dial := func(ctx context.Context, address string) (transport.StreamConn, error) {
+		clientConn, serverConn := net.Pipe()
+		select {
+		case listener.conns <- serverConn:
+			return &pipeStreamConn{clientConn}, nil
+		case <-listener.done:
+			return nil, net.ErrClosed
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return &Client{
+		sd: &config.Dialer[transport.StreamConn]{Dial: dial},
+		pl: &config.PacketListener{PacketListener: &transport.UDPListener{}},
+	}
+}
+
+type pipeListener struct {
+	conns     chan net.Conn
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *pipeListener) Close() error {
+	l.closeOnce.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr { return &net.TCPAddr{} }
+
+type pipeStreamConn struct {
+	net.Conn
+}
+
+func (c *pipeStreamConn) CloseRead() error { return nil }
+
+func (c *pipeStreamConn) CloseWrite() error { return nil }
+
+func Test_SpeedTests_CanceledContext(t *testing.T) {
+	server := newSlowServer(t)
+	client := newDirectTestClient()
+
+	tests := []struct {
+		name string
+		run  func(ctx context.Context) int64
+	}{
+		{"download", func(ctx context.Context) int64 { return client.TestDownloadSpeed(ctx, server.URL, 10) }},
+		{"upload", func(ctx context.Context) int64 { return client.TestUploadSpeed(ctx, server.URL, 10) }},
+		{"latency", func(ctx context.Context) int64 { return client.TestLatency(ctx, server.URL) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(200*time.Millisecond, cancel)
+
+			start := time.Now()
+			got := tt.run(ctx)
+			require.Less(t, time.Since(start), 2*time.Second)
+			require.GreaterOrEqual(t, got, int64(0))
+		})
+	}
+}
+
+func Test_TestLatencyAveraged(t *testing.T) {
+	server := newSlowServer(t)
+
+	result := newDirectTestClient().TestLatencyAveraged(context.Background(), server.URL, 5)
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.Equal(t, 5, result.Samples)
+	require.LessOrEqual(t, result.MinMs, result.MaxMs)
+	require.GreaterOrEqual(t, result.AverageMs, float64(result.MinMs))
+	require.LessOrEqual(t, result.AverageMs, float64(result.MaxMs))
+}
+
+func Test_TestLatencyAveraged_Errors(t *testing.T) {
+	client := newDirectTestClient()
+
+	result := client.TestLatencyAveraged(context.Background(), "http://127.0.0.1:1/", 5)
+	require.NotNil(t, result.Error)
+	require.Equal(t, platerrors.ProxyServerUnreachable, result.Error.Code)
+	require.Equal(t, 0, result.Samples)
+
+	for _, samples := range []int{0, -1, maxLatencySamples + 1} {
+		result = client.TestLatencyAveraged(context.Background(), "http://127.0.0.1:1/", samples)
+		require.NotNil(t, result.Error)
+		require.Equal(t, platerrors.InvalidConfig, result.Error.Code)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result = client.TestLatencyAveraged(ctx, "http://127.0.0.1:1/", 5)
+	require.NotNil(t, result.Error)
+	require.Equal(t, platerrors.OperationCanceled, result.Error.Code)
+}
+
+func Test_TestLatencyMulti(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			highest := maxInFlight.Load()
+			if n <= highest || maxInFlight.CompareAndSwap(highest, n) {
+				break
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	var urls []string
+	for i := 0; i < 10; i++ {
+		urls = append(urls, server.URL+"/"+strconv.Itoa(i))
+	}
+	// Port 1 on localhost is expected to refuse connections.
+	urls = append(urls, "http://127.0.0.1:1/", server.URL+"/0")
+
+	start := time.Now()
+	results := newDirectTestClient().TestLatencyMulti(context.Background(), urls)
+	require.Len(t, results, 11)
+	for i := 0; i < 10; i++ {
+		require.GreaterOrEqual(t, results[server.URL+"/"+strconv.Itoa(i)], int64(50))
+	}
+	require.Equal(t, int64(-1), results["http://127.0.0.1:1/"])
+	require.Greater(t, maxInFlight.Load(), int32(1))
+	require.LessOrEqual(t, maxInFlight.Load(), int32(maxConcurrentLatencyProbes))
+	// Probing one at a time would take at least 500ms.
+	require.Less(t, time.Since(start), 400*time.Millisecond)
+}
+
+func Test_TestLatencyMulti_Empty(t *testing.T) {
+	require.Empty(t, newDirectTestClient().TestLatencyMulti(context.Background(), nil))
+}
+
+func Test_TestLatencyAveraged_CanceledBetweenSamples(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 2 {
+			cancel()
+		}
+	}))
+	defer server.Close()
+
+	result := newDirectTestClient().TestLatencyAveraged(ctx, server.URL, 5)
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.GreaterOrEqual(t, result.Samples, 1)
+	require.LessOrEqual(t, result.Samples, 2)
+	require.LessOrEqual(t, requests.Load(), int32(2))
+}
+
+func Test_summarizeLatencySamples(t *testing.T) {
+	require.Equal(t, &AveragedLatencyResult{AverageMs: 15, MinMs: 10, MaxMs: 100, Samples: 3},
+		summarizeLatencySamples([]int64{20, 100, 10}))
+	require.Equal(t, &AveragedLatencyResult{AverageMs: 7, MinMs: 7, MaxMs: 7, Samples: 1},
+		summarizeLatencySamples([]int64{7}))
+}
+
+func Test_SpeedTests_AlreadyCanceled(t *testing.T) {
+	server := newSlowServer(t)
+	client := newDirectTestClient()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	require.Equal(t, int64(0), client.TestDownloadSpeed(ctx, server.URL, 10))
+	require.Equal(t, int64(0), client.TestUploadSpeed(ctx, server.URL, 10))
+	require.Equal(t, int64(0), client.TestLatency(ctx, server.URL))
+}
+
+func Test_SpeedTests_DialFailure(t *testing.T) {
+	client := newDirectTestClient()
+	// Port 1 on localhost is expected to refuse connections.
+	url := "http://127.0.0.1:1/"
+	require.Equal(t, int64(-1), client.TestDownloadSpeed(context.Background(), url, 1))
+	require.Equal(t, int64(-1), client.TestUploadSpeed(context.Background(), url, 1))
+	require.Equal(t, int64(-1), client.TestLatency(context.Background(), url))
+}
+
+func Test_PerformBandwidthTestWithConfig(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		if r.Method == http.MethodGet {
+			w.Write(make([]byte, 64*1024))
+		}
+	}))
+	defer server.Close()
+
+	cfg := &BandwidthTestConfig{
+		DownloadURL:     server.URL + "/down",
+		UploadURL:       server.URL + "/up",
+		LatencyURL:      server.URL + "/ping",
+		DurationSeconds: 1,
+	}
+	result := newDirectTestClient().PerformBandwidthTestWithConfig(context.Background(), cfg)
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.GreaterOrEqual(t, result.LatencyMs, int64(0))
+	require.GreaterOrEqual(t, result.DownloadSpeedKBps, int64(0))
+	require.Greater(t, result.UploadSpeedKBps, int64(0))
+	// The test is too short to tell a rate limit.
+	require.False(t, result.LikelyRateLimited)
+}
+
+func Test_PerformBandwidthTestWithConfig_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  *BandwidthTestConfig
+	}{
+		{"nil config", nil},
+		{"empty download URL", &BandwidthTestConfig{UploadURL: "https://a.example/", LatencyURL: "https://a.example/"}},
+		{"relative upload URL", &BandwidthTestConfig{DownloadURL: "https://a.example/", UploadURL: "/up", LatencyURL: "https://a.example/"}},
+		{"bad scheme", &BandwidthTestConfig{DownloadURL: "https://a.example/", UploadURL: "https://a.example/", LatencyURL: "ftp://a.example/"}},
+		{"malformed URL", &BandwidthTestConfig{DownloadURL: "https://a.example/%zz", UploadURL: "https://a.example/", LatencyURL: "https://a.example/"}},
+		{"negative duration", &BandwidthTestConfig{DownloadURL: "https://a.example/", UploadURL: "https://a.example/", LatencyURL: "https://a.example/", DurationSeconds: -1}},
+		{"negative warm-up", &BandwidthTestConfig{DownloadURL: "https://a.example/", UploadURL: "https://a.example/", LatencyURL: "https://a.example/", WarmupSeconds: -1}},
+		{"invalid header", &BandwidthTestConfig{DownloadURL: "https://a.example/", UploadURL: "https://a.example/", LatencyURL: "https://a.example/", Headers: map[string]string{"Bad Name": "x"}}},
+		{"small read buffer", &BandwidthTestConfig{DownloadURL: "https://a.example/", UploadURL: "https://a.example/", LatencyURL: "https://a.example/", ReadBufferKB: 1}},
+		{"large read buffer", &BandwidthTestConfig{DownloadURL: "https://a.example/", UploadURL: "https://a.example/", LatencyURL: "https://a.example/", ReadBufferKB: 8192}},
+		{"negative byte cap", &BandwidthTestConfig{DownloadURL: "https://a.example/", UploadURL: "https://a.example/", LatencyURL: "https://a.example/", MaxBytes: -1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := newDirectTestClient().PerformBandwidthTestWithConfig(context.Background(), tt.cfg)
+			require.NotNil(t, result.Error)
+			require.Equal(t, platerrors.InvalidConfig, result.Error.Code)
+		})
+	}
+}
+
+func Test_TestUploadSpeed_MeasuresStreamingRate(t *testing.T) {
+	const (
+		readSize     = 16 * 1024
+		readInterval = 10 * time.Millisecond
+		// The server reads readSize bytes every readInterval.
+		trueKBps = readSize * int64(time.Second/readInterval) / 1024
+	)
+	client := newPipeTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf := make([]byte, readSize)
+		for {
+			if _, err := io.ReadFull(r.Body, buf); err != nil {
+				return
+			}
+			time.Sleep(readInterval)
+		}
+	}))
+
+	got := client.TestUploadSpeed(context.Background(), "http://example.com/up", 1)
+	require.Greater(t, got, trueKBps/2)
+	require.Less(t, got, trueKBps*2)
+}
+
+func Test_TestUploadSpeed_ExcludesSetupTime(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+	}))
+	defer server.Close()
+	client := newDirectTestClient()
+	baseDial := client.sd.Dial
+	client.sd.Dial = func(ctx context.Context, address string) (transport.StreamConn, error) {
+		time.Sleep(500 * time.Millisecond) // Slow connection setup.
+		return baseDial(ctx, address)
+	}
+
+	start := time.Now()
+	got := client.TestUploadSpeed(context.Background(), server.URL, 1)
+	require.Greater(t, got, int64(0))
+	// The full test duration is spent streaming, after the connection is set up.
+	require.GreaterOrEqual(t, time.Since(start), 1500*time.Millisecond)
+}
+
+func Test_TestDownloadSpeedDetailed(t *testing.T) {
+	server := newSlowServer(t)
+
+	result := newDirectTestClient().TestDownloadSpeedDetailed(context.Background(), server.URL, 2)
+	require.Greater(t, result.SpeedKBps, int64(0))
+	require.GreaterOrEqual(t, result.DurationMs, int64(2000))
+	require.GreaterOrEqual(t, len(result.Samples), 2)
+	// The length of the response is unknown.
+	require.Equal(t, SpeedMethodTimed, result.Method)
+
+	var sampledBytes, expectedOffset int64
+	for i, sample := range result.Samples {
+		// Allow for the rounding of each interval to milliseconds.
+		require.InDelta(t, expectedOffset, sample.OffsetMs, float64(i))
+		require.Greater(t, sample.Bytes, int64(0))
+		sampledBytes += sample.Bytes
+		expectedOffset += sample.DurationMs
+	}
+	require.Equal(t, result.TotalBytes, sampledBytes)
+}
+
+func Test_TestDownloadSpeedDetailed_Failure(t *testing.T) {
+	result := newDirectTestClient().TestDownloadSpeedDetailed(context.Background(), "http://127.0.0.1:1/", 1)
+	require.Equal(t, int64(-1), result.SpeedKBps)
+	require.Empty(t, result.Samples)
+}
+
+func Test_PerformBandwidthTestWithConfig_Parallel(t *testing.T) {
+	server := newSlowServer(t)
+	cfg := &BandwidthTestConfig{
+		DownloadURL:     server.URL,
+		UploadURL:       server.URL,
+		LatencyURL:      server.URL,
+		DurationSeconds: 1,
+		Parallel:        true,
+	}
+
+	start := time.Now()
+	result := newDirectTestClient().PerformBandwidthTestWithConfig(context.Background(), cfg)
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.Greater(t, result.DownloadSpeedKBps, int64(0))
+	require.Greater(t, result.UploadSpeedKBps, int64(0))
+	// Running serially would take at least two seconds.
+	require.Less(t, time.Since(start), 1900*time.Millisecond)
+}
+
+func Test_PerformBandwidthTestWithConfig_Deadline(t *testing.T) {
+	server := newSlowServer(t)
+	for _, parallel := range []bool{false, true} {
+		cfg := &BandwidthTestConfig{
+			DownloadURL:     server.URL,
+			UploadURL:       server.URL,
+			LatencyURL:      server.URL,
+			DurationSeconds: 10,
+			WarmupSeconds:   2,
+			Parallel:        parallel,
+		}
+		const timeout = 1500 * time.Millisecond
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		start := time.Now()
+		result := newDirectTestClient().PerformBandwidthTestWithConfig(ctx, cfg)
+		elapsed := time.Since(start)
+		require.NoError(t, ctx.Err(), "the test must return before the deadline")
+		cancel()
+		require.Less(t, elapsed, timeout)
+		require.Nil(t, result.Error, "Got %v", result.Error)
+		require.Greater(t, result.DownloadSpeedKBps, int64(0))
+		require.Greater(t, result.UploadSpeedKBps, int64(0))
+	}
+}
+
+func Test_transferLimits_fitWithin(t *testing.T) {
+	limits := transferLimits{duration: 10 * time.Second, warmup: 2 * time.Second, maxBytes: 100}
+	// Limits that fit are kept.
+	require.Equal(t, limits, limits.fitWithin(15*time.Second))
+	require.Equal(t, limits, limits.fitWithin(14*time.Second))
+	// The setup keeps at most maxTransferSetupTime.
+	fitted := limits.fitWithin(8 * time.Second)
+	require.Equal(t, 5*time.Second, fitted.duration)
+	require.Equal(t, time.Second, fitted.warmup)
+	require.Equal(t, int64(100), fitted.maxBytes)
+	// And a quarter of short budgets.
+	fitted = limits.fitWithin(1200 * time.Millisecond)
+	require.Equal(t, 750*time.Millisecond, fitted.duration)
+	require.Equal(t, 150*time.Millisecond, fitted.warmup)
+	// Unlimited durations stay unlimited.
+	require.Equal(t, transferLimits{}, transferLimits{}.fitWithin(time.Second))
+}
+
+func Test_PerformBandwidthTestWithConfig_PhaseBudgetTimeout(t *testing.T) {
+	// The server never answers, so the phases run out of time.
+	blocked := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-blocked
+	}))
+	defer server.Close()
+	defer close(blocked)
+	cfg := &BandwidthTestConfig{
+		DownloadURL:     server.URL,
+		UploadURL:       server.URL,
+		LatencyURL:      server.URL,
+		DurationSeconds: 5,
+		UploadProtocol:  UploadProtocolRaw,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	result := newDirectTestClient().PerformBandwidthTestWithConfig(ctx, cfg)
+	require.NoError(t, ctx.Err())
+	require.NotNil(t, result.LatencyError)
+	require.Equal(t, platerrors.Timeout, result.LatencyError.Code)
+	require.Equal(t, int64(-1), result.LatencyMs)
+	require.NotNil(t, result.DownloadError)
+	require.Equal(t, platerrors.Timeout, result.DownloadError.Code)
+}
+
+func Test_PerformBandwidthTestWithConfig_ParallelFailureIsolated(t *testing.T) {
+	server := newSlowServer(t)
+	cfg := &BandwidthTestConfig{
+		DownloadURL:     server.URL,
+		UploadURL:       "http://127.0.0.1:1/",
+		LatencyURL:      server.URL,
+		DurationSeconds: 1,
+		Parallel:        true,
+	}
+
+	result := newDirectTestClient().PerformBandwidthTestWithConfig(context.Background(), cfg)
+	require.NotNil(t, result.Error)
+	require.Equal(t, BandwidthPhaseUpload, result.Error.Details["phase"])
+	require.NotNil(t, result.Error.Cause)
+	require.Equal(t, platerrors.ProxyServerUnreachable, result.Error.Cause.Code)
+	require.Equal(t, int64(-1), result.UploadSpeedKBps)
+	require.Greater(t, result.DownloadSpeedKBps, int64(0))
+}
+
+func Test_PerformBandwidthTestWithConfig_PhaseErrors(t *testing.T) {
+	server := newSlowServer(t)
+	cfg := &BandwidthTestConfig{
+		DownloadURL:     server.URL,
+		UploadURL:       "http://127.0.0.1:1/",
+		LatencyURL:      server.URL,
+		DurationSeconds: 1,
+	}
+
+	result := newDirectTestClient().PerformBandwidthTestWithConfig(context.Background(), cfg)
+	require.Nil(t, result.LatencyError)
+	require.GreaterOrEqual(t, result.LatencyMs, int64(0))
+	require.Nil(t, result.DownloadError)
+	require.Greater(t, result.DownloadSpeedKBps, int64(0))
+	require.NotNil(t, result.UploadError)
+	require.Equal(t, platerrors.ProxyServerUnreachable, result.UploadError.Code)
+	require.Equal(t, int64(-1), result.UploadSpeedKBps)
+	require.Equal(t, result.UploadError, result.Error.Cause)
+}
+
+func Test_PerformBandwidthTestWithConfig_DiagnosesFailure(t *testing.T) {
+	server := newSlowServer(t)
+	// Accepts connections, but drops the test requests.
+	brokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	}))
+	defer brokenServer.Close()
+
+	tests := []struct {
+		name        string
+		client      *Client
+		downloadURL string
+		wantCode    platerrors.ErrorCode
+	}{
+		{"server failed", newDirectTestClient(), brokenServer.URL, platerrors.SpeedTestServerFailed},
+		{"server unreachable", newDirectTestClient(), "http://127.0.0.1:1/", platerrors.SpeedTestServerUnreachable},
+		{"proxy failed", newUnreachableTestClient("127.0.0.1:4321"), server.URL, platerrors.ProxyServerUnreachable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &BandwidthTestConfig{
+				DownloadURL:     tt.downloadURL,
+				UploadURL:       server.URL,
+				LatencyURL:      server.URL,
+				DurationSeconds: 1,
+				BaselineURL:     server.URL,
+			}
+			result := tt.client.PerformBandwidthTestWithConfig(context.Background(), cfg)
+			require.NotNil(t, result.Error)
+			require.Equal(t, tt.wantCode, result.Error.Code)
+			require.NotNil(t, result.Error.Cause)
+		})
+	}
+}
+
+func Test_testURLAddress(t *testing.T) {
+	for testURL, want := range map[string]string{
+		"https://speed.example.com/__down": "speed.example.com:443",
+		"http://speed.example.com/__up":    "speed.example.com:80",
+		"http://127.0.0.1:8080/ping":       "127.0.0.1:8080",
+		"https://[::1]/ping":               "[::1]:443",
+	} {
+		got, err := testURLAddress(testURL)
+		require.NoError(t, err)
+		require.Equal(t, want, got)
+	}
+}
+
+func Test_runPhases_CapsConcurrency(t *testing.T) {
+	var running, maxRunning atomic.Int32
+	phase := func() {
+		n := running.Add(1)
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		running.Add(-1)
+	}
+
+	runPhases(true, phase, phase, phase, phase, phase)
+	require.Equal(t, int32(maxParallelPhases), maxRunning.Load())
+}
+
+// newFakeDNSServer returns the address of a DNS-over-TCP server that resolves every A query to
+// 127.0.0.1 and answers every other query with no records.
+func newFakeDNSServer(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveFakeDNS(conn)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func serveFakeDNS(conn net.Conn) {
+	defer conn.Close()
+	var length uint16
+	if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+		return
+	}
+	request := make([]byte, length)
+	if _, err := io.ReadFull(conn, request); err != nil {
+		return
+	}
+	var msg dnsmessage.Message
+	if err := msg.Unpack(request); err != nil || len(msg.Questions) != 1 {
+		return
+	}
+	msg.Header.Response = true
+	if q := msg.Questions[0]; q.Type == dnsmessage.TypeA {
+		msg.Answers = []dnsmessage.Resource{{
+			Header: dnsmessage.ResourceHeader{Name: q.Name, Type: q.Type, Class: q.Class, TTL: 60},
+			Body:   &dnsmessage.AResource{A: [4]byte{127, 0, 0, 1}},
+		}}
+	}
+	response, err := msg.Pack()
+	if err != nil {
+		return
+	}
+	binary.Write(conn, binary.BigEndian, uint16(len(response)))
+	conn.Write(response)
+}
+
+func Test_PerformBandwidthTestWithConfig_ResolveThroughProxy(t *testing.T) {
+	server := newSlowServer(t)
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	require.NoError(t, err)
+	dnsAddr := newFakeDNSServer(t)
+
+	client := newDirectTestClient()
+	baseDial := client.sd.Dial
+	var mu sync.Mutex
+	var dialed []string
+	client.sd.Dial = func(ctx context.Context, address string) (transport.StreamConn, error) {
+		mu.Lock()
+		dialed = append(dialed, address)
+		mu.Unlock()
+		if address == proxyResolverAddress {
+			address = dnsAddr
+		}
+		return baseDial(ctx, address)
+	}
+
+	// The system resolver cannot resolve the .invalid TLD, so the test can only succeed if the
+	// hostname is resolved through the proxy.
+	testURL := "http://speedtest.invalid:" + port
+	cfg := &BandwidthTestConfig{
+		DownloadURL:         testURL,
+		UploadURL:           testURL,
+		LatencyURL:          testURL,
+		DurationSeconds:     1,
+		ResolveThroughProxy: true,
+	}
+	result := client.PerformBandwidthTestWithConfig(context.Background(), cfg)
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.Greater(t, result.DownloadSpeedKBps, int64(0))
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Contains(t, dialed, proxyResolverAddress)
+	for _, address := range dialed {
+		require.NotContains(t, address, "speedtest.invalid")
+	}
+}
+
+func Test_PerformBandwidthTestWithConfig_Headers(t *testing.T) {
+	var mu sync.Mutex
+	userAgents := map[string]string{}
+	customHeaders := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		userAgents[r.Method] = r.UserAgent()
+		customHeaders[r.Method] = r.Header.Get("X-Test")
+		mu.Unlock()
+		io.Copy(io.Discard, r.Body)
+	}))
+	defer server.Close()
+	cfg := &BandwidthTestConfig{
+		DownloadURL:     server.URL,
+		UploadURL:       server.URL,
+		LatencyURL:      server.URL,
+		DurationSeconds: 1,
+	}
+
+	newDirectTestClient().PerformBandwidthTestWithConfig(context.Background(), cfg)
+	mu.Lock()
+	for _, method := range []string{http.MethodHead, http.MethodGet, http.MethodPost} {
+		require.Equal(t, defaultUserAgent, userAgents[method], "method %v", method)
+		require.Empty(t, customHeaders[method], "method %v", method)
+	}
+	mu.Unlock()
+
+	cfg.Headers = map[string]string{"User-Agent": "OutlineTest/1.0", "X-Test": "value"}
+	newDirectTestClient().PerformBandwidthTestWithConfig(context.Background(), cfg)
+	mu.Lock()
+	defer mu.Unlock()
+	for _, method := range []string{http.MethodHead, http.MethodGet, http.MethodPost} {
+		require.Equal(t, "OutlineTest/1.0", userAgents[method], "method %v", method)
+		require.Equal(t, "value", customHeaders[method], "method %v", method)
+	}
+}
+
+func Test_MeasureSpeed_Errors(t *testing.T) {
+	client := newDirectTestClient()
+	// Nothing listens on port 1.
+	const refusedURL = "http://127.0.0.1:1/"
+
+	latency := client.MeasureLatency(context.Background(), refusedURL)
+	require.Equal(t, int64(-1), latency.LatencyMs)
+	require.NotNil(t, latency.Error)
+	require.Equal(t, platerrors.ProxyServerUnreachable, latency.Error.Code)
+
+	download := client.MeasureDownloadSpeed(context.Background(), refusedURL, 1)
+	require.Equal(t, int64(-1), download.SpeedKBps)
+	require.NotNil(t, download.Error)
+	require.Equal(t, platerrors.ProxyServerUnreachable, download.Error.Code)
+
+	upload := client.MeasureUploadSpeed(context.Background(), refusedURL, 1)
+	require.Equal(t, int64(-1), upload.SpeedKBps)
+	require.NotNil(t, upload.Error)
+	require.Equal(t, platerrors.ProxyServerUnreachable, upload.Error.Code)
+
+	download = client.MeasureDownloadSpeed(context.Background(), "http://%zz/", 1)
+	require.NotNil(t, download.Error)
+	require.Equal(t, platerrors.InvalidConfig, download.Error.Code)
+}
+
+func Test_MeasureDownloadSpeed_ReadFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Promise a body, then drop the connection without sending it.
+		w.Header().Set("Content-Length", "1024")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	}))
+	defer server.Close()
+
+	result := newDirectTestClient().MeasureDownloadSpeed(context.Background(), server.URL, 1)
+	require.Equal(t, int64(-1), result.SpeedKBps)
+	require.NotNil(t, result.Error)
+	require.Equal(t, platerrors.ProxyServerReadFailed, result.Error.Code)
+}
+
+func Test_speedTestError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want platerrors.ErrorCode
+	}{
+		{"deadline", context.DeadlineExceeded, platerrors.Timeout},
+		{"net timeout", &net.OpError{Op: "read", Err: os.ErrDeadlineExceeded}, platerrors.Timeout},
+		{"DNS", &net.DNSError{Err: "no such host", Name: "example.invalid", IsNotFound: true}, platerrors.ResolveIPFailed},
+		{"other", io.ErrUnexpectedEOF, platerrors.ProxyServerReadFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			perr := speedTestError(tt.err, platerrors.ProxyServerReadFailed, "failed")
+			require.Equal(t, tt.want, perr.Code)
+			require.Equal(t, "failed", perr.Message)
+			require.NotNil(t, perr.Cause)
+		})
+	}
+}
+
+func Test_TestDownloadSpeedBytes(t *testing.T) {
+	server := newSlowServer(t)
+	const byteCount = 50*1024 + 100
+
+	result := newDirectTestClient().TestDownloadSpeedBytes(context.Background(), server.URL, byteCount)
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.Equal(t, int64(byteCount), result.TotalBytes)
+	require.Greater(t, result.SpeedKBps, int64(0))
+	// The server sends 1KB every 10ms.
+	require.GreaterOrEqual(t, result.DurationMs, int64(400))
+}
+
+func Test_speedTester_ReadBufferSize(t *testing.T) {
+	var maxRead atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(make([]byte, 1024*1024))
+	}))
+	defer server.Close()
+	httpTransport := newProxyHTTPTransport(newDirectTestClient())
+	// Record the size of the reads the download makes.
+	httpTransport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := (&net.Dialer{}).DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return &readSizeRecordingConn{Conn: conn, maxRead: &maxRead}, nil
+	}
+	defer httpTransport.CloseIdleConnections()
+
+	tester := &speedTester{httpTransport: httpTransport, readBufferKB: 4}
+	result := tester.download(context.Background(), server.URL, transferLimits{maxBytes: 1024 * 1024}, nil)
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.Equal(t, int64(1024*1024), result.TotalBytes)
+	// The HTTP client reads through its own 4 KB buffer, or directly into larger ones.
+	require.LessOrEqual(t, maxRead.Load(), int64(4*1024))
+}
+
+// readSizeRecordingConn records the size of its largest read in maxRead.
+type readSizeRecordingConn struct {
+	net.Conn
+	maxRead *atomic.Int64
+}
+
+func (c *readSizeRecordingConn) Read(b []byte) (int, error) {
+	for {
+		current := c.maxRead.Load()
+		if int64(len(b)) <= current || c.maxRead.CompareAndSwap(current, int64(len(b))) {
+			break
+		}
+	}
+	return c.Conn.Read(b)
+}
+
+func Test_TestDownloadSpeedDetailed_CountsCompressedBytes(t *testing.T) {
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	writer.Write(make([]byte, 1024*1024))
+	writer.Close()
+	var gotAcceptEncoding string
+	// Serves the gzipped body regardless of the Accept-Encoding of the request.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAcceptEncoding = r.Header.Get("Accept-Encoding")
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Set("Content-Length", strconv.Itoa(compressed.Len()))
+		w.Write(compressed.Bytes())
+	}))
+	defer server.Close()
+
+	result := newDirectTestClient().TestDownloadSpeedDetailed(context.Background(), server.URL, 1)
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.Equal(t, "identity", gotAcceptEncoding)
+	// The measured bytes are the advertised size, not the decompressed size.
+	require.Equal(t, int64(compressed.Len()), result.TotalBytes)
+	require.Equal(t, SpeedMethodTimed, result.Method)
+}
+
+func Test_MeasureDownloadSpeed_ContentLength(t *testing.T) {
+	const size = 512 * 1024
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The delay before the response is part of the request setup.
+		time.Sleep(300 * time.Millisecond)
+		w.Header().Set("Content-Length", strconv.Itoa(size))
+		w.Write(make([]byte, size))
+	}))
+	defer server.Close()
+
+	detailed := newDirectTestClient().TestDownloadSpeedDetailed(context.Background(), server.URL, 5)
+	require.Nil(t, detailed.Error, "Got %v", detailed.Error)
+	require.Equal(t, SpeedMethodContentLength, detailed.Method)
+	require.Equal(t, int64(size), detailed.TotalBytes)
+	require.GreaterOrEqual(t, detailed.DurationMs, int64(300))
+	// The speed leaves out the time before the response.
+	require.Greater(t, detailed.SpeedKBps, speedKBps(size, time.Duration(detailed.DurationMs)*time.Millisecond))
+
+	result := newDirectTestClient().MeasureDownloadSpeed(context.Background(), server.URL, 5)
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.Equal(t, SpeedMethodContentLength, result.Method)
+}
+
+func Test_MeasureDownloadSpeed_ContentLengthNotReached(t *testing.T) {
+	// The response announces more data than the server sends within the test.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(100*1024*1024))
+		serveSlowly(w, r)
+	}))
+	defer server.Close()
+
+	result := newDirectTestClient().MeasureDownloadSpeed(context.Background(), server.URL, 1)
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.Positive(t, result.SpeedKBps)
+	require.Equal(t, SpeedMethodTimed, result.Method)
+}
+
+func Test_TestDownloadSpeedBytes_ContextExpires(t *testing.T) {
+	server := newSlowServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	result := newDirectTestClient().TestDownloadSpeedBytes(ctx, server.URL, 1<<30)
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.Greater(t, result.TotalBytes, int64(0))
+	require.Less(t, result.TotalBytes, int64(1<<30))
+	require.Less(t, result.DurationMs, int64(1000))
+}
+
+func Test_TestDownloadSpeedBytes_InvalidCount(t *testing.T) {
+	result := newDirectTestClient().TestDownloadSpeedBytes(context.Background(), "http://example.com/", 0)
+	require.Equal(t, int64(-1), result.SpeedKBps)
+	require.NotNil(t, result.Error)
+	require.Equal(t, platerrors.InvalidConfig, result.Error.Code)
+}
+
+func Test_TestDownloadSpeedMultiStream(t *testing.T) {
+	server := newSlowServer(t)
+	client := newDirectTestClient()
+
+	result := client.TestDownloadSpeedMultiStream(context.Background(), server.URL, 3, 1)
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.Zero(t, result.FailedStreams)
+	require.GreaterOrEqual(t, result.DurationMs, int64(1000))
+	// The server sends about 100KB/s per connection.
+	require.Greater(t, result.TotalBytes, int64(150*1024))
+	require.Greater(t, result.SpeedKBps, int64(150))
+	require.Equal(t, int64(3), client.Stats().ConnectionsOpened)
+}
+
+func Test_TestDownloadSpeedMultiStream_Failure(t *testing.T) {
+	result := newDirectTestClient().TestDownloadSpeedMultiStream(context.Background(), "http://127.0.0.1:1/", 2, 1)
+	require.Equal(t, int64(-1), result.SpeedKBps)
+	require.Equal(t, 2, result.FailedStreams)
+	require.NotNil(t, result.Error)
+}
+
+func Test_TestDownloadSpeedMultiStream_InvalidArgs(t *testing.T) {
+	client := newDirectTestClient()
+	for _, streams := range []int{0, maxDownloadStreams + 1} {
+		result := client.TestDownloadSpeedMultiStream(context.Background(), "http://example.com/", streams, 1)
+		require.Equal(t, int64(-1), result.SpeedKBps)
+		require.Equal(t, platerrors.InvalidConfig, result.Error.Code)
+	}
+	result := client.TestDownloadSpeedMultiStream(context.Background(), "http://example.com/", 2, 0)
+	require.Equal(t, platerrors.InvalidConfig, result.Error.Code)
+}
+
+func Test_speedTester_DownloadWarmup(t *testing.T) {
+	// The server trickles data for the first second, and then speeds up.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		for r.Context().Err() == nil {
+			chunk := 1024
+			if time.Since(start) >= time.Second {
+				chunk = 64 * 1024
+			}
+			if _, err := w.Write(make([]byte, chunk)); err != nil {
+				return
+			}
+			w.(http.Flusher).Flush()
+			time.Sleep(10 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+	tester := newDirectTestClient().newSpeedTester()
+
+	cold := tester.download(context.Background(), server.URL, transferLimits{duration: time.Second}, nil)
+	require.Nil(t, cold.Error, "Got %v", cold.Error)
+	warm := tester.download(context.Background(), server.URL, transferLimits{duration: time.Second, warmup: time.Second}, nil)
+	require.Nil(t, warm.Error, "Got %v", warm.Error)
+
+	// The cold measurement only sees the trickle, about 100KB/s.
+	require.Less(t, cold.SpeedKBps, int64(200))
+	// The warm measurement excludes it, and sees about 6MB/s.
+	require.Greater(t, warm.SpeedKBps, int64(1000))
+	require.Less(t, warm.DurationMs, int64(1500))
+}
+
+func Test_speedTester_DownloadWarmupReceivesAll(t *testing.T) {
+	server := newPayloadServer(t, make([]byte, 64*1024))
+	tester := newDirectTestClient().newSpeedTester()
+
+	result := tester.download(context.Background(), server.URL+"/payload", transferLimits{duration: time.Second, warmup: time.Second}, nil)
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.Equal(t, int64(64*1024), result.TotalBytes)
+	require.Greater(t, result.SpeedKBps, int64(0))
+	require.Equal(t, SpeedMethodTimed, result.Method)
+}
+
+func Test_speedTester_DownloadWarmupEmptyPayload(t *testing.T) {
+	server := newPayloadServer(t, nil)
+	tester := newDirectTestClient().newSpeedTester()
+
+	result := tester.download(context.Background(), server.URL+"/payload", transferLimits{duration: time.Second, warmup: time.Second}, nil)
+	require.Equal(t, int64(-1), result.SpeedKBps)
+	require.NotNil(t, result.Error)
+	require.Equal(t, platerrors.ProxyServerReadFailed, result.Error.Code)
+	require.Equal(t, "payload too small for warm-up", result.Error.Message)
+}
+
+func Test_speedTester_UploadWarmup(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+	}))
+	defer server.Close()
+	tester := newDirectTestClient().newSpeedTester()
+
+	start := time.Now()
+	result := tester.upload(context.Background(), server.URL, transferLimits{duration: 500 * time.Millisecond, warmup: 500 * time.Millisecond}, nil)
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.Greater(t, result.SpeedKBps, int64(0))
+	require.GreaterOrEqual(t, time.Since(start), time.Second)
+}
+
+// newTruncatingServer returns a server that announces 1 MB, but drops the connection after
+// sending 64 KB. It completes ranged requests if complete is true. The returned function returns
+// the ranges requested so far.
+func newTruncatingServer(t *testing.T, complete bool) (*httptest.Server, func() []string) {
+	var mu sync.Mutex
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		chunk := make([]byte, 64*1024)
+		if rangeHeader := r.Header.Get("Range"); rangeHeader != "" {
+			mu.Lock()
+			ranges = append(ranges, rangeHeader)
+			mu.Unlock()
+			if complete {
+				w.Header().Set("Content-Length", strconv.Itoa(len(chunk)))
+				w.WriteHeader(http.StatusPartialContent)
+				w.Write(chunk)
+				return
+			}
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(1024*1024))
+		w.Write(chunk)
+		w.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(ranges)
+	}
+}
+
+func Test_speedTester_DownloadResumesAfterReadError(t *testing.T) {
+	server, ranges := newTruncatingServer(t, true)
+
+	result := newDirectTestClient().TestDownloadSpeedDetailed(context.Background(), server.URL, 5)
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.Equal(t, int64(128*1024), result.TotalBytes)
+	require.Equal(t, []string{"bytes=65536-"}, ranges())
+}
+
+func Test_speedTester_DownloadRetriesAreCapped(t *testing.T) {
+	server, ranges := newTruncatingServer(t, false)
+
+	result := newDirectTestClient().TestDownloadSpeedDetailed(context.Background(), server.URL, 5)
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.Equal(t, int64((maxDownloadRetries+1)*64*1024), result.TotalBytes)
+	require.Equal(t, []string{"bytes=65536-", "bytes=131072-", "bytes=196608-"}, ranges())
+}
+
+func Test_isRetryableReadError(t *testing.T) {
+	require.True(t, isRetryableReadError(io.ErrUnexpectedEOF))
+	require.True(t, isRetryableReadError(&net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}))
+	require.False(t, isRetryableReadError(context.DeadlineExceeded))
+	require.False(t, isRetryableReadError(errors.New("malformed chunked encoding")))
+}
+
+func Test_PerformBandwidthTestWithConfig_MaxBytes(t *testing.T) {
+	const maxBytes = 256 * 1024
+	var uploaded atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := io.Copy(io.Discard, r.Body)
+		uploaded.Add(n)
+		if r.Method != http.MethodGet {
+			return
+		}
+		chunk := make([]byte, 32*1024)
+		for r.Context().Err() == nil {
+			if _, err := w.Write(chunk); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+	cfg := &BandwidthTestConfig{
+		DownloadURL:     server.URL,
+		UploadURL:       server.URL,
+		LatencyURL:      server.URL,
+		DurationSeconds: 10,
+		MaxBytes:        maxBytes,
+	}
+
+	start := time.Now()
+	result := newDirectTestClient().PerformBandwidthTestWithConfig(context.Background(), cfg)
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.True(t, result.MaxBytesReached)
+	require.LessOrEqual(t, uploaded.Load(), int64(maxBytes))
+	// Both phases stop long before their 10 seconds.
+	require.Less(t, time.Since(start), 5*time.Second)
+}
+
+func Test_speedTester_MaxBytesIncludesWarmup(t *testing.T) {
+	const maxBytes = 64 * 1024
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		chunk := make([]byte, 1024)
+		for r.Context().Err() == nil && r.Method == http.MethodGet {
+			if _, err := w.Write(chunk); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+	tester := newDirectTestClient().newSpeedTester()
+	progress := newProgressSink(&recordingProgressListener{}).startPhase(BandwidthPhaseDownload)
+
+	limits := transferLimits{duration: 10 * time.Second, warmup: 10 * time.Second, maxBytes: maxBytes}
+	download := tester.download(context.Background(), server.URL, limits, progress)
+	require.Nil(t, download.Error, "Got %v", download.Error)
+	require.True(t, download.Capped)
+	// The warm-up received half of the bytes, and the measurement the rest.
+	require.Equal(t, int64(maxBytes/2), download.TotalBytes)
+	require.Greater(t, download.SpeedKBps, int64(0))
+	require.Equal(t, int64(maxBytes), progress.total)
+
+	for _, protocol := range []string{UploadProtocolChunked, UploadProtocolRaw} {
+		tester.uploadProtocol = uploadProtocols[protocol]
+		upload := tester.upload(context.Background(), server.URL, limits, nil)
+		require.Nil(t, upload.Error, "Got %v", upload.Error)
+		require.True(t, upload.Capped, protocol)
+		require.Greater(t, upload.SpeedKBps, int64(0), protocol)
+	}
+}
+
+func Test_speedTester_downloadStreams_HTTP2(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(serveSlowly))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+	client := newDirectTestClient()
+	tester := client.newSpeedTester()
+	tester.httpTransport = newMultiplexedHTTPTransport(client)
+	tester.httpTransport.TLSClientConfig = server.Client().Transport.(*http.Transport).TLSClientConfig
+	defer tester.httpTransport.CloseIdleConnections()
+
+	result := tester.downloadStreams(context.Background(), server.URL, 3, transferLimits{duration: time.Second}, true)
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.Equal(t, "HTTP/2.0", result.Protocol)
+	require.Zero(t, result.FailedStreams)
+	require.Greater(t, result.SpeedKBps, int64(0))
+	// All the streams share one connection.
+	require.Equal(t, int64(1), client.Stats().ConnectionsOpened)
+}
+
+func Test_TestDownloadSpeedMultiplexed_FallsBackToHTTP1(t *testing.T) {
+	server := newSlowServer(t)
+	client := newDirectTestClient()
+
+	result := client.TestDownloadSpeedMultiplexed(context.Background(), server.URL, 3, 1)
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.Equal(t, "HTTP/1.1", result.Protocol)
+	require.Zero(t, result.FailedStreams)
+	require.Equal(t, int64(3), client.Stats().ConnectionsOpened)
+}
@@ -0,0 +1,165 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/connectivity"
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+)
+
+// Limits of [BenchmarkConfigs].
+const (
+	// benchmarkDurationSeconds is the duration of each of the download and upload tests of a config.
+	benchmarkDurationSeconds = 3
+	// benchmarkDataBudget caps the data transferred by the bandwidth tests of all the configs.
+	benchmarkDataBudget = 64 << 20
+	// benchmarkMaxBytesPerTransfer caps the data of each download or upload test of a config.
+	benchmarkMaxBytesPerTransfer = 8 << 20
+	// benchmarkBandwidthTimeout is the time budget of the bandwidth test of a config.
+	benchmarkBandwidthTimeout = 15 * time.Second
+)
+
+// ConfigBenchmark is the result of the benchmark of a config by [BenchmarkConfigs].
+//
+// We use a struct instead of a tuple to preserve a strongly typed error that gobind recognizes.
+type ConfigBenchmark struct {
+	// Index is the index of the config in the configs passed to [BenchmarkConfigs].
+	Index int
+	// Score is the [ComprehensiveTestResult.QualityScore] of the config, between 0 and 100.
+	Score int
+	// LatencyMs, DownloadSpeedKBps and UploadSpeedKBps are the results of the bandwidth test, or
+	// -1 if the test failed or didn't run.
+	LatencyMs, DownloadSpeedKBps, UploadSpeedKBps int64
+	// TCPError and UDPError are the failures of the connectivity checks, if any.
+	TCPError, UDPError *platerrors.PlatformError
+	// BandwidthError is the failure of the bandwidth test, if any. The test only runs if TCP
+	// works through the proxy.
+	BandwidthError *platerrors.PlatformError
+	// Error is why the config couldn't be benchmarked, such as a config that fails to parse.
+	Error *platerrors.PlatformError
+}
+
+// BenchmarkConfigs measures each of the given configs, in the format of [NewClient], with the
+// connectivity checks of [CheckTCPAndUDPConnectivity] and a short bandwidth test, and returns
+// the results ranked by [ComprehensiveTestResult.QualityScore], best first. Configs with the same
+// score keep their order.
+//
+// The connectivity checks run in parallel, within the limit of [SetMaxConcurrentProbes], and the
+// bandwidth tests run one at a time so that they don't compete for the link. The bandwidth tests
+// of all the configs transfer 64 MiB at most, split evenly between them.
+func BenchmarkConfigs(ctx context.Context, configs []string) []ConfigBenchmark {
+	candidates := make([]*Client, len(configs))
+	parseErrs := make([]error, len(configs))
+	for i, clientConfig := range configs {
+		result := NewClient(clientConfig)
+		if result.Error != nil {
+			parseErrs[i] = result.Error
+			continue
+		}
+		candidates[i] = result.Client
+	}
+	bandwidth := NewBandwidthTestConfig()
+	bandwidth.DurationSeconds = benchmarkDurationSeconds
+	return benchmarkConfigs(ctx, candidates, parseErrs, connectivity.ProbeTargets{}, *bandwidth, benchmarkDataBudget)
+}
+
+// benchmarkConfigs implements [BenchmarkConfigs]. A nil candidate is a config that failed to
+// parse with the error at the same index of parseErrs. The byte cap of bandwidth is replaced by
+// the share of budget of each transfer.
+func benchmarkConfigs(ctx context.Context, candidates []*Client, parseErrs []error, targets connectivity.ProbeTargets, bandwidth BandwidthTestConfig, budget int64) []ConfigBenchmark {
+	valid := 0
+	for _, candidate := range candidates {
+		if candidate != nil {
+			valid++
+		}
+	}
+	if valid > 0 {
+		// Each config runs a download and an upload. A cap of zero would mean no cap.
+		bandwidth.MaxBytes = max(min(budget/int64(2*valid), benchmarkMaxBytesPerTransfer), 1)
+	}
+
+	results := make([]ConfigBenchmark, len(candidates))
+	var bandwidthMu sync.Mutex
+	var wg sync.WaitGroup
+	for i, candidate := range candidates {
+		if candidate == nil {
+			results[i] = ConfigBenchmark{
+				Index:             i,
+				LatencyMs:         -1,
+				DownloadSpeedKBps: -1,
+				UploadSpeedKBps:   -1,
+				Error:             platerrors.ToPlatformError(parseErrs[i]),
+			}
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = benchmarkClient(ctx, candidate, targets, bandwidth, &bandwidthMu)
+			results[i].Index = i
+		}()
+	}
+	wg.Wait()
+	sort.SliceStable(results, func(a, b int) bool { return results[a].Score > results[b].Score })
+	return results
+}
+
+// benchmarkClient measures client like [PerformComprehensiveTestContext], but with the given
+// bandwidth test, which only runs while holding bandwidthMu.
+func benchmarkClient(ctx context.Context, client *Client, targets connectivity.ProbeTargets, bandwidth BandwidthTestConfig, bandwidthMu *sync.Mutex) ConfigBenchmark {
+	result := &ComprehensiveTestResult{DownloadSpeedKBps: -1, UploadSpeedKBps: -1, LatencyMs: -1}
+	connectivityResult := checkTCPAndUDPConnectivity(ctx, client, defaultConnectivityTimeout, targets)
+	result.TCPError = connectivityResult.TCPError
+	result.UDPError = connectivityResult.UDPError
+	if result.TCPError == nil {
+		runBenchmarkBandwidthTest(ctx, client, &bandwidth, bandwidthMu, result)
+	}
+	return ConfigBenchmark{
+		Score:             result.QualityScore(),
+		LatencyMs:         result.LatencyMs,
+		DownloadSpeedKBps: result.DownloadSpeedKBps,
+		UploadSpeedKBps:   result.UploadSpeedKBps,
+		TCPError:          result.TCPError,
+		UDPError:          result.UDPError,
+		BandwidthError:    result.BandwidthError,
+	}
+}
+
+// runBenchmarkBandwidthTest runs the bandwidth test of [benchmarkClient] and stores its results in
+// result. Partial results of a test stopped by ctx are discarded.
+func runBenchmarkBandwidthTest(ctx context.Context, client *Client, bandwidth *BandwidthTestConfig, bandwidthMu *sync.Mutex, result *ComprehensiveTestResult) {
+	bandwidthMu.Lock()
+	defer bandwidthMu.Unlock()
+	if ctx.Err() != nil {
+		result.BandwidthError = comprehensiveTestStoppedError(ctx.Err())
+		return
+	}
+	bandwidthCtx, cancel := context.WithTimeout(ctx, benchmarkBandwidthTimeout)
+	defer cancel()
+	bandwidthResult := client.PerformBandwidthTestWithConfig(bandwidthCtx, bandwidth)
+	if ctx.Err() != nil {
+		result.BandwidthError = comprehensiveTestStoppedError(ctx.Err())
+		return
+	}
+	result.DownloadSpeedKBps = bandwidthResult.DownloadSpeedKBps
+	result.UploadSpeedKBps = bandwidthResult.UploadSpeedKBps
+	result.LatencyMs = bandwidthResult.LatencyMs
+	result.BandwidthError = bandwidthResult.Error
+}
@@ -0,0 +1,132 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/stretchr/testify/require"
+)
+
+// newBenchmarkTestServer returns the bandwidth test config of a local speed test server, and
+// reports the bytes it received and the largest number of requests it served at the same time.
+func newBenchmarkTestServer(t *testing.T) (cfg BandwidthTestConfig, uploaded func() int64, maxInFlight func() int32) {
+	var received atomic.Int64
+	var inFlight, peak atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			current := peak.Load()
+			if n <= current || peak.CompareAndSwap(current, n) {
+				break
+			}
+		}
+		copied, _ := io.Copy(io.Discard, r.Body)
+		received.Add(copied)
+		if r.Method == http.MethodGet {
+			w.Write(make([]byte, 64*1024))
+		}
+	}))
+	t.Cleanup(server.Close)
+	cfg = BandwidthTestConfig{
+		DownloadURL:     server.URL + "/down",
+		UploadURL:       server.URL + "/up",
+		LatencyURL:      server.URL + "/ping",
+		DurationSeconds: 1,
+	}
+	return cfg, received.Load, peak.Load
+}
+
+func Test_benchmarkConfigs_Ranking(t *testing.T) {
+	targets := newConnectivityTestTargets(t)
+	bandwidth, _, _ := newBenchmarkTestServer(t)
+	parseErr := &platerrors.PlatformError{Code: platerrors.InvalidConfig, Message: "bad config"}
+	candidates := []*Client{newUnreachableTestClient("127.0.0.1:1"), nil, newDirectTestClient()}
+	parseErrs := []error{nil, parseErr, nil}
+
+	results := benchmarkConfigs(context.Background(), candidates, parseErrs, targets, bandwidth, benchmarkDataBudget)
+	require.Len(t, results, 3)
+
+	best := results[0]
+	require.Equal(t, 2, best.Index)
+	require.Nil(t, best.Error)
+	require.Nil(t, best.TCPError, "Got %v", best.TCPError)
+	require.Nil(t, best.UDPError, "Got %v", best.UDPError)
+	require.Nil(t, best.BandwidthError, "Got %v", best.BandwidthError)
+	require.Greater(t, best.Score, 0)
+	require.GreaterOrEqual(t, best.LatencyMs, int64(0))
+	require.Greater(t, best.UploadSpeedKBps, int64(0))
+
+	// Configs that score the same keep their order.
+	unreachable := results[1]
+	require.Equal(t, 0, unreachable.Index)
+	require.Equal(t, 0, unreachable.Score)
+	require.NotNil(t, unreachable.TCPError)
+	require.Nil(t, unreachable.BandwidthError)
+	require.Equal(t, int64(-1), unreachable.DownloadSpeedKBps)
+
+	invalid := results[2]
+	require.Equal(t, 1, invalid.Index)
+	require.Equal(t, 0, invalid.Score)
+	require.Equal(t, platerrors.InvalidConfig, invalid.Error.Code)
+	require.Equal(t, int64(-1), invalid.LatencyMs)
+}
+
+func Test_benchmarkConfigs_BoundsBandwidthTests(t *testing.T) {
+	targets := newConnectivityTestTargets(t)
+	bandwidth, uploaded, maxInFlight := newBenchmarkTestServer(t)
+	const budget = 512 * 1024
+	candidates := []*Client{newDirectTestClient(), newDirectTestClient(), newDirectTestClient(), newDirectTestClient()}
+
+	results := benchmarkConfigs(context.Background(), candidates, make([]error, len(candidates)), targets, bandwidth, budget)
+	require.Len(t, results, len(candidates))
+	for _, result := range results {
+		require.Nil(t, result.BandwidthError, "Got %v", result.BandwidthError)
+	}
+	// The uploads get half of the budget.
+	require.Greater(t, uploaded(), int64(0))
+	require.LessOrEqual(t, uploaded(), int64(budget/2))
+	require.Equal(t, int32(1), maxInFlight())
+}
+
+func Test_benchmarkConfigs_Canceled(t *testing.T) {
+	targets := newConnectivityTestTargets(t)
+	bandwidth, _, _ := newBenchmarkTestServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results := benchmarkConfigs(ctx, []*Client{newDirectTestClient()}, []error{nil}, targets, bandwidth, benchmarkDataBudget)
+	require.Len(t, results, 1)
+	require.Equal(t, 0, results[0].Score)
+	require.NotNil(t, results[0].TCPError)
+	require.Equal(t, int64(-1), results[0].LatencyMs)
+}
+
+func Test_BenchmarkConfigs_InvalidConfigs(t *testing.T) {
+	results := BenchmarkConfigs(context.Background(), []string{"invalid config", ""})
+	require.Len(t, results, 2)
+	for i, result := range results {
+		require.Equal(t, i, result.Index)
+		require.Equal(t, 0, result.Score)
+		require.NotNil(t, result.Error)
+	}
+}
@@ -0,0 +1,206 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+)
+
+// defaultBufferbloatLoadURL is downloaded by [Client.TestBufferbloat] to load the link. It's
+// larger than fast links download during the test, so that the load doesn't stop early.
+const defaultBufferbloatLoadURL = "https://speed.cloudflare.com/__down?bytes=100000000"
+
+const (
+	// bufferbloatIdleProbes is the number of latency probes with no load.
+	bufferbloatIdleProbes = 5
+	// bufferbloatLoadStreams is the number of downloads that load the link, enough to fill it
+	// even when a single connection can't.
+	bufferbloatLoadStreams = 4
+)
+
+// BufferbloatResult is the result of [Client.TestBufferbloat].
+//
+// We use a struct instead of a tuple to preserve a strongly typed error that gobind recognizes.
+type BufferbloatResult struct {
+	// IdleLatencyMs is the median round-trip time with no load.
+	IdleLatencyMs int64
+	// LoadedLatencyMs is the median round-trip time while the downloads load the link.
+	LoadedLatencyMs int64
+	// LatencyIncreaseMs is how much the load increases the round-trip time, or zero if it
+	// doesn't.
+	LatencyIncreaseMs int64
+	// DownloadKBps is the speed of the downloads that load the link, in KB/s.
+	DownloadKBps int64
+	// Grade rates the latency increase, from "A", for less than 30 ms, through "B" (60 ms),
+	// "C" (200 ms) and "D" (400 ms), down to "F". It's empty if the test failed.
+	Grade string
+	Error *platerrors.PlatformError
+}
+
+// bufferbloatTest is the setup of a bufferbloat test.
+type bufferbloatTest struct {
+	// latencyURL receives the HEAD requests of the latency probes.
+	latencyURL string
+	// loadURL is downloaded to load the link.
+	loadURL string
+	// loadDuration is how long the downloads load the link.
+	loadDuration time.Duration
+	// loadWarmup is the time the downloads take to fill the link, before the probes under load
+	// start.
+	loadWarmup time.Duration
+	// probeInterval is the time between the starts of consecutive probes under load.
+	probeInterval time.Duration
+}
+
+// defaultBufferbloatTest is the setup of [Client.TestBufferbloat].
+var defaultBufferbloatTest = bufferbloatTest{
+	latencyURL:    defaultLatencyURL,
+	loadURL:       defaultBufferbloatLoadURL,
+	loadDuration:  10 * time.Second,
+	loadWarmup:    2 * time.Second,
+	probeInterval: 250 * time.Millisecond,
+}
+
+// TestBufferbloat measures bufferbloat through the proxy: how much the round-trip time grows
+// while the link is busy, which users feel as lag during downloads. It measures the latency with
+// no load, and then again while several downloads through the proxy saturate the link, and
+// reports the increase with a grade. The test takes about 12 seconds and downloads as much data
+// as the link carries in 10 seconds.
+//
+// The probes use a connection of their own, which is set up before the measurements start, so
+// that they time the round trips only. Probes that fail under load are left out. If ctx is
+// canceled, the test fails with a [platerrors.OperationCanceled] error.
+func (c *Client) TestBufferbloat(ctx context.Context) *BufferbloatResult {
+	return c.testBufferbloat(ctx, defaultBufferbloatTest)
+}
+
+// testBufferbloat implements [Client.TestBufferbloat] with the given setup.
+func (c *Client) testBufferbloat(ctx context.Context, test bufferbloatTest) *BufferbloatResult {
+	// The probes don't share connections with the downloads, which would delay them.
+	probeTransport := newProxyHTTPTransport(c)
+	defer probeTransport.CloseIdleConnections()
+	prober := &speedTester{httpTransport: probeTransport}
+
+	// The first probe sets up the connection, so it's not a sample.
+	if warmup := prober.latency(ctx, test.latencyURL); warmup.Error != nil {
+		return &BufferbloatResult{Error: warmup.Error}
+	}
+	var idle []int64
+	for i := 0; i < bufferbloatIdleProbes && ctx.Err() == nil; i++ {
+		latency := prober.latency(ctx, test.latencyURL)
+		if latency.Error != nil {
+			return &BufferbloatResult{Error: latency.Error}
+		}
+		if ctx.Err() == nil {
+			idle = append(idle, latency.LatencyMs)
+		}
+	}
+	if ctx.Err() != nil {
+		return &BufferbloatResult{Error: bufferbloatCanceledError()}
+	}
+
+	loadCtx, stopLoad := context.WithCancel(ctx)
+	defer stopLoad()
+	var load *MultiStreamSpeedResult
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer stopLoad()
+		load = c.newSpeedTester().downloadStreams(loadCtx, test.loadURL, bufferbloatLoadStreams,
+			transferLimits{duration: test.loadDuration}, false)
+	}()
+
+	var loaded []int64
+	var probeErr *platerrors.PlatformError
+	select {
+	case <-time.After(test.loadWarmup):
+	case <-loadCtx.Done():
+	}
+	for loadCtx.Err() == nil {
+		next := time.Now().Add(test.probeInterval)
+		latency := prober.latency(loadCtx, test.latencyURL)
+		switch {
+		case loadCtx.Err() != nil:
+			// The load ended during the probe, so it's not a sample.
+		case latency.Error != nil:
+			probeErr = latency.Error
+		default:
+			loaded = append(loaded, latency.LatencyMs)
+		}
+		select {
+		case <-time.After(time.Until(next)):
+		case <-loadCtx.Done():
+		}
+	}
+	wg.Wait()
+
+	switch {
+	case ctx.Err() != nil:
+		return &BufferbloatResult{Error: bufferbloatCanceledError()}
+	case load.SpeedKBps < 0:
+		return &BufferbloatResult{Error: load.Error}
+	case len(loaded) == 0 && probeErr != nil:
+		return &BufferbloatResult{Error: probeErr}
+	case len(loaded) == 0:
+		return &BufferbloatResult{Error: &platerrors.PlatformError{
+			Code:    platerrors.SpeedTestServerFailed,
+			Message: "the load ended before the latency could be measured",
+		}}
+	}
+	result := &BufferbloatResult{
+		IdleLatencyMs:   medianMs(idle),
+		LoadedLatencyMs: medianMs(loaded),
+		DownloadKBps:    load.SpeedKBps,
+	}
+	result.LatencyIncreaseMs = max(result.LoadedLatencyMs-result.IdleLatencyMs, 0)
+	result.Grade = bufferbloatGrade(result.LatencyIncreaseMs)
+	return result
+}
+
+// bufferbloatCanceledError is the error of a [Client.TestBufferbloat] canceled through its context.
+func bufferbloatCanceledError() *platerrors.PlatformError {
+	return &platerrors.PlatformError{Code: platerrors.OperationCanceled, Message: "bufferbloat test was canceled"}
+}
+
+// medianMs returns the median of the non-empty samples, the lower one of the two in the middle
+// if there is an even number of them.
+func medianMs(samples []int64) int64 {
+	sorted := slices.Clone(samples)
+	slices.Sort(sorted)
+	return sorted[(len(sorted)-1)/2]
+}
+
+// bufferbloatGrade returns the grade of [BufferbloatResult] for a latency increase of
+// increaseMs.
+func bufferbloatGrade(increaseMs int64) string {
+	switch {
+	case increaseMs < 30:
+		return "A"
+	case increaseMs < 60:
+		return "B"
+	case increaseMs < 200:
+		return "C"
+	case increaseMs < 400:
+		return "D"
+	default:
+		return "F"
+	}
+}
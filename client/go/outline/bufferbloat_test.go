@@ -0,0 +1,105 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/stretchr/testify/require"
+)
+
+// newBloatedServer returns a server whose HEAD responses take loadDelay longer while a download
+// is in flight, like a link with a bloated buffer.
+func newBloatedServer(t *testing.T, loadDelay time.Duration) *httptest.Server {
+	var downloads atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			if downloads.Load() > 0 {
+				time.Sleep(loadDelay)
+			}
+			return
+		}
+		downloads.Add(1)
+		defer downloads.Add(-1)
+		serveSlowly(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newTestBufferbloatTest returns a short bufferbloat test against server.
+func newTestBufferbloatTest(server *httptest.Server) bufferbloatTest {
+	return bufferbloatTest{
+		latencyURL:    server.URL + "/ping",
+		loadURL:       server.URL + "/down",
+		loadDuration:  2 * time.Second,
+		loadWarmup:    200 * time.Millisecond,
+		probeInterval: 100 * time.Millisecond,
+	}
+}
+
+func Test_TestBufferbloat_NoBloat(t *testing.T) {
+	server := newBloatedServer(t, 0)
+
+	result := newDirectTestClient().testBufferbloat(context.Background(), newTestBufferbloatTest(server))
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.Less(t, result.LatencyIncreaseMs, int64(30))
+	require.Equal(t, "A", result.Grade)
+	require.Positive(t, result.DownloadKBps)
+}
+
+func Test_TestBufferbloat_Bloat(t *testing.T) {
+	server := newBloatedServer(t, 250*time.Millisecond)
+
+	result := newDirectTestClient().testBufferbloat(context.Background(), newTestBufferbloatTest(server))
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.GreaterOrEqual(t, result.LoadedLatencyMs, int64(250))
+	require.Less(t, result.IdleLatencyMs, int64(250))
+	require.Equal(t, result.LoadedLatencyMs-result.IdleLatencyMs, result.LatencyIncreaseMs)
+	require.Contains(t, []string{"D", "F"}, result.Grade)
+}
+
+func Test_TestBufferbloat_Errors(t *testing.T) {
+	result := newUnreachableTestClient("127.0.0.1:4321").TestBufferbloat(context.Background())
+	require.NotNil(t, result.Error)
+	require.Equal(t, platerrors.ProxyServerUnreachable, result.Error.Code)
+	require.Empty(t, result.Grade)
+
+	server := newBloatedServer(t, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(500*time.Millisecond, cancel)
+	result = newDirectTestClient().testBufferbloat(ctx, newTestBufferbloatTest(server))
+	require.NotNil(t, result.Error)
+	require.Equal(t, platerrors.OperationCanceled, result.Error.Code)
+	require.Empty(t, result.Grade)
+}
+
+func Test_bufferbloatGrade(t *testing.T) {
+	for increaseMs, want := range map[int64]string{0: "A", 29: "A", 30: "B", 59: "B", 60: "C", 199: "C", 200: "D", 399: "D", 400: "F", 5000: "F"} {
+		require.Equal(t, want, bufferbloatGrade(increaseMs), "increase %d ms", increaseMs)
+	}
+}
+
+func Test_medianMs(t *testing.T) {
+	require.Equal(t, int64(5), medianMs([]int64{5}))
+	require.Equal(t, int64(2), medianMs([]int64{9, 1, 2}))
+	require.Equal(t, int64(2), medianMs([]int64{9, 1, 2, 3}))
+}
@@ -15,14 +15,25 @@
 package outline
 
 import (
+	"bytes"
 	"context"
-	"crypto/rand"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"io/fs"
 	"net"
 	"net/http"
+	"net/netip"
+	"os"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
+	"unicode/utf8"
 
 	"github.com/Jigsaw-Code/outline-apps/client/go/outline/config"
 	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
@@ -35,203 +46,638 @@ import (
 // It's used by the connectivity test and the tun2socks handlers.
 // TODO: Rename to Transport. Needs to update per-platform code.
 type Client struct {
-	sd *config.Dialer[transport.StreamConn]
-	pl *config.PacketListener
+	sd    *config.Dialer[transport.StreamConn]
+	pl    *config.PacketListener
+	stats clientStats
+	// connections tracks the open connections returned by [Client.DialStream].
+	connections connRegistry
+	// config is the config the transport was created from, or nil if unknown. It's replaced by
+	// [Client.UpdateCredentials].
+	config atomic.Pointer[ClientConfig]
+	// credentials holds the transports of a client created from a config, so that
+	// [Client.UpdateCredentials] can replace them, or is nil for other clients.
+	credentials *credentialRotation
+	// fallback is the set of transports of a client created by [NewClientWithFallback], or nil.
+	fallback *fallbackTransports
+	// httpTransport is the connection pool of [Client.HTTPClient], created on first use.
+	httpTransportOnce sync.Once
+	httpTransport     *http.Transport
+	// dialTimeout is the deadline of dials without one, in nanoseconds. Zero means
+	// [defaultDialTimeout], and a negative value means no deadline.
+	dialTimeout atomic.Int64
+	// dialPolicy restricts the destinations of [Client.DialStream], or is nil to allow all.
+	dialPolicy atomic.Pointer[dialPolicy]
+	// pool holds the idle streams of [Client.DialStream], or is nil if pooling is off.
+	pool atomic.Pointer[connPool]
+	// warnings are the non-fatal issues found in the config of the client.
+	warnings []string
+	// connectionState is the state of the tunnel detected by the connectivity monitors.
+	connectionState connectionStateTracker
 }
 
-func (c *Client) DialStream(ctx context.Context, address string) (transport.StreamConn, error) {
-	return c.sd.Dial(ctx, address)
-}
+// defaultDialTimeout is the dial timeout of clients that didn't call [Client.SetDialTimeout].
+const defaultDialTimeout = 30 * time.Second
 
-func (c *Client) ListenPacket(ctx context.Context) (net.PacketConn, error) {
-	return c.pl.ListenPacket(ctx)
+// SetDialTimeout sets how long [Client.DialStream] waits for a connection when the context has no
+// deadline. A timeout of zero or less disables it. Deadlines set by the caller always apply.
+func (c *Client) SetDialTimeout(timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = -1
+	}
+	c.dialTimeout.Store(int64(timeout))
 }
 
-// BandwidthTestResult represents the results of bandwidth and latency testing
-type BandwidthTestResult struct {
-	DownloadSpeedKBps int64 // Download speed in KB/s
-	UploadSpeedKBps   int64 // Upload speed in KB/s
-	LatencyMs         int64 // Round-trip latency in milliseconds
-	Error             *platerrors.PlatformError
+func (c *Client) getDialTimeout() time.Duration {
+	timeout := time.Duration(c.dialTimeout.Load())
+	if timeout == 0 {
+		return defaultDialTimeout
+	}
+	return timeout
 }
 
-// TestLatency measures the round-trip time to a test server through the proxy
-func (c *Client) TestLatency(ctx context.Context, testURL string) int64 {
-	start := time.Now()
-
-	// Create HTTP client that uses our proxy transport
-	httpClient := &http.Client{
-		Transport: &http.Transport{
-			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-				return c.sd.Dial(ctx, addr)
-			},
-		},
-		Timeout: 10 * time.Second,
+func (c *Client) DialStream(ctx context.Context, address string) (transport.StreamConn, error) {
+	if c.connections.isShutDown() {
+		return nil, errClientShutDown
+	}
+	if err := c.checkDialPolicy(address); err != nil {
+		logger().Debug("TCP dial rejected by the dial policy")
+		return nil, err
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		if timeout := c.getDialTimeout(); timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
 	}
+	logger().Debug("dialing TCP stream")
+	conn, err := c.dialStreamConn(ctx, address)
+	if err != nil {
+		logger().Debug("TCP dial failed", "code", speedTestError(err, platerrors.ProxyServerUnreachable, "").Code)
+		return nil, err
+	}
+	tracked, ok := c.connections.track(address, c.stats.wrapStreamConn(conn))
+	if !ok {
+		// The client was shut down during the dial.
+		conn.Close()
+		return nil, errClientShutDown
+	}
+	return tracked, nil
+}
 
-	resp, err := httpClient.Head(testURL)
+// ListenPacket returns a connection that exchanges UDP packets with any destination through the
+// proxy.
+//
+// Each call returns an independent connection with a UDP socket of its own and, for tunneled
+// transports, an association of its own with the proxy, so that concurrent UDP sessions, such as
+// DNS and media flows, never read each other's packets, and closing one leaves the others open.
+// Each open connection holds a local socket, and the proxy usually keeps state for it until it's
+// idle for a while, so callers should close the connections they no longer need rather than open
+// one per packet.
+func (c *Client) ListenPacket(ctx context.Context) (net.PacketConn, error) {
+	if c.connections.isShutDown() {
+		return nil, errClientShutDown
+	}
+	conn, err := c.pl.ListenPacket(ctx)
 	if err != nil {
-		return -1 // Error occurred
+		logger().Debug("UDP listen failed", "code", speedTestError(err, platerrors.ProxyServerUDPUnsupported, "").Code)
+		return nil, err
 	}
-	defer resp.Body.Close()
+	return c.trackPacketConn(conn)
+}
 
-	return time.Since(start).Milliseconds()
+// trackPacketConn counts the traffic of conn and tracks it for [Client.Shutdown]. It closes conn
+// and fails if the client was shut down.
+func (c *Client) trackPacketConn(conn net.PacketConn) (net.PacketConn, error) {
+	tracked, ok := c.connections.trackPacket(c.stats.wrapPacketConn(conn))
+	if !ok {
+		// The client was shut down while listening.
+		conn.Close()
+		return nil, errClientShutDown
+	}
+	return tracked, nil
 }
 
-// TestDownloadSpeed measures download speed by downloading data through the proxy
-func (c *Client) TestDownloadSpeed(ctx context.Context, testURL string, durationSeconds int) int64 {
-	// Create HTTP client that uses our proxy transport
-	httpClient := &http.Client{
-		Transport: &http.Transport{
-			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-				return c.sd.Dial(ctx, addr)
-			},
-		},
-		Timeout: time.Duration(durationSeconds+5) * time.Second,
+// DialTCP connects to address, in host:port form, through the proxy. It's like
+// [Client.DialStream], but returns a standard [net.Conn], for callers that don't use the SDK.
+//
+// Failures are [platerrors.PlatformError] values: [platerrors.InvalidConfig] for a malformed
+// address, [platerrors.OperationCanceled] if ctx is canceled, and otherwise
+// [platerrors.ProxyServerUnreachable], or a more specific code for timeouts and DNS failures.
+func (c *Client) DialTCP(ctx context.Context, address string) (net.Conn, error) {
+	if err := validateDialAddress(address); err != nil {
+		return nil, err
 	}
+	conn, err := c.DialStream(ctx, address)
+	if err != nil {
+		return nil, dialError(err, address, platerrors.ProxyServerUnreachable, "failed to connect to the address through the proxy")
+	}
+	return conn, nil
+}
 
-	start := time.Now()
-	resp, err := httpClient.Get(testURL)
+// DialUDP returns a connection that exchanges UDP packets with address, in host:port form,
+// through the proxy. Only packets from address are read, so the host should be an IP address.
+//
+// Failures are [platerrors.PlatformError] values, as with [Client.DialTCP], except that failures
+// to relay UDP traffic have code [platerrors.ProxyServerUDPUnsupported].
+func (c *Client) DialUDP(ctx context.Context, address string) (net.Conn, error) {
+	if err := validateDialAddress(address); err != nil {
+		return nil, err
+	}
+	conn, err := transport.PacketListenerDialer{Listener: c}.DialPacket(ctx, address)
 	if err != nil {
-		return -1
+		return nil, dialError(err, address, platerrors.ProxyServerUDPUnsupported, "failed to relay UDP packets to the address through the proxy")
 	}
-	defer resp.Body.Close()
+	return conn, nil
+}
 
-	var totalBytes int64
-	buffer := make([]byte, 128*1024) // Increased to 128KB buffer for better throughput
-	testDuration := time.Duration(durationSeconds) * time.Second
+// ListenPacketOn is like [Client.ListenPacket], but binds the UDP socket to localAddr, in ip:port
+// form, so that the traffic leaves through a given interface or from a given port. An empty IP
+// binds to all the interfaces, and port 0 lets the system pick the port. With a tunneled packet
+// listener, it's the socket that sends the packets to the proxy that is bound.
+//
+// Failures are [platerrors.PlatformError] values: [platerrors.InvalidConfig] for a malformed
+// address or one that doesn't belong to this device, [platerrors.LocalAddressInUse],
+// [platerrors.LocalAddressPermissionDenied], and otherwise the codes of [Client.DialUDP].
+func (c *Client) ListenPacketOn(ctx context.Context, localAddr string) (net.PacketConn, error) {
+	bindAddr, err := parseLocalUDPAddress(localAddr)
+	if err != nil {
+		return nil, err
+	}
+	var pl transport.PacketListener
+	if c.transportClient().pl.ConnType == config.ConnTypeDirect {
+		pl = &transport.UDPListener{Address: bindAddr.String()}
+	} else {
+		tcpDialer := transport.TCPDialer{Dialer: net.Dialer{KeepAlive: -1}}
+		udpDialer := transport.UDPDialer{Dialer: net.Dialer{LocalAddr: bindAddr}}
+		boundClient, err := newProbeClient(c, &tcpDialer, &udpDialer)
+		if err != nil {
+			return nil, err
+		}
+		pl = boundClient.pl
+	}
+	conn, err := pl.ListenPacket(ctx)
+	if err != nil {
+		return nil, listenError(err, localAddr)
+	}
+	return c.trackPacketConn(conn)
+}
 
-	for time.Since(start) < testDuration {
-		n, err := resp.Body.Read(buffer)
-		if err != nil && err != io.EOF {
-			break
+// parseLocalUDPAddress parses the local address of [Client.ListenPacketOn]. Host names are not
+// accepted, since they would need a DNS lookup that bypasses the proxy.
+func parseLocalUDPAddress(localAddr string) (*net.UDPAddr, error) {
+	invalidAddressError := func(cause error) error {
+		return platerrors.PlatformError{
+			Code:    platerrors.InvalidConfig,
+			Message: "local address must be in ip:port form",
+			Details: platerrors.ErrorDetails{"address": localAddr},
+			Cause:   platerrors.ToPlatformError(cause),
 		}
-		totalBytes += int64(n)
-		if err == io.EOF {
-			break
+	}
+	host, portText, err := net.SplitHostPort(localAddr)
+	if err != nil {
+		return nil, invalidAddressError(err)
+	}
+	port, err := strconv.ParseUint(portText, 10, 16)
+	if err != nil {
+		return nil, invalidAddressError(err)
+	}
+	bindAddr := &net.UDPAddr{Port: int(port)}
+	if host != "" {
+		ip, err := netip.ParseAddr(host)
+		if err != nil {
+			return nil, invalidAddressError(err)
 		}
+		bindAddr.IP = ip.AsSlice()
+		bindAddr.Zone = ip.Zone()
 	}
+	return bindAddr, nil
+}
 
-	actualDuration := time.Since(start)
-	if actualDuration.Milliseconds() == 0 {
-		return -1
+// listenError converts err, which made [Client.ListenPacketOn] fail, into a
+// [platerrors.PlatformError].
+func listenError(err error, localAddr string) error {
+	var perr *platerrors.PlatformError
+	switch {
+	case errors.Is(err, syscall.EADDRINUSE):
+		perr = &platerrors.PlatformError{
+			Code:    platerrors.LocalAddressInUse,
+			Message: "local address is already in use",
+		}
+	case errors.Is(err, syscall.EADDRNOTAVAIL):
+		perr = &platerrors.PlatformError{
+			Code:    platerrors.InvalidConfig,
+			Message: "local address does not belong to this device",
+		}
+	case errors.Is(err, os.ErrPermission):
+		perr = &platerrors.PlatformError{
+			Code:    platerrors.LocalAddressPermissionDenied,
+			Message: "not allowed to bind to the local address",
+		}
+	default:
+		return dialError(err, localAddr, platerrors.ProxyServerUDPUnsupported, "failed to listen for UDP packets")
 	}
+	perr.Details = platerrors.ErrorDetails{"address": localAddr}
+	perr.Cause = platerrors.ToPlatformError(err)
+	return *perr
+}
 
-	// Return speed in KB/s
-	return totalBytes / int64(actualDuration.Milliseconds()) * 1000 / 1024
+func validateDialAddress(address string) error {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return platerrors.PlatformError{
+			Code:    platerrors.InvalidConfig,
+			Message: "address must be in host:port form",
+			Details: platerrors.ErrorDetails{"address": address},
+			Cause:   platerrors.ToPlatformError(err),
+		}
+	}
+	return nil
 }
 
-// TestUploadSpeed measures upload speed by uploading data through the proxy
-func (c *Client) TestUploadSpeed(ctx context.Context, testURL string, durationSeconds int) int64 {
-	// Create HTTP client that uses our proxy transport
-	httpClient := &http.Client{
-		Transport: &http.Transport{
-			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-				return c.sd.Dial(ctx, addr)
-			},
-		},
-		Timeout: time.Duration(durationSeconds+5) * time.Second,
+// dialError converts err, which made a dial to address fail, into a [platerrors.PlatformError]
+// with the given code, unless it was a cancellation, a timeout or a DNS failure. Rejections of
+// the client itself, such as those of the dial policy, are returned as they are.
+func dialError(err error, address string, code platerrors.ErrorCode, message string) error {
+	var rejection platerrors.PlatformError
+	if errors.As(err, &rejection) && (rejection.Code == platerrors.DestinationForbidden || rejection.Code == platerrors.ClientShutDown) {
+		// Unwrap the rejection from the errors of the SDK, which callers can't inspect.
+		return rejection
 	}
+	var perr *platerrors.PlatformError
+	if errors.Is(err, context.Canceled) {
+		perr = &platerrors.PlatformError{
+			Code:    platerrors.OperationCanceled,
+			Message: "dial was canceled",
+			Cause:   platerrors.ToPlatformError(err),
+		}
+	} else {
+		perr = speedTestError(err, code, message)
+	}
+	perr.Details = platerrors.ErrorDetails{"address": address}
+	return *perr
+}
+
+// ClientConfig is used to create the Client.
+type ClientConfig struct {
+	Transport config.ConfigNode
+	// warnings are the non-fatal issues found when parsing the config text.
+	warnings []string
+}
 
-	// Create test data
-	chunkSize := 256 * 1024 // Increased to 256KB chunks
-	data := make([]byte, chunkSize)
-	rand.Read(data)
+// NewClientResult represents the result of [NewClientAndReturnError].
+//
+// We use a struct instead of a tuple to preserve a strongly typed error that gobind recognizes.
+type NewClientResult struct {
+	Client *Client
+	// StreamConnType and PacketConnType tell whether the TCP and UDP traffic of the client is
+	// tunneled, direct or blocked. They are [ConnTypeTunneled], [ConnTypeDirect] or
+	// [ConnTypeBlocked], or empty on error.
+	StreamConnType string
+	PacketConnType string
+	// Warnings are the non-fatal issues found in the config, such as deprecated formats and
+	// ignored fields, which the UI can show to nudge users to update their config. They never
+	// contain values from the config, which may be secrets.
+	Warnings []string
+	Error    *platerrors.PlatformError
+}
 
-	start := time.Now()
-	var totalBytes int64
-	testDuration := time.Duration(durationSeconds) * time.Second
+// Connection types reported by [NewClientResult] and [Client].
+const (
+	ConnTypeDirect   = "direct"
+	ConnTypeTunneled = "tunneled"
+	// ConnTypeBlocked is the type of the traffic the transport can't relay, such as the UDP
+	// traffic of HTTP CONNECT proxies, which fails instead of being sent directly.
+	ConnTypeBlocked = "blocked"
+)
 
-	for time.Since(start) < testDuration {
-		// Create a new request for each chunk using strings.Reader
-		resp, err := httpClient.Post(testURL, "application/octet-stream",
-			strings.NewReader(string(data)))
+// connTypeName returns the name of connType reported to the platform code.
+func connTypeName(connType config.ConnType) string {
+	switch connType {
+	case config.ConnTypeTunneled:
+		return ConnTypeTunneled
+	case config.ConnTypeBlocked:
+		return ConnTypeBlocked
+	default:
+		return ConnTypeDirect
+	}
+}
 
-		if err != nil {
-			break
-		}
-		resp.Body.Close()
+// StreamConnType returns whether the TCP traffic of the client is tunneled, direct or blocked.
+func (c *Client) StreamConnType() string {
+	return connTypeName(c.transportClient().sd.ConnType)
+}
 
-		totalBytes += int64(chunkSize)
+// PacketConnType returns whether the UDP traffic of the client is tunneled, direct or blocked.
+func (c *Client) PacketConnType() string {
+	return connTypeName(c.transportClient().pl.ConnType)
+}
 
-		// Reduced delay to 5ms to allow for higher throughput
-		time.Sleep(5 * time.Millisecond)
+// newClientResult wraps the outcome of creating a client in a [NewClientResult].
+func newClientResult(client *Client, err error) *NewClientResult {
+	if err != nil {
+		perr := platerrors.ToPlatformError(err)
+		logger().Warn("failed to create client", "code", perr.Code)
+		return &NewClientResult{Error: perr}
 	}
+	logger().Info("client created", "streamConnType", client.StreamConnType(), "packetConnType", client.PacketConnType(), "warnings", len(client.warnings))
+	return &NewClientResult{
+		Client:         client,
+		StreamConnType: client.StreamConnType(),
+		PacketConnType: client.PacketConnType(),
+		Warnings:       client.warnings,
+	}
+}
 
-	actualDuration := time.Since(start)
-	if actualDuration.Milliseconds() == 0 {
-		return -1
+// NewClient creates a new Outline client from a configuration string.
+func NewClient(clientConfig string) *NewClientResult {
+	return NewClientWithTCPOptions(clientConfig, nil)
+}
+
+// WithConfig creates a new client from clientConfigText, as [NewClient] does, that starts with the
+// traffic counters and the settings of c. This lets apps switch servers without losing the stats
+// or the restrictions of the session.
+//
+// The settings carried over are the dial timeout, the dial policy, the connection pool and the
+// connection state listener, and, if c was created from a config, the [TCPOptions] and
+// [NewClientOptions] it was created with. The new client starts without idle pooled streams, in
+// [ConnectionStateUnknown], and without the connectivity monitors and pings started on c, which
+// keep probing c. It's not a fallback client, even if c is one.
+//
+// The counters are copied when WithConfig is called: traffic that goes through c afterwards,
+// including over connections that are still open, is only counted by c. Both clients remain
+// usable, so callers that swap clients during a session should stop using c right after the
+// call to keep the counters of the new client complete. c is not modified.
+func (c *Client) WithConfig(clientConfigText string) *NewClientResult {
+	clientConfig, err := parseClientConfig(clientConfigText)
+	if err != nil {
+		return newClientResult(nil, err)
+	}
+	var tcpDialer transport.StreamDialer = &transport.TCPDialer{Dialer: net.Dialer{KeepAlive: -1}}
+	var udpDialer transport.PacketDialer = &transport.UDPDialer{}
+	var options NewClientOptions
+	if credentials := c.transportClient().credentials; credentials != nil {
+		tcpDialer, udpDialer, options = credentials.tcpDialer, credentials.udpDialer, credentials.options
+	}
+	client, err := newClientFromConfig(clientConfig, tcpDialer, udpDialer, options)
+	if err != nil {
+		return newClientResult(nil, err)
+	}
+	client.stats.copyFrom(&c.stats)
+	client.dialTimeout.Store(c.dialTimeout.Load())
+	// Policies are never modified once set, so the clients can share it.
+	client.dialPolicy.Store(c.dialPolicy.Load())
+	if pool := c.pool.Load(); pool != nil {
+		client.SetConnectionPool(pool.maxIdlePerHost, pool.idleTimeout)
 	}
+	client.connectionState.setListener(c.connectionState.getListener())
+	return newClientResult(client, nil)
+}
 
-	// Return speed in KB/s
-	return totalBytes / int64(actualDuration.Milliseconds()) * 1000 / 1024
+// NewClientOptions relaxes the checks [NewClientWithOptions] makes on the transport.
+type NewClientOptions struct {
+	// AllowDirectTCP accepts transports that send TCP traffic directly instead of tunneling it.
+	AllowDirectTCP bool
+	// AllowDirectUDP accepts transports that send UDP traffic directly instead of tunneling it,
+	// as in split-tunnel setups.
+	AllowDirectUDP bool
+	// DisableDirectUDP accepts transports that don't tunnel UDP traffic, but blocks their UDP
+	// traffic instead of sending it directly: [Client.ListenPacket] fails with a
+	// [platerrors.ProxyServerUDPUnsupported] error, while TCP keeps working. This keeps TCP-only
+	// servers usable on networks that block UDP. It takes precedence over AllowDirectUDP.
+	DisableDirectUDP bool
 }
 
-// PerformBandwidthTest runs comprehensive bandwidth and latency tests
-func (c *Client) PerformBandwidthTest(ctx context.Context) *BandwidthTestResult {
-	// Use speed.cloudflare.com for testing - it's designed for bandwidth testing
-	downloadURL := "https://speed.cloudflare.com/__down?bytes=2097152" // 2MB download
-	uploadURL := "https://speed.cloudflare.com/__up"                   // POST endpoint
-	latencyURL := "https://speed.cloudflare.com/__ping"                // Simple HEAD request
+// NewClientWithOptions is like [NewClient], but relaxes its checks according to options.
+// A nil options is the same as the zero value, which keeps the checks of [NewClient].
+func NewClientWithOptions(clientConfig string, options *NewClientOptions) *NewClientResult {
+	if options == nil {
+		options = &NewClientOptions{}
+	}
+	parsedConfig, err := parseClientConfig(clientConfig)
+	if err != nil {
+		return newClientResult(nil, err)
+	}
+	tcpDialer := transport.TCPDialer{Dialer: net.Dialer{KeepAlive: -1}}
+	udpDialer := transport.UDPDialer{}
+	return newClientResult(newClientFromConfig(parsedConfig, &tcpDialer, &udpDialer, *options))
+}
 
-	result := &BandwidthTestResult{}
+// NewClientFromJSON creates a new Outline client from a strict JSON configuration string.
+//
+// Unlike [NewClient], the input is decoded with a JSON parser, so JSON-specific escapes are
+// interpreted according to the JSON spec.
+func NewClientFromJSON(clientConfigJSON string) *NewClientResult {
+	var clientConfig ClientConfig
+	decoder := json.NewDecoder(strings.NewReader(clientConfigJSON))
+	decoder.UseNumber()
+	if err := decoder.Decode(&clientConfig); err != nil {
+		return &NewClientResult{Error: &platerrors.PlatformError{
+			Code:    platerrors.InvalidConfig,
+			Message: "config is not valid JSON",
+			Cause:   platerrors.ToPlatformError(err),
+		}}
+	}
+	clientConfig.Transport = fromJSONNumbers(clientConfig.Transport)
 
-	// Test latency (quick test)
-	result.LatencyMs = c.TestLatency(ctx, latencyURL)
+	tcpDialer := transport.TCPDialer{Dialer: net.Dialer{KeepAlive: -1}}
+	udpDialer := transport.UDPDialer{}
+	return newClientResult(newClientFromConfig(&clientConfig, &tcpDialer, &udpDialer, NewClientOptions{}))
+}
 
-	// Test download speed (10 seconds)
-	result.DownloadSpeedKBps = c.TestDownloadSpeed(ctx, downloadURL, 10)
+// maxConfigSize caps the size of the configs read by [NewClientFromReader].
+const maxConfigSize = 1 << 20
 
-	// Test upload speed (10 seconds)
-	result.UploadSpeedKBps = c.TestUploadSpeed(ctx, uploadURL, 10)
+// NewClientFromReader is like [NewClient], but reads the config from r. A UTF-8 byte order mark
+// at the start of the config is ignored.
+func NewClientFromReader(r io.Reader) *NewClientResult {
+	data, err := io.ReadAll(io.LimitReader(r, maxConfigSize+1))
+	if err != nil {
+		return &NewClientResult{Error: readConfigError(err)}
+	}
+	if len(data) > maxConfigSize {
+		return &NewClientResult{Error: &platerrors.PlatformError{
+			Code:    platerrors.InvalidConfig,
+			Message: "config is too large",
+			Details: platerrors.ErrorDetails{"maxBytes": maxConfigSize},
+		}}
+	}
+	data = bytes.TrimPrefix(data, []byte("\uFEFF"))
+	if !utf8.Valid(data) {
+		return &NewClientResult{Error: &platerrors.PlatformError{
+			Code:    platerrors.InvalidConfig,
+			Message: "config is not valid UTF-8",
+		}}
+	}
+	return NewClient(string(data))
+}
 
-	// Check for any failures
-	if result.LatencyMs == -1 || result.DownloadSpeedKBps == -1 || result.UploadSpeedKBps == -1 {
-		result.Error = &platerrors.PlatformError{
-			Code:    platerrors.InternalError,
-			Message: "bandwidth test failed",
-		}
+// NewClientFromFile is like [NewClientFromReader], but reads the config from the file at path.
+// Missing and unreadable files fail with [platerrors.ConfigFileNotFound] and
+// [platerrors.ConfigFilePermissionDenied] errors.
+func NewClientFromFile(path string) *NewClientResult {
+	file, err := os.Open(path)
+	if err != nil {
+		perr := readConfigError(err)
+		perr.Details = platerrors.ErrorDetails{"path": path}
+		return &NewClientResult{Error: perr}
 	}
+	defer file.Close()
+	return NewClientFromReader(file)
+}
 
-	return result
+// readConfigError converts err, which made reading a config fail, into a [platerrors.PlatformError].
+func readConfigError(err error) *platerrors.PlatformError {
+	perr := &platerrors.PlatformError{
+		Code:    platerrors.InternalError,
+		Message: "failed to read the config",
+		Cause:   platerrors.ToPlatformError(err),
+	}
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		perr.Code = platerrors.ConfigFileNotFound
+		perr.Message = "config file does not exist"
+	case errors.Is(err, fs.ErrPermission):
+		perr.Code = platerrors.ConfigFilePermissionDenied
+		perr.Message = "not allowed to read the config file"
+	}
+	return perr
 }
 
-// ClientConfig is used to create the Client.
-type ClientConfig struct {
-	Transport config.ConfigNode
+// fromJSONNumbers replaces the [json.Number] values in node with int64 or float64 values,
+// so that the config parsers see the same types they would get from YAML.
+func fromJSONNumbers(node config.ConfigNode) config.ConfigNode {
+	switch typed := node.(type) {
+	case json.Number:
+		if i, err := typed.Int64(); err == nil {
+			return i
+		}
+		if f, err := typed.Float64(); err == nil {
+			return f
+		}
+		return typed.String()
+	case map[string]any:
+		for k, v := range typed {
+			typed[k] = fromJSONNumbers(v)
+		}
+		return typed
+	case []any:
+		for i, v := range typed {
+			typed[i] = fromJSONNumbers(v)
+		}
+		return typed
+	default:
+		return node
+	}
 }
 
-// NewClientResult represents the result of [NewClientAndReturnError].
+// NewClientWithBaseDialers creates a new Outline client from a configuration string, using the
+// given dialers to reach the first hop. The configuration may be a YAML [ClientConfig] or a bare
+// Shadowsocks access key (ss://...).
+func NewClientWithBaseDialers(clientConfigText string, tcpDialer transport.StreamDialer, udpDialer transport.PacketDialer) (*Client, error) {
+	clientConfig, err := parseClientConfig(clientConfigText)
+	if err != nil {
+		return nil, err
+	}
+	return newClientFromConfig(clientConfig, tcpDialer, udpDialer, NewClientOptions{})
+}
+
+// NewClientFromDialers creates a new Outline client that connects with the given dialer and
+// listener as is, without parsing a config. It lets tests and integrators inject fake or
+// custom transports.
 //
-// We use a struct instead of a tuple to preserve a strongly typed error that gobind recognizes.
-type NewClientResult struct {
-	Client *Client
-	Error  *platerrors.PlatformError
+// The client is not created from a config, so the methods that recreate its transports, such as
+// [CheckTCPAndUDPConnectivityWithBaseDialers], fail with [platerrors.InternalError], and its first
+// hop is unknown.
+func NewClientFromDialers(sd transport.StreamDialer, pl transport.PacketListener) (*Client, error) {
+	if sd == nil || pl == nil {
+		return nil, platerrors.PlatformError{
+			Code:    platerrors.InvalidConfig,
+			Message: "stream dialer and packet listener must not be nil",
+		}
+	}
+	return &Client{
+		sd: &config.Dialer[transport.StreamConn]{
+			ConnectionProviderInfo: config.ConnectionProviderInfo{ConnType: config.ConnTypeTunneled},
+			Dial:                   sd.DialStream,
+		},
+		pl: &config.PacketListener{
+			ConnectionProviderInfo: config.ConnectionProviderInfo{ConnType: config.ConnTypeTunneled},
+			PacketListener:         pl,
+		},
+	}, nil
 }
 
-// NewClient creates a new Outline client from a configuration string.
-func NewClient(clientConfig string) *NewClientResult {
-	tcpDialer := transport.TCPDialer{Dialer: net.Dialer{KeepAlive: -1}}
-	udpDialer := transport.UDPDialer{}
-	client, err := NewClientWithBaseDialers(clientConfig, &tcpDialer, &udpDialer)
+// ValidateConfig checks a configuration string accepted by [NewClient] without creating a client
+// or accessing the network. It returns nil if the config is valid, or the error that [NewClient]
+// would return otherwise.
+func ValidateConfig(clientConfigText string) *platerrors.PlatformError {
+	clientConfig, err := parseClientConfig(clientConfigText)
 	if err != nil {
-		return &NewClientResult{Error: platerrors.ToPlatformError(err)}
+		return platerrors.ToPlatformError(err)
 	}
-	return &NewClientResult{Client: client}
+	// The transports are created but never used, so the base dialers never dial.
+	_, err = parseTransportPair(context.Background(), clientConfig, &transport.TCPDialer{}, &transport.UDPDialer{}, NewClientOptions{})
+	return platerrors.ToPlatformError(err)
 }
 
-func NewClientWithBaseDialers(clientConfigText string, tcpDialer transport.StreamDialer, udpDialer transport.PacketDialer) (*Client, error) {
+// parseClientConfig parses a YAML [ClientConfig] or a bare Shadowsocks access key.
+func parseClientConfig(clientConfigText string) (*ClientConfig, error) {
+	if isAccessKey(clientConfigText) {
+		return parseAccessKey(clientConfigText), nil
+	}
+
 	var clientConfig ClientConfig
 	err := yaml.Unmarshal([]byte(clientConfigText), &clientConfig)
 	if err != nil {
-		return nil, &platerrors.PlatformError{
-			Code:    platerrors.InvalidConfig,
-			Message: "config is not valid YAML",
-			Cause:   platerrors.ToPlatformError(err),
+		return nil, invalidYAMLError(err)
+	}
+	// Fields other than transport are ignored.
+	var fields map[string]any
+	if yaml.Unmarshal([]byte(clientConfigText), &fields) == nil {
+		var ignoredFields []string
+		for field := range fields {
+			if field != "transport" {
+				ignoredFields = append(ignoredFields, field)
+			}
+		}
+		slices.Sort(ignoredFields)
+		for _, field := range ignoredFields {
+			clientConfig.warnings = append(clientConfig.warnings, fmt.Sprintf("config field %q is unknown and is ignored", field))
 		}
 	}
+	return &clientConfig, nil
+}
 
-	transportPair, err := config.NewDefaultTransportProvider(tcpDialer, udpDialer).Parse(context.Background(), clientConfig.Transport)
+func newClientFromConfig(clientConfig *ClientConfig, tcpDialer transport.StreamDialer, udpDialer transport.PacketDialer, options NewClientOptions) (*Client, error) {
+	var warnings config.Warnings
+	ctx := config.WithWarnings(context.Background(), &warnings)
+	transportPair, err := parseTransportPair(ctx, clientConfig, tcpDialer, udpDialer, options)
+	if err != nil {
+		return nil, err
+	}
+	credentials := &credentialRotation{tcpDialer: tcpDialer, udpDialer: udpDialer, options: options}
+	credentials.current.Store(transportPair)
+	client := &Client{
+		sd: &config.Dialer[transport.StreamConn]{
+			ConnectionProviderInfo: transportPair.StreamDialer.ConnectionProviderInfo,
+			Dial:                   credentials.DialStream,
+		},
+		pl: &config.PacketListener{
+			ConnectionProviderInfo: transportPair.PacketListener.ConnectionProviderInfo,
+			PacketListener:         credentials,
+		},
+		credentials: credentials,
+		warnings:    append(slices.Clip(clientConfig.warnings), warnings.List()...),
+	}
+	client.config.Store(clientConfig)
+	return client, nil
+}
+
+// parseTransportPair creates the transports for clientConfig on top of the given base dialers,
+// reporting the warnings of the parsers to the [config.Warnings] of ctx, if any. It rejects
+// direct transports unless options allow them.
+func parseTransportPair(ctx context.Context, clientConfig *ClientConfig, tcpDialer transport.StreamDialer, udpDialer transport.PacketDialer, options NewClientOptions) (*config.TransportPair, error) {
+	transportPair, err := config.NewDefaultTransportProvider(tcpDialer, udpDialer).Parse(ctx, clientConfig.Transport)
 	if err != nil {
 		if errors.Is(err, errors.ErrUnsupported) {
 			return nil, &platerrors.PlatformError{
@@ -248,19 +694,39 @@ func NewClientWithBaseDialers(clientConfigText string, tcpDialer transport.Strea
 		}
 	}
 
-	// Make sure the transport is not proxyless for now.
-	if transportPair.StreamDialer.ConnType == config.ConnTypeDirect {
+	// Make sure the transport is not proxyless, unless explicitly allowed.
+	if transportPair.StreamDialer.ConnType == config.ConnTypeDirect && !options.AllowDirectTCP {
 		return nil, &platerrors.PlatformError{
 			Code:    platerrors.InvalidConfig,
 			Message: "transport must tunnel TCP traffic",
 		}
 	}
 	if transportPair.PacketListener.ConnType == config.ConnTypeDirect {
-		return nil, &platerrors.PlatformError{
-			Code:    platerrors.InvalidConfig,
-			Message: "transport must tunnel UDP traffic",
+		switch {
+		case options.DisableDirectUDP:
+			// Like the HTTP CONNECT transport, which can't relay UDP either, the UDP traffic is
+			// blocked rather than sent directly.
+			transportPair.PacketListener = &config.PacketListener{
+				ConnectionProviderInfo: config.ConnectionProviderInfo{ConnType: config.ConnTypeBlocked, FirstHop: transportPair.StreamDialer.FirstHop},
+				PacketListener:         disabledPacketListener{},
+			}
+		case !options.AllowDirectUDP:
+			return nil, &platerrors.PlatformError{
+				Code:    platerrors.InvalidConfig,
+				Message: "transport must tunnel UDP traffic",
+			}
 		}
 	}
+	return transportPair, nil
+}
 
-	return &Client{sd: transportPair.StreamDialer, pl: transportPair.PacketListener}, nil
+// disabledPacketListener is the [transport.PacketListener] of clients created with
+// [NewClientOptions.DisableDirectUDP] for transports that don't tunnel UDP traffic.
+type disabledPacketListener struct{}
+
+func (disabledPacketListener) ListenPacket(context.Context) (net.PacketConn, error) {
+	return nil, platerrors.PlatformError{
+		Code:    platerrors.ProxyServerUDPUnsupported,
+		Message: "UDP is not available, since the transport doesn't tunnel UDP traffic",
+	}
 }
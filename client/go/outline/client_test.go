@@ -15,9 +15,25 @@
 package outline
 
 import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/config"
 	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/Jigsaw-Code/outline-sdk/transport"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -303,3 +319,651 @@ func Test_NewClientFromJSON_Errors(t *testing.T) {
 		})
 	}
 }
+
+func Test_NewClientFromJSON(t *testing.T) {
+	config := `{
+  "transport": {
+    "$type": "tcpudp",
+    "tcp": {
+      "$type": "shadowsocks",
+      "endpoint": {"$type": "dial", "address": "example.com:80"},
+      "cipher": "chacha20-ietf-poly1305",
+      "secret": "SECRET",
+      "prefix": "\u0016\u0003\u0001"
+    },
+    "udp": {
+      "$type": "shadowsocks",
+      "endpoint": "example.com:53",
+      "cipher": "chacha20-ietf-poly1305",
+      "secret": "SECRET"
+    }
+  }
+}`
+
+	result := NewClientFromJSON(config)
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.Equal(t, "example.com:80", result.Client.sd.FirstHop)
+	require.Equal(t, "example.com:53", result.Client.pl.FirstHop)
+}
+
+func Test_NewClientFromJSON_Legacy(t *testing.T) {
+	config := `{"transport": {"server": "example.com", "server_port": 4321, "method": "chacha20-ietf-poly1305", "password": "SECRET"}}`
+
+	result := NewClientFromJSON(config)
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.Equal(t, "example.com:4321", result.Client.sd.FirstHop)
+	require.Equal(t, "example.com:4321", result.Client.pl.FirstHop)
+}
+
+func Test_NewClientFromJSON_InvalidJSON(t *testing.T) {
+	result := NewClientFromJSON(`transport: {server: example.com}`)
+	require.Nil(t, result.Client)
+	require.NotNil(t, result.Error)
+	require.Equal(t, platerrors.InvalidConfig, result.Error.Code)
+	require.Equal(t, "config is not valid JSON", result.Error.Message)
+}
+
+func Test_ValidateConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{"SS URL", "transport: ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpTRUNSRVQ@example.com:4321/", ""},
+		{"access key", "ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpTRUNSRVQ@example.com:4321/#name", ""},
+		{"invalid YAML", "transport: [", "config is not valid YAML at line 1, column 12"},
+		{"unsupported", "transport: {$type: unsupported}", "unsupported config"},
+		{"proxyless", "transport: {$type: tcpudp, tcp: null, udp: null}", "transport must tunnel TCP traffic"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			perr := ValidateConfig(tt.config)
+			if tt.wantErr == "" {
+				require.Nil(t, perr, "Got %v", perr)
+				return
+			}
+			require.NotNil(t, perr)
+			require.Equal(t, platerrors.InvalidConfig, perr.Code)
+			require.Equal(t, tt.wantErr, perr.Message)
+			// Same error as creating the client.
+			require.Equal(t, NewClient(tt.config).Error, perr)
+		})
+	}
+}
+
+func Test_NewClient_InvalidYAML(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      string
+		wantMessage string
+		wantDetails platerrors.ErrorDetails
+	}{
+		{
+			name:        "syntax",
+			config:      "transport: [",
+			wantMessage: "config is not valid YAML at line 1, column 12",
+			wantDetails: platerrors.ErrorDetails{"line": 1, "column": 12},
+		},
+		{
+			name:        "duplicate key",
+			config:      "transport: ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpTRUNSRVQ@example.com:4321/\ntransport: direct",
+			wantMessage: "config is not valid YAML at line 2, column 1",
+			wantDetails: platerrors.ErrorDetails{"line": 2, "column": 1},
+		},
+		{
+			name:        "not a mapping",
+			config:      "- transport\n- other",
+			wantMessage: "config is not valid YAML at line 1, column 1",
+			wantDetails: platerrors.ErrorDetails{"line": 1, "column": 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			perr := NewClient(tt.config).Error
+			require.NotNil(t, perr)
+			require.Equal(t, platerrors.InvalidConfig, perr.Code)
+			require.Equal(t, tt.wantMessage, perr.Message)
+			require.Equal(t, tt.wantDetails, perr.Details)
+			require.NotNil(t, perr.Cause)
+			require.NotEmpty(t, perr.Cause.Message)
+		})
+	}
+}
+
+func Test_NewClient_InvalidYAMLHidesSource(t *testing.T) {
+	perr := NewClient("transport:\n  password: SECRET\n  bad: [").Error
+	require.NotNil(t, perr)
+	require.Equal(t, platerrors.InvalidConfig, perr.Code)
+	require.Contains(t, perr.Details, "line")
+	require.NotContains(t, perr.Error(), "SECRET")
+}
+
+func Test_NewClient_ConnTypes(t *testing.T) {
+	result := NewClient("transport: ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpTRUNSRVQ@example.com:4321/")
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.Equal(t, ConnTypeTunneled, result.StreamConnType)
+	require.Equal(t, ConnTypeTunneled, result.PacketConnType)
+	require.Equal(t, ConnTypeTunneled, result.Client.StreamConnType())
+	require.Equal(t, ConnTypeTunneled, result.Client.PacketConnType())
+
+	result = NewClient("transport: {$type: unsupported}")
+	require.NotNil(t, result.Error)
+	require.Empty(t, result.StreamConnType)
+	require.Empty(t, result.PacketConnType)
+}
+
+func Test_Client_ConnTypes_Direct(t *testing.T) {
+	client := newDirectTestClient()
+	require.Equal(t, ConnTypeDirect, client.StreamConnType())
+	require.Equal(t, ConnTypeDirect, client.PacketConnType())
+}
+
+func Test_NewClientWithOptions_AllowDirect(t *testing.T) {
+	directUDPConfig := `
+transport:
+  $type: tcpudp
+  tcp: ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpTRUNSRVQ@example.com:4321/
+  udp:`
+
+	result := NewClientWithOptions(directUDPConfig, nil)
+	require.NotNil(t, result.Error)
+	require.Equal(t, "transport must tunnel UDP traffic", result.Error.Message)
+
+	result = NewClientWithOptions(directUDPConfig, &NewClientOptions{AllowDirectUDP: true})
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.Equal(t, ConnTypeTunneled, result.StreamConnType)
+	require.Equal(t, ConnTypeDirect, result.PacketConnType)
+	require.Equal(t, "example.com:4321", result.Client.sd.FirstHop)
+}
+
+func Test_NewClientWithOptions_AllowDirectTCPOnly(t *testing.T) {
+	proxylessConfig := `
+transport:
+  $type: tcpudp
+  tcp:
+  udp:`
+
+	result := NewClientWithOptions(proxylessConfig, &NewClientOptions{AllowDirectTCP: true})
+	require.NotNil(t, result.Error)
+	require.Equal(t, platerrors.InvalidConfig, result.Error.Code)
+	require.Equal(t, "transport must tunnel UDP traffic", result.Error.Message)
+
+	result = NewClientWithOptions(proxylessConfig, &NewClientOptions{AllowDirectTCP: true, AllowDirectUDP: true})
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.Equal(t, ConnTypeDirect, result.StreamConnType)
+	require.Equal(t, ConnTypeDirect, result.PacketConnType)
+}
+
+func Test_NewClientWithOptions_DisableDirectUDP(t *testing.T) {
+	tcpServer := newTCPEchoServer(t)
+	tcpOnlyConfig := `
+transport:
+  $type: tcpudp
+  tcp: ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpTRUNSRVQ@example.com:4321/
+  udp:`
+
+	result := NewClientWithOptions(tcpOnlyConfig, &NewClientOptions{DisableDirectUDP: true, AllowDirectUDP: true})
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.Equal(t, ConnTypeTunneled, result.StreamConnType)
+	require.Equal(t, ConnTypeBlocked, result.PacketConnType)
+	require.Equal(t, "example.com:4321", result.Client.pl.FirstHop)
+
+	conn, err := result.Client.ListenPacket(context.Background())
+	require.Nil(t, conn)
+	var perr platerrors.PlatformError
+	require.ErrorAs(t, err, &perr)
+	require.Equal(t, platerrors.ProxyServerUDPUnsupported, perr.Code)
+
+	// Recreating the client, as the connectivity checks do, keeps UDP disabled.
+	probeClient, err := newProbeClient(result.Client, &transport.TCPDialer{}, &transport.UDPDialer{})
+	require.NoError(t, err)
+	_, err = probeClient.ListenPacket(context.Background())
+	require.ErrorAs(t, err, &perr)
+	require.Equal(t, platerrors.ProxyServerUDPUnsupported, perr.Code)
+
+	// TCP still works.
+	result = NewClientWithOptions(`{transport: {$type: tcpudp, tcp: null, udp: null}}`, &NewClientOptions{AllowDirectTCP: true, DisableDirectUDP: true})
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	tcpConn, err := result.Client.DialTCP(context.Background(), tcpServer)
+	require.NoError(t, err)
+	tcpConn.Close()
+}
+
+func Test_NewTransport_HTTPConnect(t *testing.T) {
+	config := `
+transport:
+  $type: http-connect
+  endpoint: proxy.example.com:8080
+  username: user
+  password: pass`
+	firstHop := "proxy.example.com:8080"
+
+	result := NewClient(config)
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.Equal(t, ConnTypeTunneled, result.StreamConnType)
+	require.Equal(t, ConnTypeBlocked, result.PacketConnType)
+	require.Equal(t, firstHop, result.Client.sd.FirstHop)
+	require.Equal(t, firstHop, result.Client.pl.FirstHop)
+}
+
+// newDeadlineRecordingClient returns a client whose dials fail, recording the deadline of the
+// context they got.
+func newDeadlineRecordingClient(deadline *time.Time, hasDeadline *bool) *Client {
+	return &Client{sd: &config.Dialer[transport.StreamConn]{Dial: func(ctx context.Context, address string) (transport.StreamConn, error) {
+		*deadline, *hasDeadline = ctx.Deadline()
+		return nil, errors.New("not implemented")
+	}}}
+}
+
+func Test_Client_DialStream_DefaultTimeout(t *testing.T) {
+	var deadline time.Time
+	var hasDeadline bool
+	client := newDeadlineRecordingClient(&deadline, &hasDeadline)
+
+	start := time.Now()
+	client.DialStream(context.Background(), "example.com:80")
+	require.True(t, hasDeadline)
+	require.WithinDuration(t, start.Add(defaultDialTimeout), deadline, time.Second)
+
+	client.SetDialTimeout(5 * time.Second)
+	start = time.Now()
+	client.DialStream(context.Background(), "example.com:80")
+	require.True(t, hasDeadline)
+	require.WithinDuration(t, start.Add(5*time.Second), deadline, time.Second)
+
+	client.SetDialTimeout(0)
+	client.DialStream(context.Background(), "example.com:80")
+	require.False(t, hasDeadline)
+}
+
+// newTestConnectProxy returns the address of an HTTP CONNECT proxy that relays the connections it
+// accepts to the requested destinations.
+func newTestConnectProxy(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			clientConn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer clientConn.Close()
+				reader := bufio.NewReader(clientConn)
+				req, err := http.ReadRequest(reader)
+				if err != nil || req.Method != http.MethodConnect {
+					return
+				}
+				targetConn, err := net.Dial("tcp", req.Host)
+				if err != nil {
+					io.WriteString(clientConn, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+					return
+				}
+				defer targetConn.Close()
+				io.WriteString(clientConn, "HTTP/1.1 200 OK\r\n\r\n")
+				go io.Copy(targetConn, reader)
+				io.Copy(clientConn, targetConn)
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func Test_Client_DialStream_TunneledConnOutlivesDialTimeout(t *testing.T) {
+	server := newTCPEchoServer(t)
+	result := NewClient("transport: {$type: http-connect, endpoint: '" + newTestConnectProxy(t) + "'}")
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	client := result.Client
+	client.SetDialTimeout(time.Second)
+
+	// DialStream cancels the context of its dial timeout when it returns, which must not close
+	// the tunneled connection.
+	conn, err := client.DialStream(context.Background(), server)
+	require.NoError(t, err)
+	defer conn.Close()
+	time.Sleep(50 * time.Millisecond)
+	_, err = conn.Write([]byte("ping"))
+	require.NoError(t, err)
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(conn, buf)
+	require.NoError(t, err)
+	require.Equal(t, "ping", string(buf))
+}
+
+func Test_Client_DialStream_CallerDeadline(t *testing.T) {
+	var deadline time.Time
+	var hasDeadline bool
+	client := newDeadlineRecordingClient(&deadline, &hasDeadline)
+	client.SetDialTimeout(time.Second)
+
+	// A later deadline than the dial timeout is kept.
+	want := time.Now().Add(time.Hour)
+	ctx, cancel := context.WithDeadline(context.Background(), want)
+	defer cancel()
+	client.DialStream(ctx, "example.com:80")
+	require.True(t, hasDeadline)
+	require.Equal(t, want, deadline)
+}
+
+func Test_Client_DialTCP(t *testing.T) {
+	server := newTCPEchoServer(t)
+
+	conn, err := newDirectTestClient().DialTCP(context.Background(), server)
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("hello"))
+	require.NoError(t, err)
+	buf := make([]byte, 5)
+	_, err = io.ReadFull(conn, buf)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(buf))
+}
+
+func Test_Client_DialUDP(t *testing.T) {
+	server := newUDPEchoServer(t, nil)
+
+	conn, err := newDirectTestClient().DialUDP(context.Background(), server)
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("ping"))
+	require.NoError(t, err)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 10)
+	n, err := conn.Read(buf)
+	require.NoError(t, err)
+	require.Equal(t, "ping", string(buf[:n]))
+}
+
+func Test_Client_DialTCPAndUDP_Errors(t *testing.T) {
+	client := newUnreachableTestClient("127.0.0.1:4321")
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name     string
+		dial     func() (net.Conn, error)
+		wantCode platerrors.ErrorCode
+	}{
+		{"tcp unreachable", func() (net.Conn, error) { return client.DialTCP(context.Background(), "example.com:443") }, platerrors.ProxyServerUnreachable},
+		{"tcp malformed", func() (net.Conn, error) { return client.DialTCP(context.Background(), "example.com") }, platerrors.InvalidConfig},
+		{"tcp canceled", func() (net.Conn, error) {
+			return newDirectTestClient().DialTCP(canceled, "127.0.0.1:1")
+		}, platerrors.OperationCanceled},
+		{"udp unsupported", func() (net.Conn, error) { return client.DialUDP(context.Background(), "1.1.1.1:53") }, platerrors.ProxyServerUDPUnsupported},
+		{"udp malformed", func() (net.Conn, error) { return client.DialUDP(context.Background(), "1.1.1.1") }, platerrors.InvalidConfig},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := tt.dial()
+			require.Nil(t, conn)
+			var perr platerrors.PlatformError
+			require.ErrorAs(t, err, &perr)
+			require.Equal(t, tt.wantCode, perr.Code)
+		})
+	}
+}
+
+func Test_Client_ListenPacketOn_Direct(t *testing.T) {
+	client := newDirectTestClient()
+	client.pl.ConnType = config.ConnTypeDirect
+	server := newUDPEchoServer(t, nil)
+	serverAddr, err := net.ResolveUDPAddr("udp", server)
+	require.NoError(t, err)
+
+	conn, err := client.ListenPacketOn(context.Background(), "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Equal(t, "127.0.0.1", conn.LocalAddr().(*net.UDPAddr).IP.String())
+	_, err = conn.WriteTo([]byte("ping"), serverAddr)
+	require.NoError(t, err)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 10)
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	require.Equal(t, "ping", string(buf[:n]))
+}
+
+func Test_Client_ListenPacketOn_Tunneled(t *testing.T) {
+	// The fake proxy only records where the packets come from.
+	proxy, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer proxy.Close()
+	// Find a free port to bind to.
+	free, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	localAddr := free.LocalAddr().String()
+	free.Close()
+
+	result := NewClient(`{transport: {$type: shadowsocks, endpoint: "` + proxy.LocalAddr().String() + `", cipher: chacha20-ietf-poly1305, secret: SECRET}}`)
+	require.Nil(t, result.Error)
+	conn, err := result.Client.ListenPacketOn(context.Background(), localAddr)
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.WriteTo([]byte("ping"), &net.UDPAddr{IP: net.IPv4(1, 1, 1, 1), Port: 53})
+	require.NoError(t, err)
+
+	proxy.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 1024)
+	_, from, err := proxy.ReadFrom(buf)
+	require.NoError(t, err)
+	require.Equal(t, localAddr, from.String())
+}
+
+func Test_Client_ListenPacketOn_Errors(t *testing.T) {
+	client := newDirectTestClient()
+	client.pl.ConnType = config.ConnTypeDirect
+	inUse, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer inUse.Close()
+
+	tests := []struct {
+		localAddr string
+		wantCode  platerrors.ErrorCode
+	}{
+		{"127.0.0.1", platerrors.InvalidConfig},
+		{"localhost:0", platerrors.InvalidConfig},
+		{"127.0.0.1:65536", platerrors.InvalidConfig},
+		{inUse.LocalAddr().String(), platerrors.LocalAddressInUse},
+		// TEST-NET-1 addresses don't belong to any device.
+		{"192.0.2.1:0", platerrors.InvalidConfig},
+	}
+	for _, tt := range tests {
+		t.Run(tt.localAddr, func(t *testing.T) {
+			conn, err := client.ListenPacketOn(context.Background(), tt.localAddr)
+			require.Nil(t, conn)
+			var perr platerrors.PlatformError
+			require.ErrorAs(t, err, &perr)
+			require.Equal(t, tt.wantCode, perr.Code)
+			require.Equal(t, tt.localAddr, perr.Details["address"])
+		})
+	}
+}
+
+func Test_NewClientFromReader(t *testing.T) {
+	config := "\uFEFFtransport: ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpTRUNSRVQ@example.com:4321/"
+
+	result := NewClientFromReader(strings.NewReader(config))
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.Equal(t, "example.com:4321", result.Client.sd.FirstHop)
+}
+
+func Test_NewClientFromReader_Invalid(t *testing.T) {
+	for name, input := range map[string]string{
+		"invalid UTF-8": "transport: \xff",
+		"too large":     strings.Repeat("#", maxConfigSize+1),
+	} {
+		t.Run(name, func(t *testing.T) {
+			result := NewClientFromReader(strings.NewReader(input))
+			require.NotNil(t, result.Error)
+			require.Equal(t, platerrors.InvalidConfig, result.Error.Code)
+		})
+	}
+}
+
+func Test_NewClientFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "client.yaml")
+	config := "transport: ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpTRUNSRVQ@example.com:4321/"
+	require.NoError(t, os.WriteFile(path, []byte(config), 0o600))
+
+	result := NewClientFromFile(path)
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.Equal(t, "example.com:4321", result.Client.sd.FirstHop)
+}
+
+func Test_NewClientFromFile_NotFound(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.yaml")
+
+	result := NewClientFromFile(path)
+	require.NotNil(t, result.Error)
+	require.Equal(t, platerrors.ConfigFileNotFound, result.Error.Code)
+	require.Equal(t, path, result.Error.Details["path"])
+}
+
+func Test_readConfigError(t *testing.T) {
+	permissionErr := &fs.PathError{Op: "open", Path: "client.yaml", Err: fs.ErrPermission}
+	require.Equal(t, platerrors.ConfigFilePermissionDenied, readConfigError(permissionErr).Code)
+	require.Equal(t, platerrors.InternalError, readConfigError(errors.New("disk failure")).Code)
+}
+
+func Test_NewClientFromDialers(t *testing.T) {
+	address := newTCPEchoServer(t)
+	var dialed []string
+	tcpDialer := &transport.TCPDialer{}
+	sd := transport.FuncStreamDialer(func(ctx context.Context, addr string) (transport.StreamConn, error) {
+		dialed = append(dialed, addr)
+		return tcpDialer.DialStream(ctx, addr)
+	})
+
+	client, err := NewClientFromDialers(sd, failingPacketListener{})
+	require.NoError(t, err)
+	conn, err := client.DialStream(context.Background(), address)
+	require.NoError(t, err)
+	_, err = conn.Write([]byte("ping"))
+	require.NoError(t, err)
+	conn.Close()
+	require.Equal(t, []string{address}, dialed)
+	require.Equal(t, int64(4), client.Stats().BytesSent)
+
+	_, err = client.ListenPacket(context.Background())
+	require.Error(t, err)
+
+	// The transports can't be recreated without a config.
+	result := CheckTCPAndUDPConnectivityWithBaseDialers(client, tcpDialer, &transport.UDPDialer{})
+	require.Equal(t, platerrors.InternalError, result.TCPError.Code)
+}
+
+func Test_NewClientFromDialers_Nil(t *testing.T) {
+	_, err := NewClientFromDialers(nil, failingPacketListener{})
+	require.Equal(t, platerrors.InvalidConfig, platerrors.ToPlatformError(err).Code)
+	_, err = NewClientFromDialers(&transport.TCPDialer{}, nil)
+	require.Equal(t, platerrors.InvalidConfig, platerrors.ToPlatformError(err).Code)
+}
+
+func Test_NewClient_Warnings(t *testing.T) {
+	result := NewClient("transport: ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpTRUNSRVQ@example.com:4321/")
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.Empty(t, result.Warnings)
+
+	result = NewClient(`
+transport:
+  $type: tcpudp
+  tcp:
+    $type: shadowsocks
+    endpoint: example.com:4321
+    cipher: chacha20-ietf-poly1305
+    secret: SECRET
+  udp:
+    $type: shadowsocks
+    server: example.com
+    server_port: 4321
+    method: chacha20-ietf-poly1305
+    password: SECRET
+transprot: typo`)
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.Equal(t, []string{
+		`config field "transprot" is unknown and is ignored`,
+		"Shadowsocks fields server, server_port, method and password are deprecated, use endpoint, cipher and secret instead",
+	}, result.Warnings)
+	for _, warning := range result.Warnings {
+		require.NotContains(t, warning, "SECRET")
+	}
+
+	// Warnings don't make configs fail.
+	result = NewClient("transport: ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpTRUNSRVQ@example.com:4321/?plugin=v2ray")
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.Len(t, result.Warnings, 1)
+}
+
+func Test_Client_ListenPacket_Concurrent(t *testing.T) {
+	client := newDirectTestClient()
+	server, err := net.ResolveUDPAddr("udp", newUDPEchoServer(t, nil))
+	require.NoError(t, err)
+
+	const packets = 20
+	conns := make([]net.PacketConn, 2)
+	for i := range conns {
+		conns[i], err = client.ListenPacket(context.Background())
+		require.NoError(t, err)
+		defer conns[i].Close()
+	}
+	require.NotEqual(t, conns[0].LocalAddr().String(), conns[1].LocalAddr().String())
+
+	// Both flows run at the same time, and each only reads its own echoes.
+	var wg sync.WaitGroup
+	for i, conn := range conns {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, 64)
+			for j := 0; j < packets; j++ {
+				payload := fmt.Sprintf("flow %d packet %d", i, j)
+				if _, err := conn.WriteTo([]byte(payload), server); !assert.NoError(t, err) {
+					return
+				}
+				conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+				n, _, err := conn.ReadFrom(buf)
+				if !assert.NoError(t, err) {
+					return
+				}
+				assert.Equal(t, payload, string(buf[:n]))
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Closing a flow doesn't affect the other.
+	require.NoError(t, conns[0].Close())
+	_, err = conns[1].WriteTo([]byte("still open"), server)
+	require.NoError(t, err)
+	conns[1].SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 64)
+	n, _, err := conns[1].ReadFrom(buf)
+	require.NoError(t, err)
+	require.Equal(t, "still open", string(buf[:n]))
+}
+
+func Test_Client_ListenPacket_TunneledAssociations(t *testing.T) {
+	// The fake proxy only records where the packets come from.
+	proxy, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer proxy.Close()
+	result := NewClient(`{transport: {$type: shadowsocks, endpoint: "` + proxy.LocalAddr().String() + `", cipher: chacha20-ietf-poly1305, secret: SECRET}}`)
+	require.Nil(t, result.Error)
+
+	destination := &net.UDPAddr{IP: net.IPv4(1, 1, 1, 1), Port: 53}
+	sources := make(map[string]bool)
+	for i := 0; i < 2; i++ {
+		conn, err := result.Client.ListenPacket(context.Background())
+		require.NoError(t, err)
+		defer conn.Close()
+		_, err = conn.WriteTo([]byte("ping"), destination)
+		require.NoError(t, err)
+
+		proxy.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, from, err := proxy.ReadFrom(make([]byte, 1024))
+		require.NoError(t, err)
+		sources[from.String()] = true
+	}
+	require.Len(t, sources, 2, "each connection must have its own association with the proxy")
+}
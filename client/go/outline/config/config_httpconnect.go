@@ -0,0 +1,146 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/Jigsaw-Code/outline-sdk/transport"
+)
+
+// HTTPConnectConfig is the format for the HTTP CONNECT config. It specifies an HTTP proxy that
+// tunnels TCP connections with the CONNECT method.
+type HTTPConnectConfig struct {
+	// Endpoint is the proxy to connect to.
+	Endpoint ConfigNode
+	// Username and Password are sent with Basic authentication if either is set.
+	Username string
+	Password string
+}
+
+// errHTTPConnectUDP is returned when sending UDP traffic through an HTTP CONNECT proxy.
+var errHTTPConnectUDP = fmt.Errorf("HTTP CONNECT proxies cannot relay UDP traffic: %w", errors.ErrUnsupported)
+
+func parseHTTPConnectTransport(ctx context.Context, configMap map[string]any, parseSE ParseFunc[*Endpoint[transport.StreamConn]]) (*TransportPair, error) {
+	sd, err := parseHTTPConnectStreamDialer(ctx, configMap, parseSE)
+	if err != nil {
+		return nil, err
+	}
+	// The UDP traffic is not sent directly, it's dropped.
+	return &TransportPair{
+		StreamDialer:   sd,
+		PacketListener: &PacketListener{ConnectionProviderInfo{ConnTypeBlocked, sd.FirstHop}, unsupportedPacketListener{errHTTPConnectUDP}},
+	}, nil
+}
+
+func parseHTTPConnectStreamDialer(ctx context.Context, configMap map[string]any, parseSE ParseFunc[*Endpoint[transport.StreamConn]]) (*Dialer[transport.StreamConn], error) {
+	var config HTTPConnectConfig
+	if err := mapToAny(configMap, &config); err != nil {
+		return nil, fmt.Errorf("invalid config format: %w", err)
+	}
+	if config.Endpoint == nil {
+		return nil, errors.New("endpoint must be specified")
+	}
+
+	se, err := parseSE(ctx, config.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create StreamEndpoint: %w", err)
+	}
+
+	headers := http.Header{}
+	if config.Username != "" || config.Password != "" {
+		credentials := base64.StdEncoding.EncodeToString([]byte(config.Username + ":" + config.Password))
+		headers.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	d := &httpConnectDialer{endpoint: se, headers: headers}
+	return &Dialer[transport.StreamConn]{ConnectionProviderInfo{ConnTypeTunneled, se.FirstHop}, d.DialStream}, nil
+}
+
+// httpConnectDialer is a [transport.StreamDialer] that connects through an HTTP CONNECT proxy.
+//
+// We don't use the SDK's httpconnect client because its connections stop working when the
+// context of the dial is done, and callers may cancel it right after dialing.
+type httpConnectDialer struct {
+	endpoint *Endpoint[transport.StreamConn]
+	headers  http.Header
+}
+
+func (d *httpConnectDialer) DialStream(ctx context.Context, address string) (transport.StreamConn, error) {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return nil, fmt.Errorf("failed to parse address %s: %w", address, err)
+	}
+	conn, err := d.endpoint.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Abort the handshake when ctx is done.
+	stopAbort := context.AfterFunc(ctx, func() { conn.Close() })
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Host: address},
+		Host:   address,
+		Header: d.headers.Clone(),
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := func() (*http.Response, error) {
+		if err := req.Write(conn); err != nil {
+			return nil, err
+		}
+		return http.ReadResponse(reader, req)
+	}()
+	if !stopAbort() {
+		conn.Close()
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("CONNECT to proxy %s failed: %w", d.endpoint.FirstHop, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s refused the connection: %s", d.endpoint.FirstHop, resp.Status)
+	}
+	// The proxy may have sent data from the destination along with the response.
+	return &bufferedStreamConn{conn, reader}, nil
+}
+
+// bufferedStreamConn is a [transport.StreamConn] that reads through reader.
+type bufferedStreamConn struct {
+	transport.StreamConn
+	reader io.Reader
+}
+
+func (c *bufferedStreamConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+// unsupportedPacketListener is a [transport.PacketListener] that always fails with err.
+type unsupportedPacketListener struct {
+	err error
+}
+
+func (l unsupportedPacketListener) ListenPacket(ctx context.Context) (net.PacketConn, error) {
+	return nil, l.err
+}
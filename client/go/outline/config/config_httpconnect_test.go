@@ -0,0 +1,212 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newTestConnectProxy returns the address of an HTTP CONNECT proxy that requires the given
+// Proxy-Authorization header, if not empty.
+func newTestConnectProxy(t *testing.T, wantAuth string) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveConnect(conn, wantAuth)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func serveConnect(conn net.Conn, wantAuth string) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	req, err := http.ReadRequest(reader)
+	if err != nil {
+		return
+	}
+	if req.Method != http.MethodConnect {
+		io.WriteString(conn, "HTTP/1.1 405 Method Not Allowed\r\n\r\n")
+		return
+	}
+	if wantAuth != "" && req.Header.Get("Proxy-Authorization") != wantAuth {
+		io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\n\r\n")
+		return
+	}
+	target, err := net.Dial("tcp", req.Host)
+	if err != nil {
+		io.WriteString(conn, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+		return
+	}
+	defer target.Close()
+	io.WriteString(conn, "HTTP/1.1 200 OK\r\n\r\n")
+	go io.Copy(target, reader)
+	io.Copy(conn, target)
+}
+
+// newTestEchoServer returns the address of a TCP server that echoes back what it receives.
+func newTestEchoServer(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestHTTPConnect_EndToEnd(t *testing.T) {
+	// "user:pass" in base64.
+	proxyAddr := newTestConnectProxy(t, "Basic dXNlcjpwYXNz")
+	echoAddr := newTestEchoServer(t)
+
+	node, err := ParseConfigYAML(`
+$type: http-connect
+endpoint: ` + proxyAddr + `
+username: user
+password: pass`)
+	require.NoError(t, err)
+	tp, err := newTestTransportProvider().Parse(context.Background(), node)
+	require.NoError(t, err)
+	require.Equal(t, ConnTypeTunneled, tp.StreamDialer.ConnType)
+	require.Equal(t, proxyAddr, tp.StreamDialer.FirstHop)
+
+	conn, err := tp.StreamDialer.Dial(context.Background(), echoAddr)
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("hello"))
+	require.NoError(t, err)
+	buf := make([]byte, 5)
+	_, err = io.ReadFull(conn, buf)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(buf))
+
+	require.Equal(t, ConnTypeBlocked, tp.PacketListener.ConnType)
+	_, err = tp.PacketListener.ListenPacket(context.Background())
+	require.ErrorIs(t, err, errors.ErrUnsupported)
+}
+
+func TestHTTPConnect_WrongCredentials(t *testing.T) {
+	proxyAddr := newTestConnectProxy(t, "Basic dXNlcjpwYXNz")
+
+	node, err := ParseConfigYAML(`
+$type: http-connect
+endpoint: ` + proxyAddr + `
+username: user
+password: wrong`)
+	require.NoError(t, err)
+	tp, err := newTestTransportProvider().Parse(context.Background(), node)
+	require.NoError(t, err)
+
+	_, err = tp.StreamDialer.Dial(context.Background(), "example.com:80")
+	require.Error(t, err)
+}
+
+func TestHTTPConnect_InTCPUDP(t *testing.T) {
+	node, err := ParseConfigYAML(`
+$type: tcpudp
+tcp:
+  $type: http-connect
+  endpoint: proxy.example.com:8080
+udp:
+  $type: shadowsocks
+  endpoint: example.com:1234
+  cipher: chacha20-ietf-poly1305
+  secret: SECRET`)
+	require.NoError(t, err)
+	tp, err := newTestTransportProvider().Parse(context.Background(), node)
+	require.NoError(t, err)
+	require.Equal(t, ConnTypeTunneled, tp.StreamDialer.ConnType)
+	require.Equal(t, "proxy.example.com:8080", tp.StreamDialer.FirstHop)
+	require.Equal(t, "example.com:1234", tp.PacketListener.FirstHop)
+}
+
+func TestHTTPConnect_MissingEndpoint(t *testing.T) {
+	node, err := ParseConfigYAML(`$type: http-connect`)
+	require.NoError(t, err)
+	_, err = newTestTransportProvider().Parse(context.Background(), node)
+	require.Error(t, err)
+}
+
+func TestHTTPConnect_OutlivesDialContext(t *testing.T) {
+	proxyAddr := newTestConnectProxy(t, "")
+	echoAddr := newTestEchoServer(t)
+	node, err := ParseConfigYAML("{$type: http-connect, endpoint: '" + proxyAddr + "'}")
+	require.NoError(t, err)
+	tp, err := newTestTransportProvider().Parse(context.Background(), node)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	conn, err := tp.StreamDialer.Dial(ctx, echoAddr)
+	require.NoError(t, err)
+	defer conn.Close()
+	cancel()
+
+	_, err = conn.Write([]byte("hello"))
+	require.NoError(t, err)
+	buf := make([]byte, 5)
+	_, err = io.ReadFull(conn, buf)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(buf))
+}
+
+func TestHTTPConnect_HandshakeCanceled(t *testing.T) {
+	// The proxy accepts connections but never answers.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	node, err := ParseConfigYAML("{$type: http-connect, endpoint: '" + listener.Addr().String() + "'}")
+	require.NoError(t, err)
+	tp, err := newTestTransportProvider().Parse(context.Background(), node)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = tp.StreamDialer.Dial(ctx, "example.com:80")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,107 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Jigsaw-Code/outline-sdk/transport"
+	"github.com/Jigsaw-Code/outline-sdk/transport/socks5"
+)
+
+// obfs4CertBytes is the length of a decoded obfs4 bridge cert: a 20-byte node ID and a 32-byte
+// public key.
+const obfs4CertBytes = 52
+
+// Obfs4Config is the format for the obfs4 config. It specifies a Stream Dialer that connects to
+// obfs4 bridges through an obfs4 pluggable transport client, such as lyrebird, that runs
+// alongside the app. The client obfuscates the traffic to the bridge, which relays it to the
+// server the bridge fronts.
+//
+// The pluggable transport client is reached through the SOCKS5 interface that pluggable
+// transport clients expose, with the bridge parameters passed as SOCKS5 credentials, as Tor does.
+type Obfs4Config struct {
+	// Client is the SOCKS5 endpoint of the pluggable transport client.
+	Client ConfigNode
+	// Cert is the cert of the bridge, from its bridge line.
+	Cert string
+	// IATMode is the inter-arrival time obfuscation mode of the bridge: 0, 1 or 2.
+	IATMode int `yaml:"iat-mode"`
+}
+
+func parseObfs4StreamDialer(ctx context.Context, configMap map[string]any, parseSE ParseFunc[*Endpoint[transport.StreamConn]]) (*Dialer[transport.StreamConn], error) {
+	var config Obfs4Config
+	if err := mapToAny(configMap, &config); err != nil {
+		return nil, fmt.Errorf("invalid config format: %w", err)
+	}
+	if config.Client == nil {
+		return nil, errors.New("client must be specified, obfs4 requires a running pluggable transport client")
+	}
+	if config.Cert == "" {
+		return nil, errors.New("cert must be specified")
+	}
+	if cert, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(config.Cert, "=")); err != nil || len(cert) != obfs4CertBytes {
+		return nil, errors.New("cert is not a valid obfs4 bridge cert")
+	}
+	if config.IATMode < 0 || config.IATMode > 2 {
+		return nil, fmt.Errorf("iat-mode must be 0, 1 or 2, found %d", config.IATMode)
+	}
+
+	se, err := parseSE(ctx, config.Client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create StreamEndpoint: %w", err)
+	}
+	client, err := socks5.NewClient(transport.FuncStreamEndpoint(se.Connect))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pluggable transport client: %w", err)
+	}
+	username, password := ptSOCKSCredentials(map[string]string{
+		"cert":     config.Cert,
+		"iat-mode": strconv.Itoa(config.IATMode),
+	})
+	if err := client.SetCredentials(username, password); err != nil {
+		return nil, fmt.Errorf("obfs4 parameters are too long: %w", err)
+	}
+	return &Dialer[transport.StreamConn]{ConnectionProviderInfo{ConnTypeTunneled, se.FirstHop}, client.DialStream}, nil
+}
+
+// ptSOCKSCredentials encodes the per-connection arguments of a pluggable transport as the SOCKS5
+// username and password, as the pluggable transport specification defines: the arguments are
+// joined as k=v pairs separated by semicolons, escaping backslashes, equal signs and semicolons
+// of the values. The result goes in the username, and in the password once the username is full.
+// The password is a NUL byte if it's not needed, since it can't be empty.
+func ptSOCKSCredentials(args map[string]string) ([]byte, []byte) {
+	escaper := strings.NewReplacer(`\`, `\\`, `=`, `\=`, `;`, `\;`)
+	keys := make([]string, 0, len(args))
+	for key := range args {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = key + "=" + escaper.Replace(args[key])
+	}
+	encoded := []byte(strings.Join(pairs, ";"))
+	if len(encoded) <= 255 {
+		return encoded, []byte{0}
+	}
+	return encoded[:255], encoded[255:]
+}
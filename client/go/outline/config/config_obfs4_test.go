@@ -0,0 +1,128 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testObfs4Cert is a well-formed obfs4 bridge cert.
+var testObfs4Cert = base64.RawStdEncoding.EncodeToString([]byte(strings.Repeat("x", obfs4CertBytes)))
+
+func TestObfs4_StreamDialer(t *testing.T) {
+	echoServer := newTestEchoServer(t)
+	// The fake pluggable transport client checks that it gets the bridge parameters.
+	ptClient := newTestSOCKS5Proxy(t, "cert="+testObfs4Cert+";iat-mode=1", "\x00")
+
+	node, err := ParseConfigYAML(`
+$type: tcpudp
+tcp:
+  $type: obfs4
+  client: ` + ptClient + `
+  cert: ` + testObfs4Cert + `
+  iat-mode: 1`)
+	require.NoError(t, err)
+	tp, err := newTestTransportProvider().Parse(context.Background(), node)
+	require.NoError(t, err)
+	require.Equal(t, ConnTypeTunneled, tp.StreamDialer.ConnType)
+	require.Equal(t, ptClient, tp.StreamDialer.FirstHop)
+
+	conn, err := tp.StreamDialer.Dial(context.Background(), echoServer)
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("hello"))
+	require.NoError(t, err)
+	buf := make([]byte, 5)
+	_, err = io.ReadFull(conn, buf)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(buf))
+}
+
+func TestObfs4_InEndpoint(t *testing.T) {
+	node, err := ParseConfigYAML(`
+$type: tcpudp
+tcp:
+  $type: shadowsocks
+  endpoint:
+    $type: dial
+    address: bridge.example.com:443
+    dialer:
+      $type: obfs4
+      client: 127.0.0.1:47351
+      cert: ` + testObfs4Cert + `
+  cipher: chacha20-ietf-poly1305
+  secret: SECRET`)
+	require.NoError(t, err)
+	tp, err := newTestTransportProvider().Parse(context.Background(), node)
+	require.NoError(t, err)
+	require.Equal(t, ConnTypeTunneled, tp.StreamDialer.ConnType)
+}
+
+func TestObfs4_PTClientUnavailable(t *testing.T) {
+	node, err := ParseConfigYAML(`
+$type: tcpudp
+tcp:
+  $type: obfs4
+  client: 127.0.0.1:1
+  cert: ` + testObfs4Cert)
+	require.NoError(t, err)
+	tp, err := newTestTransportProvider().Parse(context.Background(), node)
+	require.NoError(t, err)
+	_, err = tp.StreamDialer.Dial(context.Background(), "example.com:443")
+	require.Error(t, err)
+}
+
+func TestObfs4_Invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+	}{
+		{"missing client", "cert: " + testObfs4Cert},
+		{"missing cert", "client: 127.0.0.1:47351"},
+		{"bad cert", "client: 127.0.0.1:47351\n  cert: not-a-cert"},
+		{"short cert", "client: 127.0.0.1:47351\n  cert: AAEC"},
+		{"bad iat-mode", "client: 127.0.0.1:47351\n  cert: " + testObfs4Cert + "\n  iat-mode: 3"},
+		{"unknown field", "client: 127.0.0.1:47351\n  cert: " + testObfs4Cert + "\n  node-id: x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := ParseConfigYAML(`
+$type: tcpudp
+tcp:
+  $type: obfs4
+  ` + tt.config)
+			require.NoError(t, err)
+			_, err = newTestTransportProvider().Parse(context.Background(), node)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestPTSOCKSCredentials(t *testing.T) {
+	username, password := ptSOCKSCredentials(map[string]string{"cert": "a=b;c\\d", "iat-mode": "0"})
+	require.Equal(t, `cert=a\=b\;c\\d;iat-mode=0`, string(username))
+	require.Equal(t, []byte{0}, password)
+
+	// Arguments that don't fit in the username continue in the password.
+	username, password = ptSOCKSCredentials(map[string]string{"cert": strings.Repeat("x", 300)})
+	require.Len(t, username, 255)
+	require.Equal(t, "cert="+strings.Repeat("x", 300), string(username)+string(password))
+}
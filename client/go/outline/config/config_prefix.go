@@ -0,0 +1,115 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"cmp"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/Jigsaw-Code/outline-sdk/transport"
+)
+
+// Encodings of [PrefixDialerConfig.Prefix].
+const (
+	prefixEncodingBase64 = "base64"
+	prefixEncodingHex    = "hex"
+)
+
+// PrefixDialerConfig is the format for the prefix dialer config. It sends fixed bytes ahead of
+// the data of every stream, in the same packet as the first write, which some servers require
+// to evade DPI.
+type PrefixDialerConfig struct {
+	// Prefix is the encoded bytes to send.
+	Prefix string
+	// Encoding is the encoding of Prefix, "base64" or "hex". It defaults to "base64".
+	Encoding string
+	// Dialer is the dialer of the streams. It defaults to TCP.
+	Dialer ConfigNode
+}
+
+func parsePrefixStreamDialer(ctx context.Context, configMap map[string]any, parseSD ParseFunc[*Dialer[transport.StreamConn]]) (*Dialer[transport.StreamConn], error) {
+	var config PrefixDialerConfig
+	if err := mapToAny(configMap, &config); err != nil {
+		return nil, fmt.Errorf("invalid config format: %w", err)
+	}
+	prefix, err := decodePrefix(config.Prefix, config.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	sd, err := parseSD(ctx, config.Dialer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sub-dialer: %w", err)
+	}
+	dial := func(ctx context.Context, address string) (transport.StreamConn, error) {
+		conn, err := sd.Dial(ctx, address)
+		if err != nil {
+			return nil, err
+		}
+		return &prefixConn{StreamConn: conn, prefix: prefix}, nil
+	}
+	// The prefix doesn't change where the streams go.
+	return &Dialer[transport.StreamConn]{sd.ConnectionProviderInfo, dial}, nil
+}
+
+// decodePrefix returns the bytes of prefix, which is in the given encoding.
+func decodePrefix(prefix, encoding string) ([]byte, error) {
+	if prefix == "" {
+		return nil, errors.New("prefix must be specified")
+	}
+	var decoded []byte
+	var err error
+	switch encoding {
+	case "", prefixEncodingBase64:
+		decoded, err = base64.StdEncoding.DecodeString(prefix)
+	case prefixEncodingHex:
+		decoded, err = hex.DecodeString(prefix)
+	default:
+		return nil, fmt.Errorf("unsupported prefix encoding %q, must be %q or %q", encoding, prefixEncodingBase64, prefixEncodingHex)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("prefix is not valid %s: %w", cmp.Or(encoding, prefixEncodingBase64), err)
+	}
+	return decoded, nil
+}
+
+// prefixConn is a [transport.StreamConn] that sends prefix with its first write.
+type prefixConn struct {
+	transport.StreamConn
+	mu     sync.Mutex
+	prefix []byte
+}
+
+func (c *prefixConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.prefix == nil {
+		return c.StreamConn.Write(b)
+	}
+	// The prefix is shared by the connections of the dialer, so it's copied, not appended to.
+	n, err := c.StreamConn.Write(append(append(make([]byte, 0, len(c.prefix)+len(b)), c.prefix...), b...))
+	if n < len(c.prefix) {
+		// Send the rest of the prefix with the next write.
+		c.prefix = c.prefix[n:]
+		return 0, err
+	}
+	n -= len(c.prefix)
+	c.prefix = nil
+	return n, err
+}
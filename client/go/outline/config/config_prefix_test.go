@@ -0,0 +1,124 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newTestRecordingServer returns the address of a TCP server that sends what it receives on its
+// first connection to received.
+func newTestRecordingServer(t *testing.T) (string, <-chan []byte) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		data, _ := io.ReadAll(conn)
+		received <- data
+	}()
+	return listener.Addr().String(), received
+}
+
+func TestPrefix_EndToEnd(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+	}{
+		{"base64", "prefix: AAEC"},
+		{"explicit base64", "prefix: AAEC\n  encoding: base64"},
+		{"hex", "prefix: '000102'\n  encoding: hex"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serverAddr, received := newTestRecordingServer(t)
+			node, err := ParseConfigYAML(`
+$type: tcpudp
+tcp:
+  $type: prefix
+  ` + tt.config)
+			require.NoError(t, err)
+			tp, err := newTestTransportProvider().Parse(context.Background(), node)
+			require.NoError(t, err)
+			require.Equal(t, ConnTypeDirect, tp.StreamDialer.ConnType)
+
+			conn, err := tp.StreamDialer.Dial(context.Background(), serverAddr)
+			require.NoError(t, err)
+			n, err := conn.Write([]byte("hello"))
+			require.NoError(t, err)
+			require.Equal(t, 5, n)
+			_, err = conn.Write([]byte(" world"))
+			require.NoError(t, err)
+			conn.Close()
+			require.Equal(t, "\x00\x01\x02hello world", string(<-received))
+		})
+	}
+}
+
+func TestPrefix_InEndpoint(t *testing.T) {
+	// Prefixes only apply to streams, so the endpoint is only used for TCP.
+	node, err := ParseConfigYAML(`
+$type: tcpudp
+tcp:
+  $type: shadowsocks
+  endpoint:
+    $type: dial
+    address: example.com:1234
+    dialer:
+      $type: prefix
+      prefix: '160301'
+      encoding: hex
+  cipher: chacha20-ietf-poly1305
+  secret: SECRET`)
+	require.NoError(t, err)
+	tp, err := newTestTransportProvider().Parse(context.Background(), node)
+	require.NoError(t, err)
+	require.Equal(t, ConnTypeTunneled, tp.StreamDialer.ConnType)
+	require.Equal(t, "example.com:1234", tp.StreamDialer.FirstHop)
+}
+
+func TestPrefix_Invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+	}{
+		{"missing prefix", "encoding: hex"},
+		{"bad base64", "prefix: '!!'"},
+		{"bad hex", "prefix: xyz\n  encoding: hex"},
+		{"unknown encoding", "prefix: AAEC\n  encoding: base32"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := ParseConfigYAML(`
+$type: tcpudp
+tcp:
+  $type: prefix
+  ` + tt.config)
+			require.NoError(t, err)
+			_, err = newTestTransportProvider().Parse(context.Background(), node)
+			require.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,320 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"sync"
+
+	"github.com/Jigsaw-Code/outline-sdk/transport"
+	"github.com/quic-go/quic-go"
+)
+
+// QUICTransportConfig is the format for the quic transport. It tunnels the traffic over the
+// streams of a QUIC connection to the server, which evades blocking that targets TCP.
+type QUICTransportConfig struct {
+	// Endpoint is the UDP address of the server, or the packet endpoint to reach it.
+	Endpoint ConfigNode
+	// SNI is the server name of the TLS handshake, and the name the certificate of the server is
+	// verified against. It defaults to the host of Endpoint.
+	SNI string
+	// ALPN are the application protocols offered in the TLS handshake. They default to h3, so
+	// that the connections look like HTTP/3.
+	ALPN []string
+	// CA and CAFile are the trusted root certificates of the server, as in
+	// [WebsocketEndpointConfig].
+	CA     string
+	CAFile string `yaml:"ca-file"`
+	// Transport is the transport to run over the QUIC streams, without an endpoint. If absent,
+	// the streams are relayed as they are, to the destination the server picks, which only stream
+	// dialers allow: a quic transport at the top level must have one.
+	Transport ConfigNode
+}
+
+// QUICEndpointConfig is the format for the quic endpoint, whose connections are the streams of a
+// QUIC connection. It has the fields of [QUICTransportConfig] but the transport.
+type QUICEndpointConfig struct {
+	Endpoint ConfigNode
+	SNI      string
+	ALPN     []string
+	CA       string
+	CAFile   string `yaml:"ca-file"`
+}
+
+// defaultQUICALPN is the default of [QUICTransportConfig.ALPN].
+var defaultQUICALPN = []string{"h3"}
+
+// errQUICUDP is returned when sending UDP traffic through a quic transport. Relaying it with QUIC
+// datagrams is not supported yet.
+var errQUICUDP = fmt.Errorf("quic transports cannot relay UDP traffic yet: %w", errors.ErrUnsupported)
+
+// errQUICDestination is returned when parsing a quic transport without an inner transport, whose
+// connections would all go to the destination the server picks instead of the requested ones.
+var errQUICDestination = fmt.Errorf("quic transports need an inner transport to reach the requested destinations: %w", errors.ErrUnsupported)
+
+func parseQUICTransport(ctx context.Context, configMap map[string]any, parseSD ParseFunc[*Dialer[transport.StreamConn]]) (*TransportPair, error) {
+	inner, _, err := parseQUICTransportConfig(configMap)
+	if err != nil {
+		return nil, err
+	}
+	if inner == nil {
+		return nil, errQUICDestination
+	}
+	sd, err := parseSD(ctx, inner)
+	if err != nil {
+		return nil, err
+	}
+	// The UDP traffic is not sent directly, it's dropped.
+	return &TransportPair{
+		StreamDialer:   sd,
+		PacketListener: &PacketListener{ConnectionProviderInfo{ConnTypeBlocked, sd.FirstHop}, unsupportedPacketListener{errQUICUDP}},
+	}, nil
+}
+
+func parseQUICStreamDialer(ctx context.Context, configMap map[string]any, parseSD ParseFunc[*Dialer[transport.StreamConn]], parseSE ParseFunc[*Endpoint[transport.StreamConn]]) (*Dialer[transport.StreamConn], error) {
+	inner, endpointConfig, err := parseQUICTransportConfig(configMap)
+	if err != nil {
+		return nil, err
+	}
+	if inner != nil {
+		return parseSD(ctx, inner)
+	}
+	qe, err := parseSE(ctx, endpointConfig)
+	if err != nil {
+		return nil, err
+	}
+	// The server picks the destination, so the address is ignored.
+	dial := func(ctx context.Context, _ string) (transport.StreamConn, error) {
+		return qe.Connect(ctx)
+	}
+	return &Dialer[transport.StreamConn]{ConnectionProviderInfo{ConnTypeTunneled, qe.FirstHop}, dial}, nil
+}
+
+// parseQUICTransportConfig returns the config of the quic endpoint described by configMap, and
+// the config of the inner transport with that endpoint, or nil if there is none.
+func parseQUICTransportConfig(configMap map[string]any) (ConfigNode, map[string]any, error) {
+	var config QUICTransportConfig
+	if err := mapToAny(configMap, &config); err != nil {
+		return nil, nil, fmt.Errorf("invalid config format: %w", err)
+	}
+	endpointConfig := maps.Clone(configMap)
+	endpointConfig["$type"] = "quic"
+	delete(endpointConfig, "transport")
+	if config.Transport == nil {
+		return nil, endpointConfig, nil
+	}
+
+	innerMap, ok := config.Transport.(map[string]any)
+	if !ok {
+		return nil, nil, fmt.Errorf("transport must be a map, found %T", config.Transport)
+	}
+	if _, ok := innerMap["endpoint"]; ok {
+		return nil, nil, errors.New("transport must not have an endpoint, it uses the quic streams")
+	}
+	inner := maps.Clone(innerMap)
+	inner["endpoint"] = endpointConfig
+	return inner, endpointConfig, nil
+}
+
+func parseQUICStreamEndpoint(ctx context.Context, configMap map[string]any, parsePE ParseFunc[*Endpoint[net.Conn]]) (*Endpoint[transport.StreamConn], error) {
+	var config QUICEndpointConfig
+	if err := mapToAny(configMap, &config); err != nil {
+		return nil, fmt.Errorf("invalid config format: %w", err)
+	}
+	if config.Endpoint == nil {
+		return nil, errors.New("endpoint must be specified")
+	}
+	serverName := config.SNI
+	if serverName == "" {
+		serverName = endpointHost(config.Endpoint)
+		if serverName == "" {
+			return nil, errors.New("sni must be specified when the endpoint has no address")
+		}
+	}
+	alpn := config.ALPN
+	if alpn == nil {
+		alpn = defaultQUICALPN
+	}
+	if len(alpn) == 0 {
+		return nil, errors.New("alpn must not be empty")
+	}
+	rootCAs, err := newCertPool(config.CA, config.CAFile)
+	if err != nil {
+		return nil, err
+	}
+	pe, err := parsePE(ctx, config.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse quic endpoint: %w", err)
+	}
+
+	session := &quicSession{
+		connect:   pe.Connect,
+		tlsConfig: &tls.Config{ServerName: serverName, NextProtos: alpn, RootCAs: rootCAs},
+	}
+	return &Endpoint[transport.StreamConn]{
+		ConnectionProviderInfo: ConnectionProviderInfo{ConnTypeTunneled, pe.FirstHop},
+		Connect:                session.openStream,
+	}, nil
+}
+
+// endpointHost returns the host of the address of the endpoint config node, or an empty string
+// if it has none.
+func endpointHost(node ConfigNode) string {
+	var address string
+	switch typed := node.(type) {
+	case string:
+		address = typed
+	case map[string]any:
+		address, _ = typed["address"].(string)
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return ""
+	}
+	return host
+}
+
+// quicSession holds the QUIC connection whose streams are the connections of a quic endpoint.
+// The connection is made on first use, and made again once it's closed, such as after being
+// idle for a while.
+type quicSession struct {
+	connect   ConnectFunc[net.Conn]
+	tlsConfig *tls.Config
+
+	mu   sync.Mutex
+	conn quic.Connection
+}
+
+// openStream opens a stream on the QUIC connection, connecting first if needed.
+func (s *quicSession) openStream(ctx context.Context) (transport.StreamConn, error) {
+	conn, err := s.connection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := conn.OpenStreamSync(ctx)
+	if err != nil && conn.Context().Err() != nil && ctx.Err() == nil {
+		// The connection was closed in the meantime, so try again with a new one.
+		if conn, err = s.connection(ctx); err != nil {
+			return nil, err
+		}
+		stream, err = conn.OpenStreamSync(ctx)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open quic stream: %w", err)
+	}
+	return &quicStreamConn{Stream: stream, conn: conn}, nil
+}
+
+// connection returns the open QUIC connection, or makes a new one.
+func (s *quicSession) connection(ctx context.Context) (quic.Connection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn != nil && s.conn.Context().Err() == nil {
+		return s.conn, nil
+	}
+	packetConn, err := s.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := quic.Dial(ctx, &connectedPacketConn{Conn: packetConn}, packetConn.RemoteAddr(), s.tlsConfig, nil)
+	if observer, ok := ctx.Value(tlsHandshakeObserverKey{}).(TLSHandshakeObserver); ok {
+		if err != nil {
+			observer(s.tlsConfig.ServerName, nil, err)
+		} else {
+			state := conn.ConnectionState().TLS
+			observer(s.tlsConfig.ServerName, &state, nil)
+		}
+	}
+	if err != nil {
+		packetConn.Close()
+		return nil, fmt.Errorf("failed to establish quic connection: %w", err)
+	}
+	go func() {
+		// The QUIC connection doesn't own the packet connection.
+		<-conn.Context().Done()
+		packetConn.Close()
+	}()
+	s.conn = conn
+	return conn, nil
+}
+
+// connectedPacketConn is a [net.PacketConn] that exchanges the packets of a connected
+// [net.Conn], whose remote address is the only peer.
+type connectedPacketConn struct {
+	net.Conn
+}
+
+func (c *connectedPacketConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	n, err := c.Read(p)
+	return n, c.RemoteAddr(), err
+}
+
+func (c *connectedPacketConn) WriteTo(p []byte, _ net.Addr) (int, error) {
+	return c.Write(p)
+}
+
+// SetReadBuffer sets the receive buffer of the socket, if the connection has one. QUIC needs
+// large buffers for fast transfers, and quic-go logs a warning if it can't set them.
+func (c *connectedPacketConn) SetReadBuffer(bytes int) error {
+	if conn, ok := c.Conn.(interface{ SetReadBuffer(int) error }); ok {
+		return conn.SetReadBuffer(bytes)
+	}
+	return nil
+}
+
+// SetWriteBuffer sets the send buffer of the socket, if the connection has one.
+func (c *connectedPacketConn) SetWriteBuffer(bytes int) error {
+	if conn, ok := c.Conn.(interface{ SetWriteBuffer(int) error }); ok {
+		return conn.SetWriteBuffer(bytes)
+	}
+	return nil
+}
+
+// quicStreamConn is a [transport.StreamConn] for a QUIC stream.
+type quicStreamConn struct {
+	quic.Stream
+	conn quic.Connection
+}
+
+var _ transport.StreamConn = (*quicStreamConn)(nil)
+
+func (c *quicStreamConn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+func (c *quicStreamConn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+func (c *quicStreamConn) CloseRead() error {
+	c.Stream.CancelRead(0)
+	return nil
+}
+
+// CloseWrite closes the send direction of the stream. It's what the Close method of QUIC streams
+// does.
+func (c *quicStreamConn) CloseWrite() error {
+	return c.Stream.Close()
+}
+
+func (c *quicStreamConn) Close() error {
+	c.Stream.CancelRead(0)
+	return c.Stream.Close()
+}
@@ -0,0 +1,190 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/quic-go/quic-go"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestQUICEchoServer starts a QUIC server that echoes back what it receives on each stream,
+// and returns its address, the PEM of its certificate, and the number of connections it accepted.
+func newTestQUICEchoServer(t *testing.T) (string, string, *atomic.Int32) {
+	// Borrow the test certificate of httptest, which is valid for 127.0.0.1.
+	httpServer := httptest.NewUnstartedServer(http.NotFoundHandler())
+	httpServer.StartTLS()
+	defer httpServer.Close()
+	tlsConfig := httpServer.TLS.Clone()
+	tlsConfig.NextProtos = []string{"h3"}
+
+	listener, err := quic.ListenAddr("127.0.0.1:0", tlsConfig, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	var conns atomic.Int32
+	go func() {
+		for {
+			conn, err := listener.Accept(context.Background())
+			if err != nil {
+				return
+			}
+			conns.Add(1)
+			go func() {
+				for {
+					stream, err := conn.AcceptStream(context.Background())
+					if err != nil {
+						return
+					}
+					go func() {
+						io.Copy(stream, stream)
+						stream.Close()
+					}()
+				}
+			}()
+		}
+	}()
+	return listener.Addr().String(), certificatePEM(httpServer.Certificate()), &conns
+}
+
+func TestQUIC_StreamDialer(t *testing.T) {
+	address, caPEM, conns := newTestQUICEchoServer(t)
+	tp, err := newTestTransportProvider().Parse(context.Background(), map[string]any{
+		"$type": "tcpudp",
+		"tcp":   map[string]any{"$type": "quic", "endpoint": address, "ca": caPEM},
+	})
+	require.NoError(t, err)
+	require.Equal(t, ConnTypeTunneled, tp.StreamDialer.ConnType)
+	require.Equal(t, address, tp.StreamDialer.FirstHop)
+
+	for _, message := range []string{"hello", "world"} {
+		conn, err := tp.StreamDialer.Dial(context.Background(), "ignored.example.com:443")
+		require.NoError(t, err)
+		_, err = conn.Write([]byte(message))
+		require.NoError(t, err)
+		require.NoError(t, conn.CloseWrite())
+		got, err := io.ReadAll(conn)
+		require.NoError(t, err)
+		require.Equal(t, message, string(got))
+		require.NoError(t, conn.Close())
+	}
+	// The streams share the QUIC connection.
+	require.Equal(t, int32(1), conns.Load())
+}
+
+func TestQUIC_ObservesHandshake(t *testing.T) {
+	address, caPEM, _ := newTestQUICEchoServer(t)
+	tp, err := newTestTransportProvider().Parse(context.Background(), map[string]any{
+		"$type": "tcpudp",
+		"tcp":   map[string]any{"$type": "quic", "endpoint": address, "ca": caPEM, "sni": "example.com"},
+	})
+	require.NoError(t, err)
+
+	var observations []tlsObservation
+	ctx := WithTLSHandshakeObserver(context.Background(), func(serverName string, state *tls.ConnectionState, err error) {
+		observations = append(observations, tlsObservation{serverName, state, err})
+	})
+	conn, err := tp.StreamDialer.Dial(ctx, "ignored.example.com:443")
+	require.NoError(t, err)
+	conn.Close()
+	require.Len(t, observations, 1)
+	require.Equal(t, "example.com", observations[0].serverName)
+	require.NoError(t, observations[0].err)
+	require.Equal(t, "h3", observations[0].state.NegotiatedProtocol)
+}
+
+func TestQUIC_UntrustedCertificate(t *testing.T) {
+	address, _, _ := newTestQUICEchoServer(t)
+	tp, err := newTestTransportProvider().Parse(context.Background(), map[string]any{
+		"$type": "tcpudp",
+		"tcp":   map[string]any{"$type": "quic", "endpoint": address},
+	})
+	require.NoError(t, err)
+
+	_, err = tp.StreamDialer.Dial(context.Background(), "ignored.example.com:443")
+	var certErr *tls.CertificateVerificationError
+	require.ErrorAs(t, err, &certErr)
+}
+
+func TestQUIC_Transport(t *testing.T) {
+	node, err := ParseConfigYAML(`
+$type: quic
+endpoint: quic.example.com:443
+transport:
+  $type: shadowsocks
+  cipher: chacha20-ietf-poly1305
+  secret: SECRET`)
+	require.NoError(t, err)
+	tp, err := newTestTransportProvider().Parse(context.Background(), node)
+	require.NoError(t, err)
+	require.Equal(t, ConnTypeTunneled, tp.StreamDialer.ConnType)
+	require.Equal(t, "quic.example.com:443", tp.StreamDialer.FirstHop)
+	require.Equal(t, ConnTypeBlocked, tp.PacketListener.ConnType)
+	_, err = tp.PacketListener.ListenPacket(context.Background())
+	require.ErrorIs(t, err, errors.ErrUnsupported)
+}
+
+func TestQUIC_TransportWithoutInnerTransport(t *testing.T) {
+	node, err := ParseConfigYAML(`
+$type: quic
+endpoint: quic.example.com:443`)
+	require.NoError(t, err)
+	_, err = newTestTransportProvider().Parse(context.Background(), node)
+	require.ErrorIs(t, err, errQUICDestination)
+	require.ErrorIs(t, err, errors.ErrUnsupported)
+}
+
+func TestQUIC_UDPUnsupported(t *testing.T) {
+	node, err := ParseConfigYAML(`
+$type: tcpudp
+tcp:
+  $type: quic
+  endpoint: quic.example.com:443
+udp:
+  $type: quic
+  endpoint: quic.example.com:443`)
+	require.NoError(t, err)
+	_, err = newTestTransportProvider().Parse(context.Background(), node)
+	require.ErrorIs(t, err, errors.ErrUnsupported)
+}
+
+func TestQUIC_InvalidConfigs(t *testing.T) {
+	for _, config := range []string{
+		// Missing endpoint.
+		`{$type: quic, sni: example.com}`,
+		// No host to derive the SNI from.
+		`{$type: quic, endpoint: {$type: dial, dialer: {$type: shadowsocks}}}`,
+		// Empty ALPN.
+		`{$type: quic, endpoint: quic.example.com:443, alpn: []}`,
+		// Unknown field.
+		`{$type: quic, endpoint: quic.example.com:443, datagrams: true}`,
+		// The inner transport can't have its own endpoint.
+		`{$type: quic, endpoint: quic.example.com:443, transport: {$type: shadowsocks, endpoint: example.com:443, cipher: chacha20-ietf-poly1305, secret: SECRET}}`,
+		// Malformed CA.
+		`{$type: quic, endpoint: quic.example.com:443, ca: not a certificate}`,
+	} {
+		node, err := ParseConfigYAML(config)
+		require.NoError(t, err)
+		_, err = newTestTransportProvider().Parse(context.Background(), node)
+		require.Error(t, err, config)
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"net"
 	"net/url"
 	neturl "net/url"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -47,7 +48,7 @@ type LegacyShadowsocksConfig struct {
 }
 
 func parseShadowsocksTransport(ctx context.Context, config ConfigNode, parseSE ParseFunc[*Endpoint[transport.StreamConn]], parsePE ParseFunc[*Endpoint[net.Conn]]) (*TransportPair, error) {
-	params, err := parseShadowsocksParams(config)
+	params, err := parseShadowsocksParams(ctx, config)
 	if err != nil {
 		return nil, err
 	}
@@ -81,7 +82,7 @@ func parseShadowsocksTransport(ctx context.Context, config ConfigNode, parseSE P
 }
 
 func parseShadowsocksStreamDialer(ctx context.Context, config ConfigNode, parseSE ParseFunc[*Endpoint[transport.StreamConn]]) (*Dialer[transport.StreamConn], error) {
-	params, err := parseShadowsocksParams(config)
+	params, err := parseShadowsocksParams(ctx, config)
 	if err != nil {
 		return nil, err
 	}
@@ -111,7 +112,7 @@ func parseShadowsocksPacketDialer(ctx context.Context, config ConfigNode, parseP
 }
 
 func parseShadowsocksPacketListener(ctx context.Context, config ConfigNode, parsePE ParseFunc[*Endpoint[net.Conn]]) (*PacketListener, error) {
-	params, err := parseShadowsocksParams(config)
+	params, err := parseShadowsocksParams(ctx, config)
 	if err != nil {
 		return nil, err
 	}
@@ -135,14 +136,28 @@ type shadowsocksParams struct {
 	SaltGenerator shadowsocks.SaltGenerator
 }
 
-func parseShadowsocksConfig(node ConfigNode) (*ShadowsocksConfig, error) {
+func parseShadowsocksConfig(ctx context.Context, node ConfigNode) (*ShadowsocksConfig, error) {
 	switch typed := node.(type) {
 	case string:
 		urlConfig, err := neturl.Parse(typed)
 		if err != nil {
 			return nil, fmt.Errorf("string config is not a valid URL")
 		}
-		return parseShadowsocksURL(*urlConfig)
+		config, err := parseShadowsocksURL(ctx, *urlConfig)
+		if err != nil {
+			return nil, err
+		}
+		var ignoredParams []string
+		for param := range urlConfig.Query() {
+			if param != "prefix" {
+				ignoredParams = append(ignoredParams, param)
+			}
+		}
+		slices.Sort(ignoredParams)
+		for _, param := range ignoredParams {
+			addWarning(ctx, fmt.Sprintf("Shadowsocks URL parameter %q is not supported and is ignored", param))
+		}
+		return config, nil
 	case map[string]any:
 		// If the map has an "endpoint" field, we assume the new format.
 		if _, ok := typed["endpoint"]; ok {
@@ -157,6 +172,7 @@ func parseShadowsocksConfig(node ConfigNode) (*ShadowsocksConfig, error) {
 			if err := mapToAny(typed, &config); err != nil {
 				return nil, err
 			}
+			addWarning(ctx, "Shadowsocks fields server, server_port, method and password are deprecated, use endpoint, cipher and secret instead")
 			return &ShadowsocksConfig{
 				Endpoint: net.JoinHostPort(config.Server, strconv.FormatUint(uint64(config.Server_Port), 10)),
 				Cipher:   config.Method,
@@ -171,8 +187,8 @@ func parseShadowsocksConfig(node ConfigNode) (*ShadowsocksConfig, error) {
 	}
 }
 
-func parseShadowsocksParams(node ConfigNode) (*shadowsocksParams, error) {
-	config, err := parseShadowsocksConfig(node)
+func parseShadowsocksParams(ctx context.Context, node ConfigNode) (*shadowsocksParams, error) {
+	config, err := parseShadowsocksConfig(ctx, node)
 	if err != nil {
 		return nil, err
 	}
@@ -213,14 +229,18 @@ func parseStringPrefix(utf8Str string) ([]byte, error) {
 	return rawBytes, nil
 }
 
-func parseShadowsocksURL(url url.URL) (*ShadowsocksConfig, error) {
+func parseShadowsocksURL(ctx context.Context, url url.URL) (*ShadowsocksConfig, error) {
 	// attempt to decode as SIP002 URI format and
 	// fall back to legacy base64 format if decoding fails
 	config, err := parseShadowsocksSIP002URL(url)
 	if err == nil {
 		return config, nil
 	}
-	return parseShadowsocksLegacyBase64URL(url)
+	config, err = parseShadowsocksLegacyBase64URL(url)
+	if err == nil {
+		addWarning(ctx, "Shadowsocks URL uses the deprecated legacy base64 format, use the SIP002 format instead")
+	}
+	return config, err
 }
 
 // cutLust slices s around the last instance of sep, returning the text before
@@ -240,7 +260,12 @@ func parseShadowsocksLegacyBase64URL(url url.URL) (*ShadowsocksConfig, error) {
 	if url.Host == "" {
 		return nil, errors.New("host not specified")
 	}
-	decoded, err := base64.URLEncoding.WithPadding(base64.NoPadding).DecodeString(url.Host)
+	// Keys are encoded with either alphabet, and often padded.
+	encodedHost := strings.TrimRight(url.Host, "=")
+	decoded, err := base64.RawURLEncoding.DecodeString(encodedHost)
+	if err != nil {
+		decoded, err = base64.RawStdEncoding.DecodeString(encodedHost)
+	}
 	if err != nil {
 		// If decoding fails, return the original url with error
 		return nil, fmt.Errorf("failed to decode host string [%v]: %w", url.String(), err)
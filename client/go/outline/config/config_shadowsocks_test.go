@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"net"
 	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/Jigsaw-Code/outline-sdk/transport"
@@ -31,7 +32,7 @@ func parseFromYAMLText(configText string) (*ShadowsocksConfig, error) {
 	if err != nil {
 		return nil, err
 	}
-	return parseShadowsocksConfig(node)
+	return parseShadowsocksConfig(context.Background(), node)
 }
 
 func TestParseShadowsocksConfig_URL(t *testing.T) {
@@ -61,6 +62,16 @@ func TestParseShadowsocksConfig_URL(t *testing.T) {
 		require.Equal(t, "SECRET/!@#@example.com:1234?prefix=", config.Secret)
 	})
 
+	t.Run("Fully Base64 Encoded With Padding", func(t *testing.T) {
+		encoded := base64.StdEncoding.EncodeToString([]byte("chacha20-ietf-poly1305:pa+sss@example.com:1234"))
+		require.True(t, strings.HasSuffix(encoded, "="))
+		config, err := parseFromYAMLText("ss://" + encoded + "#outline-123")
+		require.NoError(t, err)
+		require.Equal(t, "example.com:1234", config.Endpoint)
+		require.Equal(t, "chacha20-ietf-poly1305", config.Cipher)
+		require.Equal(t, "pa+sss", config.Secret)
+	})
+
 	t.Run("User Info Base64 Encoded", func(t *testing.T) {
 		encoded := base64.URLEncoding.WithPadding(base64.NoPadding).EncodeToString([]byte("chacha20-ietf-poly1305:SECRET/!@#"))
 		config, err := parseFromYAMLText("ss://" + string(encoded) + "@example.com:1234?prefix=HTTP%2F1.1%20" + "#outline-123")
@@ -95,13 +106,13 @@ func TestParseShadowsocksConfig_URL(t *testing.T) {
 
 	t.Run("Invalid Cipher Fails", func(t *testing.T) {
 		configString := "ss://chacha20-ietf-poly13051234567@example.com:1234"
-		_, err := parseShadowsocksParams(configString)
+		_, err := parseShadowsocksParams(context.Background(), configString)
 		require.Error(t, err)
 	})
 
 	t.Run("Unsupported Cipher Fails", func(t *testing.T) {
 		configString := "ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwnTpLeTUyN2duU3FEVFB3R0JpQ1RxUnlT@example.com:1234"
-		_, err := parseShadowsocksParams(configString)
+		_, err := parseShadowsocksParams(context.Background(), configString)
 		require.Error(t, err)
 	})
 }
@@ -187,3 +198,44 @@ func TestParseShadowsocksConfig_YAML(t *testing.T) {
 		require.Error(t, err)
 	})
 }
+
+func TestParseShadowsocksConfig_Warnings(t *testing.T) {
+	parseWithWarnings := func(configText string) []string {
+		node, err := ParseConfigYAML(configText)
+		require.NoError(t, err)
+		var warnings Warnings
+		_, err = parseShadowsocksConfig(WithWarnings(context.Background(), &warnings), node)
+		require.NoError(t, err)
+		return warnings.List()
+	}
+
+	t.Run("SIP002", func(t *testing.T) {
+		require.Empty(t, parseWithWarnings("ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpTRUNSRVQ@example.com:1234?prefix=HTTP"))
+	})
+
+	t.Run("Legacy Base64 URL", func(t *testing.T) {
+		encoded := base64.URLEncoding.WithPadding(base64.NoPadding).EncodeToString([]byte("chacha20-ietf-poly1305:SECRET@example.com:1234"))
+		warnings := parseWithWarnings("ss://" + encoded)
+		require.Equal(t, []string{"Shadowsocks URL uses the deprecated legacy base64 format, use the SIP002 format instead"}, warnings)
+	})
+
+	t.Run("Ignored URL Parameters", func(t *testing.T) {
+		warnings := parseWithWarnings("ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpTRUNSRVQ@example.com:1234?plugin=obfs-local%3Bobfs-host%3DSECRET&outline=1")
+		require.Equal(t, []string{
+			`Shadowsocks URL parameter "outline" is not supported and is ignored`,
+			`Shadowsocks URL parameter "plugin" is not supported and is ignored`,
+		}, warnings)
+	})
+
+	t.Run("Legacy Fields", func(t *testing.T) {
+		warnings := parseWithWarnings(`{"server": "example.com", "server_port": 1234, "method": "chacha20-ietf-poly1305", "password": "SECRET"}`)
+		require.Len(t, warnings, 1)
+		require.Contains(t, warnings[0], "deprecated")
+		require.NotContains(t, warnings[0], "SECRET")
+	})
+
+	t.Run("No Collector", func(t *testing.T) {
+		_, err := parseFromYAMLText(`{"server": "example.com", "server_port": 1234, "method": "chacha20-ietf-poly1305", "password": "SECRET"}`)
+		require.NoError(t, err)
+	})
+}
@@ -0,0 +1,134 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/Jigsaw-Code/outline-sdk/transport"
+	"github.com/Jigsaw-Code/outline-sdk/transport/socks5"
+)
+
+// SOCKS5 authentication methods.
+const (
+	socks5AuthNone     = "none"
+	socks5AuthPassword = "password"
+)
+
+// SOCKS5Config is the format for the SOCKS5 config. It specifies a SOCKS5 proxy that relays TCP
+// connections with the CONNECT command, and UDP traffic with the UDP ASSOCIATE command.
+type SOCKS5Config struct {
+	// Endpoint is the proxy to connect to.
+	Endpoint ConfigNode
+	// Auth is the authentication method, "none" or "password". If empty, it's "password" if
+	// Username or Password are set, and "none" otherwise.
+	Auth string
+	// Username and Password are the credentials of the "password" method.
+	Username string
+	Password string
+}
+
+func parseSOCKS5Transport(ctx context.Context, configMap map[string]any, parseSE ParseFunc[*Endpoint[transport.StreamConn]], parsePD ParseFunc[*Dialer[net.Conn]]) (*TransportPair, error) {
+	client, firstHop, err := newSOCKS5Client(ctx, configMap, parseSE, parsePD)
+	if err != nil {
+		return nil, err
+	}
+	return &TransportPair{
+		&Dialer[transport.StreamConn]{ConnectionProviderInfo{ConnTypeTunneled, firstHop}, client.DialStream},
+		&PacketListener{ConnectionProviderInfo{ConnTypeTunneled, firstHop}, client},
+	}, nil
+}
+
+func parseSOCKS5StreamDialer(ctx context.Context, configMap map[string]any, parseSE ParseFunc[*Endpoint[transport.StreamConn]], parsePD ParseFunc[*Dialer[net.Conn]]) (*Dialer[transport.StreamConn], error) {
+	client, firstHop, err := newSOCKS5Client(ctx, configMap, parseSE, parsePD)
+	if err != nil {
+		return nil, err
+	}
+	return &Dialer[transport.StreamConn]{ConnectionProviderInfo{ConnTypeTunneled, firstHop}, client.DialStream}, nil
+}
+
+func parseSOCKS5PacketDialer(ctx context.Context, configMap map[string]any, parseSE ParseFunc[*Endpoint[transport.StreamConn]], parsePD ParseFunc[*Dialer[net.Conn]]) (*Dialer[net.Conn], error) {
+	pl, err := parseSOCKS5PacketListener(ctx, configMap, parseSE, parsePD)
+	if err != nil {
+		return nil, err
+	}
+	pd := transport.PacketListenerDialer{Listener: pl}
+	return &Dialer[net.Conn]{ConnectionProviderInfo{ConnTypeTunneled, pl.FirstHop}, pd.DialPacket}, nil
+}
+
+func parseSOCKS5PacketListener(ctx context.Context, configMap map[string]any, parseSE ParseFunc[*Endpoint[transport.StreamConn]], parsePD ParseFunc[*Dialer[net.Conn]]) (*PacketListener, error) {
+	client, firstHop, err := newSOCKS5Client(ctx, configMap, parseSE, parsePD)
+	if err != nil {
+		return nil, err
+	}
+	return &PacketListener{ConnectionProviderInfo{ConnTypeTunneled, firstHop}, client}, nil
+}
+
+// newSOCKS5Client returns a SOCKS5 client for the config in configMap, and its first hop.
+// The UDP packets to the relay address returned by the proxy are sent with the default
+// packet dialer.
+func newSOCKS5Client(ctx context.Context, configMap map[string]any, parseSE ParseFunc[*Endpoint[transport.StreamConn]], parsePD ParseFunc[*Dialer[net.Conn]]) (*socks5.Client, string, error) {
+	var config SOCKS5Config
+	if err := mapToAny(configMap, &config); err != nil {
+		return nil, "", fmt.Errorf("invalid config format: %w", err)
+	}
+	if config.Endpoint == nil {
+		return nil, "", errors.New("endpoint must be specified")
+	}
+
+	auth := config.Auth
+	if auth == "" {
+		auth = socks5AuthNone
+		if config.Username != "" || config.Password != "" {
+			auth = socks5AuthPassword
+		}
+	}
+	switch auth {
+	case socks5AuthNone:
+		if config.Username != "" || config.Password != "" {
+			return nil, "", fmt.Errorf("SOCKS5 auth method %q does not take a username or password", auth)
+		}
+	case socks5AuthPassword:
+		if config.Username == "" || config.Password == "" {
+			return nil, "", fmt.Errorf("SOCKS5 auth method %q requires a username and a password", auth)
+		}
+	default:
+		return nil, "", fmt.Errorf("unsupported SOCKS5 auth method %q, must be %q or %q", auth, socks5AuthNone, socks5AuthPassword)
+	}
+
+	se, err := parseSE(ctx, config.Endpoint)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create StreamEndpoint: %w", err)
+	}
+	client, err := socks5.NewClient(transport.FuncStreamEndpoint(se.Connect))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create SOCKS5 client: %w", err)
+	}
+	if auth == socks5AuthPassword {
+		if err := client.SetCredentials([]byte(config.Username), []byte(config.Password)); err != nil {
+			return nil, "", fmt.Errorf("invalid SOCKS5 credentials: %w", err)
+		}
+	}
+
+	pd, err := parsePD(ctx, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create PacketDialer: %w", err)
+	}
+	client.EnablePacket(transport.FuncPacketDialer(pd.Dial))
+	return client, se.FirstHop, nil
+}
@@ -0,0 +1,328 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newTestSOCKS5Proxy returns the address of a SOCKS5 proxy that supports the CONNECT and
+// UDP ASSOCIATE commands, and requires the given credentials if user is not empty.
+func newTestSOCKS5Proxy(t *testing.T, user, pass string) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSOCKS5(conn, user, pass)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func serveSOCKS5(conn net.Conn, user, pass string) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	// Method negotiation.
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return
+	}
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(reader, methods); err != nil {
+		return
+	}
+	if user == "" {
+		conn.Write([]byte{5, 0})
+	} else {
+		conn.Write([]byte{5, 2})
+		// Username/password sub-negotiation.
+		version := make([]byte, 1)
+		if _, err := io.ReadFull(reader, version); err != nil {
+			return
+		}
+		gotUser, err := readSOCKS5String(reader)
+		if err != nil {
+			return
+		}
+		gotPass, err := readSOCKS5String(reader)
+		if err != nil {
+			return
+		}
+		if gotUser != user || gotPass != pass {
+			conn.Write([]byte{1, 1})
+			return
+		}
+		conn.Write([]byte{1, 0})
+	}
+
+	// Request.
+	request := make([]byte, 3)
+	if _, err := io.ReadFull(reader, request); err != nil {
+		return
+	}
+	address, err := readSOCKS5Address(reader)
+	if err != nil {
+		return
+	}
+	switch request[1] {
+	case 1: // CONNECT
+		target, err := net.Dial("tcp", address)
+		if err != nil {
+			conn.Write(appendSOCKS5Reply(nil, 5, netip.AddrPortFrom(netip.IPv4Unspecified(), 0)))
+			return
+		}
+		defer target.Close()
+		conn.Write(appendSOCKS5Reply(nil, 0, netip.MustParseAddrPort(target.LocalAddr().String())))
+		go io.Copy(target, reader)
+		io.Copy(conn, target)
+	case 3: // UDP ASSOCIATE
+		relay, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			return
+		}
+		defer relay.Close()
+		conn.Write(appendSOCKS5Reply(nil, 0, netip.MustParseAddrPort(relay.LocalAddr().String())))
+		go relaySOCKS5Packets(relay)
+		// The association lasts as long as the TCP connection.
+		io.Copy(io.Discard, reader)
+	default:
+		conn.Write(appendSOCKS5Reply(nil, 7, netip.AddrPortFrom(netip.IPv4Unspecified(), 0)))
+	}
+}
+
+// relaySOCKS5Packets relays the packets of a single client to their destinations and back.
+func relaySOCKS5Packets(relay net.PacketConn) {
+	buf := make([]byte, 65507)
+	var client net.Addr
+	for {
+		n, from, err := relay.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if client == nil || from.String() == client.String() {
+			client = from
+			// RSV, RSV, FRAG, then the destination address.
+			reader := bytes.NewReader(buf[3:n])
+			address, err := readSOCKS5Address(reader)
+			if err != nil {
+				continue
+			}
+			payload, _ := io.ReadAll(reader)
+			target, err := net.ResolveUDPAddr("udp", address)
+			if err != nil {
+				continue
+			}
+			relay.WriteTo(payload, target)
+			continue
+		}
+		source := netip.MustParseAddrPort(from.String())
+		packet := appendSOCKS5Address([]byte{0, 0, 0}, source)
+		relay.WriteTo(append(packet, buf[:n]...), client)
+	}
+}
+
+func readSOCKS5String(r io.Reader) (string, error) {
+	length := make([]byte, 1)
+	if _, err := io.ReadFull(r, length); err != nil {
+		return "", err
+	}
+	value := make([]byte, length[0])
+	_, err := io.ReadFull(r, value)
+	return string(value), err
+}
+
+func readSOCKS5Address(r io.Reader) (string, error) {
+	atyp := make([]byte, 1)
+	if _, err := io.ReadFull(r, atyp); err != nil {
+		return "", err
+	}
+	var host string
+	switch atyp[0] {
+	case 1, 4:
+		ip := make([]byte, 4)
+		if atyp[0] == 4 {
+			ip = make([]byte, 16)
+		}
+		if _, err := io.ReadFull(r, ip); err != nil {
+			return "", err
+		}
+		host = net.IP(ip).String()
+	case 3:
+		name, err := readSOCKS5String(r)
+		if err != nil {
+			return "", err
+		}
+		host = name
+	}
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(r, port); err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))), nil
+}
+
+func appendSOCKS5Address(b []byte, address netip.AddrPort) []byte {
+	b = append(b, 1)
+	ip := address.Addr().As4()
+	b = append(b, ip[:]...)
+	return binary.BigEndian.AppendUint16(b, address.Port())
+}
+
+func appendSOCKS5Reply(b []byte, code byte, bound netip.AddrPort) []byte {
+	return appendSOCKS5Address(append(b, 5, code, 0), bound)
+}
+
+func TestSOCKS5_StreamDialer(t *testing.T) {
+	proxyAddr := newTestSOCKS5Proxy(t, "user", "pass")
+	echoAddr := newTestEchoServer(t)
+
+	node, err := ParseConfigYAML(`
+$type: socks5
+endpoint: ` + proxyAddr + `
+username: user
+password: pass`)
+	require.NoError(t, err)
+	tp, err := newTestTransportProvider().Parse(context.Background(), node)
+	require.NoError(t, err)
+	require.Equal(t, ConnTypeTunneled, tp.StreamDialer.ConnType)
+	require.Equal(t, proxyAddr, tp.StreamDialer.FirstHop)
+
+	conn, err := tp.StreamDialer.Dial(context.Background(), echoAddr)
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("hello"))
+	require.NoError(t, err)
+	buf := make([]byte, 5)
+	_, err = io.ReadFull(conn, buf)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(buf))
+}
+
+func TestSOCKS5_WrongCredentials(t *testing.T) {
+	proxyAddr := newTestSOCKS5Proxy(t, "user", "pass")
+
+	node, err := ParseConfigYAML(`
+$type: socks5
+endpoint: ` + proxyAddr + `
+username: user
+password: wrong`)
+	require.NoError(t, err)
+	tp, err := newTestTransportProvider().Parse(context.Background(), node)
+	require.NoError(t, err)
+
+	_, err = tp.StreamDialer.Dial(context.Background(), "example.com:80")
+	require.Error(t, err)
+}
+
+func TestSOCKS5_PacketListener(t *testing.T) {
+	proxyAddr := newTestSOCKS5Proxy(t, "", "")
+	echo, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer echo.Close()
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, from, err := echo.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			echo.WriteTo(buf[:n], from)
+		}
+	}()
+
+	node, err := ParseConfigYAML("{$type: socks5, endpoint: '" + proxyAddr + "'}")
+	require.NoError(t, err)
+	tp, err := newTestTransportProvider().Parse(context.Background(), node)
+	require.NoError(t, err)
+	require.Equal(t, ConnTypeTunneled, tp.PacketListener.ConnType)
+	require.Equal(t, proxyAddr, tp.PacketListener.FirstHop)
+
+	conn, err := tp.PacketListener.ListenPacket(context.Background())
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.WriteTo([]byte("hello"), echo.LocalAddr())
+	require.NoError(t, err)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 1024)
+	n, from, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(buf[:n]))
+	require.Equal(t, echo.LocalAddr().String(), from.String())
+}
+
+func TestSOCKS5_BaseDialerOfShadowsocks(t *testing.T) {
+	node, err := ParseConfigYAML(`
+$type: shadowsocks
+endpoint:
+  $type: dial
+  address: ss.example.com:4321
+  dialer:
+    $type: socks5
+    endpoint: socks.example.com:1080
+    username: user
+    password: pass
+cipher: chacha20-ietf-poly1305
+secret: SECRET`)
+	require.NoError(t, err)
+	tp, err := newTestTransportProvider().Parse(context.Background(), node)
+	require.NoError(t, err)
+	require.Equal(t, ConnTypeTunneled, tp.StreamDialer.ConnType)
+	require.Equal(t, "socks.example.com:1080", tp.StreamDialer.FirstHop)
+	require.Equal(t, ConnTypeTunneled, tp.PacketListener.ConnType)
+	require.Equal(t, "socks.example.com:1080", tp.PacketListener.FirstHop)
+}
+
+func TestSOCKS5_InvalidConfigs(t *testing.T) {
+	for _, config := range []string{
+		// Missing endpoint.
+		`{$type: socks5}`,
+		// Unsupported auth method.
+		`{$type: socks5, endpoint: example.com:1080, auth: gssapi}`,
+		// Incomplete credentials.
+		`{$type: socks5, endpoint: example.com:1080, username: user}`,
+		`{$type: socks5, endpoint: example.com:1080, auth: password}`,
+		// Credentials without auth.
+		`{$type: socks5, endpoint: example.com:1080, auth: none, username: user, password: pass}`,
+	} {
+		node, err := ParseConfigYAML(config)
+		require.NoError(t, err)
+		_, err = newTestTransportProvider().Parse(context.Background(), node)
+		require.Error(t, err, config)
+	}
+}
+
+func TestSOCKS5_UnsupportedAuthError(t *testing.T) {
+	node, err := ParseConfigYAML(`{$type: socks5, endpoint: example.com:1080, auth: gssapi}`)
+	require.NoError(t, err)
+	_, err = newTestTransportProvider().Parse(context.Background(), node)
+	require.ErrorContains(t, err, `unsupported SOCKS5 auth method "gssapi"`)
+}
@@ -16,7 +16,10 @@ package config
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"maps"
 	"net"
 	"net/http"
 	"net/url"
@@ -27,10 +30,120 @@ import (
 )
 
 type WebsocketEndpointConfig struct {
-	URL      string
+	URL string
+	// Headers are added to the HTTP upgrade request.
+	Headers map[string]string
+	// CA is a PEM bundle of the root certificates that verify the server, instead of the system
+	// roots. It requires a wss or https URL.
+	CA string
+	// CAFile is the path of a PEM file with root certificates, like CA. If both are set, the
+	// certificates of both are trusted.
+	CAFile   string `yaml:"ca-file"`
 	Endpoint any
 }
 
+// WebsocketTransportConfig is the format for the websocket transport. It tunnels the traffic over
+// WebSocket connections to URL, which lets servers behind a CDN evade SNI filtering.
+type WebsocketTransportConfig struct {
+	// URL is the WebSocket URL. TLS is used for the wss and https schemes.
+	URL string
+	// Headers are added to the HTTP upgrade request.
+	Headers map[string]string
+	// CA and CAFile are the trusted root certificates of the server, as in
+	// [WebsocketEndpointConfig].
+	CA     string
+	CAFile string `yaml:"ca-file"`
+	// Endpoint is how to reach the host of URL. It defaults to a direct connection.
+	Endpoint ConfigNode
+	// Transport is the transport to run over the WebSocket connections, without an endpoint.
+	// If absent, the connections are relayed as they are, to the destination the server of
+	// URL picks, and UDP is not supported.
+	Transport ConfigNode
+}
+
+// errWebsocketUDP is returned when parsing a websocket transport without an inner transport for UDP.
+var errWebsocketUDP = fmt.Errorf("websocket transports need an inner transport to relay UDP traffic: %w", errors.ErrUnsupported)
+
+func parseWebsocketTransport(ctx context.Context, configMap map[string]any, parseTransport ParseFunc[*TransportPair]) (*TransportPair, error) {
+	inner, _, err := parseWebsocketTransportConfig(configMap)
+	if err != nil {
+		return nil, err
+	}
+	if inner == nil {
+		return nil, errWebsocketUDP
+	}
+	return parseTransport(ctx, inner)
+}
+
+func parseWebsocketStreamDialer(ctx context.Context, configMap map[string]any, parseSD ParseFunc[*Dialer[transport.StreamConn]], parseSE ParseFunc[*Endpoint[transport.StreamConn]]) (*Dialer[transport.StreamConn], error) {
+	inner, endpointConfig, err := parseWebsocketTransportConfig(configMap)
+	if err != nil {
+		return nil, err
+	}
+	if inner != nil {
+		return parseSD(ctx, inner)
+	}
+	we, err := parseSE(ctx, endpointConfig)
+	if err != nil {
+		return nil, err
+	}
+	// The server of the URL picks the destination, so the address is ignored.
+	dial := func(ctx context.Context, _ string) (transport.StreamConn, error) {
+		return we.Connect(ctx)
+	}
+	return &Dialer[transport.StreamConn]{ConnectionProviderInfo{ConnTypeTunneled, we.FirstHop}, dial}, nil
+}
+
+func parseWebsocketPacketListener(ctx context.Context, configMap map[string]any, parsePL ParseFunc[*PacketListener]) (*PacketListener, error) {
+	inner, _, err := parseWebsocketTransportConfig(configMap)
+	if err != nil {
+		return nil, err
+	}
+	if inner == nil {
+		return nil, errWebsocketUDP
+	}
+	return parsePL(ctx, inner)
+}
+
+// parseWebsocketTransportConfig returns the config of the websocket endpoint described by
+// configMap, and the config of the inner transport with that endpoint, or nil if there is none.
+func parseWebsocketTransportConfig(configMap map[string]any) (ConfigNode, map[string]any, error) {
+	var config WebsocketTransportConfig
+	if err := mapToAny(configMap, &config); err != nil {
+		return nil, nil, fmt.Errorf("invalid config format: %w", err)
+	}
+	if config.URL == "" {
+		return nil, nil, errors.New("url must be specified")
+	}
+	endpointConfig := map[string]any{"$type": "websocket", "url": config.URL}
+	if config.Headers != nil {
+		endpointConfig["headers"] = config.Headers
+	}
+	if config.CA != "" {
+		endpointConfig["ca"] = config.CA
+	}
+	if config.CAFile != "" {
+		endpointConfig["ca-file"] = config.CAFile
+	}
+	if config.Endpoint != nil {
+		endpointConfig["endpoint"] = config.Endpoint
+	}
+	if config.Transport == nil {
+		return nil, endpointConfig, nil
+	}
+
+	innerMap, ok := config.Transport.(map[string]any)
+	if !ok {
+		return nil, nil, fmt.Errorf("transport must be a map, found %T", config.Transport)
+	}
+	if _, ok := innerMap["endpoint"]; ok {
+		return nil, nil, errors.New("transport must not have an endpoint, it uses the websocket")
+	}
+	inner := maps.Clone(innerMap)
+	inner["endpoint"] = endpointConfig
+	return inner, endpointConfig, nil
+}
+
 func parseWebsocketStreamEndpoint(ctx context.Context, configMap map[string]any, parseSE ParseFunc[*Endpoint[transport.StreamConn]]) (*Endpoint[transport.StreamConn], error) {
 	return parseWebsocketEndpoint[transport.StreamConn](ctx, configMap, parseSE, websocket.NewStreamEndpoint)
 }
@@ -63,6 +176,15 @@ func parseWebsocketEndpoint[ConnType any](ctx context.Context, configMap map[str
 		}
 	}
 
+	useTLS := url.Scheme == "wss" || url.Scheme == "https"
+	rootCAs, err := newCertPool(config.CA, config.CAFile)
+	if err != nil {
+		return nil, err
+	}
+	if rootCAs != nil && !useTLS {
+		return nil, errors.New("ca and ca-file require a wss or https url")
+	}
+
 	if config.Endpoint == nil {
 		config.Endpoint = net.JoinHostPort(url.Hostname(), port)
 	}
@@ -74,7 +196,17 @@ func parseWebsocketEndpoint[ConnType any](ctx context.Context, configMap map[str
 	headers := http.Header(map[string][]string{
 		"User-Agent": {fmt.Sprintf("Outline (%s; %s; %s)", runtime.GOOS, runtime.GOARCH, runtime.Version())},
 	})
-	connect, err := newWE(url.String(), transport.FuncStreamEndpoint(se.Connect), websocket.WithHTTPHeaders(headers))
+	for name, value := range config.Headers {
+		headers.Set(name, value)
+	}
+	var streamEndpoint transport.StreamEndpoint = transport.FuncStreamEndpoint(se.Connect)
+	if useTLS {
+		// We make the TLS connection ourselves, so that the handshake can be observed.
+		tlsConfig := &tls.Config{ServerName: url.Hostname(), NextProtos: []string{"http/1.1"}, RootCAs: rootCAs}
+		streamEndpoint = newTLSStreamEndpoint(se.Connect, tlsConfig)
+		url.Scheme = "ws"
+	}
+	connect, err := newWE(url.String(), streamEndpoint, websocket.WithHTTPHeaders(headers))
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,157 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+	"net/url"
+	"strings"
+)
+
+// Redacted replaces the credentials in the output of [DescribeTransportConfig].
+const Redacted = "REDACTED"
+
+// sensitiveFieldParts are the parts of the names of the fields and HTTP headers that hold
+// credentials, in lower case.
+var sensitiveFieldParts = []string{"secret", "password", "passwd", "username", "token", "key", "cert", "cookie", "authorization", "credential"}
+
+// DescribeTransportConfig returns a copy of the transport config node in the form the parsers
+// resolve it to, with the credentials masked, so that operators can share it to debug issues:
+//   - Shadowsocks configs, including ss:// URLs, legacy fields and the transports without a
+//     $type, are expanded to their endpoint, cipher, secret and prefix fields.
+//   - SOCKS5 configs get the auth method they default to.
+//   - The values of the fields and HTTP headers that hold credentials, such as secrets,
+//     passwords, usernames, keys, certificates, tokens and cookies, are replaced with [Redacted].
+//   - The user info, path and query values of URLs, which may hold tokens, are replaced with
+//     [Redacted] too. Only the scheme, host and query parameter names are kept.
+//
+// Configs that are not valid are described as well as possible. node is not modified.
+func DescribeTransportConfig(node ConfigNode) ConfigNode {
+	return describeNode(node, true)
+}
+
+// describeNode implements [DescribeTransportConfig]. isTransport tells whether node is in the
+// place of a transport, where configs without a $type are Shadowsocks configs.
+func describeNode(node ConfigNode, isTransport bool) ConfigNode {
+	switch typed := node.(type) {
+	case string:
+		if strings.HasPrefix(strings.ToLower(typed), "ss://") {
+			return describeShadowsocks(typed)
+		}
+		if strings.Contains(typed, "://") {
+			return redactURL(typed)
+		}
+		return typed
+	case []any:
+		described := make([]any, len(typed))
+		for i, item := range typed {
+			described[i] = describeNode(item, false)
+		}
+		return described
+	case map[string]any:
+		configType, _ := typed["$type"].(string)
+		if configType == "shadowsocks" || (isTransport && configType == "") {
+			return describeShadowsocks(typed)
+		}
+		described := make(map[string]any, len(typed)+1)
+		for key, value := range typed {
+			switch {
+			case value != nil && value != "" && isSensitiveField(key):
+				described[key] = Redacted
+			default:
+				// The inner transport of websocket transports is a transport too.
+				described[key] = describeNode(value, key == "transport")
+			}
+		}
+		if configType == "socks5" && typed["auth"] == nil {
+			described["auth"] = socks5AuthNone
+			if typed["username"] != nil || typed["password"] != nil {
+				described["auth"] = socks5AuthPassword
+			}
+		}
+		return described
+	default:
+		return node
+	}
+}
+
+// describeShadowsocks returns the description of the Shadowsocks config node, or a redacted copy
+// of it if it's not valid.
+func describeShadowsocks(node ConfigNode) ConfigNode {
+	config, err := parseShadowsocksConfig(context.Background(), node)
+	if err != nil {
+		if _, isString := node.(string); isString {
+			return Redacted
+		}
+		typed, _ := node.(map[string]any)
+		described := make(map[string]any, len(typed))
+		for key, value := range typed {
+			if key == "$type" {
+				described[key] = value
+			} else if isSensitiveField(key) {
+				described[key] = Redacted
+			} else {
+				described[key] = describeNode(value, false)
+			}
+		}
+		return described
+	}
+	described := map[string]any{
+		"$type":    "shadowsocks",
+		"endpoint": describeNode(config.Endpoint, false),
+		"cipher":   config.Cipher,
+		"secret":   Redacted,
+	}
+	if config.Prefix != "" {
+		described["prefix"] = config.Prefix
+	}
+	return described
+}
+
+// isSensitiveField returns whether the field or HTTP header name holds credentials.
+func isSensitiveField(name string) bool {
+	name = strings.ToLower(name)
+	for _, part := range sensitiveFieldParts {
+		if strings.Contains(name, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactURL returns rawURL with its user info, path and query values replaced with [Redacted],
+// or [Redacted] if it's not a valid URL.
+func redactURL(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		return Redacted
+	}
+	redacted := &url.URL{Scheme: parsed.Scheme, Host: parsed.Host}
+	if parsed.User != nil {
+		redacted.User = url.User(Redacted)
+	}
+	if parsed.Path != "" && parsed.Path != "/" {
+		redacted.Path = "/" + Redacted
+	} else {
+		redacted.Path = parsed.Path
+	}
+	if query := parsed.Query(); len(query) > 0 {
+		for name := range query {
+			query[name] = []string{Redacted}
+		}
+		redacted.RawQuery = query.Encode()
+	}
+	return redacted.String()
+}
@@ -127,6 +127,9 @@ func NewDefaultTransportProvider(tcpDialer transport.StreamDialer, udpDialer tra
 	packetListeners.RegisterSubParser("shadowsocks", func(ctx context.Context, input map[string]any) (*PacketListener, error) {
 		return parseShadowsocksPacketListener(ctx, input, packetEndpoints.Parse)
 	})
+	transports.RegisterSubParser("shadowsocks", func(ctx context.Context, input map[string]any) (*TransportPair, error) {
+		return parseShadowsocksTransport(ctx, input, streamEndpoints.Parse, packetEndpoints.Parse)
+	})
 
 	streamEndpoints.RegisterSubParser("websocket", func(ctx context.Context, input map[string]any) (*Endpoint[transport.StreamConn], error) {
 		return parseWebsocketStreamEndpoint(ctx, input, streamEndpoints.Parse)
@@ -135,10 +138,68 @@ func NewDefaultTransportProvider(tcpDialer transport.StreamDialer, udpDialer tra
 		return parseWebsocketPacketEndpoint(ctx, input, streamEndpoints.Parse)
 	})
 
+	// WebSocket transport support. It relays UDP traffic only with an inner transport.
+	streamDialers.RegisterSubParser("websocket", func(ctx context.Context, input map[string]any) (*Dialer[transport.StreamConn], error) {
+		return parseWebsocketStreamDialer(ctx, input, streamDialers.Parse, streamEndpoints.Parse)
+	})
+	packetListeners.RegisterSubParser("websocket", func(ctx context.Context, input map[string]any) (*PacketListener, error) {
+		return parseWebsocketPacketListener(ctx, input, packetListeners.Parse)
+	})
+	transports.RegisterSubParser("websocket", func(ctx context.Context, input map[string]any) (*TransportPair, error) {
+		return parseWebsocketTransport(ctx, input, transports.Parse)
+	})
+
 	// Support distinct TCP and UDP configuration.
 	transports.RegisterSubParser("tcpudp", func(ctx context.Context, config map[string]any) (*TransportPair, error) {
 		return parseTCPUDPTransportPair(ctx, config, streamDialers.Parse, packetListeners.Parse)
 	})
 
+	// HTTP CONNECT support. It only relays TCP traffic.
+	streamDialers.RegisterSubParser("http-connect", func(ctx context.Context, input map[string]any) (*Dialer[transport.StreamConn], error) {
+		return parseHTTPConnectStreamDialer(ctx, input, streamEndpoints.Parse)
+	})
+	transports.RegisterSubParser("http-connect", func(ctx context.Context, input map[string]any) (*TransportPair, error) {
+		return parseHTTPConnectTransport(ctx, input, streamEndpoints.Parse)
+	})
+
+	// QUIC transport support. It relays TCP traffic over QUIC streams, but not UDP traffic yet.
+	streamEndpoints.RegisterSubParser("quic", func(ctx context.Context, input map[string]any) (*Endpoint[transport.StreamConn], error) {
+		return parseQUICStreamEndpoint(ctx, input, packetEndpoints.Parse)
+	})
+	streamDialers.RegisterSubParser("quic", func(ctx context.Context, input map[string]any) (*Dialer[transport.StreamConn], error) {
+		return parseQUICStreamDialer(ctx, input, streamDialers.Parse, streamEndpoints.Parse)
+	})
+	packetListeners.RegisterSubParser("quic", func(ctx context.Context, input map[string]any) (*PacketListener, error) {
+		return nil, errQUICUDP
+	})
+	transports.RegisterSubParser("quic", func(ctx context.Context, input map[string]any) (*TransportPair, error) {
+		return parseQUICTransport(ctx, input, streamDialers.Parse)
+	})
+
+	// Prefix support. It wraps another stream dialer.
+	streamDialers.RegisterSubParser("prefix", func(ctx context.Context, input map[string]any) (*Dialer[transport.StreamConn], error) {
+		return parsePrefixStreamDialer(ctx, input, streamDialers.Parse)
+	})
+
+	// obfs4 support, through a pluggable transport client. It only relays TCP traffic.
+	streamDialers.RegisterSubParser("obfs4", func(ctx context.Context, input map[string]any) (*Dialer[transport.StreamConn], error) {
+		return parseObfs4StreamDialer(ctx, input, streamEndpoints.Parse)
+	})
+
+	// SOCKS5 support. It can also be the dialer of the endpoint of another transport, to chain
+	// through an existing SOCKS5 proxy.
+	streamDialers.RegisterSubParser("socks5", func(ctx context.Context, input map[string]any) (*Dialer[transport.StreamConn], error) {
+		return parseSOCKS5StreamDialer(ctx, input, streamEndpoints.Parse, packetDialers.Parse)
+	})
+	packetDialers.RegisterSubParser("socks5", func(ctx context.Context, input map[string]any) (*Dialer[net.Conn], error) {
+		return parseSOCKS5PacketDialer(ctx, input, streamEndpoints.Parse, packetDialers.Parse)
+	})
+	packetListeners.RegisterSubParser("socks5", func(ctx context.Context, input map[string]any) (*PacketListener, error) {
+		return parseSOCKS5PacketListener(ctx, input, streamEndpoints.Parse, packetDialers.Parse)
+	})
+	transports.RegisterSubParser("socks5", func(ctx context.Context, input map[string]any) (*TransportPair, error) {
+		return parseSOCKS5Transport(ctx, input, streamEndpoints.Parse, packetDialers.Parse)
+	})
+
 	return transports
 }
@@ -0,0 +1,132 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/Jigsaw-Code/outline-sdk/transport"
+)
+
+// TLSHandshakeObserver receives the outcome of a TLS handshake that a transport made to reach its
+// server. state is nil if the handshake failed with err.
+type TLSHandshakeObserver func(serverName string, state *tls.ConnectionState, err error)
+
+type tlsHandshakeObserverKey struct{}
+
+// WithTLSHandshakeObserver returns a context that makes the dials that use it report their TLS
+// handshakes to observer. It's meant for diagnostics.
+func WithTLSHandshakeObserver(ctx context.Context, observer TLSHandshakeObserver) context.Context {
+	return context.WithValue(ctx, tlsHandshakeObserverKey{}, observer)
+}
+
+// newTLSStreamEndpoint returns a [transport.StreamEndpoint] that makes a TLS connection over the
+// connections of connect, and reports the handshake to the [TLSHandshakeObserver] of the context.
+func newTLSStreamEndpoint(connect ConnectFunc[transport.StreamConn], tlsConfig *tls.Config) transport.StreamEndpoint {
+	return transport.FuncStreamEndpoint(func(ctx context.Context) (transport.StreamConn, error) {
+		conn, err := connect(ctx)
+		if err != nil {
+			return nil, err
+		}
+		tlsConn := tls.Client(conn, tlsConfig)
+		err = tlsConn.HandshakeContext(ctx)
+		if observer, ok := ctx.Value(tlsHandshakeObserverKey{}).(TLSHandshakeObserver); ok {
+			if err != nil {
+				observer(tlsConfig.ServerName, nil, err)
+			} else {
+				state := tlsConn.ConnectionState()
+				observer(tlsConfig.ServerName, &state, nil)
+			}
+		}
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return &tlsStreamConn{Conn: tlsConn, inner: conn}, nil
+	})
+}
+
+// tlsStreamConn is a [transport.StreamConn] for a TLS connection.
+type tlsStreamConn struct {
+	*tls.Conn
+	inner transport.StreamConn
+}
+
+var _ transport.StreamConn = (*tlsStreamConn)(nil)
+
+func (c *tlsStreamConn) CloseRead() error {
+	return c.inner.CloseRead()
+}
+
+// newCertPool returns the pool of the root certificates of the PEM bundle caPEM and of the PEM
+// file at caFile, either of which may be empty, or nil if both are. It fails if a bundle has no
+// certificates or holds anything other than valid certificates.
+func newCertPool(caPEM string, caFile string) (*x509.CertPool, error) {
+	if caPEM == "" && caFile == "" {
+		return nil, nil
+	}
+	pool := x509.NewCertPool()
+	if caPEM != "" {
+		if err := appendPEMCerts(pool, []byte(caPEM)); err != nil {
+			return nil, fmt.Errorf("ca is invalid: %w", err)
+		}
+	}
+	if caFile != "" {
+		data, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ca-file: %w", err)
+		}
+		if err := appendPEMCerts(pool, data); err != nil {
+			return nil, fmt.Errorf("ca-file is invalid: %w", err)
+		}
+	}
+	return pool, nil
+}
+
+// appendPEMCerts adds the certificates of the PEM bundle data to pool. Unlike
+// [x509.CertPool.AppendCertsFromPEM], it doesn't skip the blocks it can't parse.
+func appendPEMCerts(pool *x509.CertPool, data []byte) error {
+	count := 0
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			return fmt.Errorf("found a PEM block of type %q, expected CERTIFICATE", block.Type)
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return fmt.Errorf("failed to parse certificate: %w", err)
+		}
+		pool.AddCert(cert)
+		count++
+	}
+	if len(bytes.TrimSpace(data)) > 0 {
+		return errors.New("found data that is not PEM encoded")
+	}
+	if count == 0 {
+		return errors.New("no PEM certificates found")
+	}
+	return nil
+}
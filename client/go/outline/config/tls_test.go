@@ -0,0 +1,89 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Jigsaw-Code/outline-sdk/transport"
+	"github.com/stretchr/testify/require"
+)
+
+// tlsObservation is a call to a [TLSHandshakeObserver].
+type tlsObservation struct {
+	serverName string
+	state      *tls.ConnectionState
+	err        error
+}
+
+func newTLSTestEndpoint(t *testing.T, tlsConfig *tls.Config) (transport.StreamEndpoint, *[]tlsObservation, context.Context) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	t.Cleanup(server.Close)
+	dialer := &transport.TCPDialer{}
+	connect := func(ctx context.Context) (transport.StreamConn, error) {
+		return dialer.DialStream(ctx, strings.TrimPrefix(server.URL, "https://"))
+	}
+	if tlsConfig.RootCAs != nil {
+		tlsConfig.RootCAs.AddCert(server.Certificate())
+	}
+	var observations []tlsObservation
+	ctx := WithTLSHandshakeObserver(context.Background(), func(serverName string, state *tls.ConnectionState, err error) {
+		observations = append(observations, tlsObservation{serverName, state, err})
+	})
+	return newTLSStreamEndpoint(connect, tlsConfig), &observations, ctx
+}
+
+func TestTLSStreamEndpoint_ObservesHandshake(t *testing.T) {
+	endpoint, observations, ctx := newTLSTestEndpoint(t, &tls.Config{ServerName: "example.com", RootCAs: x509.NewCertPool()})
+
+	conn, err := endpoint.ConnectStream(ctx)
+	require.NoError(t, err)
+	conn.Close()
+
+	require.Len(t, *observations, 1)
+	observation := (*observations)[0]
+	require.NoError(t, observation.err)
+	require.Equal(t, "example.com", observation.serverName)
+	require.NotNil(t, observation.state)
+	require.Equal(t, uint16(tls.VersionTLS13), observation.state.Version)
+}
+
+func TestTLSStreamEndpoint_ObservesCertificateError(t *testing.T) {
+	// The test server certificate is not trusted.
+	endpoint, observations, ctx := newTLSTestEndpoint(t, &tls.Config{ServerName: "example.com"})
+
+	_, err := endpoint.ConnectStream(ctx)
+	var certErr *tls.CertificateVerificationError
+	require.ErrorAs(t, err, &certErr)
+
+	require.Len(t, *observations, 1)
+	require.Nil(t, (*observations)[0].state)
+	require.ErrorAs(t, (*observations)[0].err, &certErr)
+}
+
+func TestTLSStreamEndpoint_NoObserver(t *testing.T) {
+	endpoint, observations, _ := newTLSTestEndpoint(t, &tls.Config{ServerName: "example.com", RootCAs: x509.NewCertPool()})
+
+	conn, err := endpoint.ConnectStream(context.Background())
+	require.NoError(t, err)
+	conn.Close()
+	require.Empty(t, *observations)
+}
@@ -27,6 +27,9 @@ type ConnType int
 const (
 	ConnTypeDirect ConnType = iota
 	ConnTypeTunneled
+	// ConnTypeBlocked is the type of the providers that never make connections, because their
+	// transport can't relay that kind of traffic.
+	ConnTypeBlocked
 )
 
 // ConnProviderConfig represents a dialer or endpoint that can create connections.
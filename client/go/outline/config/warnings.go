@@ -0,0 +1,57 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// Warnings collects the non-fatal issues that the parsers find in a config, such as deprecated
+// formats and ignored fields, which don't prevent the config from working. Pass it to the parsers
+// with [WithWarnings]. The zero value is ready to use.
+type Warnings struct {
+	mu   sync.Mutex
+	list []string
+}
+
+// List returns the warnings in the order they were found, without duplicates.
+func (w *Warnings) List() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return slices.Clone(w.list)
+}
+
+type warningsKey struct{}
+
+// WithWarnings returns a context that makes the parsers report their warnings to warnings.
+func WithWarnings(ctx context.Context, warnings *Warnings) context.Context {
+	return context.WithValue(ctx, warningsKey{}, warnings)
+}
+
+// addWarning reports warning to the [Warnings] of ctx, if any. Warnings are shown to users, so
+// they may name fields, but must never hold values from the config, which may be secrets.
+func addWarning(ctx context.Context, warning string) {
+	warnings, ok := ctx.Value(warningsKey{}).(*Warnings)
+	if !ok {
+		return
+	}
+	warnings.mu.Lock()
+	defer warnings.mu.Unlock()
+	if !slices.Contains(warnings.list, warning) {
+		warnings.list = append(warnings.list, warning)
+	}
+}
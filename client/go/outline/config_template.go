@@ -0,0 +1,68 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"os"
+	"regexp"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+)
+
+// templateVariable matches the ${NAME} placeholders of config templates, including the escaped
+// $${NAME} form.
+var templateVariable = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// NewClientFromTemplate is like [NewClient], but first replaces the ${NAME} placeholders in
+// template with the values of the NAME environment variables. This lets operators keep secrets,
+// such as passwords, out of stored configs. A placeholder of a variable that is not set fails
+// with a [platerrors.InvalidConfig] error that names the variable in the "variable" detail.
+//
+// Names are made of letters, digits and underscores, and don't start with a digit. $${NAME}
+// stands for a literal ${NAME}, and any other use of $ is left as is. Values are inserted into
+// the YAML text unchanged, so placeholders of values with YAML special characters should be
+// quoted, as in password: '${SS_PASSWORD}'.
+func NewClientFromTemplate(template string) *NewClientResult {
+	clientConfig, perr := expandConfigTemplate(template, os.LookupEnv)
+	if perr != nil {
+		return &NewClientResult{Error: perr}
+	}
+	return NewClient(clientConfig)
+}
+
+// expandConfigTemplate replaces the placeholders in template with the values returned by lookup,
+// as described in [NewClientFromTemplate].
+func expandConfigTemplate(template string, lookup func(name string) (string, bool)) (string, *platerrors.PlatformError) {
+	var perr *platerrors.PlatformError
+	expanded := templateVariable.ReplaceAllStringFunc(template, func(placeholder string) string {
+		if placeholder[1] == '$' {
+			return placeholder[1:]
+		}
+		name := placeholder[2 : len(placeholder)-1]
+		value, ok := lookup(name)
+		if !ok && perr == nil {
+			perr = &platerrors.PlatformError{
+				Code:    platerrors.InvalidConfig,
+				Message: "config references an environment variable that is not set",
+				Details: platerrors.ErrorDetails{"variable": name},
+			}
+		}
+		return value
+	})
+	if perr != nil {
+		return "", perr
+	}
+	return expanded, nil
+}
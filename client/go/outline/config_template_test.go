@@ -0,0 +1,78 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"testing"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_expandConfigTemplate(t *testing.T) {
+	env := map[string]string{"SS_PASSWORD": "SECRET", "HOST": "example.com", "EMPTY": ""}
+	lookup := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"no placeholders", "transport: direct", "transport: direct"},
+		{"placeholders", "password: '${SS_PASSWORD}'\nendpoint: ${HOST}:443", "password: 'SECRET'\nendpoint: example.com:443"},
+		{"empty value", "prefix: '${EMPTY}'", "prefix: ''"},
+		{"escaped", "password: $${SS_PASSWORD}", "password: ${SS_PASSWORD}"},
+		{"other dollars", "password: a$b$HOST${", "password: a$b$HOST${"},
+		{"invalid name", "password: ${1A}", "password: ${1A}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, perr := expandConfigTemplate(tt.template, lookup)
+			require.Nil(t, perr, "Got %v", perr)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_expandConfigTemplate_Unset(t *testing.T) {
+	lookup := func(name string) (string, bool) { return "", name == "SET" }
+	_, perr := expandConfigTemplate("a: ${SET}\nb: ${FIRST_UNSET}\nc: ${SECOND_UNSET}", lookup)
+	require.NotNil(t, perr)
+	require.Equal(t, platerrors.InvalidConfig, perr.Code)
+	require.Equal(t, platerrors.ErrorDetails{"variable": "FIRST_UNSET"}, perr.Details)
+}
+
+func Test_NewClientFromTemplate(t *testing.T) {
+	t.Setenv("OUTLINE_TEST_SS_PASSWORD", "SECRET")
+	result := NewClientFromTemplate(`
+transport:
+  $type: shadowsocks
+  endpoint: example.com:4321
+  cipher: chacha20-ietf-poly1305
+  secret: '${OUTLINE_TEST_SS_PASSWORD}'
+`)
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.Equal(t, ConnTypeTunneled, result.StreamConnType)
+}
+
+func Test_NewClientFromTemplate_Unset(t *testing.T) {
+	result := NewClientFromTemplate("transport: ss://chacha20-ietf-poly1305:${OUTLINE_TEST_UNSET}@example.com:4321/")
+	require.Nil(t, result.Client)
+	require.NotNil(t, result.Error)
+	require.Equal(t, platerrors.InvalidConfig, result.Error.Code)
+	require.Equal(t, platerrors.ErrorDetails{"variable": "OUTLINE_TEST_UNSET"}, result.Error.Details)
+}
@@ -0,0 +1,335 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/Jigsaw-Code/outline-sdk/transport"
+)
+
+// maxPooledConnsPerHost caps the maxIdlePerHost of [Client.SetConnectionPool].
+const maxPooledConnsPerHost = 16
+
+// SetConnectionPool makes [Client.DialStream] reuse idle tunneled streams to the same
+// destination, which saves the handshakes of workloads that open many short connections to the
+// same hosts, such as series of small HTTP requests. It's off by default, since not all workloads
+// benefit from it.
+//
+// A stream carries a single conversation with its destination, so only the streams that never
+// sent or received any data can be reused. To have one ready, the pool dials a spare stream in the
+// background each time it hands one out, until it holds maxIdlePerHost idle streams for the
+// destination. That costs an extra connection per destination in use, which idle streams hold
+// for up to idleTimeout before they are closed. Idle streams are checked before reuse, and
+// discarded if their destination closed them or sent data on them.
+//
+// A maxIdlePerHost of zero disables the pool and closes its idle streams. Otherwise it must be
+// at most 16, and idleTimeout must be positive, or SetConnectionPool fails with
+// [platerrors.InvalidConfig] and the previous pool stays in place.
+func (c *Client) SetConnectionPool(maxIdlePerHost int, idleTimeout time.Duration) *platerrors.PlatformError {
+	if maxIdlePerHost == 0 {
+		if old := c.pool.Swap(nil); old != nil {
+			old.close()
+		}
+		return nil
+	}
+	if maxIdlePerHost < 0 || maxIdlePerHost > maxPooledConnsPerHost {
+		return &platerrors.PlatformError{
+			Code:    platerrors.InvalidConfig,
+			Message: "idle connections per host must be between 0 and 16",
+			Details: platerrors.ErrorDetails{"maxIdlePerHost": maxIdlePerHost},
+		}
+	}
+	if idleTimeout <= 0 {
+		return &platerrors.PlatformError{
+			Code:    platerrors.InvalidConfig,
+			Message: "idle timeout must be positive",
+		}
+	}
+	pool := &connPool{
+		maxIdlePerHost: maxIdlePerHost,
+		idleTimeout:    idleTimeout,
+		dial: func(ctx context.Context, address string) (transport.StreamConn, error) {
+			return c.sd.Dial(ctx, address)
+		},
+		dialTimeout: c.getDialTimeout,
+	}
+	if old := c.pool.Swap(pool); old != nil {
+		old.close()
+	}
+	return nil
+}
+
+// dialStreamConn dials address with the transport of c, reusing an idle stream of its connection
+// pool if there is one.
+func (c *Client) dialStreamConn(ctx context.Context, address string) (transport.StreamConn, error) {
+	if pool := c.pool.Load(); pool != nil {
+		return pool.dialStream(ctx, address)
+	}
+	return c.sd.Dial(ctx, address)
+}
+
+// connPool holds the idle streams of [Client.SetConnectionPool], by destination.
+type connPool struct {
+	maxIdlePerHost int
+	idleTimeout    time.Duration
+	dial           func(ctx context.Context, address string) (transport.StreamConn, error)
+	dialTimeout    func() time.Duration
+
+	mu sync.Mutex
+	// idle are the idle streams, oldest first.
+	idle map[string][]*idleConn
+	// spares is the number of spare dials in progress.
+	spares map[string]int
+	// generation changes when the idle streams are flushed, so that the streams dialed before
+	// don't join the pool.
+	generation int
+	closed     bool
+}
+
+// idleConn is a stream in the pool.
+type idleConn struct {
+	conn transport.StreamConn
+	// read receives the result of the read that watches the stream while it's idle. The read
+	// only returns if the destination sends data or closes the stream, or when it's closed.
+	read  chan idleRead
+	timer *time.Timer
+}
+
+// idleRead is the result of a read of an idle stream.
+type idleRead struct {
+	n   int
+	b   byte
+	err error
+}
+
+// dialStream returns an idle stream to address if there is a valid one, and dials one otherwise.
+// It then dials a spare stream in the background for the next call.
+func (p *connPool) dialStream(ctx context.Context, address string) (transport.StreamConn, error) {
+	p.mu.Lock()
+	generation := p.generation
+	p.mu.Unlock()
+	for {
+		ic := p.take(address)
+		if ic == nil {
+			break
+		}
+		select {
+		case <-ic.read:
+			// The destination sent data or closed the stream while it was idle.
+			ic.conn.Close()
+			continue
+		default:
+		}
+		logger().Debug("reusing pooled TCP stream")
+		p.dialSpare(address)
+		return &pooledConn{StreamConn: ic.conn, pool: p, address: address, generation: generation, pending: ic.read}, nil
+	}
+	conn, err := p.dial(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	p.dialSpare(address)
+	return &pooledConn{StreamConn: conn, pool: p, address: address, generation: generation}, nil
+}
+
+// take removes the newest idle stream to address from the pool, and returns it, or nil if there
+// is none.
+func (p *connPool) take(address string) *idleConn {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for conns := p.idle[address]; len(conns) > 0; conns = p.idle[address] {
+		ic := conns[len(conns)-1]
+		p.idle[address] = conns[:len(conns)-1]
+		if ic.timer.Stop() {
+			return ic
+		}
+		// The stream expired, and is being closed.
+	}
+	return nil
+}
+
+// put adds conn, an unused stream to address that was dialed in generation, to the pool. read is
+// the channel of the read that already watches conn, or nil. It returns false, without taking
+// conn, if the pool is closed, flushed since, or full.
+func (p *connPool) put(address string, conn transport.StreamConn, generation int, read chan idleRead) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed || generation != p.generation || len(p.idle[address]) >= p.maxIdlePerHost {
+		return false
+	}
+	if read == nil {
+		conn.SetDeadline(time.Time{})
+		read = make(chan idleRead, 1)
+		go func() {
+			var buf [1]byte
+			n, err := conn.Read(buf[:])
+			read <- idleRead{n: n, b: buf[0], err: err}
+		}()
+	}
+	ic := &idleConn{conn: conn, read: read}
+	ic.timer = time.AfterFunc(p.idleTimeout, func() { p.expire(address, ic) })
+	if p.idle == nil {
+		p.idle = make(map[string][]*idleConn)
+	}
+	p.idle[address] = append(p.idle[address], ic)
+	return true
+}
+
+// expire removes ic, whose idle timeout passed, from the pool and closes it.
+func (p *connPool) expire(address string, ic *idleConn) {
+	p.mu.Lock()
+	conns := p.idle[address]
+	for i, other := range conns {
+		if other == ic {
+			p.idle[address] = append(conns[:i:i], conns[i+1:]...)
+			break
+		}
+	}
+	if len(p.idle[address]) == 0 {
+		delete(p.idle, address)
+	}
+	p.mu.Unlock()
+	ic.conn.Close()
+}
+
+// dialSpare dials a stream to address in the background and adds it to the pool, unless the pool
+// already has enough idle and spare streams for address.
+func (p *connPool) dialSpare(address string) {
+	p.mu.Lock()
+	if p.closed || len(p.idle[address])+p.spares[address] >= p.maxIdlePerHost {
+		p.mu.Unlock()
+		return
+	}
+	if p.spares == nil {
+		p.spares = make(map[string]int)
+	}
+	p.spares[address]++
+	generation := p.generation
+	p.mu.Unlock()
+
+	go func() {
+		ctx := context.Background()
+		if timeout := p.dialTimeout(); timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		conn, err := p.dial(ctx, address)
+		pooled := err == nil && p.put(address, conn, generation, nil)
+		p.mu.Lock()
+		if p.spares[address]--; p.spares[address] == 0 {
+			delete(p.spares, address)
+		}
+		p.mu.Unlock()
+		if err != nil {
+			logger().Debug("spare TCP dial failed", "code", speedTestError(err, platerrors.ProxyServerUnreachable, "").Code)
+		} else if !pooled {
+			conn.Close()
+		}
+	}()
+}
+
+// flush closes the idle streams, and keeps the streams dialed so far from joining the pool, as
+// they may use stale credentials.
+func (p *connPool) flush() {
+	p.mu.Lock()
+	p.generation++
+	idle := p.idle
+	p.idle = nil
+	p.mu.Unlock()
+	for _, conns := range idle {
+		for _, ic := range conns {
+			ic.timer.Stop()
+			ic.conn.Close()
+		}
+	}
+}
+
+// close flushes the pool, and stops it from taking streams.
+func (p *connPool) close() {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+	p.flush()
+}
+
+// pooledConn is a stream handed out by a [connPool]. It returns to the pool when it's closed
+// without having been used.
+type pooledConn struct {
+	transport.StreamConn
+	pool       *connPool
+	address    string
+	generation int
+
+	mu sync.Mutex
+	// pending is the channel of the read that watched the stream while it was idle, or nil.
+	// The first read returns its result.
+	pending chan idleRead
+	used    atomic.Bool
+	closed  atomic.Bool
+}
+
+func (c *pooledConn) Read(b []byte) (int, error) {
+	c.used.Store(true)
+	if len(b) > 0 {
+		c.mu.Lock()
+		pending := c.pending
+		c.pending = nil
+		c.mu.Unlock()
+		if pending != nil {
+			result := <-pending
+			if result.n > 0 {
+				b[0] = result.b
+			}
+			return result.n, result.err
+		}
+	}
+	return c.StreamConn.Read(b)
+}
+
+func (c *pooledConn) Write(b []byte) (int, error) {
+	c.used.Store(true)
+	return c.StreamConn.Write(b)
+}
+
+func (c *pooledConn) CloseRead() error {
+	c.used.Store(true)
+	return c.StreamConn.CloseRead()
+}
+
+func (c *pooledConn) CloseWrite() error {
+	c.used.Store(true)
+	return c.StreamConn.CloseWrite()
+}
+
+func (c *pooledConn) Close() error {
+	if c.closed.Swap(true) {
+		return nil
+	}
+	if !c.used.Load() {
+		c.mu.Lock()
+		pending := c.pending
+		c.mu.Unlock()
+		if c.pool.put(c.address, c.StreamConn, c.generation, pending) {
+			return nil
+		}
+	}
+	return c.StreamConn.Close()
+}
@@ -0,0 +1,222 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/Jigsaw-Code/outline-sdk/transport"
+	"github.com/stretchr/testify/require"
+)
+
+// newPoolTestClient returns a direct client that counts its dials.
+func newPoolTestClient(dials *atomic.Int32) *Client {
+	client := newDirectTestClient()
+	dial := client.sd.Dial
+	client.sd.Dial = func(ctx context.Context, address string) (transport.StreamConn, error) {
+		dials.Add(1)
+		return dial(ctx, address)
+	}
+	return client
+}
+
+// requireEcho checks that conn echoes data back.
+func requireEcho(t *testing.T, conn net.Conn) {
+	_, err := conn.Write([]byte("ping"))
+	require.NoError(t, err)
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(conn, buf)
+	require.NoError(t, err)
+	require.Equal(t, "ping", string(buf))
+}
+
+// requireIdle waits until the pool of client holds count idle streams to address.
+func requireIdle(t *testing.T, client *Client, address string, count int) {
+	pool := client.pool.Load()
+	require.Eventually(t, func() bool {
+		pool.mu.Lock()
+		defer pool.mu.Unlock()
+		return len(pool.idle[address]) == count && pool.spares[address] == 0
+	}, time.Second, time.Millisecond)
+}
+
+func TestSetConnectionPool_ReusesSpare(t *testing.T) {
+	server := newTCPEchoServer(t)
+	var dials atomic.Int32
+	client := newPoolTestClient(&dials)
+	require.Nil(t, client.SetConnectionPool(1, time.Minute))
+
+	conn, err := client.DialStream(context.Background(), server)
+	require.NoError(t, err)
+	requireEcho(t, conn)
+	require.NoError(t, conn.Close())
+	// The used stream is closed, and a spare is ready.
+	requireIdle(t, client, server, 1)
+	require.Equal(t, int32(2), dials.Load())
+
+	conn, err = client.DialStream(context.Background(), server)
+	require.NoError(t, err)
+	requireEcho(t, conn)
+	require.NoError(t, conn.Close())
+	requireIdle(t, client, server, 1)
+	// The spare was used, and replaced.
+	require.Equal(t, int32(3), dials.Load())
+	require.Equal(t, int64(8), client.Stats().BytesSent)
+}
+
+func TestSetConnectionPool_ReusesUnusedConn(t *testing.T) {
+	server := newTCPEchoServer(t)
+	var dials atomic.Int32
+	client := newPoolTestClient(&dials)
+	require.Nil(t, client.SetConnectionPool(2, time.Minute))
+
+	conn, err := client.DialStream(context.Background(), server)
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+	// The unused stream and the spare are both idle.
+	requireIdle(t, client, server, 2)
+	require.Equal(t, int32(2), dials.Load())
+	require.Empty(t, client.ActiveConnections())
+
+	conn, err = client.DialStream(context.Background(), server)
+	require.NoError(t, err)
+	defer conn.Close()
+	requireEcho(t, conn)
+	// The reused stream was replaced by a spare.
+	requireIdle(t, client, server, 2)
+	require.Equal(t, int32(3), dials.Load())
+}
+
+func TestSetConnectionPool_DiscardsDirtyConns(t *testing.T) {
+	// The server sends a greeting, which makes the idle streams unusable.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte("hello"))
+			go func() {
+				defer conn.Close()
+				io.Copy(io.Discard, conn)
+			}()
+		}
+	}()
+	server := listener.Addr().String()
+	var dials atomic.Int32
+	client := newPoolTestClient(&dials)
+	require.Nil(t, client.SetConnectionPool(1, time.Minute))
+
+	conn, err := client.DialStream(context.Background(), server)
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+	requireIdle(t, client, server, 1)
+	pool := client.pool.Load()
+	require.Eventually(t, func() bool {
+		pool.mu.Lock()
+		defer pool.mu.Unlock()
+		return len(pool.idle[server][0].read) == 1
+	}, time.Second, time.Millisecond)
+
+	conn, err = client.DialStream(context.Background(), server)
+	require.NoError(t, err)
+	defer conn.Close()
+	// The greeting was not consumed by the pool.
+	buf := make([]byte, 5)
+	_, err = io.ReadFull(conn, buf)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(buf))
+	// The dirty stream was replaced by a new stream and a spare.
+	requireIdle(t, client, server, 1)
+	require.Equal(t, int32(4), dials.Load())
+}
+
+func TestSetConnectionPool_IdleTimeout(t *testing.T) {
+	server := newTCPEchoServer(t)
+	var dials atomic.Int32
+	client := newPoolTestClient(&dials)
+	require.Nil(t, client.SetConnectionPool(1, 20*time.Millisecond))
+
+	conn, err := client.DialStream(context.Background(), server)
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+	require.Eventually(t, func() bool { return dials.Load() == 2 }, time.Second, time.Millisecond)
+	requireIdle(t, client, server, 0)
+}
+
+func TestSetConnectionPool_Disable(t *testing.T) {
+	server := newTCPEchoServer(t)
+	var dials atomic.Int32
+	client := newPoolTestClient(&dials)
+	require.Nil(t, client.SetConnectionPool(1, time.Minute))
+	conn, err := client.DialStream(context.Background(), server)
+	require.NoError(t, err)
+	requireIdle(t, client, server, 1)
+	pool := client.pool.Load()
+
+	require.Nil(t, client.SetConnectionPool(0, 0))
+	require.Nil(t, client.pool.Load())
+	require.Empty(t, pool.idle)
+	// Streams handed out before don't return to the old pool.
+	require.NoError(t, conn.Close())
+	require.Empty(t, pool.idle)
+
+	conn, err = client.DialStream(context.Background(), server)
+	require.NoError(t, err)
+	requireEcho(t, conn)
+	require.NoError(t, conn.Close())
+	require.Equal(t, int32(3), dials.Load())
+}
+
+func TestSetConnectionPool_Shutdown(t *testing.T) {
+	server := newTCPEchoServer(t)
+	client := newDirectTestClient()
+	require.Nil(t, client.SetConnectionPool(1, time.Minute))
+	conn, err := client.DialStream(context.Background(), server)
+	require.NoError(t, err)
+	requireIdle(t, client, server, 1)
+	pool := client.pool.Load()
+	require.NoError(t, conn.Close())
+
+	require.NoError(t, client.Shutdown(context.Background()))
+	require.Nil(t, client.pool.Load())
+	require.Empty(t, pool.idle)
+}
+
+func TestSetConnectionPool_Invalid(t *testing.T) {
+	client := newDirectTestClient()
+	for _, tc := range []struct {
+		maxIdle     int
+		idleTimeout time.Duration
+	}{
+		{-1, time.Minute},
+		{maxPooledConnsPerHost + 1, time.Minute},
+		{1, 0},
+	} {
+		perr := client.SetConnectionPool(tc.maxIdle, tc.idleTimeout)
+		require.NotNil(t, perr)
+		require.Equal(t, platerrors.InvalidConfig, perr.Code)
+	}
+	require.Nil(t, client.pool.Load())
+}
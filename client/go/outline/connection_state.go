@@ -0,0 +1,156 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"sync"
+	"time"
+)
+
+// States of the tunnel of a [Client], as detected by [Client.StartConnectivityMonitor].
+const (
+	// ConnectionStateUnknown is the state before the first report of a connectivity monitor.
+	ConnectionStateUnknown = "unknown"
+	// ConnectionStateHealthy means the last probe got through the proxy quickly.
+	ConnectionStateHealthy = "healthy"
+	// ConnectionStateDegraded means the last probe got through the proxy slowly, or failed after
+	// fewer than [connectionDownFailures] probes in a row.
+	ConnectionStateDegraded = "degraded"
+	// ConnectionStateDown means [connectionDownFailures] or more probes in a row failed.
+	ConnectionStateDown = "down"
+)
+
+const (
+	// connectionDownFailures is the number of failed probes in a row that make the tunnel down.
+	connectionDownFailures = 3
+	// degradedLatency is the probe latency from which the tunnel is degraded.
+	degradedLatency = 2 * time.Second
+)
+
+// ConnectionStateListener is notified when the state of the tunnel of a [Client] changes, with
+// one of the ConnectionState* constants, so that apps can react to transitions instead of
+// polling.
+//
+// We use an interface instead of a func type so that it can be implemented by the platform code
+// through gobind. Calls are never concurrent, and they come in the order of the changes.
+type ConnectionStateListener interface {
+	OnStateChanged(oldState string, newState string)
+}
+
+// SetConnectionStateListener sets the listener of the state changes that the connectivity
+// monitors of c detect, replacing the previous one. A nil listener stops the notifications.
+//
+// The listener is called on a goroutine of its own, so a slow listener delays neither the
+// monitors nor the connections of c.
+func (c *Client) SetConnectionStateListener(listener ConnectionStateListener) {
+	c.connectionState.setListener(listener)
+}
+
+// ConnectionState returns the current state of the tunnel of c, one of the ConnectionState*
+// constants. It stays [ConnectionStateUnknown] until a connectivity monitor reports, and keeps
+// the last state detected after the monitors stop.
+func (c *Client) ConnectionState() string {
+	return c.connectionState.get()
+}
+
+// connectionStateOf returns the state of the tunnel that status reveals.
+func connectionStateOf(status *ConnectivityMonitorStatus) string {
+	switch {
+	case status.ConsecutiveFailures >= connectionDownFailures:
+		return ConnectionStateDown
+	case !status.Reachable || status.LatencyMs >= degradedLatency.Milliseconds():
+		return ConnectionStateDegraded
+	default:
+		return ConnectionStateHealthy
+	}
+}
+
+// connectionStateTracker holds the state of the tunnel of a client, and notifies its listener of
+// the changes.
+type connectionStateTracker struct {
+	mu       sync.Mutex
+	state    string
+	listener ConnectionStateListener
+	// pending are the changes not yet delivered to their listener, oldest first.
+	pending []connectionStateChange
+	// delivering tells whether a goroutine is delivering the pending changes.
+	delivering bool
+}
+
+// connectionStateChange is a notification to a [ConnectionStateListener].
+type connectionStateChange struct {
+	listener           ConnectionStateListener
+	oldState, newState string
+}
+
+func (t *connectionStateTracker) setListener(listener ConnectionStateListener) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.listener = listener
+}
+
+func (t *connectionStateTracker) getListener() ConnectionStateListener {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.listener
+}
+
+func (t *connectionStateTracker) get() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.state == "" {
+		return ConnectionStateUnknown
+	}
+	return t.state
+}
+
+// update sets the state to state, and queues a notification to the listener if it changed.
+// It never blocks on the listener.
+func (t *connectionStateTracker) update(state string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	oldState := t.state
+	if oldState == "" {
+		oldState = ConnectionStateUnknown
+	}
+	if state == oldState {
+		return
+	}
+	t.state = state
+	if t.listener == nil {
+		return
+	}
+	t.pending = append(t.pending, connectionStateChange{listener: t.listener, oldState: oldState, newState: state})
+	if !t.delivering {
+		t.delivering = true
+		go t.deliver()
+	}
+}
+
+// deliver notifies the pending changes in order, until there are none left.
+func (t *connectionStateTracker) deliver() {
+	for {
+		t.mu.Lock()
+		if len(t.pending) == 0 {
+			t.delivering = false
+			t.mu.Unlock()
+			return
+		}
+		change := t.pending[0]
+		t.pending = t.pending[1:]
+		t.mu.Unlock()
+		change.listener.OnStateChanged(change.oldState, change.newState)
+	}
+}
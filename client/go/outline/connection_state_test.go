@@ -0,0 +1,125 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// stateRecorder is a [ConnectionStateListener] that sends the changes to a channel.
+type stateRecorder chan [2]string
+
+func (r stateRecorder) OnStateChanged(oldState string, newState string) {
+	r <- [2]string{oldState, newState}
+}
+
+func (r stateRecorder) next(t *testing.T) [2]string {
+	select {
+	case change := <-r:
+		return change
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "no state change notified")
+		return [2]string{}
+	}
+}
+
+func Test_connectionStateOf(t *testing.T) {
+	tests := []struct {
+		status *ConnectivityMonitorStatus
+		want   string
+	}{
+		{&ConnectivityMonitorStatus{Reachable: true, LatencyMs: 50}, ConnectionStateHealthy},
+		{&ConnectivityMonitorStatus{Reachable: true, LatencyMs: degradedLatency.Milliseconds()}, ConnectionStateDegraded},
+		{&ConnectivityMonitorStatus{LatencyMs: -1, ConsecutiveFailures: 1}, ConnectionStateDegraded},
+		{&ConnectivityMonitorStatus{LatencyMs: -1, ConsecutiveFailures: connectionDownFailures}, ConnectionStateDown},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.want, connectionStateOf(tt.status), "status %+v", tt.status)
+	}
+}
+
+func Test_Client_ConnectionState_Changes(t *testing.T) {
+	client := newDirectTestClient()
+	require.Equal(t, ConnectionStateUnknown, client.ConnectionState())
+	recorder := make(stateRecorder, 10)
+	client.SetConnectionStateListener(recorder)
+
+	for _, state := range []string{ConnectionStateHealthy, ConnectionStateHealthy, ConnectionStateDegraded, ConnectionStateDown, ConnectionStateHealthy} {
+		client.connectionState.update(state)
+	}
+	require.Equal(t, [2]string{ConnectionStateUnknown, ConnectionStateHealthy}, recorder.next(t))
+	require.Equal(t, [2]string{ConnectionStateHealthy, ConnectionStateDegraded}, recorder.next(t))
+	require.Equal(t, [2]string{ConnectionStateDegraded, ConnectionStateDown}, recorder.next(t))
+	require.Equal(t, [2]string{ConnectionStateDown, ConnectionStateHealthy}, recorder.next(t))
+	require.Equal(t, ConnectionStateHealthy, client.ConnectionState())
+
+	// No more notifications once the listener is removed.
+	client.SetConnectionStateListener(nil)
+	client.connectionState.update(ConnectionStateDown)
+	require.Equal(t, ConnectionStateDown, client.ConnectionState())
+	select {
+	case change := <-recorder:
+		require.FailNow(t, "unexpected notification", "%v", change)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func Test_Client_ConnectionState_SlowListener(t *testing.T) {
+	client := newDirectTestClient()
+	// The listener blocks until the test reads the changes.
+	recorder := make(stateRecorder)
+	client.SetConnectionStateListener(recorder)
+
+	updated := make(chan struct{})
+	go func() {
+		defer close(updated)
+		for i := 0; i < 10; i++ {
+			client.connectionState.update(ConnectionStateHealthy)
+			client.connectionState.update(ConnectionStateDegraded)
+		}
+	}()
+	select {
+	case <-updated:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "updates blocked on the listener")
+	}
+	// All the changes are delivered, in order.
+	require.Equal(t, [2]string{ConnectionStateUnknown, ConnectionStateHealthy}, recorder.next(t))
+	for i := 0; i < 19; i++ {
+		change := recorder.next(t)
+		require.NotEqual(t, change[0], change[1])
+	}
+}
+
+func Test_ConnectivityMonitor_ConnectionState(t *testing.T) {
+	client := newUnreachableTestClient("127.0.0.1:4321")
+	recorder := make(stateRecorder, 10)
+	client.SetConnectionStateListener(recorder)
+	statuses := make(statusRecorder, 10)
+
+	monitor := client.startConnectivityMonitor(context.Background(), 20*time.Millisecond, statuses, "http://example.com")
+	defer monitor.Stop()
+	require.Equal(t, [2]string{ConnectionStateUnknown, ConnectionStateDegraded}, recorder.next(t))
+	require.Equal(t, [2]string{ConnectionStateDegraded, ConnectionStateDown}, recorder.next(t))
+	require.Equal(t, ConnectionStateDown, client.ConnectionState())
+	for i := 1; i <= connectionDownFailures; i++ {
+		status := statuses.next(t)
+		require.Equal(t, connectionStateOf(status), status.State)
+	}
+}
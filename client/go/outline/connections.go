@@ -0,0 +1,211 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"io"
+	"net"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-sdk/transport"
+)
+
+// ConnectionInfo describes a stream connection of a [Client] that is open.
+type ConnectionInfo struct {
+	RemoteAddress string // Address the connection was dialed to, as passed to [Client.DialStream]
+	DurationMs    int64  // Time since the connection was established
+	BytesSent     int64  // Bytes written to the connection
+	BytesReceived int64  // Bytes read from the connection
+}
+
+// ActiveConnections returns a snapshot of the stream connections dialed with [Client.DialStream]
+// that are not closed yet, oldest first.
+func (c *Client) ActiveConnections() []ConnectionInfo {
+	return c.connections.snapshot()
+}
+
+// connRegistry tracks the open stream and packet connections of a [Client]. The zero value is
+// ready to use.
+//
+// Connections are removed when they are closed, so a connection that its user never closes
+// stays in the registry.
+type connRegistry struct {
+	mu    sync.Mutex
+	conns map[*trackedStreamConn]struct{}
+	// packetConns are the open packet connections, which are only tracked for the shutdown.
+	packetConns map[*trackedPacketConn]struct{}
+	// drained is created by shutdown, and closed once the registry is empty after it.
+	drained chan struct{}
+}
+
+// track adds conn, dialed to address, to the registry until the returned connection is closed.
+// It returns false, without tracking conn, once shutdown was called.
+func (r *connRegistry) track(address string, conn transport.StreamConn) (transport.StreamConn, bool) {
+	tracked := &trackedStreamConn{StreamConn: conn, registry: r, address: address, start: time.Now()}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.drained != nil {
+		return nil, false
+	}
+	if r.conns == nil {
+		r.conns = make(map[*trackedStreamConn]struct{})
+	}
+	r.conns[tracked] = struct{}{}
+	return tracked, true
+}
+
+// trackPacket is like [connRegistry.track], for packet connections.
+func (r *connRegistry) trackPacket(conn net.PacketConn) (net.PacketConn, bool) {
+	tracked := &trackedPacketConn{PacketConn: conn, registry: r}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.drained != nil {
+		return nil, false
+	}
+	if r.packetConns == nil {
+		r.packetConns = make(map[*trackedPacketConn]struct{})
+	}
+	r.packetConns[tracked] = struct{}{}
+	return tracked, true
+}
+
+func (r *connRegistry) remove(conn *trackedStreamConn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.conns[conn]; !ok {
+		return
+	}
+	delete(r.conns, conn)
+	r.notifyIfDrainedLocked()
+}
+
+func (r *connRegistry) removePacket(conn *trackedPacketConn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.packetConns[conn]; !ok {
+		return
+	}
+	delete(r.packetConns, conn)
+	r.notifyIfDrainedLocked()
+}
+
+// notifyIfDrainedLocked closes drained if shutdown was called and the registry is empty. r.mu
+// must be held.
+func (r *connRegistry) notifyIfDrainedLocked() {
+	if r.drained != nil && len(r.conns) == 0 && len(r.packetConns) == 0 {
+		close(r.drained)
+	}
+}
+
+// shutdown stops the registry from tracking new connections, and returns a channel that is closed
+// once all the tracked connections are closed.
+func (r *connRegistry) shutdown() <-chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.drained == nil {
+		r.drained = make(chan struct{})
+		r.notifyIfDrainedLocked()
+	}
+	return r.drained
+}
+
+// isShutDown returns whether shutdown was called.
+func (r *connRegistry) isShutDown() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.drained != nil
+}
+
+// closeAll closes all the tracked connections, and returns how many there were.
+func (r *connRegistry) closeAll() int {
+	r.mu.Lock()
+	conns := make([]io.Closer, 0, len(r.conns)+len(r.packetConns))
+	for conn := range r.conns {
+		conns = append(conns, conn)
+	}
+	for conn := range r.packetConns {
+		conns = append(conns, conn)
+	}
+	r.mu.Unlock()
+
+	for _, conn := range conns {
+		conn.Close()
+	}
+	return len(conns)
+}
+
+func (r *connRegistry) snapshot() []ConnectionInfo {
+	r.mu.Lock()
+	conns := make([]*trackedStreamConn, 0, len(r.conns))
+	for conn := range r.conns {
+		conns = append(conns, conn)
+	}
+	r.mu.Unlock()
+
+	slices.SortFunc(conns, func(a, b *trackedStreamConn) int { return a.start.Compare(b.start) })
+	now := time.Now()
+	infos := make([]ConnectionInfo, len(conns))
+	for i, conn := range conns {
+		infos[i] = ConnectionInfo{
+			RemoteAddress: conn.address,
+			DurationMs:    now.Sub(conn.start).Milliseconds(),
+			BytesSent:     conn.bytesSent.Load(),
+			BytesReceived: conn.bytesReceived.Load(),
+		}
+	}
+	return infos
+}
+
+// trackedStreamConn is a [transport.StreamConn] that counts its traffic and leaves its
+// registry when closed.
+type trackedStreamConn struct {
+	transport.StreamConn
+	registry      *connRegistry
+	address       string
+	start         time.Time
+	bytesSent     atomic.Int64
+	bytesReceived atomic.Int64
+}
+
+func (c *trackedStreamConn) Read(b []byte) (int, error) {
+	n, err := c.StreamConn.Read(b)
+	c.bytesReceived.Add(int64(n))
+	return n, err
+}
+
+func (c *trackedStreamConn) Write(b []byte) (int, error) {
+	n, err := c.StreamConn.Write(b)
+	c.bytesSent.Add(int64(n))
+	return n, err
+}
+
+func (c *trackedStreamConn) Close() error {
+	c.registry.remove(c)
+	return c.StreamConn.Close()
+}
+
+// trackedPacketConn is a [net.PacketConn] that leaves its registry when closed.
+type trackedPacketConn struct {
+	net.PacketConn
+	registry *connRegistry
+}
+
+func (c *trackedPacketConn) Close() error {
+	c.registry.removePacket(c)
+	return c.PacketConn.Close()
+}
@@ -0,0 +1,88 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ActiveConnections(t *testing.T) {
+	server := newTCPEchoServer(t)
+	client := newDirectTestClient()
+	require.Empty(t, client.ActiveConnections())
+
+	first, err := client.DialStream(context.Background(), server)
+	require.NoError(t, err)
+	defer first.Close()
+	time.Sleep(10 * time.Millisecond)
+	second, err := client.DialStream(context.Background(), server)
+	require.NoError(t, err)
+	defer second.Close()
+	_, err = first.Write([]byte("hello"))
+	require.NoError(t, err)
+	_, err = io.ReadFull(first, make([]byte, 3))
+	require.NoError(t, err)
+
+	conns := client.ActiveConnections()
+	require.Len(t, conns, 2)
+	require.Equal(t, server, conns[0].RemoteAddress)
+	require.Equal(t, int64(5), conns[0].BytesSent)
+	require.Equal(t, int64(3), conns[0].BytesReceived)
+	require.GreaterOrEqual(t, conns[0].DurationMs, int64(10))
+	require.Equal(t, server, conns[1].RemoteAddress)
+	require.Equal(t, int64(0), conns[1].BytesSent)
+
+	require.NoError(t, first.Close())
+	conns = client.ActiveConnections()
+	require.Len(t, conns, 1)
+	require.Equal(t, int64(0), conns[0].BytesSent)
+
+	// Closing twice is harmless.
+	first.Close()
+	second.Close()
+	require.Empty(t, client.ActiveConnections())
+}
+
+func Test_ActiveConnections_Concurrent(t *testing.T) {
+	server := newTCPEchoServer(t)
+	client := newDirectTestClient()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			conn, err := client.DialStream(context.Background(), server)
+			if !assert.NoError(t, err) {
+				return
+			}
+			conn.Write([]byte("ping"))
+			conn.Close()
+		}()
+		go func() {
+			defer wg.Done()
+			client.ActiveConnections()
+		}()
+	}
+	wg.Wait()
+	require.Empty(t, client.ActiveConnections())
+}
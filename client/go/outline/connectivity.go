@@ -16,10 +16,15 @@ package outline
 
 import (
 	"context"
+	"errors"
+	"net"
+	"net/netip"
 	"time"
 
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/config"
 	"github.com/Jigsaw-Code/outline-apps/client/go/outline/connectivity"
 	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/Jigsaw-Code/outline-sdk/transport"
 )
 
 // TCPAndUDPConnectivityResult represents the result of TCP and UDP connectivity checks.
@@ -27,19 +32,249 @@ import (
 // We use a struct instead of a tuple to preserve a strongly typed error that gobind recognizes.
 type TCPAndUDPConnectivityResult struct {
 	TCPError, UDPError *platerrors.PlatformError
+
+	// ServerAddress is the address of the first hop as configured, in host:port form.
+	ServerAddress string
+	// ResolvedAddress is the IP:port the first hop resolved to, or empty if resolution failed.
+	// If resolution failed and the TCP check failed, TCPError has code [platerrors.ResolveIPFailed].
+	ResolvedAddress string
+	// TLSInfo is the outcome of the TLS handshake of the TCP check, or nil if the transport
+	// doesn't use TLS to reach its server.
+	TLSInfo *TLSHandshakeInfo
+}
+
+// Connectivity statuses reported in [ConnectivityAssessment.Status].
+const (
+	// ConnectivityFullyConnected means that both TCP and UDP traffic can be proxied.
+	ConnectivityFullyConnected = "fully-connected"
+	// ConnectivityTCPOnly means that TCP traffic can be proxied, but UDP traffic can't.
+	ConnectivityTCPOnly = "tcp-only"
+	// ConnectivityBlocked means that TCP traffic can't be proxied, so the client can't be used.
+	ConnectivityBlocked = "blocked"
+)
+
+// ConnectivityAssessment is the interpretation of a [TCPAndUDPConnectivityResult].
+type ConnectivityAssessment struct {
+	// Status is one of [ConnectivityFullyConnected], [ConnectivityTCPOnly] or [ConnectivityBlocked].
+	Status string
+	// Recommendation is a message for the user about the status, or empty if there's nothing to do.
+	Recommendation string
 }
 
+// ConnectivityStatus tells whether the client can be used, and whether UDP traffic will work.
+// A client with working TCP should still be used when UDP is blocked.
+func (r *TCPAndUDPConnectivityResult) ConnectivityStatus() *ConnectivityAssessment {
+	switch {
+	case r.TCPError != nil:
+		return &ConnectivityAssessment{
+			Status:         ConnectivityBlocked,
+			Recommendation: "The server is unreachable. Check the access key or try a different network.",
+		}
+	case r.UDPError != nil:
+		return &ConnectivityAssessment{
+			Status:         ConnectivityTCPOnly,
+			Recommendation: "UDP traffic is blocked, so apps that rely on it, such as calls and games, may not work.",
+		}
+	default:
+		return &ConnectivityAssessment{Status: ConnectivityFullyConnected}
+	}
+}
+
+// defaultConnectivityTimeout is the time budget of each of the TCP and UDP connectivity checks.
+const defaultConnectivityTimeout = 10 * time.Second
+
 // CheckTCPAndUDPConnectivity checks if a [Client] can relay TCP and UDP traffic.
 //
 // It parallelizes the execution of TCP and UDP checks, and returns a [TCPAndUDPConnectivityResult]
 // containing a TCP error and a UDP error.
 // If the connectivity check was successful, the corresponding error field will be nil.
 func CheckTCPAndUDPConnectivity(client *Client) *TCPAndUDPConnectivityResult {
-	tcpErr, udpErr := connectivity.CheckTCPAndUDPConnectivity(client, client)
-	return &TCPAndUDPConnectivityResult{
-		TCPError: platerrors.ToPlatformError(tcpErr),
-		UDPError: platerrors.ToPlatformError(udpErr),
+	return CheckTCPAndUDPConnectivityWithTimeout(client, defaultConnectivityTimeout)
+}
+
+// CheckTCPAndUDPConnectivityWithTimeout is like [CheckTCPAndUDPConnectivity], but each of the
+// TCP and UDP checks fails if it doesn't complete within timeout.
+func CheckTCPAndUDPConnectivityWithTimeout(client *Client, timeout time.Duration) *TCPAndUDPConnectivityResult {
+	return checkTCPAndUDPConnectivity(context.Background(), client, timeout, connectivity.ProbeTargets{})
+}
+
+// CheckTCPAndUDPConnectivityWithTargets is like [CheckTCPAndUDPConnectivity], but the TCP check
+// sends an HTTP HEAD request to tcpAddress, and the UDP check sends a DNS query to udpAddress,
+// both in host:port form. This helps on networks that block the default destinations, which are
+// used for empty addresses.
+func CheckTCPAndUDPConnectivityWithTargets(client *Client, tcpAddress, udpAddress string) *TCPAndUDPConnectivityResult {
+	targets := connectivity.ProbeTargets{TCPAddress: tcpAddress, UDPAddress: udpAddress}
+	return checkTCPAndUDPConnectivity(context.Background(), client, defaultConnectivityTimeout, targets)
+}
+
+// checkTCPAndUDPConnectivity implements [CheckTCPAndUDPConnectivityWithTimeout], probing the
+// given targets once there is a free probe slot (see [SetMaxConcurrentProbes]). The checks are
+// also aborted when ctx is done.
+func checkTCPAndUDPConnectivity(ctx context.Context, client *Client, timeout time.Duration, targets connectivity.ProbeTargets) *TCPAndUDPConnectivityResult {
+	result := &TCPAndUDPConnectivityResult{ServerAddress: client.transportClient().sd.FirstHop}
+	if err := probeSlots.acquire(ctx); err != nil {
+		perr := &platerrors.PlatformError{
+			Code:    platerrors.OperationCanceled,
+			Message: "connectivity check was canceled",
+			Cause:   platerrors.ToPlatformError(err),
+		}
+		result.TCPError, result.UDPError = perr, perr
+		return result
+	}
+	defer probeSlots.release()
+
+	// Resolve the first hop alongside the checks, so we can tell DNS failures from server failures.
+	// The first hop is unknown for clients that weren't created from a config.
+	type resolution struct {
+		address string
+		err     error
+	}
+	resolve := func(firstHop string) <-chan resolution {
+		resolutionChan := make(chan resolution, 1)
+		if firstHop == "" {
+			resolutionChan <- resolution{}
+			return resolutionChan
+		}
+		go func() {
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			address, err := resolveAddress(ctx, firstHop)
+			resolutionChan <- resolution{address, err}
+		}()
+		return resolutionChan
+	}
+	resolutionChan := resolve(result.ServerAddress)
+
+	tlsRecorder := &tlsHandshakeRecorder{}
+	tcpDialer := transport.FuncStreamDialer(func(ctx context.Context, address string) (transport.StreamConn, error) {
+		return client.DialStream(config.WithTLSHandshakeObserver(ctx, tlsRecorder.observe), address)
+	})
+	tcpErr, udpErr := connectivity.CheckTCPAndUDPConnectivityWithTargets(ctx, tcpDialer, client, timeout, targets)
+	result.TCPError = platerrors.ToPlatformError(tcpErr)
+	result.UDPError = platerrors.ToPlatformError(udpErr)
+	result.TLSInfo = tlsRecorder.info()
+
+	resolved := <-resolutionChan
+	if firstHop := client.transportClient().sd.FirstHop; firstHop != result.ServerAddress {
+		// The client failed over during the checks, so they ended on another server.
+		result.ServerAddress = firstHop
+		resolved = <-resolve(firstHop)
+	}
+	result.ResolvedAddress = resolved.address
+	var dnsErr *net.DNSError
+	if result.TCPError != nil && result.TCPError.Code != platerrors.InvalidConfig && errors.As(resolved.err, &dnsErr) {
+		result.TCPError = &platerrors.PlatformError{
+			Code:    platerrors.ResolveIPFailed,
+			Message: "failed to resolve the server address",
+			Details: platerrors.ErrorDetails{"address": result.ServerAddress},
+			Cause:   result.TCPError,
+		}
 	}
+	logger().Info("connectivity check done", "tcpError", errorCode(result.TCPError), "udpError", errorCode(result.UDPError))
+	return result
+}
+
+// CheckTCPAndUDPConnectivityWithBaseDialers is like [CheckTCPAndUDPConnectivity], but the checks
+// reach the first hop through the given dialers, as with [NewClientWithBaseDialers], instead of
+// the ones the client was created with. The client itself keeps using its own dialers.
+func CheckTCPAndUDPConnectivityWithBaseDialers(client *Client, tcpDialer transport.StreamDialer, udpDialer transport.PacketDialer) *TCPAndUDPConnectivityResult {
+	probeClient, err := newProbeClient(client, tcpDialer, udpDialer)
+	if err != nil {
+		perr := platerrors.ToPlatformError(err)
+		return &TCPAndUDPConnectivityResult{TCPError: perr, UDPError: perr, ServerAddress: client.transportClient().sd.FirstHop}
+	}
+	return CheckTCPAndUDPConnectivity(probeClient)
+}
+
+// newProbeClient recreates client on top of the given base dialers, for connectivity checks and
+// [Client.ListenPacketOn].
+func newProbeClient(client *Client, tcpDialer transport.StreamDialer, udpDialer transport.PacketDialer) (*Client, error) {
+	client = client.transportClient()
+	clientConfig := client.config.Load()
+	if clientConfig == nil {
+		return nil, platerrors.PlatformError{
+			Code:    platerrors.InternalError,
+			Message: "client was not created from a config",
+		}
+	}
+	// Accept the same transports the client was created with.
+	options := NewClientOptions{
+		AllowDirectTCP: client.sd.ConnType == config.ConnTypeDirect,
+		AllowDirectUDP: client.pl.ConnType == config.ConnTypeDirect,
+	}
+	if client.credentials != nil {
+		options = client.credentials.options
+	}
+	return newClientFromConfig(clientConfig, tcpDialer, udpDialer, options)
+}
+
+// resolveAddress resolves the host in the host:port address to an IP address,
+// and returns it as IP:port.
+func resolveAddress(ctx context.Context, address string) (string, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", err
+	}
+	if net.ParseIP(host) != nil {
+		return address, nil
+	}
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return "", err
+	}
+	if len(ips) == 0 {
+		return "", &net.DNSError{Err: "no addresses found", Name: host, IsNotFound: true}
+	}
+	return net.JoinHostPort(ips[0].IP.String(), port), nil
+}
+
+// CheckReachability checks whether the TCP address, in host:port form, can be reached through the
+// proxy. It returns nil if a connection could be established.
+func (c *Client) CheckReachability(ctx context.Context, address string) *platerrors.PlatformError {
+	conn, err := c.DialTCP(ctx, address)
+	if err != nil {
+		return platerrors.ToPlatformError(err)
+	}
+	conn.Close()
+	return nil
+}
+
+// UDPSupportResult is the result of [Client.ProbeUDPSupport].
+//
+// We use a struct instead of a tuple to preserve a strongly typed error that gobind recognizes.
+type UDPSupportResult struct {
+	// ConnType is the type of the UDP traffic, as reported by [Client.PacketConnType]. A tunneled
+	// packet listener may still not work, if the proxy doesn't relay UDP traffic.
+	ConnType string
+	// Functional tells whether UDP packets made a round trip through the packet listener.
+	Functional bool
+	// Error is why the packets didn't make it, or nil if Functional is set.
+	Error *platerrors.PlatformError
+}
+
+// udpSupportProbeTimeout bounds [Client.ProbeUDPSupport], so that it can run right after the
+// client is created.
+const udpSupportProbeTimeout = 3 * time.Second
+
+// ProbeUDPSupport checks whether the UDP traffic of the client actually works, by sending a DNS
+// query through it. This lets the app warn users before they run apps that need UDP, as some
+// servers don't relay UDP traffic. It takes up to three seconds.
+func (c *Client) ProbeUDPSupport(ctx context.Context) *UDPSupportResult {
+	resolverAddr := net.UDPAddrFromAddrPort(netip.MustParseAddrPort(connectivity.DefaultUDPProbeAddress))
+	return c.probeUDPSupport(ctx, resolverAddr)
+}
+
+// probeUDPSupport implements [Client.ProbeUDPSupport] with a query to the resolver at resolverAddr.
+func (c *Client) probeUDPSupport(ctx context.Context, resolverAddr net.Addr) *UDPSupportResult {
+	ctx, cancel := context.WithTimeout(ctx, udpSupportProbeTimeout)
+	defer cancel()
+	result := &UDPSupportResult{ConnType: c.PacketConnType()}
+	if err := connectivity.CheckUDPConnectivityWithDNSContext(ctx, c, resolverAddr); err != nil {
+		result.Error = platerrors.ToPlatformError(err)
+		return result
+	}
+	result.Functional = true
+	return result
 }
 
 // ComprehensiveTestResult represents the result of comprehensive connectivity and bandwidth testing.
@@ -50,48 +285,127 @@ type ComprehensiveTestResult struct {
 	// Connectivity results
 	TCPError, UDPError *platerrors.PlatformError
 
-	// Bandwidth results
+	// Bandwidth results. The value of a phase that failed or didn't run is -1.
 	DownloadSpeedKBps int64 // Download speed in KB/s
 	UploadSpeedKBps   int64 // Upload speed in KB/s
 	LatencyMs         int64 // Round-trip latency in milliseconds
-	BandwidthError    *platerrors.PlatformError
+	// BandwidthError is why the bandwidth test failed or didn't run, or the failure of its first
+	// failed phase.
+	BandwidthError *platerrors.PlatformError
+	// LatencyError, DownloadError and UploadError are the failures of each phase of the
+	// bandwidth test, so that the results of the other phases can still be used.
+	LatencyError, DownloadError, UploadError *platerrors.PlatformError
 }
 
+const (
+	// comprehensiveBandwidthTimeout is the time budget of the bandwidth phase of
+	// [PerformComprehensiveTestContext].
+	comprehensiveBandwidthTimeout = 30 * time.Second
+	// defaultComprehensiveTestTimeout is the time budget of [PerformComprehensiveTest].
+	defaultComprehensiveTestTimeout = defaultConnectivityTimeout + comprehensiveBandwidthTimeout
+)
+
 // PerformComprehensiveTest performs both connectivity and bandwidth testing.
 //
 // It first checks TCP and UDP connectivity, then performs bandwidth and latency tests
 // if the connectivity checks pass. This provides a complete picture of the proxy's performance.
+// [PlanComprehensiveTest] reports the endpoints and time budgets it uses without running it.
 func PerformComprehensiveTest(client *Client) *ComprehensiveTestResult {
-	result := &ComprehensiveTestResult{}
+	ctx, cancel := context.WithTimeout(context.Background(), defaultComprehensiveTestTimeout)
+	defer cancel()
+	return PerformComprehensiveTestContext(ctx, client)
+}
+
+// PerformComprehensiveTestContext is like [PerformComprehensiveTest], but stops when ctx is done.
+// The running phase is aborted and the bandwidth phase is skipped, with a BandwidthError of code
+// [platerrors.OperationCanceled], or [platerrors.Timeout] if the deadline of ctx passed.
+func PerformComprehensiveTestContext(ctx context.Context, client *Client) *ComprehensiveTestResult {
+	result := &ComprehensiveTestResult{DownloadSpeedKBps: -1, UploadSpeedKBps: -1, LatencyMs: -1}
 
 	// First perform connectivity tests
-	connectivityResult := CheckTCPAndUDPConnectivity(client)
+	connectivityResult := checkTCPAndUDPConnectivity(ctx, client, defaultConnectivityTimeout, connectivity.ProbeTargets{})
 	result.TCPError = connectivityResult.TCPError
 	result.UDPError = connectivityResult.UDPError
-
+	if ctx.Err() != nil {
+		result.BandwidthError = comprehensiveTestStoppedError(ctx.Err())
+		return result
+	}
 	// Only perform bandwidth tests if TCP connectivity succeeds
-	if result.TCPError == nil {
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		defer cancel()
-
-		bandwidthResult := client.PerformBandwidthTest(ctx)
-		if bandwidthResult.Error != nil {
-			result.BandwidthError = bandwidthResult.Error
-			// Set default values on bandwidth test failure
-			result.DownloadSpeedKBps = -1
-			result.UploadSpeedKBps = -1
-			result.LatencyMs = -1
-		} else {
-			result.DownloadSpeedKBps = bandwidthResult.DownloadSpeedKBps
-			result.UploadSpeedKBps = bandwidthResult.UploadSpeedKBps
-			result.LatencyMs = bandwidthResult.LatencyMs
-		}
-	} else {
-		// TCP failed, so skip bandwidth tests
-		result.DownloadSpeedKBps = -1
-		result.UploadSpeedKBps = -1
-		result.LatencyMs = -1
+	if result.TCPError != nil {
+		return result
 	}
 
+	bandwidthCtx, cancel := context.WithTimeout(ctx, comprehensiveBandwidthTimeout)
+	defer cancel()
+	bandwidthResult := client.PerformBandwidthTest(bandwidthCtx)
+	if ctx.Err() != nil {
+		// The results are partial.
+		result.BandwidthError = comprehensiveTestStoppedError(ctx.Err())
+		return result
+	}
+	result.DownloadSpeedKBps = bandwidthResult.DownloadSpeedKBps
+	result.UploadSpeedKBps = bandwidthResult.UploadSpeedKBps
+	result.LatencyMs = bandwidthResult.LatencyMs
+	result.BandwidthError = bandwidthResult.Error
+	result.LatencyError = bandwidthResult.LatencyError
+	result.DownloadError = bandwidthResult.DownloadError
+	result.UploadError = bandwidthResult.UploadError
 	return result
 }
+
+// comprehensiveTestStoppedError returns the error for a comprehensive test whose context is done
+// with err.
+func comprehensiveTestStoppedError(err error) *platerrors.PlatformError {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &platerrors.PlatformError{Code: platerrors.Timeout, Message: "comprehensive test timed out"}
+	}
+	return &platerrors.PlatformError{Code: platerrors.OperationCanceled, Message: "comprehensive test was canceled"}
+}
+
+// QuickTestResult is the result of [Client.QuickTest].
+//
+// We use a struct instead of a tuple to preserve a strongly typed error that gobind recognizes.
+type QuickTestResult struct {
+	// Healthy tells whether the proxy relayed both probes.
+	Healthy bool
+	// LatencyMs is the round-trip time of the latency probe, or -1 if the test failed.
+	LatencyMs int64
+	// Error is why the test failed, or nil if the proxy is healthy.
+	Error *platerrors.PlatformError
+}
+
+// quickTestTimeout is the time budget of [Client.QuickTest].
+const quickTestTimeout = 3 * time.Second
+
+// QuickTest checks in a few seconds at most that the proxy works, with a TCP connection and an
+// HTTP HEAD request through it. It's meant for a snappy health indicator, for example when the
+// app comes to the foreground, where [PerformComprehensiveTest] would take too long.
+//
+// It's cheap enough to call often, since the latency probe reuses the connections of the client.
+// It fails with [platerrors.Timeout] if the probes don't complete in three seconds.
+func (c *Client) QuickTest(ctx context.Context) *QuickTestResult {
+	return c.quickTest(ctx, connectivity.DefaultTCPProbeAddress, "http://"+connectivity.DefaultTCPProbeAddress)
+}
+
+// quickTest implements [Client.QuickTest] with a connection to probeAddress and a HEAD request
+// to latencyURL.
+func (c *Client) quickTest(ctx context.Context, probeAddress, latencyURL string) *QuickTestResult {
+	ctx, cancel := context.WithTimeout(ctx, quickTestTimeout)
+	defer cancel()
+
+	if perr := c.CheckReachability(ctx, probeAddress); perr != nil {
+		return &QuickTestResult{LatencyMs: -1, Error: perr}
+	}
+	latency := c.newSpeedTester().latency(ctx, latencyURL)
+	if latency.Error != nil {
+		return &QuickTestResult{LatencyMs: -1, Error: latency.Error}
+	}
+	if err := ctx.Err(); err != nil {
+		perr := &platerrors.PlatformError{Code: platerrors.OperationCanceled, Message: "quick test was canceled"}
+		if errors.Is(err, context.DeadlineExceeded) {
+			perr = &platerrors.PlatformError{Code: platerrors.Timeout, Message: "quick test timed out"}
+		}
+		return &QuickTestResult{LatencyMs: -1, Error: perr}
+	}
+	return &QuickTestResult{Healthy: true, LatencyMs: latency.LatencyMs}
+}
@@ -32,12 +32,24 @@ const (
 	bufferLength        = 512
 )
 
+// Default destinations of the checks of [CheckTCPAndUDPConnectivity].
 const (
-	testTCPWebsite    = "http://example.com"
-	testDNSServerIP   = "1.1.1.1"
-	testDNSServerPort = 53
+	// DefaultTCPProbeAddress is an HTTP server that gets a HEAD request.
+	DefaultTCPProbeAddress = "example.com:80"
+	// DefaultUDPProbeAddress is a DNS resolver that gets a query.
+	DefaultUDPProbeAddress = "1.1.1.1:53"
 )
 
+// ProbeTargets are the destinations of the TCP and UDP checks, for networks that block the
+// defaults. Empty addresses mean the defaults.
+type ProbeTargets struct {
+	// TCPAddress is the host:port of an HTTP server, which gets a HEAD request.
+	TCPAddress string
+	// UDPAddress is the host:port of a DNS resolver, which gets a query. A host name is
+	// resolved locally.
+	UDPAddress string
+}
+
 // CheckTCPAndUDPConnectivity checks whether the given `tcp` and `udp` clients can relay traffic.
 //
 // It parallelizes the execution of TCP and UDP checks, and returns a TCP error and a UDP error.
@@ -45,23 +57,107 @@ const (
 func CheckTCPAndUDPConnectivity(
 	tcp transport.StreamDialer, udp transport.PacketListener,
 ) (tcpErr error, udpErr error) {
+	return CheckTCPAndUDPConnectivityWithTimeout(tcp, udp, tcpTimeout)
+}
+
+// CheckTCPAndUDPConnectivityWithTimeout is like [CheckTCPAndUDPConnectivity], but each of the
+// TCP and UDP checks gives up after `timeout`. The checks have independent deadlines, so a slow
+// protocol doesn't consume the time budget of the other.
+func CheckTCPAndUDPConnectivityWithTimeout(
+	tcp transport.StreamDialer, udp transport.PacketListener, timeout time.Duration,
+) (tcpErr error, udpErr error) {
+	return CheckTCPAndUDPConnectivityContext(context.Background(), tcp, udp, timeout)
+}
+
+// CheckTCPAndUDPConnectivityContext is like [CheckTCPAndUDPConnectivityWithTimeout], but both
+// checks are also aborted when ctx is done.
+func CheckTCPAndUDPConnectivityContext(
+	ctx context.Context, tcp transport.StreamDialer, udp transport.PacketListener, timeout time.Duration,
+) (tcpErr error, udpErr error) {
+	return CheckTCPAndUDPConnectivityWithTargets(ctx, tcp, udp, timeout, ProbeTargets{})
+}
+
+// CheckTCPAndUDPConnectivityWithTargets is like [CheckTCPAndUDPConnectivityContext], but the
+// checks probe the given targets. A target that is not a valid host:port address fails its check
+// with an [platerrors.InvalidConfig] error.
+func CheckTCPAndUDPConnectivityWithTargets(
+	ctx context.Context, tcp transport.StreamDialer, udp transport.PacketListener, timeout time.Duration, targets ProbeTargets,
+) (tcpErr error, udpErr error) {
+	tcpAddress := targets.TCPAddress
+	if tcpAddress == "" {
+		tcpAddress = DefaultTCPProbeAddress
+	}
+	udpAddress := targets.UDPAddress
+	if udpAddress == "" {
+		udpAddress = DefaultUDPProbeAddress
+	}
+
 	// Start asynchronous UDP support check.
 	udpErrChan := make(chan error)
 	go func() {
-		resolverAddr := &net.UDPAddr{IP: net.ParseIP(testDNSServerIP), Port: testDNSServerPort}
-		udpErrChan <- CheckUDPConnectivityWithDNS(udp, resolverAddr)
+		udpCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		resolverAddr, err := resolveUDPProbeAddress(udpCtx, udpAddress)
+		if err != nil {
+			udpErrChan <- err
+			return
+		}
+		udpErrChan <- CheckUDPConnectivityWithDNSContext(udpCtx, udp, resolverAddr)
 	}()
 
-	tcpErr = CheckTCPConnectivityWithHTTP(tcp, testTCPWebsite)
+	if _, _, err := net.SplitHostPort(tcpAddress); err != nil {
+		tcpErr = invalidProbeAddressError(tcpAddress, err)
+	} else {
+		tcpCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		tcpErr = CheckTCPConnectivityWithHTTPContext(tcpCtx, tcp, "http://"+tcpAddress)
+	}
 	udpErr = <-udpErrChan
 	return
 }
 
+// resolveUDPProbeAddress resolves the host:port address of a UDP check.
+func resolveUDPProbeAddress(ctx context.Context, address string) (*net.UDPAddr, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, invalidProbeAddressError(address, err)
+	}
+	ips, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil, platerrors.PlatformError{
+			Code:    platerrors.ResolveIPFailed,
+			Message: "failed to resolve the UDP probe address",
+			Details: platerrors.ErrorDetails{"address": address},
+			Cause:   platerrors.ToPlatformError(err),
+		}
+	}
+	portNumber, err := net.LookupPort("udp", port)
+	if err != nil {
+		return nil, invalidProbeAddressError(address, err)
+	}
+	return &net.UDPAddr{IP: ips[0].Unmap().AsSlice(), Port: portNumber}, nil
+}
+
+func invalidProbeAddressError(address string, cause error) error {
+	return platerrors.PlatformError{
+		Code:    platerrors.InvalidConfig,
+		Message: "probe address must be in host:port form",
+		Details: platerrors.ErrorDetails{"address": address},
+		Cause:   platerrors.ToPlatformError(cause),
+	}
+}
+
 // CheckUDPConnectivityWithDNS determines whether the Outline proxy represented by `client` and
 // the network support UDP traffic by issuing a DNS query though a resolver at `resolverAddr`.
 // Returns nil on success or an error on failure.
 func CheckUDPConnectivityWithDNS(client transport.PacketListener, resolverAddr net.Addr) error {
-	conn, err := client.ListenPacket(context.Background())
+	return CheckUDPConnectivityWithDNSContext(context.Background(), client, resolverAddr)
+}
+
+// CheckUDPConnectivityWithDNSContext is like [CheckUDPConnectivityWithDNS], but gives up
+// when `ctx` is done.
+func CheckUDPConnectivityWithDNSContext(ctx context.Context, client transport.PacketListener, resolverAddr net.Addr) error {
+	conn, err := client.ListenPacket(ctx)
 	if err != nil {
 		return platerrors.PlatformError{
 			Code:    platerrors.ProxyServerUDPUnsupported,
@@ -72,8 +168,8 @@ func CheckUDPConnectivityWithDNS(client transport.PacketListener, resolverAddr n
 	defer conn.Close()
 
 	buf := make([]byte, bufferLength)
-	for attempt := 0; attempt < udpMaxRetryAttempts; attempt++ {
-		conn.SetDeadline(time.Now().Add(udpTimeout))
+	for attempt := 0; attempt < udpMaxRetryAttempts && ctx.Err() == nil; attempt++ {
+		conn.SetDeadline(deadlineWithin(ctx, udpTimeout))
 		_, err := conn.WriteTo(getDNSRequest(), resolverAddr)
 		if err != nil {
 			continue
@@ -100,8 +196,14 @@ func CheckUDPConnectivityWithDNS(client transport.PacketListener, resolverAddr n
 //
 // Returns nil on success, error on connectivity failure.
 func CheckTCPConnectivityWithHTTP(dialer transport.StreamDialer, targetURL string) error {
-	deadline := time.Now().Add(tcpTimeout)
-	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	return CheckTCPConnectivityWithHTTPContext(context.Background(), dialer, targetURL)
+}
+
+// CheckTCPConnectivityWithHTTPContext is like [CheckTCPConnectivityWithHTTP], but gives up
+// when `ctx` is done.
+func CheckTCPConnectivityWithHTTPContext(ctx context.Context, dialer transport.StreamDialer, targetURL string) error {
+	deadline := deadlineWithin(ctx, tcpTimeout)
+	ctx, cancel := context.WithDeadline(ctx, deadline)
 	defer cancel()
 	req, err := http.NewRequest("HEAD", targetURL, nil)
 	if err != nil {
@@ -140,6 +242,15 @@ func CheckTCPConnectivityWithHTTP(dialer transport.StreamDialer, targetURL strin
 	return nil
 }
 
+// deadlineWithin returns the time `timeout` from now, or the deadline of `ctx` if it's earlier.
+func deadlineWithin(ctx context.Context, timeout time.Duration) time.Time {
+	deadline := time.Now().Add(timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		return ctxDeadline
+	}
+	return deadline
+}
+
 func getDNSRequest() []byte {
 	return []byte{
 		0, 0, // [0-1]   query ID
@@ -18,6 +18,8 @@ import (
 	"context"
 	"errors"
 	"net"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -67,14 +69,90 @@ func TestCheckTCPConnectivityWithHTTP_FailAuthentication(t *testing.T) {
 	require.Equal(t, platerrors.ProxyServerReadFailed, perr.Code)
 }
 
+func TestCheckTCPAndUDPConnectivityWithTargets(t *testing.T) {
+	httpServer := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer httpServer.Close()
+	udpServer, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer udpServer.Close()
+	go func() {
+		buf := make([]byte, bufferLength)
+		for {
+			n, addr, err := udpServer.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			udpServer.WriteTo(buf[:n], addr)
+		}
+	}()
+
+	targets := ProbeTargets{
+		TCPAddress: httpServer.Listener.Addr().String(),
+		UDPAddress: udpServer.LocalAddr().String(),
+	}
+	tcpErr, udpErr := CheckTCPAndUDPConnectivityWithTargets(
+		context.Background(), &transport.TCPDialer{}, &transport.UDPListener{}, time.Second, targets)
+	require.NoError(t, tcpErr)
+	require.NoError(t, udpErr)
+}
+
+func TestCheckTCPAndUDPConnectivityWithTargets_Invalid(t *testing.T) {
+	client := &fakeSSClient{}
+	targets := ProbeTargets{TCPAddress: "example.com", UDPAddress: "1.1.1.1"}
+	tcpErr, udpErr := CheckTCPAndUDPConnectivityWithTargets(context.Background(), client, client, time.Second, targets)
+	require.Equal(t, platerrors.InvalidConfig, platerrors.ToPlatformError(tcpErr).Code)
+	require.Equal(t, platerrors.InvalidConfig, platerrors.ToPlatformError(udpErr).Code)
+}
+
+func TestCheckTCPAndUDPConnectivityWithTimeout(t *testing.T) {
+	client := &fakeSSClient{hangTCP: true, hangUDP: true}
+
+	start := time.Now()
+	tcpErr, udpErr := CheckTCPAndUDPConnectivityWithTimeout(client, client, 200*time.Millisecond)
+	require.Less(t, time.Since(start), time.Second)
+
+	require.Error(t, tcpErr)
+	require.Equal(t, platerrors.ProxyServerUnreachable, platerrors.ToPlatformError(tcpErr).Code)
+	require.Error(t, udpErr)
+	require.Equal(t, platerrors.ProxyServerUDPUnsupported, platerrors.ToPlatformError(udpErr).Code)
+}
+
+func TestCheckTCPAndUDPConnectivityWithTimeout_IndependentBudgets(t *testing.T) {
+	// The UDP check uses up its whole budget, which must not affect the TCP check.
+	client := &fakeSSClient{hangUDP: true}
+
+	start := time.Now()
+	tcpErr, udpErr := CheckTCPAndUDPConnectivityWithTimeout(client, client, 200*time.Millisecond)
+	require.NoError(t, tcpErr)
+	require.Error(t, udpErr)
+	require.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+}
+
+func TestCheckUDPConnectivityWithDNSContext_Canceled(t *testing.T) {
+	client := &fakeSSClient{hangUDP: true}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := CheckUDPConnectivityWithDNSContext(ctx, client, &net.UDPAddr{})
+	require.Error(t, err)
+}
+
 // Fake shadowsocks.Client that can be configured to return failing UDP and TCP connections.
 type fakeSSClient struct {
 	failReachability   bool
 	failAuthentication bool
 	failUDP            bool
+	// hangTCP makes dials block until the context is done.
+	hangTCP bool
+	// hangUDP makes UDP reads block until the deadline.
+	hangUDP bool
 }
 
-func (c *fakeSSClient) DialStream(_ context.Context, raddr string) (transport.StreamConn, error) {
+func (c *fakeSSClient) DialStream(ctx context.Context, raddr string) (transport.StreamConn, error) {
+	if c.hangTCP {
+		<-ctx.Done()
+		return nil, &net.OpError{Err: ctx.Err()}
+	}
 	if c.failReachability {
 		// OpError.Error() panics if Err is nil.
 		return nil, &net.OpError{Err: errors.New("unreachable fakeSSClient")}
@@ -88,7 +166,7 @@ func (c *fakeSSClient) ListenPacket(_ context.Context) (net.PacketConn, error) {
 	}
 	// The UDP check should fail if any of the failure conditions are true since it is a superset of the others.
 	failRead := c.failAuthentication || c.failUDP || c.failReachability
-	return &fakePacketConn{PacketConn: conn, failRead: failRead}, nil
+	return &fakePacketConn{PacketConn: conn, failRead: failRead, hang: c.hangUDP}, nil
 }
 func (c *fakeSSClient) SetTCPSaltGenerator(salter shadowsocks.SaltGenerator) {
 }
@@ -98,6 +176,7 @@ type fakePacketConn struct {
 	net.PacketConn
 	addr     net.Addr
 	failRead bool
+	hang     bool
 }
 
 func (c *fakePacketConn) WriteTo(b []byte, addr net.Addr) (int, error) {
@@ -106,6 +185,10 @@ func (c *fakePacketConn) WriteTo(b []byte, addr net.Addr) (int, error) {
 }
 
 func (c *fakePacketConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	if c.hang {
+		// Nothing is ever sent to the underlying conn, so this blocks until the deadline.
+		return c.PacketConn.ReadFrom(b)
+	}
 	if c.failRead {
 		return 0, c.addr, errors.New("Fake read error")
 	}
@@ -0,0 +1,116 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/connectivity"
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+)
+
+// maxConnectivityAttempts caps the number of attempts of [CheckTCPAndUDPConnectivityN].
+const maxConnectivityAttempts = 100
+
+// connectivityAttemptSpacing is the pause between the attempts of [CheckTCPAndUDPConnectivityN],
+// so that they sample the network over a few seconds rather than a single burst.
+const connectivityAttemptSpacing = time.Second
+
+// ConnectivityAttempt is the outcome of one of the attempts of [CheckTCPAndUDPConnectivityN].
+type ConnectivityAttempt struct {
+	DurationMs int64 // Time the TCP and UDP checks of the attempt took
+	TCPError   *platerrors.PlatformError
+	UDPError   *platerrors.PlatformError
+}
+
+// ConnectivityHistory is the result of [CheckTCPAndUDPConnectivityN].
+//
+// We use a struct instead of a tuple to preserve a strongly typed error that gobind recognizes.
+type ConnectivityHistory struct {
+	Attempts       []ConnectivityAttempt
+	TCPSuccessRate float64 // Fraction of the attempts whose TCP check passed, from 0 to 1
+	UDPSuccessRate float64 // Fraction of the attempts whose UDP check passed, from 0 to 1
+	// Error is why no attempt completed, such as invalid arguments or a canceled context.
+	Error *platerrors.PlatformError
+}
+
+// CheckTCPAndUDPConnectivityN is like [CheckTCPAndUDPConnectivity], but runs the checks attempts
+// times, about a second apart, and reports the outcome of each attempt along with the success
+// rates. This tells a blocked server from a flaky network that only drops some of the traffic.
+func CheckTCPAndUDPConnectivityN(client *Client, attempts int) *ConnectivityHistory {
+	return CheckTCPAndUDPConnectivityNContext(context.Background(), client, attempts)
+}
+
+// CheckTCPAndUDPConnectivityNContext is like [CheckTCPAndUDPConnectivityN], but stops when ctx is
+// done. The history then holds the attempts completed so far, or has an Error of code
+// [platerrors.OperationCanceled] if there are none.
+func CheckTCPAndUDPConnectivityNContext(ctx context.Context, client *Client, attempts int) *ConnectivityHistory {
+	return checkTCPAndUDPConnectivityN(ctx, client, attempts, connectivityAttemptSpacing, connectivity.ProbeTargets{})
+}
+
+func checkTCPAndUDPConnectivityN(ctx context.Context, client *Client, attempts int, spacing time.Duration, targets connectivity.ProbeTargets) *ConnectivityHistory {
+	if attempts < 1 || attempts > maxConnectivityAttempts {
+		return &ConnectivityHistory{Error: &platerrors.PlatformError{
+			Code:    platerrors.InvalidConfig,
+			Message: fmt.Sprintf("connectivity attempts must be between 1 and %d", maxConnectivityAttempts),
+			Details: platerrors.ErrorDetails{"attempts": attempts},
+		}}
+	}
+	history := &ConnectivityHistory{}
+	tcpSuccesses, udpSuccesses := 0, 0
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			timer := time.NewTimer(spacing)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		start := time.Now()
+		result := checkTCPAndUDPConnectivity(ctx, client, defaultConnectivityTimeout, targets)
+		if ctx.Err() != nil {
+			break // The checks were aborted, so their errors don't tell about the network.
+		}
+		history.Attempts = append(history.Attempts, ConnectivityAttempt{
+			DurationMs: time.Since(start).Milliseconds(),
+			TCPError:   result.TCPError,
+			UDPError:   result.UDPError,
+		})
+		if result.TCPError == nil {
+			tcpSuccesses++
+		}
+		if result.UDPError == nil {
+			udpSuccesses++
+		}
+	}
+	if len(history.Attempts) == 0 {
+		history.Error = &platerrors.PlatformError{
+			Code:    platerrors.OperationCanceled,
+			Message: "connectivity check was canceled",
+		}
+		return history
+	}
+	history.TCPSuccessRate = float64(tcpSuccesses) / float64(len(history.Attempts))
+	history.UDPSuccessRate = float64(udpSuccesses) / float64(len(history.Attempts))
+	logger().Info("connectivity history done", "attempts", len(history.Attempts),
+		"tcpSuccessRate", history.TCPSuccessRate, "udpSuccessRate", history.UDPSuccessRate)
+	return history
+}
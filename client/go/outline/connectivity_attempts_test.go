@@ -0,0 +1,107 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/connectivity"
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/Jigsaw-Code/outline-sdk/transport"
+	"github.com/stretchr/testify/require"
+)
+
+func newConnectivityTestTargets(t *testing.T) connectivity.ProbeTargets {
+	httpServer := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	t.Cleanup(httpServer.Close)
+	return connectivity.ProbeTargets{
+		TCPAddress: httpServer.Listener.Addr().String(),
+		UDPAddress: newUDPEchoServer(t, nil),
+	}
+}
+
+func Test_CheckTCPAndUDPConnectivityN(t *testing.T) {
+	targets := newConnectivityTestTargets(t)
+	history := checkTCPAndUDPConnectivityN(context.Background(), newDirectTestClient(), 3, time.Millisecond, targets)
+	require.Nil(t, history.Error)
+	require.Len(t, history.Attempts, 3)
+	for _, attempt := range history.Attempts {
+		require.Nil(t, attempt.TCPError, "Got %v", attempt.TCPError)
+		require.Nil(t, attempt.UDPError, "Got %v", attempt.UDPError)
+		require.GreaterOrEqual(t, attempt.DurationMs, int64(0))
+	}
+	require.Equal(t, 1.0, history.TCPSuccessRate)
+	require.Equal(t, 1.0, history.UDPSuccessRate)
+}
+
+func Test_CheckTCPAndUDPConnectivityN_Intermittent(t *testing.T) {
+	targets := newConnectivityTestTargets(t)
+	client := newDirectTestClient()
+	var dials atomic.Int32
+	dial := client.sd.Dial
+	client.sd.Dial = func(ctx context.Context, address string) (transport.StreamConn, error) {
+		if dials.Add(1)%2 == 0 {
+			return nil, errors.New("connection reset")
+		}
+		return dial(ctx, address)
+	}
+
+	history := checkTCPAndUDPConnectivityN(context.Background(), client, 4, time.Millisecond, targets)
+	require.Nil(t, history.Error)
+	require.Len(t, history.Attempts, 4)
+	require.Nil(t, history.Attempts[0].TCPError)
+	require.NotNil(t, history.Attempts[1].TCPError)
+	require.Equal(t, 0.5, history.TCPSuccessRate)
+	require.Equal(t, 1.0, history.UDPSuccessRate)
+}
+
+func Test_CheckTCPAndUDPConnectivityN_Canceled(t *testing.T) {
+	targets := newConnectivityTestTargets(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	client := newDirectTestClient()
+	var dials atomic.Int32
+	dial := client.sd.Dial
+	client.sd.Dial = func(ctx context.Context, address string) (transport.StreamConn, error) {
+		if dials.Add(1) > 1 {
+			// Abort the second attempt.
+			cancel()
+		}
+		return dial(ctx, address)
+	}
+
+	history := checkTCPAndUDPConnectivityN(ctx, client, 5, time.Millisecond, targets)
+	require.Nil(t, history.Error)
+	require.Len(t, history.Attempts, 1)
+	require.Equal(t, 1.0, history.TCPSuccessRate)
+
+	history = CheckTCPAndUDPConnectivityNContext(ctx, client, 5)
+	require.NotNil(t, history.Error)
+	require.Equal(t, platerrors.OperationCanceled, history.Error.Code)
+	require.Empty(t, history.Attempts)
+}
+
+func Test_CheckTCPAndUDPConnectivityN_InvalidAttempts(t *testing.T) {
+	for _, attempts := range []int{0, -1, maxConnectivityAttempts + 1} {
+		history := CheckTCPAndUDPConnectivityN(newDirectTestClient(), attempts)
+		require.NotNil(t, history.Error)
+		require.Equal(t, platerrors.InvalidConfig, history.Error.Code)
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/Jigsaw-Code/outline-sdk/transport"
+)
+
+// ConnectivityResultByFamily represents the result of [CheckTCPAndUDPConnectivityByFamily].
+//
+// We use a struct instead of a tuple to preserve a strongly typed error that gobind recognizes.
+type ConnectivityResultByFamily struct {
+	// IPv4 is the result of connecting to the server over IPv4, or nil if the server has no IPv4 address.
+	IPv4 *TCPAndUDPConnectivityResult
+	// IPv6 is the result of connecting to the server over IPv6, or nil if the server has no IPv6 address.
+	IPv6 *TCPAndUDPConnectivityResult
+	// Error is set if the check could not be performed at all.
+	Error *platerrors.PlatformError
+}
+
+// ipFamily is an IP address family, identified by the suffix of its network names ("tcp4", "udp6").
+type ipFamily string
+
+const (
+	ipFamilyV4 ipFamily = "4"
+	ipFamilyV6 ipFamily = "6"
+)
+
+func (f ipFamily) matches(ip net.IP) bool {
+	return (ip.To4() != nil) == (f == ipFamilyV4)
+}
+
+// CheckTCPAndUDPConnectivityByFamily checks if a [Client] can relay TCP and UDP traffic when
+// connecting to the server over IPv4 and over IPv6 separately.
+//
+// This surfaces networks where one family works and the other silently fails. Families the
+// server has no address for are skipped, so servers with only an A record get a nil IPv6 result.
+func CheckTCPAndUDPConnectivityByFamily(client *Client) *ConnectivityResultByFamily {
+	client = client.transportClient()
+	if client.config.Load() == nil {
+		return &ConnectivityResultByFamily{Error: &platerrors.PlatformError{
+			Code:    platerrors.InternalError,
+			Message: "client was not created from a config",
+		}}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultConnectivityTimeout)
+	defer cancel()
+	firstHops := []string{client.sd.FirstHop, client.pl.FirstHop}
+	addrsByHost := make(map[string][]net.IP)
+	for _, firstHop := range firstHops {
+		host, _, err := net.SplitHostPort(firstHop)
+		if err != nil {
+			continue
+		}
+		if _, ok := addrsByHost[host]; ok {
+			continue
+		}
+		ips, err := lookupIP(ctx, host)
+		if err != nil {
+			return &ConnectivityResultByFamily{Error: &platerrors.PlatformError{
+				Code:    platerrors.ResolveIPFailed,
+				Message: "failed to resolve the server address",
+				Details: platerrors.ErrorDetails{"address": firstHop},
+				Cause:   platerrors.ToPlatformError(err),
+			}}
+		}
+		addrsByHost[host] = ips
+	}
+
+	result := &ConnectivityResultByFamily{}
+	var wg sync.WaitGroup
+	for _, family := range []ipFamily{ipFamilyV4, ipFamilyV6} {
+		addrMap, ok := newFamilyAddrMap(family, firstHops, addrsByHost)
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			familyResult := checkFamilyConnectivity(client, family, addrMap)
+			if family == ipFamilyV4 {
+				result.IPv4 = familyResult
+			} else {
+				result.IPv6 = familyResult
+			}
+		}()
+	}
+	wg.Wait()
+	return result
+}
+
+func checkFamilyConnectivity(client *Client, family ipFamily, addrMap map[string]string) *TCPAndUDPConnectivityResult {
+	tcpDialer := &familyStreamDialer{network: "tcp" + string(family), addrMap: addrMap}
+	udpDialer := &familyPacketDialer{network: "udp" + string(family), addrMap: addrMap}
+	familyClient, err := newProbeClient(client, tcpDialer, udpDialer)
+	if err != nil {
+		perr := platerrors.ToPlatformError(err)
+		return &TCPAndUDPConnectivityResult{TCPError: perr, UDPError: perr}
+	}
+	result := CheckTCPAndUDPConnectivity(familyClient)
+	if addr, ok := addrMap[result.ServerAddress]; ok {
+		result.ResolvedAddress = addr
+	}
+	return result
+}
+
+// newFamilyAddrMap maps the first hop addresses, and all the IP addresses they resolve to, to an
+// address of the given family. It returns false if no first hop has an address of that family.
+//
+// The config parser may pre-resolve the first hop to an IP of the wrong family, which is why
+// we need to map the IPs as well.
+func newFamilyAddrMap(family ipFamily, firstHops []string, addrsByHost map[string][]net.IP) (map[string]string, bool) {
+	addrMap := make(map[string]string)
+	for _, firstHop := range firstHops {
+		host, port, err := net.SplitHostPort(firstHop)
+		if err != nil {
+			continue
+		}
+		var familyIP net.IP
+		for _, ip := range addrsByHost[host] {
+			if family.matches(ip) {
+				familyIP = ip
+				break
+			}
+		}
+		if familyIP == nil {
+			continue
+		}
+		familyAddr := net.JoinHostPort(familyIP.String(), port)
+		addrMap[firstHop] = familyAddr
+		for _, ip := range addrsByHost[host] {
+			addrMap[net.JoinHostPort(ip.String(), port)] = familyAddr
+		}
+	}
+	return addrMap, len(addrMap) > 0
+}
+
+func lookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	ips := make([]net.IP, len(addrs))
+	for i, addr := range addrs {
+		ips[i] = addr.IP
+	}
+	return ips, nil
+}
+
+// familyStreamDialer is a [transport.StreamDialer] that only connects over one IP family.
+type familyStreamDialer struct {
+	network string
+	addrMap map[string]string
+	dialer  net.Dialer
+}
+
+var _ transport.StreamDialer = (*familyStreamDialer)(nil)
+
+func (d *familyStreamDialer) DialStream(ctx context.Context, addr string) (transport.StreamConn, error) {
+	if mapped, ok := d.addrMap[addr]; ok {
+		addr = mapped
+	}
+	conn, err := d.dialer.DialContext(ctx, d.network, addr)
+	if err != nil {
+		return nil, err
+	}
+	return conn.(*net.TCPConn), nil
+}
+
+// familyPacketDialer is a [transport.PacketDialer] that only connects over one IP family.
+type familyPacketDialer struct {
+	network string
+	addrMap map[string]string
+	dialer  net.Dialer
+}
+
+var _ transport.PacketDialer = (*familyPacketDialer)(nil)
+
+func (d *familyPacketDialer) DialPacket(ctx context.Context, addr string) (net.Conn, error) {
+	if mapped, ok := d.addrMap[addr]; ok {
+		addr = mapped
+	}
+	return d.dialer.DialContext(ctx, d.network, addr)
+}
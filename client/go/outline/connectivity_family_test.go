@@ -0,0 +1,98 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_CheckTCPAndUDPConnectivityByFamily_IPv4Only(t *testing.T) {
+	result := NewClient("ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpTRUNSRVQ@127.0.0.1:1/")
+	require.Nil(t, result.Error, "Got %v", result.Error)
+
+	byFamily := CheckTCPAndUDPConnectivityByFamily(result.Client)
+	require.Nil(t, byFamily.Error)
+	require.Nil(t, byFamily.IPv6)
+	require.NotNil(t, byFamily.IPv4)
+	require.NotNil(t, byFamily.IPv4.TCPError) // Nothing is listening.
+	require.Equal(t, "127.0.0.1:1", byFamily.IPv4.ResolvedAddress)
+}
+
+func Test_CheckTCPAndUDPConnectivityByFamily_IPv6Only(t *testing.T) {
+	result := NewClient("ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpTRUNSRVQ@[::1]:1/")
+	require.Nil(t, result.Error, "Got %v", result.Error)
+
+	byFamily := CheckTCPAndUDPConnectivityByFamily(result.Client)
+	require.Nil(t, byFamily.Error)
+	require.Nil(t, byFamily.IPv4)
+	require.NotNil(t, byFamily.IPv6)
+	require.NotNil(t, byFamily.IPv6.TCPError)
+}
+
+func Test_CheckTCPAndUDPConnectivityByFamily_ResolveFailed(t *testing.T) {
+	result := NewClient("ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpTRUNSRVQ@outline.invalid:4321/")
+	require.Nil(t, result.Error, "Got %v", result.Error)
+
+	byFamily := CheckTCPAndUDPConnectivityByFamily(result.Client)
+	require.NotNil(t, byFamily.Error)
+	require.Equal(t, platerrors.ResolveIPFailed, byFamily.Error.Code)
+}
+
+func Test_newFamilyAddrMap(t *testing.T) {
+	addrsByHost := map[string][]net.IP{
+		"dual.example":  {net.ParseIP("192.0.2.1"), net.ParseIP("2001:db8::1")},
+		"only4.example": {net.ParseIP("192.0.2.2")},
+	}
+
+	v4, ok := newFamilyAddrMap(ipFamilyV4, []string{"dual.example:443", "only4.example:53"}, addrsByHost)
+	require.True(t, ok)
+	require.Equal(t, map[string]string{
+		"dual.example:443":  "192.0.2.1:443",
+		"192.0.2.1:443":     "192.0.2.1:443",
+		"[2001:db8::1]:443": "192.0.2.1:443",
+		"only4.example:53":  "192.0.2.2:53",
+		"192.0.2.2:53":      "192.0.2.2:53",
+	}, v4)
+
+	v6, ok := newFamilyAddrMap(ipFamilyV6, []string{"dual.example:443", "only4.example:53"}, addrsByHost)
+	require.True(t, ok)
+	require.Equal(t, map[string]string{
+		"dual.example:443":  "[2001:db8::1]:443",
+		"192.0.2.1:443":     "[2001:db8::1]:443",
+		"[2001:db8::1]:443": "[2001:db8::1]:443",
+	}, v6)
+
+	_, ok = newFamilyAddrMap(ipFamilyV6, []string{"only4.example:53"}, addrsByHost)
+	require.False(t, ok)
+}
+
+func Test_familyStreamDialer(t *testing.T) {
+	server := newTCPEchoServer(t)
+	addrMap := map[string]string{"server.example:80": server}
+
+	v4 := &familyStreamDialer{network: "tcp4", addrMap: addrMap}
+	conn, err := v4.DialStream(context.Background(), "server.example:80")
+	require.NoError(t, err)
+	conn.Close()
+
+	v6 := &familyStreamDialer{network: "tcp6", addrMap: addrMap}
+	_, err = v6.DialStream(context.Background(), "server.example:80")
+	require.Error(t, err)
+}
@@ -0,0 +1,303 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/Jigsaw-Code/outline-sdk/transport"
+	"github.com/stretchr/testify/require"
+)
+
+// newUnreachableTestClient returns a [Client] with the given first hop that fails all dials.
+func newUnreachableTestClient(firstHop string) *Client {
+	client := newDirectTestClient()
+	client.sd.FirstHop = firstHop
+	client.sd.Dial = func(context.Context, string) (transport.StreamConn, error) {
+		return nil, errors.New("connection refused")
+	}
+	client.pl.PacketListener = failingPacketListener{}
+	return client
+}
+
+func Test_CheckTCPAndUDPConnectivity_ResolvedAddress(t *testing.T) {
+	client := newUnreachableTestClient("127.0.0.1:4321")
+
+	result := CheckTCPAndUDPConnectivityWithTimeout(client, time.Second)
+	require.Equal(t, "127.0.0.1:4321", result.ServerAddress)
+	require.Equal(t, "127.0.0.1:4321", result.ResolvedAddress)
+	require.NotNil(t, result.TCPError)
+	require.Equal(t, platerrors.ProxyServerUnreachable, result.TCPError.Code)
+}
+
+func Test_CheckTCPAndUDPConnectivity_ResolveFailed(t *testing.T) {
+	// The .invalid TLD is guaranteed not to resolve.
+	client := newUnreachableTestClient("outline.invalid:4321")
+
+	result := CheckTCPAndUDPConnectivityWithTimeout(client, time.Second)
+	require.Equal(t, "outline.invalid:4321", result.ServerAddress)
+	require.Empty(t, result.ResolvedAddress)
+	require.NotNil(t, result.TCPError)
+	require.Equal(t, platerrors.ResolveIPFailed, result.TCPError.Code)
+	require.Equal(t, platerrors.ProxyServerUnreachable, result.TCPError.Cause.Code)
+}
+
+func Test_CheckTCPAndUDPConnectivity_UnknownFirstHop(t *testing.T) {
+	failingDialer := transport.FuncStreamDialer(func(context.Context, string) (transport.StreamConn, error) {
+		return nil, errors.New("connection refused")
+	})
+	client, err := NewClientFromDialers(failingDialer, failingPacketListener{})
+	require.NoError(t, err)
+
+	result := CheckTCPAndUDPConnectivityWithTimeout(client, time.Second)
+	require.Empty(t, result.ServerAddress)
+	require.Empty(t, result.ResolvedAddress)
+	require.NotNil(t, result.TCPError)
+	// Without a first hop to resolve, the failure is not blamed on DNS.
+	require.Equal(t, platerrors.ProxyServerUnreachable, result.TCPError.Code)
+}
+
+func Test_Client_CheckReachability(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	client := newDirectTestClient()
+	require.Nil(t, client.CheckReachability(context.Background(), listener.Addr().String()))
+}
+
+func Test_Client_CheckReachability_Errors(t *testing.T) {
+	client := newUnreachableTestClient("127.0.0.1:4321")
+
+	perr := client.CheckReachability(context.Background(), "example.com")
+	require.NotNil(t, perr)
+	require.Equal(t, platerrors.InvalidConfig, perr.Code)
+
+	perr = client.CheckReachability(context.Background(), "example.com:443")
+	require.NotNil(t, perr)
+	require.Equal(t, platerrors.ProxyServerUnreachable, perr.Code)
+	require.Equal(t, "example.com:443", perr.Details["address"])
+
+	client.sd.Dial = func(ctx context.Context, _ string) (transport.StreamConn, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	perr = client.CheckReachability(ctx, "example.com:443")
+	require.NotNil(t, perr)
+	require.Equal(t, platerrors.Timeout, perr.Code)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	perr = client.CheckReachability(ctx, "example.com:443")
+	require.NotNil(t, perr)
+	require.Equal(t, platerrors.OperationCanceled, perr.Code)
+}
+
+func Test_Client_ProbeUDPSupport(t *testing.T) {
+	server, err := net.ResolveUDPAddr("udp", newUDPEchoServer(t, nil))
+	require.NoError(t, err)
+
+	result := newDirectTestClient().probeUDPSupport(context.Background(), server)
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.True(t, result.Functional)
+	require.Equal(t, ConnTypeDirect, result.ConnType)
+}
+
+func Test_Client_ProbeUDPSupport_Unsupported(t *testing.T) {
+	server, err := net.ResolveUDPAddr("udp", newUDPEchoServer(t, nil))
+	require.NoError(t, err)
+
+	result := newUnreachableTestClient("127.0.0.1:4321").probeUDPSupport(context.Background(), server)
+	require.False(t, result.Functional)
+	require.NotNil(t, result.Error)
+	require.Equal(t, platerrors.ProxyServerUDPUnsupported, result.Error.Code)
+}
+
+func Test_Client_ProbeUDPSupport_Blocked(t *testing.T) {
+	server, err := net.ResolveUDPAddr("udp", newUDPEchoServer(t, nil))
+	require.NoError(t, err)
+	clientResult := NewClient("transport: {$type: http-connect, endpoint: proxy.example.com:8080}")
+	require.Nil(t, clientResult.Error, "Got %v", clientResult.Error)
+
+	result := clientResult.Client.probeUDPSupport(context.Background(), server)
+	require.Equal(t, ConnTypeBlocked, result.ConnType)
+	require.False(t, result.Functional)
+	require.NotNil(t, result.Error)
+}
+
+func Test_TCPAndUDPConnectivityResult_ConnectivityStatus(t *testing.T) {
+	perr := &platerrors.PlatformError{Code: platerrors.ProxyServerUnreachable}
+
+	status := (&TCPAndUDPConnectivityResult{}).ConnectivityStatus()
+	require.Equal(t, ConnectivityFullyConnected, status.Status)
+	require.Empty(t, status.Recommendation)
+
+	status = (&TCPAndUDPConnectivityResult{UDPError: perr}).ConnectivityStatus()
+	require.Equal(t, ConnectivityTCPOnly, status.Status)
+	require.NotEmpty(t, status.Recommendation)
+
+	status = (&TCPAndUDPConnectivityResult{TCPError: perr}).ConnectivityStatus()
+	require.Equal(t, ConnectivityBlocked, status.Status)
+	require.NotEmpty(t, status.Recommendation)
+
+	status = (&TCPAndUDPConnectivityResult{TCPError: perr, UDPError: perr}).ConnectivityStatus()
+	require.Equal(t, ConnectivityBlocked, status.Status)
+}
+
+func Test_CheckTCPAndUDPConnectivityWithTargets(t *testing.T) {
+	httpServer := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer httpServer.Close()
+	udpServer := newUDPEchoServer(t, nil)
+
+	result := CheckTCPAndUDPConnectivityWithTargets(newDirectTestClient(), httpServer.Listener.Addr().String(), udpServer)
+	require.Nil(t, result.TCPError, "Got %v", result.TCPError)
+	require.Nil(t, result.UDPError, "Got %v", result.UDPError)
+
+	result = CheckTCPAndUDPConnectivityWithTargets(newDirectTestClient(), "no-port", udpServer)
+	require.NotNil(t, result.TCPError)
+	require.Equal(t, platerrors.InvalidConfig, result.TCPError.Code)
+	require.Nil(t, result.UDPError, "Got %v", result.UDPError)
+}
+
+func Test_CheckTCPAndUDPConnectivityWithBaseDialers(t *testing.T) {
+	result := NewClient("ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpTRUNSRVQ@127.0.0.1:4321/")
+	require.Nil(t, result.Error, "Got %v", result.Error)
+
+	var tcpAddrs, udpAddrs []string
+	var mu sync.Mutex
+	tcpDialer := transport.FuncStreamDialer(func(_ context.Context, addr string) (transport.StreamConn, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		tcpAddrs = append(tcpAddrs, addr)
+		return nil, errors.New("blocked")
+	})
+	udpDialer := transport.FuncPacketDialer(func(_ context.Context, addr string) (net.Conn, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		udpAddrs = append(udpAddrs, addr)
+		return nil, errors.New("blocked")
+	})
+
+	connectivity := CheckTCPAndUDPConnectivityWithBaseDialers(result.Client, tcpDialer, udpDialer)
+	require.NotNil(t, connectivity.TCPError)
+	require.NotNil(t, connectivity.UDPError)
+	require.Equal(t, "127.0.0.1:4321", connectivity.ServerAddress)
+	require.Equal(t, []string{"127.0.0.1:4321"}, tcpAddrs)
+	require.Equal(t, []string{"127.0.0.1:4321"}, udpAddrs)
+}
+
+func Test_CheckTCPAndUDPConnectivityWithBaseDialers_NoConfig(t *testing.T) {
+	client := newDirectTestClient()
+	connectivity := CheckTCPAndUDPConnectivityWithBaseDialers(client, &transport.TCPDialer{}, &transport.UDPDialer{})
+	require.NotNil(t, connectivity.TCPError)
+	require.Equal(t, platerrors.InternalError, connectivity.TCPError.Code)
+}
+
+func Test_PerformComprehensiveTestContext_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	result := PerformComprehensiveTestContext(ctx, newUnreachableTestClient("127.0.0.1:4321"))
+	require.NotNil(t, result.BandwidthError)
+	require.Equal(t, platerrors.OperationCanceled, result.BandwidthError.Code)
+	require.Equal(t, int64(-1), result.DownloadSpeedKBps)
+	require.Equal(t, int64(-1), result.UploadSpeedKBps)
+	require.Equal(t, int64(-1), result.LatencyMs)
+}
+
+func Test_PerformComprehensiveTestContext_AbortsRunningPhase(t *testing.T) {
+	client := newUnreachableTestClient("127.0.0.1:4321")
+	client.sd.Dial = func(ctx context.Context, _ string) (transport.StreamConn, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	start := time.Now()
+	result := PerformComprehensiveTestContext(ctx, client)
+	require.Less(t, time.Since(start), 2*time.Second)
+	require.NotNil(t, result.TCPError)
+	require.NotNil(t, result.BandwidthError)
+	require.Equal(t, platerrors.OperationCanceled, result.BandwidthError.Code)
+}
+
+func Test_PerformComprehensiveTestContext_DeadlinePassed(t *testing.T) {
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	result := PerformComprehensiveTestContext(ctx, newUnreachableTestClient("127.0.0.1:4321"))
+	require.NotNil(t, result.BandwidthError)
+	require.Equal(t, platerrors.Timeout, result.BandwidthError.Code)
+}
+
+func Test_Client_QuickTest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer server.Close()
+
+	result := newDirectTestClient().quickTest(context.Background(), server.Listener.Addr().String(), server.URL)
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.True(t, result.Healthy)
+	require.GreaterOrEqual(t, result.LatencyMs, int64(0))
+}
+
+func Test_Client_QuickTest_Errors(t *testing.T) {
+	result := newUnreachableTestClient("127.0.0.1:4321").QuickTest(context.Background())
+	require.False(t, result.Healthy)
+	require.Equal(t, int64(-1), result.LatencyMs)
+	require.NotNil(t, result.Error)
+	require.Equal(t, platerrors.ProxyServerUnreachable, result.Error.Code)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result = newDirectTestClient().QuickTest(ctx)
+	require.False(t, result.Healthy)
+	require.NotNil(t, result.Error)
+	require.Equal(t, platerrors.OperationCanceled, result.Error.Code)
+}
+
+func Test_Client_QuickTest_SlowServer(t *testing.T) {
+	// The server accepts connections, but never responds.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	result := newDirectTestClient().quickTest(ctx, server.Listener.Addr().String(), server.URL)
+	require.False(t, result.Healthy)
+	require.NotNil(t, result.Error)
+	require.Equal(t, platerrors.Timeout, result.Error.Code)
+}
@@ -0,0 +1,133 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/config"
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/Jigsaw-Code/outline-sdk/transport"
+)
+
+// UpdateCredentials replaces the credentials of c, such as a rotated Shadowsocks key, with the
+// ones of newConfig, a config in any format [NewClient] accepts. The connections made afterwards
+// use the new credentials, while the open ones, including the UDP connections of
+// [Client.ListenPacket], keep the credentials they were made with, so that rotating keys doesn't
+// drop active flows.
+//
+// Only transports of the same type can be hot-swapped: newConfig must have the same transports,
+// with the same $type at each level, and reach the same first hop as the config of c, so that
+// only values such as keys and ciphers change. Otherwise, or if newConfig is not valid, it fails
+// with [platerrors.InvalidConfig] and c keeps its credentials. Clients that were not created from
+// a single config, such as those of [NewClientFromDialers] and [NewClientWithFallback], can't
+// update their credentials.
+func (c *Client) UpdateCredentials(newConfig string) *platerrors.PlatformError {
+	if c.credentials == nil {
+		return &platerrors.PlatformError{
+			Code:    platerrors.InvalidConfig,
+			Message: "only clients created from a single config can update their credentials",
+		}
+	}
+	clientConfig, err := parseClientConfig(newConfig)
+	if err != nil {
+		return platerrors.ToPlatformError(err)
+	}
+	c.credentials.mu.Lock()
+	defer c.credentials.mu.Unlock()
+	if !reflect.DeepEqual(transportShape(c.config.Load().Transport), transportShape(clientConfig.Transport)) {
+		return &platerrors.PlatformError{
+			Code:    platerrors.InvalidConfig,
+			Message: "new config must have transports of the same type",
+		}
+	}
+	transportPair, err := parseTransportPair(context.Background(), clientConfig, c.credentials.tcpDialer, c.credentials.udpDialer, c.credentials.options)
+	if err != nil {
+		return platerrors.ToPlatformError(err)
+	}
+	current := c.credentials.current.Load()
+	if transportPair.StreamDialer.ConnectionProviderInfo != current.StreamDialer.ConnectionProviderInfo ||
+		transportPair.PacketListener.ConnectionProviderInfo != current.PacketListener.ConnectionProviderInfo {
+		return &platerrors.PlatformError{
+			Code:    platerrors.InvalidConfig,
+			Message: "new config must use the same first hop",
+		}
+	}
+	c.credentials.current.Store(transportPair)
+	c.config.Store(clientConfig)
+	if pool := c.pool.Load(); pool != nil {
+		// The idle streams use the old credentials.
+		pool.flush()
+	}
+	logger().Info("credentials updated")
+	return nil
+}
+
+// transportShape returns the transport config node in the form [config.DescribeTransportConfig]
+// resolves it to, with only its structure and $type fields kept, so that configs that differ only
+// in their values have the same shape.
+func transportShape(node config.ConfigNode) config.ConfigNode {
+	return nodeShape(config.DescribeTransportConfig(node))
+}
+
+func nodeShape(node config.ConfigNode) config.ConfigNode {
+	switch typed := node.(type) {
+	case []any:
+		shape := make([]any, len(typed))
+		for i, item := range typed {
+			shape[i] = nodeShape(item)
+		}
+		return shape
+	case map[string]any:
+		shape := make(map[string]any, len(typed))
+		for key, value := range typed {
+			if key == "$type" {
+				shape[key] = value
+			} else {
+				shape[key] = nodeShape(value)
+			}
+		}
+		return shape
+	default:
+		return nil
+	}
+}
+
+// credentialRotation relays the traffic of a client created from a config through the transports
+// of its current credentials, which [Client.UpdateCredentials] replaces.
+type credentialRotation struct {
+	// tcpDialer, udpDialer and options are the ones the client was created with, which the
+	// transports of new credentials use too.
+	tcpDialer transport.StreamDialer
+	udpDialer transport.PacketDialer
+	options   NewClientOptions
+	// mu makes sure only one goroutine updates the credentials at a time.
+	mu      sync.Mutex
+	current atomic.Pointer[config.TransportPair]
+}
+
+// DialStream dials through the transport of the current credentials.
+func (r *credentialRotation) DialStream(ctx context.Context, address string) (transport.StreamConn, error) {
+	return r.current.Load().StreamDialer.Dial(ctx, address)
+}
+
+// ListenPacket listens through the transport of the current credentials.
+func (r *credentialRotation) ListenPacket(ctx context.Context) (net.PacketConn, error) {
+	return r.current.Load().PacketListener.ListenPacket(ctx)
+}
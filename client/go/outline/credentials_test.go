@@ -0,0 +1,147 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/stretchr/testify/require"
+)
+
+// newPrefixRecordingServer returns the address of a server that sends the first prefixLength
+// bytes of each connection to the returned channel, and keeps the connections open until the
+// test is done.
+func newPrefixRecordingServer(t *testing.T, prefixLength int) (string, <-chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	prefixes := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				prefix := make([]byte, prefixLength)
+				if _, err := io.ReadFull(conn, prefix); err != nil {
+					return
+				}
+				prefixes <- string(prefix)
+				io.Copy(io.Discard, conn)
+			}()
+		}
+	}()
+	return listener.Addr().String(), prefixes
+}
+
+func newShadowsocksTestConfig(endpoint, secret, prefix string) string {
+	return fmt.Sprintf(`
+transport:
+  $type: shadowsocks
+  endpoint: %s
+  cipher: chacha20-ietf-poly1305
+  secret: %s
+  prefix: %s`, endpoint, secret, prefix)
+}
+
+// dialPrefix dials through client and returns the connection and the prefix the server got.
+func dialPrefix(t *testing.T, client *Client, prefixes <-chan string) (net.Conn, string) {
+	conn, err := client.DialStream(context.Background(), "example.com:443")
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	_, err = conn.Write([]byte("data"))
+	require.NoError(t, err)
+	select {
+	case prefix := <-prefixes:
+		return conn, prefix
+	case <-time.After(5 * time.Second):
+		t.Fatal("server didn't get the connection")
+		return nil, ""
+	}
+}
+
+func Test_UpdateCredentials(t *testing.T) {
+	server, prefixes := newPrefixRecordingServer(t, 3)
+	result := NewClient(newShadowsocksTestConfig(server, "OLD_SECRET", "AAA"))
+	require.Nil(t, result.Error)
+	client := result.Client
+	oldConn, prefix := dialPrefix(t, client, prefixes)
+	require.Equal(t, "AAA", prefix)
+
+	require.Nil(t, client.UpdateCredentials(newShadowsocksTestConfig(server, "NEW_SECRET", "BBB")))
+	_, prefix = dialPrefix(t, client, prefixes)
+	require.Equal(t, "BBB", prefix)
+	require.Contains(t, client.DescribeTransport(), "BBB")
+
+	// The connection made before the update is still open.
+	_, err := oldConn.Write([]byte("more data"))
+	require.NoError(t, err)
+}
+
+func Test_UpdateCredentials_RejectsOtherTypes(t *testing.T) {
+	server, prefixes := newPrefixRecordingServer(t, 3)
+	oldConfig := newShadowsocksTestConfig(server, "OLD_SECRET", "AAA")
+	result := NewClient(oldConfig)
+	require.Nil(t, result.Error)
+	client := result.Client
+
+	for name, newConfig := range map[string]string{
+		"other type": fmt.Sprintf(`
+transport:
+  $type: tcpudp
+  tcp: {$type: shadowsocks, endpoint: %s, cipher: chacha20-ietf-poly1305, secret: NEW_SECRET, prefix: BBB}
+  udp: {$type: shadowsocks, endpoint: %s, cipher: chacha20-ietf-poly1305, secret: NEW_SECRET, prefix: BBB}`, server, server),
+		"other first hop": newShadowsocksTestConfig("127.0.0.1:1", "NEW_SECRET", "BBB"),
+		"invalid config":  "transport: {$type: shadowsocks}",
+	} {
+		t.Run(name, func(t *testing.T) {
+			perr := client.UpdateCredentials(newConfig)
+			require.NotNil(t, perr)
+			require.Equal(t, platerrors.InvalidConfig, perr.Code)
+
+			// The client keeps its credentials.
+			_, prefix := dialPrefix(t, client, prefixes)
+			require.Equal(t, "AAA", prefix)
+		})
+	}
+}
+
+func Test_UpdateCredentials_ClientWithoutConfig(t *testing.T) {
+	client := newDirectTestClient()
+
+	perr := client.UpdateCredentials(newShadowsocksTestConfig("127.0.0.1:1", "NEW_SECRET", "BBB"))
+	require.NotNil(t, perr)
+	require.Equal(t, platerrors.InvalidConfig, perr.Code)
+}
+
+func Test_TransportShape(t *testing.T) {
+	shapeOf := func(transportText string) any {
+		clientConfig, err := parseClientConfig("transport: " + transportText)
+		require.NoError(t, err)
+		return transportShape(clientConfig.Transport)
+	}
+	accessKeyShape := shapeOf("ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpTRUNSRVQ@example.com:4321")
+
+	require.Equal(t, accessKeyShape, shapeOf("{server: example.com, server_port: 443, method: aes-256-gcm, password: ROTATED}"))
+	require.NotEqual(t, accessKeyShape, shapeOf("{$type: websocket, url: wss://example.com/tcp}"))
+}
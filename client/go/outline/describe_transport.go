@@ -0,0 +1,38 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/config"
+	"github.com/goccy/go-yaml"
+)
+
+// DescribeTransport returns the transport config of c as YAML, for debugging. The config is in
+// the form the parsers resolve it to, with Shadowsocks URLs and legacy fields expanded and
+// defaults filled in, and its credentials are replaced with "REDACTED", so that support can
+// reproduce issues without seeing secrets. See [config.DescribeTransportConfig] for the details.
+//
+// It returns an empty string if c was not created from a config, as with [NewClientFromDialers].
+func (c *Client) DescribeTransport() string {
+	clientConfig := c.transportClient().config.Load()
+	if clientConfig == nil {
+		return ""
+	}
+	description, err := yaml.Marshal(map[string]any{"transport": config.DescribeTransportConfig(clientConfig.Transport)})
+	if err != nil {
+		return ""
+	}
+	return string(description)
+}
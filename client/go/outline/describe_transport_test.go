@@ -0,0 +1,62 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"testing"
+
+	"github.com/Jigsaw-Code/outline-sdk/transport"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Client_DescribeTransport(t *testing.T) {
+	result := NewClient(`
+transport:
+  $type: tcpudp
+  tcp: ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpTRUNSRVQ@example.com:4321/
+  udp:
+    $type: shadowsocks
+    endpoint: example.com:4321
+    cipher: chacha20-ietf-poly1305
+    secret: SECRET`)
+	require.Nil(t, result.Error, "Got %v", result.Error)
+
+	description := result.Client.DescribeTransport()
+	require.NotContains(t, description, "SECRET")
+	// The secret of the URL is base64-encoded.
+	require.NotContains(t, description, "Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpTRUNSRVQ")
+	// The description is a valid config, with the same transports.
+	described := NewClient(description)
+	require.Nil(t, described.Error, "Got %v", described.Error)
+	require.Equal(t, `transport:
+  $type: tcpudp
+  tcp:
+    $type: shadowsocks
+    cipher: chacha20-ietf-poly1305
+    endpoint: example.com:4321
+    secret: REDACTED
+  udp:
+    $type: shadowsocks
+    cipher: chacha20-ietf-poly1305
+    endpoint: example.com:4321
+    secret: REDACTED
+`, description)
+}
+
+func Test_Client_DescribeTransport_NoConfig(t *testing.T) {
+	client, err := NewClientFromDialers(&transport.TCPDialer{}, &transport.UDPListener{})
+	require.NoError(t, err)
+	require.Empty(t, client.DescribeTransport())
+}
@@ -0,0 +1,147 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+)
+
+// dialPolicy restricts the destinations of [Client.DialStream].
+type dialPolicy struct {
+	// allowedPorts are the destination ports that can be dialed, or nil for all of them.
+	allowedPorts map[int]bool
+	// deniedHosts are the host names that can't be dialed, in lower case. Their subdomains are
+	// denied too.
+	deniedHosts map[string]bool
+	// deniedIPs are the IP addresses that can't be dialed, in the form of [policyIP].
+	deniedIPs map[netip.Addr]bool
+}
+
+// SetDialPolicy restricts the destinations that [Client.DialStream] connects to, for deployments
+// that must limit where the tunnel goes. Disallowed destinations fail with a
+// [platerrors.DestinationForbidden] error before any connection is attempted.
+//
+// allowedPorts lists the destination ports that can be dialed, and is empty to allow all ports.
+// deniedHosts lists the host names and IP addresses that can't be dialed. Denying a host name
+// also denies its subdomains, and denying an IP address also denies its other forms, such as
+// the IPv4-mapped IPv6 form of an IPv4 address. Empty lists for both, the default, allow all destinations.
+//
+// The policy applies to TCP connections only. It fails with [platerrors.InvalidConfig] if a
+// port or a host is not valid, in which case the previous policy stays in place.
+func (c *Client) SetDialPolicy(allowedPorts []int, deniedHosts []string) *platerrors.PlatformError {
+	if len(allowedPorts) == 0 && len(deniedHosts) == 0 {
+		c.dialPolicy.Store(nil)
+		return nil
+	}
+	policy := &dialPolicy{deniedHosts: make(map[string]bool), deniedIPs: make(map[netip.Addr]bool)}
+	if len(allowedPorts) > 0 {
+		policy.allowedPorts = make(map[int]bool, len(allowedPorts))
+	}
+	for _, port := range allowedPorts {
+		if port < 1 || port > 65535 {
+			return &platerrors.PlatformError{
+				Code:    platerrors.InvalidConfig,
+				Message: "allowed ports must be between 1 and 65535",
+				Details: platerrors.ErrorDetails{"port": port},
+			}
+		}
+		policy.allowedPorts[port] = true
+	}
+	for _, host := range deniedHosts {
+		host = normalizePolicyHost(host)
+		if ip, ok := policyIP(host); ok {
+			policy.deniedIPs[ip] = true
+			continue
+		}
+		if host == "" || strings.ContainsAny(host, ":/ ") {
+			return &platerrors.PlatformError{
+				Code:    platerrors.InvalidConfig,
+				Message: "denied hosts must be host names or IP addresses",
+				Details: platerrors.ErrorDetails{"host": host},
+			}
+		}
+		policy.deniedHosts[host] = true
+	}
+	c.dialPolicy.Store(policy)
+	return nil
+}
+
+// normalizePolicyHost returns host in the form the policy compares hosts: in lower case, and
+// without brackets or a trailing dot.
+func normalizePolicyHost(host string) string {
+	host = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+	return strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+}
+
+// policyIP returns host as an IP address in the form the policy compares them, so that the
+// different forms of an address match: IPv4-mapped IPv6 addresses are unmapped, and zones are
+// dropped. It returns false if host is not an IP address.
+func policyIP(host string) (netip.Addr, bool) {
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return ip.WithZone("").Unmap(), true
+}
+
+// checkDialPolicy returns a [platerrors.DestinationForbidden] error if the dial policy of c
+// doesn't allow connecting to address, and nil otherwise.
+func (c *Client) checkDialPolicy(address string) error {
+	policy := c.dialPolicy.Load()
+	if policy == nil {
+		return nil
+	}
+	forbidden := func(message string) error {
+		return platerrors.PlatformError{
+			Code:    platerrors.DestinationForbidden,
+			Message: message,
+			Details: platerrors.ErrorDetails{"address": address},
+		}
+	}
+	host, portText, err := net.SplitHostPort(address)
+	if err != nil {
+		return forbidden("destination address is malformed")
+	}
+	if policy.allowedPorts != nil {
+		port, err := strconv.Atoi(portText)
+		if err != nil || !policy.allowedPorts[port] {
+			return forbidden("destination port is not allowed")
+		}
+	}
+	host = normalizePolicyHost(host)
+	if ip, ok := policyIP(host); ok {
+		if policy.deniedIPs[ip] {
+			return forbidden("destination host is denied")
+		}
+		return nil
+	}
+	// Check the host and its parent domains.
+	for name := host; name != ""; {
+		if policy.deniedHosts[name] {
+			return forbidden("destination host is denied")
+		}
+		_, parent, found := strings.Cut(name, ".")
+		if !found {
+			break
+		}
+		name = parent
+	}
+	return nil
+}
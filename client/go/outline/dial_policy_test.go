@@ -0,0 +1,128 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/config"
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/Jigsaw-Code/outline-sdk/transport"
+	"github.com/stretchr/testify/require"
+)
+
+// newCountingTestClient returns a direct client that counts its stream dials.
+func newCountingTestClient(dials *int) *Client {
+	client := newDirectTestClient()
+	tcpDialer := &transport.TCPDialer{}
+	client.sd = &config.Dialer[transport.StreamConn]{Dial: func(ctx context.Context, address string) (transport.StreamConn, error) {
+		*dials++
+		return tcpDialer.DialStream(ctx, address)
+	}}
+	return client
+}
+
+// requireForbidden checks that err is a dial policy rejection and returns it.
+func requireForbidden(t *testing.T, err error) *platerrors.PlatformError {
+	t.Helper()
+	require.Error(t, err)
+	perr := platerrors.ToPlatformError(err)
+	require.Equal(t, platerrors.DestinationForbidden, perr.Code)
+	return perr
+}
+
+func TestSetDialPolicy(t *testing.T) {
+	address := newTCPEchoServer(t)
+	_, port, err := net.SplitHostPort(address)
+	require.NoError(t, err)
+	var dials int
+	client := newCountingTestClient(&dials)
+
+	conn, err := client.DialStream(context.Background(), address)
+	require.NoError(t, err)
+	conn.Close()
+
+	require.Nil(t, client.SetDialPolicy([]int{443}, nil))
+	_, err = client.DialStream(context.Background(), address)
+	perr := requireForbidden(t, err)
+	require.Equal(t, address, perr.Details["address"])
+	require.Equal(t, 1, dials)
+
+	portNumber, err := net.LookupPort("tcp", port)
+	require.NoError(t, err)
+	require.Nil(t, client.SetDialPolicy([]int{443, portNumber}, nil))
+	conn, err = client.DialStream(context.Background(), address)
+	require.NoError(t, err)
+	conn.Close()
+	require.Equal(t, 2, dials)
+
+	require.Nil(t, client.SetDialPolicy(nil, []string{"127.0.0.1"}))
+	_, err = client.DialStream(context.Background(), address)
+	requireForbidden(t, err)
+	require.Equal(t, 2, dials)
+
+	require.Nil(t, client.SetDialPolicy(nil, nil))
+	conn, err = client.DialStream(context.Background(), address)
+	require.NoError(t, err)
+	conn.Close()
+	require.Equal(t, 3, dials)
+}
+
+func TestSetDialPolicy_DeniedHosts(t *testing.T) {
+	client := newDirectTestClient()
+	require.Nil(t, client.SetDialPolicy(nil, []string{"Example.com.", "10.0.0.1", "[2001:db8::1]"}))
+
+	for _, address := range []string{"example.com:443", "EXAMPLE.COM.:80", "www.example.com:443", "10.0.0.1:53", "[2001:db8::1]:443"} {
+		requireForbidden(t, client.checkDialPolicy(address))
+	}
+	for _, address := range []string{"notexample.com:443", "example.org:443", "10.0.0.2:53", "[2001:db8::2]:443"} {
+		require.NoError(t, client.checkDialPolicy(address), address)
+	}
+	requireForbidden(t, client.checkDialPolicy("example.org"))
+}
+
+func TestSetDialPolicy_EquivalentIPs(t *testing.T) {
+	client := newDirectTestClient()
+	require.Nil(t, client.SetDialPolicy(nil, []string{"2001:DB8:0::1", "::ffff:10.0.0.1", "192.168.0.1", "fe80::1"}))
+
+	for _, address := range []string{"[2001:db8::1]:443", "[2001:0db8:0000::0001]:443", "10.0.0.1:53", "[::ffff:192.168.0.1]:80", "[fe80::1%eth0]:80"} {
+		requireForbidden(t, client.checkDialPolicy(address))
+	}
+	for _, address := range []string{"[2001:db8::2]:443", "10.0.0.2:53", "[::ffff:192.168.0.2]:80"} {
+		require.NoError(t, client.checkDialPolicy(address), address)
+	}
+}
+
+func TestSetDialPolicy_Invalid(t *testing.T) {
+	client := newDirectTestClient()
+	require.Nil(t, client.SetDialPolicy([]int{443}, nil))
+
+	for _, ports := range [][]int{{0}, {443, 65536}, {-1}} {
+		perr := client.SetDialPolicy(ports, nil)
+		require.NotNil(t, perr)
+		require.Equal(t, platerrors.InvalidConfig, perr.Code)
+	}
+	for _, hosts := range [][]string{{""}, {"example.com:443"}, {"https://example.com"}} {
+		perr := client.SetDialPolicy(nil, hosts)
+		require.NotNil(t, perr)
+		require.Equal(t, platerrors.InvalidConfig, perr.Code)
+	}
+
+	// The previous policy stays in place.
+	require.NoError(t, client.checkDialPolicy("example.com:443"))
+	requireForbidden(t, client.checkDialPolicy("example.com:80"))
+}
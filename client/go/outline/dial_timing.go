@@ -0,0 +1,134 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/Jigsaw-Code/outline-sdk/transport"
+)
+
+// maxDialTimingCount caps the count of [Client.MeasureDialTimes].
+const maxDialTimingCount = 100
+
+// DialTimingResult is the distribution of the connection setup times of [Client.MeasureDialTimes].
+// Times are in milliseconds, and only count the dials that succeeded.
+//
+// We use a struct instead of a tuple to preserve a strongly typed error that gobind recognizes.
+type DialTimingResult struct {
+	Succeeded int // Number of dials that succeeded
+	Failed    int // Number of dials that failed
+	MinMs     float64
+	MedianMs  float64
+	P95Ms     float64
+	MaxMs     float64
+	// LastDialError is the failure of the last dial that failed, or nil if none did.
+	LastDialError *platerrors.PlatformError
+	// Error is why the measurement failed, if no dial succeeded.
+	Error *platerrors.PlatformError
+}
+
+// MeasureDialTimes opens count connections to address, in host:port form, through the proxy one
+// after the other, closing each right after it's established, and reports the distribution of
+// the times they took to set up. Unlike the bandwidth tests, this isolates the handshakes with
+// the proxy from the throughput, which helps diagnose servers that are slow to connect.
+//
+// The dials don't reuse the streams of [Client.SetConnectionPool], but are subject to the dial
+// timeout and the dial policy. Failed dials are counted and left out of the times, which fail
+// with the error of the last dial if none succeeded. If ctx is done, it stops and reports the
+// dials made so far, or an [platerrors.OperationCanceled] error if there are none.
+func (c *Client) MeasureDialTimes(ctx context.Context, address string, count int) *DialTimingResult {
+	if count < 1 || count > maxDialTimingCount {
+		return &DialTimingResult{Error: &platerrors.PlatformError{
+			Code:    platerrors.InvalidConfig,
+			Message: fmt.Sprintf("dial count must be between 1 and %d", maxDialTimingCount),
+			Details: platerrors.ErrorDetails{"count": count},
+		}}
+	}
+	if err := validateDialAddress(address); err != nil {
+		return &DialTimingResult{Error: platerrors.ToPlatformError(err)}
+	}
+	result := &DialTimingResult{}
+	var times []time.Duration
+	for i := 0; i < count && ctx.Err() == nil; i++ {
+		elapsed, err := c.timeDial(ctx, address)
+		if ctx.Err() != nil {
+			break // The dial was canceled, so it tells nothing about the server.
+		}
+		if err != nil {
+			result.Failed++
+			result.LastDialError = platerrors.ToPlatformError(dialError(err, address, platerrors.ProxyServerUnreachable, "failed to connect to the address through the proxy"))
+			continue
+		}
+		times = append(times, elapsed)
+	}
+	result.Succeeded = len(times)
+	if len(times) == 0 {
+		if result.LastDialError != nil {
+			result.Error = result.LastDialError
+		} else {
+			result.Error = &platerrors.PlatformError{
+				Code:    platerrors.OperationCanceled,
+				Message: "dial time measurement was canceled",
+			}
+		}
+		return result
+	}
+	slices.Sort(times)
+	toMs := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	result.MinMs = toMs(times[0])
+	result.MedianMs = toMs(times[(len(times)-1)/2])
+	result.P95Ms = toMs(times[int(math.Ceil(0.95*float64(len(times))))-1])
+	result.MaxMs = toMs(times[len(times)-1])
+	logger().Debug("dial times measured", "succeeded", result.Succeeded, "failed", result.Failed)
+	return result
+}
+
+// timeDial dials address with the transport of c, bypassing the connection pool, closes the
+// connection, and returns how long the dial took.
+func (c *Client) timeDial(ctx context.Context, address string) (time.Duration, error) {
+	start := time.Now()
+	conn, err := c.dialUnpooled(ctx, address)
+	elapsed := time.Since(start)
+	if err != nil {
+		return 0, err
+	}
+	conn.Close()
+	return elapsed, nil
+}
+
+// dialUnpooled dials address with the transport of c, bypassing the connection pool, but subject
+// to the shutdown of c, the dial policy and the dial timeout.
+func (c *Client) dialUnpooled(ctx context.Context, address string) (transport.StreamConn, error) {
+	if c.connections.isShutDown() {
+		return nil, errClientShutDown
+	}
+	if err := c.checkDialPolicy(address); err != nil {
+		return nil, err
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		if timeout := c.getDialTimeout(); timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+	}
+	return c.sd.Dial(ctx, address)
+}
@@ -0,0 +1,139 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/Jigsaw-Code/outline-sdk/transport"
+	"github.com/stretchr/testify/require"
+)
+
+// newDelayedDialTestClient returns a direct client whose dials take the given delays in turn,
+// and fail if the delay is negative.
+func newDelayedDialTestClient(delays ...time.Duration) *Client {
+	client := newDirectTestClient()
+	dial := client.sd.Dial
+	var calls atomic.Int32
+	client.sd.Dial = func(ctx context.Context, address string) (transport.StreamConn, error) {
+		delay := delays[int(calls.Add(1)-1)%len(delays)]
+		if delay < 0 {
+			return nil, errors.New("connection refused")
+		}
+		time.Sleep(delay)
+		return dial(ctx, address)
+	}
+	return client
+}
+
+func TestClient_MeasureDialTimes(t *testing.T) {
+	server := newTCPEchoServer(t)
+	client := newDelayedDialTestClient(10*time.Millisecond, 20*time.Millisecond, 30*time.Millisecond, 40*time.Millisecond)
+
+	result := client.MeasureDialTimes(context.Background(), server, 4)
+	require.Nil(t, result.Error)
+	require.Nil(t, result.LastDialError)
+	require.Equal(t, 4, result.Succeeded)
+	require.Zero(t, result.Failed)
+	require.GreaterOrEqual(t, result.MinMs, 10.0)
+	require.Less(t, result.MinMs, result.MedianMs)
+	require.GreaterOrEqual(t, result.MedianMs, 20.0)
+	require.Less(t, result.MedianMs, 30.0)
+	require.Equal(t, result.MaxMs, result.P95Ms)
+	require.GreaterOrEqual(t, result.MaxMs, 40.0)
+	// The connections were closed.
+	require.Empty(t, client.ActiveConnections())
+}
+
+func TestClient_MeasureDialTimes_PartialFailure(t *testing.T) {
+	server := newTCPEchoServer(t)
+	client := newDelayedDialTestClient(0, -1)
+
+	result := client.MeasureDialTimes(context.Background(), server, 5)
+	require.Nil(t, result.Error)
+	require.Equal(t, 3, result.Succeeded)
+	require.Equal(t, 2, result.Failed)
+	require.NotNil(t, result.LastDialError)
+	require.Equal(t, platerrors.ProxyServerUnreachable, result.LastDialError.Code)
+}
+
+func TestClient_MeasureDialTimes_AllFailed(t *testing.T) {
+	client := newUnreachableTestClient("proxy.example.com:443")
+
+	result := client.MeasureDialTimes(context.Background(), "example.com:443", 3)
+	require.NotNil(t, result.Error)
+	require.Equal(t, platerrors.ProxyServerUnreachable, result.Error.Code)
+	require.Zero(t, result.Succeeded)
+	require.Equal(t, 3, result.Failed)
+}
+
+func TestClient_MeasureDialTimes_BypassesPool(t *testing.T) {
+	server := newTCPEchoServer(t)
+	var dials atomic.Int32
+	client := newPoolTestClient(&dials)
+	require.Nil(t, client.SetConnectionPool(1, time.Minute))
+
+	result := client.MeasureDialTimes(context.Background(), server, 3)
+	require.Nil(t, result.Error)
+	require.Equal(t, int32(3), dials.Load())
+}
+
+func TestClient_MeasureDialTimes_Canceled(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	client := newDirectTestClient()
+	var dials atomic.Int32
+	dial := client.sd.Dial
+	client.sd.Dial = func(ctx context.Context, address string) (transport.StreamConn, error) {
+		if dials.Add(1) > 2 {
+			cancel()
+		}
+		return dial(ctx, address)
+	}
+
+	result := client.MeasureDialTimes(ctx, listener.Addr().String(), 5)
+	require.Nil(t, result.Error)
+	require.Equal(t, 2, result.Succeeded)
+	require.Zero(t, result.Failed)
+
+	result = client.MeasureDialTimes(ctx, listener.Addr().String(), 5)
+	require.NotNil(t, result.Error)
+	require.Equal(t, platerrors.OperationCanceled, result.Error.Code)
+}
+
+func TestClient_MeasureDialTimes_Invalid(t *testing.T) {
+	client := newDirectTestClient()
+	for _, count := range []int{0, maxDialTimingCount + 1} {
+		result := client.MeasureDialTimes(context.Background(), "example.com:443", count)
+		require.NotNil(t, result.Error)
+		require.Equal(t, platerrors.InvalidConfig, result.Error.Code)
+	}
+	result := client.MeasureDialTimes(context.Background(), "no-port", 1)
+	require.NotNil(t, result.Error)
+	require.Equal(t, platerrors.InvalidConfig, result.Error.Code)
+
+	require.Nil(t, client.SetDialPolicy([]int{443}, nil))
+	result = client.MeasureDialTimes(context.Background(), "example.com:80", 1)
+	require.NotNil(t, result.Error)
+	require.Equal(t, platerrors.DestinationForbidden, result.Error.Code)
+}
@@ -0,0 +1,54 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"net"
+
+	"github.com/Jigsaw-Code/outline-sdk/transport"
+)
+
+// StreamDialer returns the client as a [transport.StreamDialer], for integrators that plug the
+// tunnel into their own HTTP stack, connection pool or gRPC transport instead of reimplementing the
+// dialing.
+//
+// Dials go through [Client.DialStream], so the dial timeout, the dial policy, the stats and
+// [Client.Shutdown] apply to them, and credentials rotated by [Client.UpdateCredentials] are used
+// by the next dial. The caller owns the connections it dials, but not the dialer: the client still
+// owns the transports behind it, which callers must not close or tear down while the client is in
+// use. The dialer fails with [platerrors.ClientShutDown] once the client is shut down.
+func (c *Client) StreamDialer() transport.StreamDialer {
+	return transport.FuncStreamDialer(c.DialStream)
+}
+
+// PacketListener returns the client as a [transport.PacketListener], for integrators that plug the
+// tunnel into their own UDP stack.
+//
+// Listens go through [Client.ListenPacket], so the stats apply to them. As with
+// [Client.StreamDialer], the caller owns the connections it opens, but the client still owns the
+// transports behind the listener, which callers must not close while the client is in use.
+func (c *Client) PacketListener() transport.PacketListener {
+	return clientPacketListener{client: c}
+}
+
+// clientPacketListener is the [transport.PacketListener] of [Client.PacketListener].
+type clientPacketListener struct {
+	client *Client
+}
+
+func (l clientPacketListener) ListenPacket(ctx context.Context) (net.PacketConn, error) {
+	return l.client.ListenPacket(ctx)
+}
@@ -0,0 +1,76 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamDialer(t *testing.T) {
+	client := newDirectTestClient()
+	dialer := client.StreamDialer()
+
+	conn, err := dialer.DialStream(context.Background(), newTCPEchoServer(t))
+	require.NoError(t, err)
+	_, err = conn.Write([]byte("ping"))
+	require.NoError(t, err)
+	buf := make([]byte, 4)
+	_, err = conn.Read(buf)
+	require.NoError(t, err)
+	require.Equal(t, "ping", string(buf))
+	require.Len(t, client.ActiveConnections(), 1)
+	require.NoError(t, conn.Close())
+	require.Equal(t, int64(4), client.Stats().BytesSent)
+}
+
+func TestStreamDialer_AppliesDialPolicy(t *testing.T) {
+	client := newDirectTestClient()
+	require.Nil(t, client.SetDialPolicy([]int{443}, nil))
+
+	_, err := client.StreamDialer().DialStream(context.Background(), newTCPEchoServer(t))
+	perr := platerrors.ToPlatformError(err)
+	require.NotNil(t, perr)
+	require.Equal(t, platerrors.DestinationForbidden, perr.Code)
+}
+
+func TestStreamDialer_ShutDown(t *testing.T) {
+	client := newDirectTestClient()
+	dialer := client.StreamDialer()
+	require.NoError(t, client.Shutdown(context.Background()))
+
+	_, err := dialer.DialStream(context.Background(), newTCPEchoServer(t))
+	requireShutDownError(t, err)
+}
+
+func TestPacketListener(t *testing.T) {
+	client := newDirectTestClient()
+	serverAddr, err := net.ResolveUDPAddr("udp", newUDPEchoServer(t, nil))
+	require.NoError(t, err)
+
+	conn, err := client.PacketListener().ListenPacket(context.Background())
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.WriteTo([]byte("ping"), serverAddr)
+	require.NoError(t, err)
+	buf := make([]byte, 16)
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	require.Equal(t, "ping", string(buf[:n]))
+}
@@ -0,0 +1,146 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"slices"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/Jigsaw-Code/outline-sdk/dns"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	// dnsEchoName is answered by Akamai with the address of the resolver that asked for it.
+	dnsEchoName = "whoami.akamai.net."
+	// dnsLeakTestTimeout is the time budget of [Client.TestDNSLeak].
+	dnsLeakTestTimeout = 10 * time.Second
+)
+
+// DNSLeakResult is the result of [Client.TestDNSLeak].
+//
+// We use a struct instead of a tuple to preserve a strongly typed error that gobind recognizes.
+type DNSLeakResult struct {
+	// ResolverIPs are the addresses of the resolvers seen by the echo service for the query sent
+	// through the proxy.
+	ResolverIPs []string
+	// LocalResolverIPs are the addresses of the resolvers seen by the echo service for the query
+	// sent to the resolver of the local network. It's empty if that query failed.
+	LocalResolverIPs []string
+	// Leak is true if the queries through the proxy reach the echo service from the same
+	// resolver as the queries of the local network.
+	Leak  bool
+	Error *platerrors.PlatformError
+}
+
+// TestDNSLeak checks that DNS queries go through the proxy. It asks an echo service, which
+// answers with the address of the resolver that queried it, once through the proxy and once
+// through the resolver of the local network, and reports a leak if both queries came from the
+// same resolver.
+//
+// The test fails if the echo service can't be reached through the proxy. If it can't be reached
+// from the local network, which is common when the VPN is on, there is nothing to compare to
+// and no leak is reported.
+func (c *Client) TestDNSLeak(ctx context.Context) *DNSLeakResult {
+	return testDNSLeak(ctx, dns.NewTCPResolver(c, proxyResolverAddress), net.DefaultResolver)
+}
+
+// testDNSLeak implements [Client.TestDNSLeak] with the given resolvers.
+func testDNSLeak(ctx context.Context, proxyResolver dns.Resolver, localResolver *net.Resolver) *DNSLeakResult {
+	ctx, cancel := context.WithTimeout(ctx, dnsLeakTestTimeout)
+	defer cancel()
+
+	// Query the local resolver concurrently, since it may take as long as the timeout to fail.
+	localIPsChan := make(chan []string, 1)
+	go func() {
+		ips, err := localResolver.LookupNetIP(ctx, "ip4", dnsEchoName)
+		if err != nil {
+			localIPsChan <- nil
+			return
+		}
+		localIPsChan <- addrStrings(ips)
+	}()
+
+	proxyIPs, perr := queryDNSEcho(ctx, proxyResolver)
+	if perr != nil {
+		return &DNSLeakResult{Error: perr}
+	}
+	result := &DNSLeakResult{ResolverIPs: proxyIPs, LocalResolverIPs: <-localIPsChan}
+	for _, ip := range result.ResolverIPs {
+		if slices.Contains(result.LocalResolverIPs, ip) {
+			result.Leak = true
+		}
+	}
+	logger().Debug("DNS leak test done", "leak", result.Leak)
+	return result
+}
+
+// queryDNSEcho asks the echo service for the addresses of the resolvers that query it for
+// resolver.
+func queryDNSEcho(ctx context.Context, resolver dns.Resolver) ([]string, *platerrors.PlatformError) {
+	q, err := dns.NewQuestion(dnsEchoName, dnsmessage.TypeA)
+	if err != nil {
+		return nil, &platerrors.PlatformError{Code: platerrors.InternalError, Message: "failed to create the DNS question", Cause: platerrors.ToPlatformError(err)}
+	}
+	response, err := resolver.Query(ctx, *q)
+	if err != nil {
+		return nil, dnsEchoError(ctx, err)
+	}
+	var ips []netip.Addr
+	for _, answer := range response.Answers {
+		if a, ok := answer.Body.(*dnsmessage.AResource); ok {
+			ips = append(ips, netip.AddrFrom4(a.A))
+		}
+	}
+	if len(ips) == 0 {
+		return nil, &platerrors.PlatformError{
+			Code:    platerrors.ResolveIPFailed,
+			Message: "DNS echo service returned no resolver address",
+			Details: platerrors.ErrorDetails{"rcode": response.RCode.String()},
+		}
+	}
+	return addrStrings(ips), nil
+}
+
+// dnsEchoError converts err, which made the query to the echo service fail, into a
+// [platerrors.PlatformError]. Failures to connect are blamed on the proxy.
+func dnsEchoError(ctx context.Context, err error) *platerrors.PlatformError {
+	const message = "failed to reach the DNS echo service through the proxy"
+	var perr platerrors.PlatformError
+	switch {
+	case errors.Is(ctx.Err(), context.Canceled):
+		return &platerrors.PlatformError{Code: platerrors.OperationCanceled, Message: "DNS leak test was canceled"}
+	case errors.As(err, &perr):
+		return &platerrors.PlatformError{Code: perr.Code, Message: message, Cause: &perr}
+	case errors.Is(err, dns.ErrDial):
+		return speedTestError(err, platerrors.ProxyServerUnreachable, "failed to connect to the proxy")
+	default:
+		return speedTestError(err, platerrors.ResolveIPFailed, message)
+	}
+}
+
+// addrStrings returns the text form of ips.
+func addrStrings(ips []netip.Addr) []string {
+	texts := make([]string, len(ips))
+	for i, ip := range ips {
+		texts[i] = ip.Unmap().String()
+	}
+	return texts
+}
@@ -0,0 +1,124 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/Jigsaw-Code/outline-sdk/dns"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// newTestLocalResolver returns a resolver that sends its queries to the DNS-over-TCP server at
+// address.
+func newTestLocalResolver(address string) *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "tcp", address)
+		},
+	}
+}
+
+// echoResolver answers every query with ip.
+func echoResolver(ip [4]byte) dns.Resolver {
+	return dns.FuncResolver(func(_ context.Context, q dnsmessage.Question) (*dnsmessage.Message, error) {
+		return &dnsmessage.Message{
+			Header:    dnsmessage.Header{Response: true},
+			Questions: []dnsmessage.Question{q},
+			Answers: []dnsmessage.Resource{{
+				Header: dnsmessage.ResourceHeader{Name: q.Name, Type: q.Type, Class: q.Class, TTL: 60},
+				Body:   &dnsmessage.AResource{A: ip},
+			}},
+		}, nil
+	})
+}
+
+func Test_TestDNSLeak(t *testing.T) {
+	dnsAddr := newFakeDNSServer(t)
+	localResolver := newTestLocalResolver(dnsAddr)
+
+	// The fake server answers the local query with 127.0.0.1 too.
+	result := testDNSLeak(context.Background(), dns.NewTCPResolver(newDirectTestClient(), dnsAddr), localResolver)
+	require.Nil(t, result.Error)
+	require.Equal(t, []string{"127.0.0.1"}, result.ResolverIPs)
+	require.Equal(t, []string{"127.0.0.1"}, result.LocalResolverIPs)
+	require.True(t, result.Leak)
+
+	result = testDNSLeak(context.Background(), echoResolver([4]byte{203, 0, 113, 1}), localResolver)
+	require.Nil(t, result.Error)
+	require.Equal(t, []string{"203.0.113.1"}, result.ResolverIPs)
+	require.Equal(t, []string{"127.0.0.1"}, result.LocalResolverIPs)
+	require.False(t, result.Leak)
+}
+
+func Test_TestDNSLeak_LocalResolverFails(t *testing.T) {
+	localResolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(context.Context, string, string) (net.Conn, error) {
+			return nil, errors.New("network is unreachable")
+		},
+	}
+
+	result := testDNSLeak(context.Background(), echoResolver([4]byte{203, 0, 113, 1}), localResolver)
+	require.Nil(t, result.Error)
+	require.Equal(t, []string{"203.0.113.1"}, result.ResolverIPs)
+	require.Empty(t, result.LocalResolverIPs)
+	require.False(t, result.Leak)
+}
+
+func Test_TestDNSLeak_Errors(t *testing.T) {
+	localResolver := newTestLocalResolver(newFakeDNSServer(t))
+
+	client := newUnreachableTestClient("127.0.0.1:4321")
+	result := testDNSLeak(context.Background(), dns.NewTCPResolver(client, proxyResolverAddress), localResolver)
+	require.NotNil(t, result.Error)
+	require.Equal(t, platerrors.ProxyServerUnreachable, result.Error.Code)
+	require.False(t, result.Leak)
+
+	// The proxy is reachable, but the echo service hangs up.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	result = testDNSLeak(context.Background(), dns.NewTCPResolver(newDirectTestClient(), listener.Addr().String()), localResolver)
+	require.NotNil(t, result.Error)
+	require.Equal(t, platerrors.ResolveIPFailed, result.Error.Code)
+
+	result = testDNSLeak(context.Background(), dns.FuncResolver(func(context.Context, dnsmessage.Question) (*dnsmessage.Message, error) {
+		return &dnsmessage.Message{Header: dnsmessage.Header{Response: true, RCode: dnsmessage.RCodeServerFailure}}, nil
+	}), localResolver)
+	require.NotNil(t, result.Error)
+	require.Equal(t, platerrors.ResolveIPFailed, result.Error.Code)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result = testDNSLeak(ctx, dns.NewTCPResolver(newDirectTestClient(), proxyResolverAddress), localResolver)
+	require.NotNil(t, result.Error)
+	require.Equal(t, platerrors.OperationCanceled, result.Error.Code)
+}
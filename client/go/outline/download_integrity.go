@@ -0,0 +1,85 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+)
+
+// DownloadIntegrityResult is the result of [Client.TestDownloadIntegrity].
+//
+// We use a struct instead of a tuple to preserve a strongly typed error that gobind recognizes.
+type DownloadIntegrityResult struct {
+	SpeedKBps  int64  // Download speed in KB/s, or -1 if the download failed
+	TotalBytes int64  // Bytes received
+	SHA256     string // SHA-256 hash of the received payload in hex, or empty if it's incomplete
+	Error      *platerrors.PlatformError
+}
+
+// TestDownloadIntegrity downloads testURL through the proxy like [Client.TestDownloadSpeed], but
+// in full, and checks that the SHA-256 hash of the payload is expectedSHA256, in hex, to catch
+// transparent proxies, caches and censors that alter the data on the way. The URL and its hash
+// must come from a trusted source, such as a file published with its checksum.
+//
+// The speed is measured over the whole download, and reported even if the hash doesn't match,
+// in which case the error has code [platerrors.IntegrityCheckFailed]. Invalid hashes fail with
+// [platerrors.InvalidConfig], and the other failures have the codes of [Client.DownloadThrough].
+func (c *Client) TestDownloadIntegrity(ctx context.Context, testURL string, expectedSHA256 string) *DownloadIntegrityResult {
+	expected, err := hex.DecodeString(strings.TrimSpace(expectedSHA256))
+	if err != nil || len(expected) != sha256.Size {
+		return &DownloadIntegrityResult{SpeedKBps: -1, Error: &platerrors.PlatformError{
+			Code:    platerrors.InvalidConfig,
+			Message: "expected SHA-256 hash must be 64 hex digits",
+		}}
+	}
+	result := c.testDownloadIntegrity(ctx, testURL, expected)
+	logger().Info("download integrity tested", "speedKBps", result.SpeedKBps, "error", errorCode(result.Error))
+	return result
+}
+
+func (c *Client) testDownloadIntegrity(ctx context.Context, testURL string, expected []byte) *DownloadIntegrityResult {
+	payloadHash := sha256.New()
+	start := time.Now()
+	total, perr := c.DownloadThrough(ctx, testURL, payloadHash)
+	result := &DownloadIntegrityResult{TotalBytes: total, SpeedKBps: speedKBps(total, time.Since(start))}
+	if perr != nil {
+		if total == 0 {
+			result.SpeedKBps = -1
+		}
+		result.Error = perr
+		return result
+	}
+	actual := payloadHash.Sum(nil)
+	result.SHA256 = hex.EncodeToString(actual)
+	if !bytes.Equal(actual, expected) {
+		result.Error = &platerrors.PlatformError{
+			Code:    platerrors.IntegrityCheckFailed,
+			Message: "downloaded data doesn't have the expected hash",
+			Details: platerrors.ErrorDetails{
+				"expectedSHA256": hex.EncodeToString(expected),
+				"actualSHA256":   result.SHA256,
+				"bytes":          total,
+			},
+		}
+	}
+	return result
+}
@@ -0,0 +1,84 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/stretchr/testify/require"
+)
+
+func newPayloadServer(t *testing.T, payload []byte) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/payload" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(payload)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func Test_TestDownloadIntegrity(t *testing.T) {
+	payload := []byte(strings.Repeat("payload", 10000))
+	sum := sha256.Sum256(payload)
+	server := newPayloadServer(t, payload)
+
+	result := newDirectTestClient().TestDownloadIntegrity(context.Background(), server.URL+"/payload", strings.ToUpper(hex.EncodeToString(sum[:])))
+	require.Nil(t, result.Error)
+	require.Equal(t, int64(len(payload)), result.TotalBytes)
+	require.Equal(t, hex.EncodeToString(sum[:]), result.SHA256)
+	require.GreaterOrEqual(t, result.SpeedKBps, int64(0))
+}
+
+func Test_TestDownloadIntegrity_Mismatch(t *testing.T) {
+	server := newPayloadServer(t, []byte("injected content"))
+	sum := sha256.Sum256([]byte("original content"))
+
+	result := newDirectTestClient().TestDownloadIntegrity(context.Background(), server.URL+"/payload", hex.EncodeToString(sum[:]))
+	require.NotNil(t, result.Error)
+	require.Equal(t, platerrors.IntegrityCheckFailed, result.Error.Code)
+	require.Equal(t, int64(len("injected content")), result.TotalBytes)
+	require.GreaterOrEqual(t, result.SpeedKBps, int64(0))
+	injectedSum := sha256.Sum256([]byte("injected content"))
+	require.Equal(t, hex.EncodeToString(injectedSum[:]), result.Error.Details["actualSHA256"])
+}
+
+func Test_TestDownloadIntegrity_ErrorStatus(t *testing.T) {
+	server := newPayloadServer(t, nil)
+	sum := sha256.Sum256(nil)
+
+	result := newDirectTestClient().TestDownloadIntegrity(context.Background(), server.URL+"/missing", hex.EncodeToString(sum[:]))
+	require.NotNil(t, result.Error)
+	require.Equal(t, platerrors.SpeedTestServerFailed, result.Error.Code)
+	require.Equal(t, int64(-1), result.SpeedKBps)
+	require.Empty(t, result.SHA256)
+}
+
+func Test_TestDownloadIntegrity_InvalidHash(t *testing.T) {
+	for _, expected := range []string{"", "not hex", "abcd"} {
+		result := newDirectTestClient().TestDownloadIntegrity(context.Background(), "http://example.com/payload", expected)
+		require.NotNil(t, result.Error)
+		require.Equal(t, platerrors.InvalidConfig, result.Error.Code)
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+)
+
+// DownloadThrough downloads downloadURL through the proxy with a GET request and writes the body
+// to w as it arrives, so that callers can check the integrity of the data, for example with a
+// checksum, and not just the speed. It returns the number of bytes written to w, which is the
+// part of the body received so far if it fails.
+//
+// The response body is written as it is on the wire, without decompression. The download is only
+// bounded by ctx.
+//
+// Failures have code [platerrors.InvalidConfig] for URLs that aren't http or https,
+// [platerrors.SpeedTestServerFailed] for error statuses, [platerrors.ProxyServerReadFailed] if
+// the connection breaks during the download, [platerrors.InternalError] if writing to w fails,
+// [platerrors.Timeout], [platerrors.OperationCanceled], and otherwise
+// [platerrors.ProxyServerUnreachable].
+func (c *Client) DownloadThrough(ctx context.Context, downloadURL string, w io.Writer) (int64, *platerrors.PlatformError) {
+	parsedURL, err := url.Parse(downloadURL)
+	if err == nil && parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
+		err = errors.New("URL scheme must be http or https")
+	}
+	if err != nil {
+		return 0, invalidTestURLError(downloadURL, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, downloadURL, nil)
+	if err != nil {
+		return 0, invalidTestURLError(downloadURL, err)
+	}
+	resp, err := c.HTTPClient(0).Do(req)
+	if err != nil {
+		return 0, downloadThroughError(ctx, err, platerrors.ProxyServerUnreachable, "download request failed")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return 0, &platerrors.PlatformError{
+			Code:    platerrors.SpeedTestServerFailed,
+			Message: "download server returned an error status",
+			Details: platerrors.ErrorDetails{"status": resp.Status},
+		}
+	}
+
+	dst := &errorRecordingWriter{Writer: w}
+	written, err := io.Copy(dst, resp.Body)
+	switch {
+	case dst.err != nil:
+		return written, &platerrors.PlatformError{
+			Code:    platerrors.InternalError,
+			Message: "failed to write the downloaded data",
+			Cause:   platerrors.ToPlatformError(dst.err),
+		}
+	case err != nil:
+		return written, downloadThroughError(ctx, err, platerrors.ProxyServerReadFailed, "download was interrupted")
+	}
+	return written, nil
+}
+
+// downloadThroughError converts err, which made [Client.DownloadThrough] fail, into a
+// [platerrors.PlatformError] with the given code, unless ctx was canceled.
+func downloadThroughError(ctx context.Context, err error, code platerrors.ErrorCode, message string) *platerrors.PlatformError {
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return &platerrors.PlatformError{
+			Code:    platerrors.OperationCanceled,
+			Message: "download was canceled",
+		}
+	}
+	return speedTestError(err, code, message)
+}
+
+// errorRecordingWriter is an [io.Writer] that keeps the error of its Writer, to tell write
+// failures from read failures of [io.Copy].
+type errorRecordingWriter struct {
+	io.Writer
+	err error
+}
+
+func (w *errorRecordingWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	if err != nil {
+		w.err = err
+	}
+	return n, err
+}
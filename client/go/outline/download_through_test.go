@@ -0,0 +1,96 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_DownloadThrough(t *testing.T) {
+	payload := make([]byte, 1024*1024+7)
+	rand.Read(payload)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(payload)
+	}))
+	defer server.Close()
+
+	hash := sha256.New()
+	written, perr := newDirectTestClient().DownloadThrough(context.Background(), server.URL, hash)
+	require.Nil(t, perr, "Got %v", perr)
+	require.Equal(t, int64(len(payload)), written)
+	want := sha256.Sum256(payload)
+	require.Equal(t, want[:], hash.Sum(nil))
+}
+
+func Test_DownloadThrough_Interrupted(t *testing.T) {
+	server, _ := newTruncatingServer(t, false)
+	var received bytes.Buffer
+	written, perr := newDirectTestClient().DownloadThrough(context.Background(), server.URL, &received)
+	require.NotNil(t, perr)
+	require.Equal(t, platerrors.ProxyServerReadFailed, perr.Code)
+	require.Equal(t, int64(64*1024), written)
+	require.Equal(t, 64*1024, received.Len())
+}
+
+// failingWriter fails all writes.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func Test_DownloadThrough_Errors(t *testing.T) {
+	okServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("data"))
+	}))
+	defer okServer.Close()
+	notFoundServer := httptest.NewServer(http.NotFoundHandler())
+	defer notFoundServer.Close()
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name        string
+		ctx         context.Context
+		downloadURL string
+		w           io.Writer
+		want        platerrors.ErrorCode
+	}{
+		{"error status", context.Background(), notFoundServer.URL, io.Discard, platerrors.SpeedTestServerFailed},
+		{"write failure", context.Background(), okServer.URL, failingWriter{}, platerrors.InternalError},
+		{"unreachable", context.Background(), "http://127.0.0.1:1/", io.Discard, platerrors.ProxyServerUnreachable},
+		{"canceled", canceled, okServer.URL, io.Discard, platerrors.OperationCanceled},
+		{"invalid URL", context.Background(), "file:///etc/passwd", io.Discard, platerrors.InvalidConfig},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			written, perr := newDirectTestClient().DownloadThrough(tt.ctx, tt.downloadURL, tt.w)
+			require.NotNil(t, perr)
+			require.Equal(t, tt.want, perr.Code)
+			require.Zero(t, written)
+		})
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+)
+
+const (
+	// defaultExitLocationURL is the geo-IP endpoint of [Client.DetectExitLocation].
+	defaultExitLocationURL = "https://ipinfo.io/json"
+	// exitLocationTimeout bounds [Client.DetectExitLocation].
+	exitLocationTimeout = 15 * time.Second
+	// maxExitLocationBytes caps the size of the responses of the geo-IP endpoint.
+	maxExitLocationBytes = 64 * 1024
+)
+
+// ExitLocationResult is the result of [Client.DetectExitLocation].
+//
+// We use a struct instead of a tuple to preserve a strongly typed error that gobind recognizes.
+type ExitLocationResult struct {
+	// IP is the public IP address that the traffic of the proxy comes from.
+	IP string
+	// Country is the country of IP, usually as a two-letter ISO 3166 code, or empty if unknown.
+	Country string
+	// ISP is the network that IP belongs to, or empty if unknown.
+	ISP   string
+	Error *platerrors.PlatformError
+}
+
+// DetectExitLocation looks up where the traffic of the proxy exits to the internet, to show
+// users the location they appear to be in. It asks ipinfo.io through the proxy, which sees the
+// address of the exit node.
+//
+// Failures have code [platerrors.ExitLocationFailed] if the endpoint answers with an error or
+// without an IP address, and otherwise the codes of [Client.TestHTTPSReachable].
+func (c *Client) DetectExitLocation(ctx context.Context) *ExitLocationResult {
+	return c.DetectExitLocationFrom(ctx, defaultExitLocationURL)
+}
+
+// DetectExitLocationFrom is like [Client.DetectExitLocation], but asks geoURL, for networks that
+// block the default endpoint. geoURL must answer GET requests with a JSON object with the "ip"
+// and "country" of the requester, and its "isp" or "org", as ipinfo.io and compatible services do.
+func (c *Client) DetectExitLocationFrom(ctx context.Context, geoURL string) *ExitLocationResult {
+	ctx, cancel := context.WithTimeout(ctx, exitLocationTimeout)
+	defer cancel()
+	result := c.detectExitLocation(ctx, geoURL)
+	logger().Info("exit location detected", "country", result.Country, "error", errorCode(result.Error))
+	return result
+}
+
+func (c *Client) detectExitLocation(ctx context.Context, geoURL string) *ExitLocationResult {
+	if parsedURL, err := url.Parse(geoURL); err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") {
+		if err == nil {
+			err = errors.New("URL scheme must be http or https")
+		}
+		return &ExitLocationResult{Error: invalidTestURLError(geoURL, err)}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, geoURL, nil)
+	if err != nil {
+		return &ExitLocationResult{Error: invalidTestURLError(geoURL, err)}
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.HTTPClient(0).Do(req)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return &ExitLocationResult{Error: &platerrors.PlatformError{
+				Code:    platerrors.OperationCanceled,
+				Message: "exit location detection was canceled",
+			}}
+		}
+		return &ExitLocationResult{Error: speedTestError(err, platerrors.ProxyServerUnreachable, "geo-IP request failed")}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &ExitLocationResult{Error: &platerrors.PlatformError{
+			Code:    platerrors.ExitLocationFailed,
+			Message: "geo-IP endpoint returned an error status",
+			Details: platerrors.ErrorDetails{"status": resp.Status},
+		}}
+	}
+
+	var location struct {
+		IP      string `json:"ip"`
+		Country string `json:"country"`
+		ISP     string `json:"isp"`
+		Org     string `json:"org"`
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxExitLocationBytes))
+	if err != nil {
+		return &ExitLocationResult{Error: speedTestError(err, platerrors.ProxyServerReadFailed, "failed to read the geo-IP response")}
+	}
+	if err := json.Unmarshal(body, &location); err != nil || location.IP == "" {
+		perr := &platerrors.PlatformError{
+			Code:    platerrors.ExitLocationFailed,
+			Message: "geo-IP response has no IP address",
+		}
+		if err != nil {
+			perr.Message = "geo-IP response is not valid JSON"
+			perr.Cause = platerrors.ToPlatformError(err)
+		}
+		return &ExitLocationResult{Error: perr}
+	}
+	result := &ExitLocationResult{IP: location.IP, Country: location.Country, ISP: location.ISP}
+	if result.ISP == "" {
+		result.ISP = location.Org
+	}
+	return result
+}
@@ -0,0 +1,94 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/stretchr/testify/require"
+)
+
+// newGeoIPServer returns a server that answers all requests with status and body.
+func newGeoIPServer(t *testing.T, status int, body string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func Test_DetectExitLocationFrom(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want ExitLocationResult
+	}{
+		{
+			name: "ipinfo",
+			body: `{"ip": "203.0.113.7", "city": "Paris", "country": "FR", "org": "AS64500 Example ISP"}`,
+			want: ExitLocationResult{IP: "203.0.113.7", Country: "FR", ISP: "AS64500 Example ISP"},
+		},
+		{
+			name: "isp over org",
+			body: `{"ip": "2001:db8::1", "country": "DE", "isp": "Example Telecom", "org": "Example Holding"}`,
+			want: ExitLocationResult{IP: "2001:db8::1", Country: "DE", ISP: "Example Telecom"},
+		},
+		{
+			name: "ip only",
+			body: `{"ip": "198.51.100.1"}`,
+			want: ExitLocationResult{IP: "198.51.100.1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newGeoIPServer(t, http.StatusOK, tt.body)
+			result := newDirectTestClient().DetectExitLocationFrom(context.Background(), server.URL)
+			require.Nil(t, result.Error, "Got %v", result.Error)
+			require.Equal(t, tt.want, *result)
+		})
+	}
+}
+
+func Test_DetectExitLocationFrom_Errors(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		geoURL string
+		want   platerrors.ErrorCode
+	}{
+		{"error status", context.Background(), newGeoIPServer(t, http.StatusTooManyRequests, `{"error": "rate limited"}`).URL, platerrors.ExitLocationFailed},
+		{"invalid JSON", context.Background(), newGeoIPServer(t, http.StatusOK, `<html>`).URL, platerrors.ExitLocationFailed},
+		{"no IP", context.Background(), newGeoIPServer(t, http.StatusOK, `{"country": "FR"}`).URL, platerrors.ExitLocationFailed},
+		{"unreachable", context.Background(), "http://127.0.0.1:1/json", platerrors.ProxyServerUnreachable},
+		{"canceled", canceled, newGeoIPServer(t, http.StatusOK, `{"ip": "203.0.113.7"}`).URL, platerrors.OperationCanceled},
+		{"invalid URL", context.Background(), "ftp://example.com/json", platerrors.InvalidConfig},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := newDirectTestClient().DetectExitLocationFrom(tt.ctx, tt.geoURL)
+			require.NotNil(t, result.Error)
+			require.Equal(t, tt.want, result.Error.Code)
+			require.Empty(t, result.IP)
+		})
+	}
+}
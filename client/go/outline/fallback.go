@@ -0,0 +1,495 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/config"
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/connectivity"
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/Jigsaw-Code/outline-sdk/transport"
+)
+
+const (
+	// fallbackProbeURL is fetched through each candidate transport to check that it works.
+	fallbackProbeURL = "http://example.com"
+	// fallbackProbeTimeout is the time budget of the check of each candidate transport.
+	fallbackProbeTimeout = 5 * time.Second
+)
+
+// NewClientWithFallbackResult represents the result of [NewClientWithFallback].
+//
+// We use a struct instead of a tuple to preserve a strongly typed error that gobind recognizes.
+type NewClientWithFallbackResult struct {
+	Client *Client
+	// Index is the index of the config the client started with.
+	Index int
+	// ID is the ID of the server the client started with, for clients created by
+	// [NewClientWithPrioritizedFallback].
+	ID    string
+	Error *platerrors.PlatformError
+}
+
+// NewClientWithFallback creates a client from the first of the given configs whose transport
+// can relay TCP traffic, trying them in order.
+//
+// If none works, it returns an error whose details list the failure of each config.
+// See [Client.SetFailoverEnabled] to switch transports when the active one stops working.
+func NewClientWithFallback(clientConfigs []string) *NewClientWithFallbackResult {
+	return NewClientWithFallbackAndRetry(context.Background(), clientConfigs, nil)
+}
+
+// FallbackRetryConfig specifies how [NewClientWithFallbackAndRetry] retries the check of each
+// config before moving on to the next one.
+type FallbackRetryConfig struct {
+	// MaxAttempts is the number of times each config is checked. Values below 2 disable retries.
+	MaxAttempts int
+	// InitialDelayMs is the delay before the first retry, in milliseconds. The delay doubles on
+	// each retry. Zero means 500ms.
+	InitialDelayMs int64
+	// MaxDelayMs caps the delay between retries, in milliseconds. Zero means no cap.
+	MaxDelayMs int64
+}
+
+const defaultFallbackRetryDelay = 500 * time.Millisecond
+
+// NewClientWithFallbackAndRetry is like [NewClientWithFallback], but retries the check of each
+// config with exponential backoff as specified by retry, so that a config that fails because of
+// a transient network error is still selected. Retries also apply when the client fails over.
+//
+// Cancelling ctx aborts the selection of the config, but not the failover of the client.
+func NewClientWithFallbackAndRetry(ctx context.Context, clientConfigs []string, retry *FallbackRetryConfig) *NewClientWithFallbackResult {
+	probe, failoverProbe := withProbeSlot(probeFallbackTransport), probeFallbackTransport
+	if retry != nil {
+		if retry.InitialDelayMs < 0 || retry.MaxDelayMs < 0 {
+			return &NewClientWithFallbackResult{Error: &platerrors.PlatformError{
+				Code:    platerrors.InvalidConfig,
+				Message: "retry delays must not be negative",
+			}}
+		}
+		probe = withProbeRetry(probe, *retry)
+		failoverProbe = withProbeRetry(failoverProbe, *retry)
+	}
+	candidates := make([]*Client, len(clientConfigs))
+	parseErrs := make([]error, len(clientConfigs))
+	for i, clientConfig := range clientConfigs {
+		result := NewClient(clientConfig)
+		if result.Error != nil {
+			parseErrs[i] = result.Error
+			continue
+		}
+		candidates[i] = result.Client
+	}
+	return newClientWithFallback(ctx, candidates, parseErrs, probe, failoverProbe)
+}
+
+// probeFallbackTransport checks whether c can relay TCP traffic.
+func probeFallbackTransport(ctx context.Context, c *Client) error {
+	ctx, cancel := context.WithTimeout(ctx, fallbackProbeTimeout)
+	defer cancel()
+	return connectivity.CheckTCPConnectivityWithHTTPContext(ctx, c, fallbackProbeURL)
+}
+
+// withProbeSlot returns a probe that calls probe once there is a free probe slot (see
+// [SetMaxConcurrentProbes]).
+//
+// Only the selection of the config takes a slot. The failover of a client runs within its dials,
+// which may already hold a slot, as those of [CheckTCPAndUDPConnectivity] do, and would then wait
+// for themselves when all the slots are taken.
+func withProbeSlot(probe func(context.Context, *Client) error) func(context.Context, *Client) error {
+	return func(ctx context.Context, c *Client) error {
+		if err := probeSlots.acquire(ctx); err != nil {
+			return err
+		}
+		defer probeSlots.release()
+		return probe(ctx, c)
+	}
+}
+
+// withProbeRetry returns a probe that calls probe until it succeeds, the attempts of retry run
+// out, or ctx is done. It returns the error of the last attempt.
+func withProbeRetry(probe func(context.Context, *Client) error, retry FallbackRetryConfig) func(context.Context, *Client) error {
+	initialDelay := time.Duration(retry.InitialDelayMs) * time.Millisecond
+	if initialDelay == 0 {
+		initialDelay = defaultFallbackRetryDelay
+	}
+	maxDelay := time.Duration(retry.MaxDelayMs) * time.Millisecond
+	return func(ctx context.Context, c *Client) error {
+		delay := initialDelay
+		for attempt := 1; ; attempt++ {
+			err := probe(ctx, c)
+			if err == nil || attempt >= retry.MaxAttempts {
+				return err
+			}
+			if maxDelay > 0 && delay > maxDelay {
+				delay = maxDelay
+			}
+			timer := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return err
+			case <-timer.C:
+			}
+			delay *= 2
+		}
+	}
+}
+
+// newClientWithFallback implements [NewClientWithFallback]. A nil candidate is a config that
+// failed to parse with the error at the same index of parseErrs. The candidates are checked with
+// probe, and with failoverProbe when the client fails over.
+func newClientWithFallback(ctx context.Context, candidates []*Client, parseErrs []error, probe, failoverProbe func(context.Context, *Client) error) *NewClientWithFallbackResult {
+	if len(candidates) == 0 {
+		return &NewClientWithFallbackResult{Error: &platerrors.PlatformError{
+			Code:    platerrors.InvalidConfig,
+			Message: "no configs were provided",
+		}}
+	}
+
+	failures := make([]platerrors.ErrorDetails, 0, len(candidates))
+	errorCode := platerrors.InvalidConfig
+	for i, candidate := range candidates {
+		err := parseErrs[i]
+		if candidate != nil {
+			if err = probe(ctx, candidate); err == nil {
+				return &NewClientWithFallbackResult{Client: newFallbackClient(candidates, i, failoverProbe), Index: i}
+			}
+			if ctx.Err() != nil {
+				return &NewClientWithFallbackResult{Error: &platerrors.PlatformError{
+					Code:    platerrors.OperationCanceled,
+					Message: "config selection was canceled",
+					Cause:   platerrors.ToPlatformError(ctx.Err()),
+				}}
+			}
+			// At least one config is valid, so the failure is about reaching the servers.
+			errorCode = platerrors.ProxyServerUnreachable
+		}
+		perr := platerrors.ToPlatformError(err)
+		failures = append(failures, platerrors.ErrorDetails{
+			"index":   i,
+			"code":    perr.Code,
+			"message": perr.Message,
+		})
+	}
+	return &NewClientWithFallbackResult{Error: &platerrors.PlatformError{
+		Code:    errorCode,
+		Message: "none of the configs works",
+		Details: platerrors.ErrorDetails{"failures": failures},
+	}}
+}
+
+// FallbackServer is a server of [NewClientWithPrioritizedFallback].
+type FallbackServer struct {
+	// ID identifies the server, so that the app can show which server is active.
+	ID string
+	// Config is the config of the server, in the format of [NewClient].
+	Config string
+	// Priority ranks the server. Servers with a lower value are preferred, and servers with the
+	// same value are ranked by latency.
+	Priority int
+}
+
+// NewClientWithPrioritizedFallback is like [NewClientWithFallback], but picks the server to use
+// by priority instead of by order, so that operators can prefer some servers and keep the others
+// as backups.
+//
+// The servers with the best priority are checked at the same time, and the fastest one that works
+// is picked. If none works, the servers with the next priority are checked, and so on. The client
+// fails over in the order of priority, and then in the order of servers.
+func NewClientWithPrioritizedFallback(ctx context.Context, servers []*FallbackServer) *NewClientWithFallbackResult {
+	candidates := make([]*Client, len(servers))
+	parseErrs := make([]error, len(servers))
+	for i, server := range servers {
+		if server == nil {
+			parseErrs[i] = &platerrors.PlatformError{Code: platerrors.InvalidConfig, Message: "server is missing"}
+			continue
+		}
+		result := NewClient(server.Config)
+		if result.Error != nil {
+			parseErrs[i] = result.Error
+			continue
+		}
+		candidates[i] = result.Client
+	}
+	return newClientWithPrioritizedFallback(ctx, servers, candidates, parseErrs, withProbeSlot(probeFallbackTransport), probeFallbackTransport)
+}
+
+// newClientWithPrioritizedFallback implements [NewClientWithPrioritizedFallback]. A nil candidate
+// is a server that failed to parse with the error at the same index of parseErrs. The candidates
+// are checked as with [newClientWithFallback].
+func newClientWithPrioritizedFallback(ctx context.Context, servers []*FallbackServer, candidates []*Client, parseErrs []error, probe, failoverProbe func(context.Context, *Client) error) *NewClientWithFallbackResult {
+	if len(servers) == 0 {
+		return &NewClientWithFallbackResult{Error: &platerrors.PlatformError{
+			Code:    platerrors.InvalidConfig,
+			Message: "no configs were provided",
+		}}
+	}
+	ids := make([]string, len(servers))
+	priorities := make([]int, len(servers))
+	order := make([]int, len(servers))
+	for i, server := range servers {
+		if server != nil {
+			ids[i] = server.ID
+			priorities[i] = server.Priority
+		}
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return priorities[order[a]] < priorities[order[b]] })
+
+	errs := append([]error(nil), parseErrs...)
+	latencies := make([]time.Duration, len(servers))
+	errorCode := platerrors.InvalidConfig
+	for start := 0; start < len(order); {
+		end := start + 1
+		for end < len(order) && priorities[order[end]] == priorities[order[start]] {
+			end++
+		}
+		group := order[start:end]
+		start = end
+
+		var wg sync.WaitGroup
+		for _, i := range group {
+			if candidates[i] == nil {
+				continue
+			}
+			// At least one config is valid, so the failure is about reaching the servers.
+			errorCode = platerrors.ProxyServerUnreachable
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				probeStart := time.Now()
+				errs[i] = probe(ctx, candidates[i])
+				latencies[i] = time.Since(probeStart)
+			}()
+		}
+		wg.Wait()
+		if ctx.Err() != nil {
+			return &NewClientWithFallbackResult{Error: &platerrors.PlatformError{
+				Code:    platerrors.OperationCanceled,
+				Message: "config selection was canceled",
+				Cause:   platerrors.ToPlatformError(ctx.Err()),
+			}}
+		}
+
+		best := -1
+		for _, i := range group {
+			if candidates[i] != nil && errs[i] == nil && (best < 0 || latencies[i] < latencies[best]) {
+				best = i
+			}
+		}
+		if best >= 0 {
+			client := newFallbackClient(candidates, best, failoverProbe)
+			client.fallback.order = order
+			client.fallback.ids = ids
+			return &NewClientWithFallbackResult{Client: client, Index: best, ID: ids[best]}
+		}
+	}
+
+	failures := make([]platerrors.ErrorDetails, 0, len(servers))
+	for i, err := range errs {
+		perr := platerrors.ToPlatformError(err)
+		failures = append(failures, platerrors.ErrorDetails{
+			"index":   i,
+			"id":      ids[i],
+			"code":    perr.Code,
+			"message": perr.Message,
+		})
+	}
+	return &NewClientWithFallbackResult{Error: &platerrors.PlatformError{
+		Code:    errorCode,
+		Message: "none of the configs works",
+		Details: platerrors.ErrorDetails{"failures": failures},
+	}}
+}
+
+// fallbackTransports relays the traffic of a client created by [NewClientWithFallback] through
+// the active candidate transport.
+type fallbackTransports struct {
+	candidates []*Client
+	probe      func(context.Context, *Client) error
+	active     atomic.Int64
+	failover   atomic.Bool
+	// switchMu guards switching, and the publication of the new active index.
+	switchMu sync.Mutex
+	// switching is the search for a transport to replace the active one, or nil if there is none.
+	switching *fallbackSwitch
+	// order lists the indexes of the candidates in the order failover tries them, or is nil for
+	// the order of candidates.
+	order []int
+	// ids are the IDs of the candidates, or nil if they have none.
+	ids []string
+}
+
+// newFallbackClient returns a [Client] that relays traffic through candidates[active], and
+// can switch to the other non-nil candidates if failover is enabled.
+//
+// The client has no connection provider info or config of its own, since they change when it
+// fails over: they are the ones of the active candidate, which [Client.transportClient] returns.
+func newFallbackClient(candidates []*Client, active int, probe func(context.Context, *Client) error) *Client {
+	f := &fallbackTransports{candidates: candidates, probe: probe}
+	f.active.Store(int64(active))
+	return &Client{
+		sd:       &config.Dialer[transport.StreamConn]{Dial: f.DialStream},
+		pl:       &config.PacketListener{PacketListener: f},
+		fallback: f,
+	}
+}
+
+// DialStream dials through the active transport. If that fails and failover is enabled, it
+// switches to the first other transport that passes the connectivity check and retries once.
+func (f *fallbackTransports) DialStream(ctx context.Context, address string) (transport.StreamConn, error) {
+	index := int(f.active.Load())
+	conn, err := f.candidates[index].sd.Dial(ctx, address)
+	if err == nil || !f.failover.Load() || ctx.Err() != nil {
+		return conn, err
+	}
+	next, ok := f.switchFrom(ctx, index)
+	if !ok {
+		return nil, err
+	}
+	return f.candidates[next].sd.Dial(ctx, address)
+}
+
+// ListenPacket listens through the active transport. If that fails and failover is enabled, it
+// switches transports as [fallbackTransports.DialStream] does. Failures to relay the packets of a
+// connection it returned don't make the client fail over, since UDP has no way to tell them.
+func (f *fallbackTransports) ListenPacket(ctx context.Context) (net.PacketConn, error) {
+	index := int(f.active.Load())
+	conn, err := f.candidates[index].pl.ListenPacket(ctx)
+	if err == nil || !f.failover.Load() || ctx.Err() != nil {
+		return conn, err
+	}
+	next, ok := f.switchFrom(ctx, index)
+	if !ok {
+		return nil, err
+	}
+	return f.candidates[next].pl.ListenPacket(ctx)
+}
+
+// fallbackSwitch is a search for a transport to replace a failed one, which the dials that fail
+// through that transport wait for.
+type fallbackSwitch struct {
+	// done is closed when the search ends, with its outcome in next and ok.
+	done chan struct{}
+	next int
+	ok   bool
+}
+
+// switchFrom replaces the failed transport with the first other transport that passes the
+// connectivity check, and returns its index. If another goroutine already replaced it, it
+// returns the current transport instead.
+//
+// The search runs in the background, independently of ctx, so that a caller with a short
+// deadline doesn't abort it for the others, and concurrent callers share it. switchFrom returns
+// false without waiting for it if ctx is done first.
+func (f *fallbackTransports) switchFrom(ctx context.Context, failed int) (int, bool) {
+	f.switchMu.Lock()
+	if active := int(f.active.Load()); active != failed {
+		f.switchMu.Unlock()
+		return active, true
+	}
+	s := f.switching
+	if s == nil {
+		s = &fallbackSwitch{done: make(chan struct{})}
+		f.switching = s
+		go f.search(s, failed)
+	}
+	f.switchMu.Unlock()
+
+	select {
+	case <-s.done:
+		return s.next, s.ok
+	case <-ctx.Done():
+		return 0, false
+	}
+}
+
+// search looks for a transport to replace the failed one for s, and makes it the active one.
+func (f *fallbackTransports) search(s *fallbackSwitch, failed int) {
+	order := f.order
+	if order == nil {
+		order = make([]int, len(f.candidates))
+		for i := range order {
+			order[i] = i
+		}
+	}
+	for _, i := range order {
+		candidate := f.candidates[i]
+		if i == failed || candidate == nil {
+			continue
+		}
+		// The probes have their own timeout, so the search always ends.
+		if f.probe(context.Background(), candidate) == nil {
+			s.next, s.ok = i, true
+			break
+		}
+	}
+
+	f.switchMu.Lock()
+	defer f.switchMu.Unlock()
+	if s.ok {
+		f.active.Store(int64(s.next))
+	}
+	f.switching = nil
+	close(s.done)
+}
+
+// SetFailoverEnabled sets whether a client created by [NewClientWithFallback] switches to
+// another working config when dialing or listening through the active one fails. It's disabled
+// by default, and has no effect on other clients.
+//
+// After a failover, the client reports the first hop, connection types and config of the new
+// active config, and the checks that recreate its transports, such as
+// [CheckTCPAndUDPConnectivityWithBaseDialers], use the new active config.
+func (c *Client) SetFailoverEnabled(enabled bool) {
+	if c.fallback != nil {
+		c.fallback.failover.Store(enabled)
+	}
+}
+
+// ActiveConfigIndex returns the index of the config that a client created by
+// [NewClientWithFallback] currently uses, or -1 for other clients.
+func (c *Client) ActiveConfigIndex() int {
+	if c.fallback == nil {
+		return -1
+	}
+	return int(c.fallback.active.Load())
+}
+
+// ActiveConfigID returns the ID of the server that a client created by
+// [NewClientWithPrioritizedFallback] currently uses, or an empty string for other clients.
+func (c *Client) ActiveConfigID() string {
+	if c.fallback == nil || c.fallback.ids == nil {
+		return ""
+	}
+	return c.fallback.ids[c.fallback.active.Load()]
+}
+
+// transportClient returns the client whose transports carry the traffic of c, which is the
+// active candidate for clients created by [NewClientWithFallback], and c itself otherwise. The
+// first hop, connection types and config of c are the ones of that client.
+func (c *Client) transportClient() *Client {
+	if c.fallback == nil {
+		return c
+	}
+	return c.fallback.candidates[c.fallback.active.Load()]
+}
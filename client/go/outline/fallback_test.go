@@ -0,0 +1,383 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/config"
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/Jigsaw-Code/outline-sdk/transport"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCandidate is a transport that dials directly unless it's broken, and counts its dials.
+type fakeCandidate struct {
+	broken atomic.Bool
+	dials  atomic.Int64
+}
+
+func (f *fakeCandidate) client(firstHop string) *Client {
+	tcpDialer := &transport.TCPDialer{}
+	return &Client{
+		sd: &config.Dialer[transport.StreamConn]{
+			ConnectionProviderInfo: config.ConnectionProviderInfo{ConnType: config.ConnTypeTunneled, FirstHop: firstHop},
+			Dial: func(ctx context.Context, address string) (transport.StreamConn, error) {
+				f.dials.Add(1)
+				if f.broken.Load() {
+					return nil, errors.New("candidate is broken")
+				}
+				return tcpDialer.DialStream(ctx, address)
+			},
+		},
+		pl: &config.PacketListener{
+			ConnectionProviderInfo: config.ConnectionProviderInfo{ConnType: config.ConnTypeTunneled, FirstHop: firstHop},
+			PacketListener:         &transport.UDPListener{},
+		},
+	}
+}
+
+// newTestProbe returns a probe that dials address through the candidate.
+func newTestProbe(address string) func(context.Context, *Client) error {
+	return func(ctx context.Context, c *Client) error {
+		conn, err := c.sd.Dial(ctx, address)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+}
+
+func Test_newClientWithFallback_FirstWorkingWins(t *testing.T) {
+	echoAddr := newTCPEchoServer(t)
+	broken, working := &fakeCandidate{}, &fakeCandidate{}
+	broken.broken.Store(true)
+	candidates := []*Client{nil, broken.client("a:1"), working.client("b:2")}
+	parseErrs := []error{errors.New("bad config"), nil, nil}
+
+	probe := newTestProbe(echoAddr)
+	result := newClientWithFallback(context.Background(), candidates, parseErrs, probe, probe)
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.Equal(t, 2, result.Index)
+	require.Equal(t, 2, result.Client.ActiveConfigIndex())
+	require.Equal(t, "b:2", result.Client.transportClient().sd.FirstHop)
+
+	conn, err := result.Client.DialStream(context.Background(), echoAddr)
+	require.NoError(t, err)
+	conn.Close()
+	require.Equal(t, int64(2), working.dials.Load())
+}
+
+func Test_newClientWithFallback_AllFail(t *testing.T) {
+	echoAddr := newTCPEchoServer(t)
+	broken := &fakeCandidate{}
+	broken.broken.Store(true)
+	candidates := []*Client{nil, broken.client("a:1")}
+	parseErrs := []error{&platerrors.PlatformError{Code: platerrors.InvalidConfig, Message: "bad config"}, nil}
+
+	probe := newTestProbe(echoAddr)
+	result := newClientWithFallback(context.Background(), candidates, parseErrs, probe, probe)
+	require.Nil(t, result.Client)
+	require.NotNil(t, result.Error)
+	require.Equal(t, platerrors.ProxyServerUnreachable, result.Error.Code)
+	failures := result.Error.Details["failures"].([]platerrors.ErrorDetails)
+	require.Len(t, failures, 2)
+	require.Equal(t, 0, failures[0]["index"])
+	require.Equal(t, platerrors.InvalidConfig, failures[0]["code"])
+	require.Equal(t, "bad config", failures[0]["message"])
+	require.Equal(t, 1, failures[1]["index"])
+}
+
+func Test_NewClientWithFallback_InvalidConfigs(t *testing.T) {
+	result := NewClientWithFallback([]string{"transport: {$type: unknown}", "{{{"})
+	require.Nil(t, result.Client)
+	require.NotNil(t, result.Error)
+	require.Equal(t, platerrors.InvalidConfig, result.Error.Code)
+	require.Len(t, result.Error.Details["failures"], 2)
+}
+
+func Test_NewClientWithFallback_Empty(t *testing.T) {
+	result := NewClientWithFallback(nil)
+	require.NotNil(t, result.Error)
+	require.Equal(t, platerrors.InvalidConfig, result.Error.Code)
+}
+
+func Test_FallbackClient_Failover(t *testing.T) {
+	echoAddr := newTCPEchoServer(t)
+	first, second := &fakeCandidate{}, &fakeCandidate{}
+	candidates := []*Client{first.client("a:1"), second.client("b:2")}
+	probe := newTestProbe(echoAddr)
+	result := newClientWithFallback(context.Background(), candidates, make([]error, 2), probe, probe)
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.Equal(t, 0, result.Index)
+	client := result.Client
+
+	first.broken.Store(true)
+	_, err := client.DialStream(context.Background(), echoAddr)
+	require.Error(t, err, "failover is disabled by default")
+	require.Equal(t, 0, client.ActiveConfigIndex())
+
+	client.SetFailoverEnabled(true)
+	conn, err := client.DialStream(context.Background(), echoAddr)
+	require.NoError(t, err)
+	conn.Close()
+	require.Equal(t, 1, client.ActiveConfigIndex())
+}
+
+func Test_FallbackClient_FailoverUpdatesTransportInfo(t *testing.T) {
+	echoAddr := newTCPEchoServer(t)
+	first, second := &fakeCandidate{}, &fakeCandidate{}
+	candidates := []*Client{first.client("127.0.0.1:1"), second.client("127.0.0.2:2")}
+	candidates[0].config.Store(&ClientConfig{Transport: "ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpTRUNSRVQ@127.0.0.1:1/"})
+	candidates[1].config.Store(&ClientConfig{Transport: "ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpTRUNSRVQ@127.0.0.2:2/"})
+	probe := newTestProbe(echoAddr)
+	result := newClientWithFallback(context.Background(), candidates, make([]error, 2), probe, probe)
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	client := result.Client
+	require.Contains(t, client.DescribeTransport(), "127.0.0.1:1")
+
+	first.broken.Store(true)
+	client.SetFailoverEnabled(true)
+	connectivityResult := CheckTCPAndUDPConnectivityWithTargets(client, echoAddr, "")
+	require.Nil(t, connectivityResult.TCPError, "Got %v", connectivityResult.TCPError)
+	require.Equal(t, 1, client.ActiveConfigIndex())
+	require.Equal(t, "127.0.0.2:2", connectivityResult.ServerAddress)
+	require.Equal(t, "127.0.0.2:2", connectivityResult.ResolvedAddress)
+	require.Contains(t, client.DescribeTransport(), "127.0.0.2:2")
+	require.NotContains(t, client.DescribeTransport(), "127.0.0.1:1")
+	require.Equal(t, "127.0.0.2:2", newTestPlan(client, &BandwidthTestConfig{}).ServerAddress)
+
+	probeClient, err := newProbeClient(client, &transport.TCPDialer{}, &transport.UDPDialer{})
+	require.NoError(t, err)
+	require.Equal(t, "127.0.0.2:2", probeClient.sd.FirstHop)
+}
+
+func Test_FallbackClient_ListenPacketFailover(t *testing.T) {
+	echoAddr := newTCPEchoServer(t)
+	first, second := &fakeCandidate{}, &fakeCandidate{}
+	candidates := []*Client{first.client("a:1"), second.client("b:2")}
+	candidates[0].pl.PacketListener = &transport.UDPListener{Address: "invalid address"}
+	probe := newTestProbe(echoAddr)
+	result := newClientWithFallback(context.Background(), candidates, make([]error, 2), probe, probe)
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	client := result.Client
+
+	_, err := client.ListenPacket(context.Background())
+	require.Error(t, err, "failover is disabled by default")
+
+	client.SetFailoverEnabled(true)
+	conn, err := client.ListenPacket(context.Background())
+	require.NoError(t, err)
+	conn.Close()
+	require.Equal(t, 1, client.ActiveConfigIndex())
+}
+
+func Test_FallbackClient_FailoverOutlivesCallerContext(t *testing.T) {
+	echoAddr := newTCPEchoServer(t)
+	first, second := &fakeCandidate{}, &fakeCandidate{}
+	candidates := []*Client{first.client("a:1"), second.client("b:2")}
+	var blocked atomic.Bool
+	release := make(chan struct{})
+	probe := func(ctx context.Context, c *Client) error {
+		if blocked.Load() {
+			<-release
+		}
+		return newTestProbe(echoAddr)(ctx, c)
+	}
+	result := newClientWithFallback(context.Background(), candidates, make([]error, 2), probe, probe)
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	client := result.Client
+	client.SetFailoverEnabled(true)
+
+	first.broken.Store(true)
+	blocked.Store(true)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := client.DialStream(ctx, echoAddr)
+	require.Error(t, err)
+	require.Less(t, time.Since(start), 5*time.Second, "the dial waited for the failover past its deadline")
+	require.Equal(t, 0, client.ActiveConfigIndex())
+
+	// The failover goes on after the caller gave up, and the next dials use its outcome.
+	close(release)
+	require.Eventually(t, func() bool { return client.ActiveConfigIndex() == 1 }, 5*time.Second, 10*time.Millisecond)
+	conn, err := client.DialStream(context.Background(), echoAddr)
+	require.NoError(t, err)
+	conn.Close()
+}
+
+func Test_Client_FailoverNoopWithoutFallback(t *testing.T) {
+	client := newDirectTestClient()
+	client.SetFailoverEnabled(true)
+	require.Equal(t, -1, client.ActiveConfigIndex())
+}
+
+// newFlakyProbe returns a probe that fails the first failures times, and counts its calls.
+func newFlakyProbe(failures int64, calls *atomic.Int64) func(context.Context, *Client) error {
+	return func(ctx context.Context, c *Client) error {
+		if calls.Add(1) <= failures {
+			return errors.New("transient failure")
+		}
+		return nil
+	}
+}
+
+func Test_withProbeRetry_RecoversFromTransientFailure(t *testing.T) {
+	var calls atomic.Int64
+	probe := withProbeRetry(newFlakyProbe(2, &calls), FallbackRetryConfig{MaxAttempts: 3, InitialDelayMs: 1})
+	candidates := []*Client{(&fakeCandidate{}).client("a:1")}
+
+	result := newClientWithFallback(context.Background(), candidates, make([]error, 1), probe, probe)
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.Equal(t, 0, result.Index)
+	require.Equal(t, int64(3), calls.Load())
+}
+
+func Test_withProbeRetry_MaxAttempts(t *testing.T) {
+	var calls atomic.Int64
+	probe := withProbeRetry(newFlakyProbe(10, &calls), FallbackRetryConfig{MaxAttempts: 3, InitialDelayMs: 1})
+	require.Error(t, probe(context.Background(), nil))
+	require.Equal(t, int64(3), calls.Load())
+
+	calls.Store(0)
+	probe = withProbeRetry(newFlakyProbe(10, &calls), FallbackRetryConfig{})
+	require.Error(t, probe(context.Background(), nil))
+	require.Equal(t, int64(1), calls.Load())
+}
+
+func Test_withProbeRetry_MaxDelay(t *testing.T) {
+	var calls atomic.Int64
+	probe := withProbeRetry(newFlakyProbe(10, &calls), FallbackRetryConfig{MaxAttempts: 4, InitialDelayMs: 20, MaxDelayMs: 20})
+	start := time.Now()
+	require.Error(t, probe(context.Background(), nil))
+	// The delays add up to 60ms instead of 20ms + 40ms + 80ms.
+	require.GreaterOrEqual(t, time.Since(start), 60*time.Millisecond)
+	require.Less(t, time.Since(start), 140*time.Millisecond)
+}
+
+func Test_newClientWithFallback_Canceled(t *testing.T) {
+	var calls atomic.Int64
+	probe := withProbeRetry(newFlakyProbe(10, &calls), FallbackRetryConfig{MaxAttempts: 100, InitialDelayMs: 10_000})
+	candidates := []*Client{(&fakeCandidate{}).client("a:1"), (&fakeCandidate{}).client("b:2")}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	result := newClientWithFallback(ctx, candidates, make([]error, 2), probe, probe)
+	require.NotNil(t, result.Error)
+	require.Equal(t, platerrors.OperationCanceled, result.Error.Code)
+	// The second candidate is never probed.
+	require.Equal(t, int64(1), calls.Load())
+}
+
+func Test_NewClientWithFallbackAndRetry_InvalidRetry(t *testing.T) {
+	result := NewClientWithFallbackAndRetry(context.Background(), []string{"ss://invalid"}, &FallbackRetryConfig{InitialDelayMs: -1})
+	require.NotNil(t, result.Error)
+	require.Equal(t, platerrors.InvalidConfig, result.Error.Code)
+}
+
+// newDelayedTestProbe returns a probe like [newTestProbe] that takes at least the delay of the
+// candidate in delays.
+func newDelayedTestProbe(address string, delays map[*Client]time.Duration) func(context.Context, *Client) error {
+	probe := newTestProbe(address)
+	return func(ctx context.Context, c *Client) error {
+		time.Sleep(delays[c])
+		return probe(ctx, c)
+	}
+}
+
+func Test_newClientWithPrioritizedFallback_PriorityThenLatency(t *testing.T) {
+	echoAddr := newTCPEchoServer(t)
+	brokenPrimary, slowSecondary, fastSecondary, fastBackup := &fakeCandidate{}, &fakeCandidate{}, &fakeCandidate{}, &fakeCandidate{}
+	brokenPrimary.broken.Store(true)
+	candidates := []*Client{fastBackup.client("d:4"), slowSecondary.client("b:2"), brokenPrimary.client("a:1"), fastSecondary.client("c:3")}
+	servers := []*FallbackServer{{ID: "backup", Priority: 3}, {ID: "slow", Priority: 2}, {ID: "primary", Priority: 1}, {ID: "fast", Priority: 2}}
+	delays := map[*Client]time.Duration{candidates[1]: 100 * time.Millisecond}
+
+	probe := newDelayedTestProbe(echoAddr, delays)
+	result := newClientWithPrioritizedFallback(context.Background(), servers, candidates, make([]error, 4), probe, probe)
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.Equal(t, 3, result.Index)
+	require.Equal(t, "fast", result.ID)
+	require.Equal(t, "fast", result.Client.ActiveConfigID())
+	// The backup server, with the worst priority, was never checked.
+	require.Zero(t, fastBackup.dials.Load())
+}
+
+func Test_newClientWithPrioritizedFallback_FailoverByPriority(t *testing.T) {
+	echoAddr := newTCPEchoServer(t)
+	first, backup, second := &fakeCandidate{}, &fakeCandidate{}, &fakeCandidate{}
+	candidates := []*Client{first.client("a:1"), backup.client("b:2"), second.client("c:3")}
+	servers := []*FallbackServer{{ID: "first", Priority: 1}, {ID: "backup", Priority: 5}, {ID: "second", Priority: 2}}
+
+	probe := newTestProbe(echoAddr)
+	result := newClientWithPrioritizedFallback(context.Background(), servers, candidates, make([]error, 3), probe, probe)
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.Equal(t, "first", result.ID)
+
+	result.Client.SetFailoverEnabled(true)
+	first.broken.Store(true)
+	conn, err := result.Client.DialStream(context.Background(), echoAddr)
+	require.NoError(t, err)
+	conn.Close()
+	require.Equal(t, "second", result.Client.ActiveConfigID())
+	require.Equal(t, 2, result.Client.ActiveConfigIndex())
+}
+
+func Test_newClientWithPrioritizedFallback_AllFail(t *testing.T) {
+	echoAddr := newTCPEchoServer(t)
+	broken := &fakeCandidate{}
+	broken.broken.Store(true)
+	candidates := []*Client{nil, broken.client("a:1")}
+	parseErrs := []error{&platerrors.PlatformError{Code: platerrors.InvalidConfig, Message: "bad config"}, nil}
+	servers := []*FallbackServer{{ID: "bad"}, {ID: "broken"}}
+
+	probe := newTestProbe(echoAddr)
+	result := newClientWithPrioritizedFallback(context.Background(), servers, candidates, parseErrs, probe, probe)
+	require.Nil(t, result.Client)
+	require.NotNil(t, result.Error)
+	require.Equal(t, platerrors.ProxyServerUnreachable, result.Error.Code)
+	failures := result.Error.Details["failures"].([]platerrors.ErrorDetails)
+	require.Len(t, failures, 2)
+	require.Equal(t, "bad", failures[0]["id"])
+	require.Equal(t, "bad config", failures[0]["message"])
+	require.Equal(t, "broken", failures[1]["id"])
+}
+
+func Test_NewClientWithPrioritizedFallback_InvalidServers(t *testing.T) {
+	result := NewClientWithPrioritizedFallback(context.Background(), nil)
+	require.NotNil(t, result.Error)
+	require.Equal(t, platerrors.InvalidConfig, result.Error.Code)
+
+	result = NewClientWithPrioritizedFallback(context.Background(), []*FallbackServer{nil, {ID: "bad", Config: "{{{"}})
+	require.NotNil(t, result.Error)
+	require.Equal(t, platerrors.InvalidConfig, result.Error.Code)
+	require.Len(t, result.Error.Details["failures"], 2)
+}
+
+func Test_Client_ActiveConfigID_WithoutIDs(t *testing.T) {
+	require.Empty(t, newDirectTestClient().ActiveConfigID())
+
+	echoAddr := newTCPEchoServer(t)
+	working := &fakeCandidate{}
+	probe := newTestProbe(echoAddr)
+	result := newClientWithFallback(context.Background(), []*Client{working.client("a:1")}, []error{nil}, probe, probe)
+	require.Nil(t, result.Error)
+	require.Empty(t, result.Client.ActiveConfigID())
+}
@@ -0,0 +1,43 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"net/netip"
+
+	"github.com/Jigsaw-Code/outline-sdk/transport"
+)
+
+// lookupNetIPFunc resolves host to its addresses of network, "ip4" or "ip6", like
+// [net.Resolver.LookupNetIP].
+type lookupNetIPFunc func(ctx context.Context, network, host string) ([]netip.Addr, error)
+
+// newHappyEyeballsDialer returns a dialer for [TCPOptions.HappyEyeballs] that connects through
+// base. It resolves the IPv6 and IPv4 addresses of host names with lookup in parallel, and
+// starts connecting as soon as addresses arrive, IPv6 first, with a short delay between the
+// attempts. The first attempt to connect wins and the others are canceled. IP addresses are
+// dialed as is.
+func newHappyEyeballsDialer(base transport.StreamDialer, lookup lookupNetIPFunc) transport.StreamDialer {
+	lookupFamily := func(network string) func(ctx context.Context, host string) ([]netip.Addr, error) {
+		return func(ctx context.Context, host string) ([]netip.Addr, error) {
+			return lookup(ctx, network, host)
+		}
+	}
+	return &transport.HappyEyeballsStreamDialer{
+		Dialer:  base,
+		Resolve: transport.NewParallelHappyEyeballsResolveFunc(lookupFamily("ip6"), lookupFamily("ip4")),
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-sdk/transport"
+	"github.com/stretchr/testify/require"
+)
+
+// raceTestDialer connects every attempt to server, except the attempts to blocked addresses,
+// which hang until they are canceled, and records the addresses it dials.
+type raceTestDialer struct {
+	server  string
+	blocked map[string]bool
+
+	mu     sync.Mutex
+	dialed []string
+}
+
+func (d *raceTestDialer) DialStream(ctx context.Context, addr string) (transport.StreamConn, error) {
+	d.mu.Lock()
+	d.dialed = append(d.dialed, addr)
+	d.mu.Unlock()
+	if d.blocked[addr] {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return (&transport.TCPDialer{}).DialStream(ctx, d.server)
+}
+
+func (d *raceTestDialer) dialedAddresses() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.dialed...)
+}
+
+// newRaceTestLookup returns a lookup that resolves every host to ip6 and ip4, or fails for a
+// family with no address.
+func newRaceTestLookup(ip6, ip4 string) lookupNetIPFunc {
+	return func(ctx context.Context, network, host string) ([]netip.Addr, error) {
+		ip := ip4
+		if network == "ip6" {
+			ip = ip6
+		}
+		if ip == "" {
+			return nil, errors.New("no such host")
+		}
+		return []netip.Addr{netip.MustParseAddr(ip)}, nil
+	}
+}
+
+func Test_newHappyEyeballsDialer_PrefersIPv6(t *testing.T) {
+	base := &raceTestDialer{server: newTCPEchoServer(t)}
+	dialer := newHappyEyeballsDialer(base, newRaceTestLookup("2001:db8::1", "192.0.2.1"))
+
+	conn, err := dialer.DialStream(context.Background(), "example.com:443")
+	require.NoError(t, err)
+	defer conn.Close()
+	// IPv6 connects right away, so IPv4 is never tried.
+	require.Equal(t, []string{"[2001:db8::1]:443"}, base.dialedAddresses())
+}
+
+func Test_newHappyEyeballsDialer_BlockedIPv6(t *testing.T) {
+	base := &raceTestDialer{server: newTCPEchoServer(t), blocked: map[string]bool{"[2001:db8::1]:443": true}}
+	dialer := newHappyEyeballsDialer(base, newRaceTestLookup("2001:db8::1", "192.0.2.1"))
+
+	start := time.Now()
+	conn, err := dialer.DialStream(context.Background(), "example.com:443")
+	require.NoError(t, err)
+	defer conn.Close()
+	// IPv4 wins the race after a short delay instead of waiting for IPv6 to time out.
+	require.Less(t, time.Since(start), time.Second)
+	require.Equal(t, []string{"[2001:db8::1]:443", "192.0.2.1:443"}, base.dialedAddresses())
+}
+
+func Test_newHappyEyeballsDialer_NoIPv6(t *testing.T) {
+	base := &raceTestDialer{server: newTCPEchoServer(t)}
+	dialer := newHappyEyeballsDialer(base, newRaceTestLookup("", "192.0.2.1"))
+
+	conn, err := dialer.DialStream(context.Background(), "example.com:443")
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Equal(t, []string{"192.0.2.1:443"}, base.dialedAddresses())
+}
+
+func Test_newHappyEyeballsDialer_IPAddress(t *testing.T) {
+	base := &raceTestDialer{server: newTCPEchoServer(t)}
+	dialer := newHappyEyeballsDialer(base, func(context.Context, string, string) ([]netip.Addr, error) {
+		panic("IP addresses are not resolved")
+	})
+
+	conn, err := dialer.DialStream(context.Background(), "192.0.2.1:443")
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Equal(t, []string{"192.0.2.1:443"}, base.dialedAddresses())
+}
+
+func Test_newHappyEyeballsDialer_Fails(t *testing.T) {
+	base := &raceTestDialer{server: newTCPEchoServer(t)}
+	dialer := newHappyEyeballsDialer(base, newRaceTestLookup("", ""))
+
+	_, err := dialer.DialStream(context.Background(), "example.com:443")
+	require.Error(t, err)
+	require.Empty(t, base.dialedAddresses())
+}
+
+func Test_NewClientWithTCPOptions_HappyEyeballs(t *testing.T) {
+	const config = "transport: ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpTRUNSRVQ@example.com:4321/"
+	result := NewClientWithTCPOptions(config, &TCPOptions{NoDelay: true, HappyEyeballs: true})
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.Equal(t, ConnTypeTunneled, result.StreamConnType)
+}
@@ -0,0 +1,66 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-sdk/transport"
+)
+
+// HTTPClient returns an HTTP client that sends its requests through the proxy, giving up on
+// each request after timeout, or never if it's zero.
+//
+// All the clients returned for a [Client] share a pool of connections, which the speed tests use
+// as well. HTTP/2 is not used, so that concurrent requests get connections of their own.
+// Compressed responses are not decompressed, so that the speed tests measure the bytes on the wire.
+func (c *Client) HTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{Transport: c.proxyHTTPTransport(), Timeout: timeout}
+}
+
+// proxyHTTPTransport returns the connection pool of the client, creating it on first use.
+func (c *Client) proxyHTTPTransport() *http.Transport {
+	c.httpTransportOnce.Do(func() {
+		c.httpTransport = newProxyHTTPTransport(c)
+	})
+	return c.httpTransport
+}
+
+// newProxyHTTPTransport returns an HTTP transport that makes all its connections through sd.
+func newProxyHTTPTransport(sd transport.StreamDialer) *http.Transport {
+	return &http.Transport{
+		// Never use a proxy from the environment, all traffic must go through sd.
+		Proxy: nil,
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return sd.DialStream(ctx, addr)
+		},
+		DisableCompression:    true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
+// newMultiplexedHTTPTransport returns an HTTP transport like [newProxyHTTPTransport] that uses
+// HTTP/2 when the server negotiates it.
+func newMultiplexedHTTPTransport(sd transport.StreamDialer) *http.Transport {
+	httpTransport := newProxyHTTPTransport(sd)
+	httpTransport.ForceAttemptHTTP2 = true
+	return httpTransport
+}
@@ -0,0 +1,63 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Client_HTTPClient_ReusesConnections(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+	client := newDirectTestClient()
+
+	for i := 0; i < 3; i++ {
+		// Each call returns a new http.Client on the same pool.
+		resp, err := client.HTTPClient(5 * time.Second).Get(server.URL)
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.NoError(t, err)
+		require.Equal(t, "ok", string(body))
+	}
+
+	// All requests went through the proxy, over a single connection.
+	require.Equal(t, int64(1), client.Stats().ConnectionsOpened)
+}
+
+func Test_Client_SpeedTestsShareHTTPClientPool(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+	}))
+	defer server.Close()
+	client := newDirectTestClient()
+
+	resp, err := client.HTTPClient(0).Get(server.URL)
+	require.NoError(t, err)
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	require.GreaterOrEqual(t, client.TestLatency(context.Background(), server.URL), int64(0))
+	require.Equal(t, int64(1), client.Stats().ConnectionsOpened)
+}
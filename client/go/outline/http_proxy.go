@@ -0,0 +1,192 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"sync"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"golang.org/x/net/netutil"
+)
+
+const (
+	// httpProxyMaxConns is the maximum number of client connections that the HTTP proxy serves at
+	// the same time, including CONNECT tunnels. Further connections wait for one to be closed.
+	httpProxyMaxConns = 256
+	// httpProxyHeaderTimeout bounds the time a client takes to send the header of a request.
+	httpProxyHeaderTimeout = 10 * time.Second
+	// httpProxyShutdownTimeout is the time the requests in progress get to complete once the
+	// proxy is stopped.
+	httpProxyShutdownTimeout = 5 * time.Second
+)
+
+// RunHTTPProxy serves a local HTTP forward proxy on listenAddr that relays the traffic through c,
+// so that browsers and other apps can use the tunnel by pointing their proxy settings to it. It
+// forwards plain HTTP requests, which must have an absolute URL, and tunnels HTTPS and other
+// traffic with the CONNECT method, both with [Client.DialStream]. The proxy doesn't authenticate
+// its clients, so listenAddr should be a loopback address, such as "127.0.0.1:8080". It serves
+// up to 256 connections at the same time.
+//
+// RunHTTPProxy blocks until ctx is done. It then stops accepting connections, closes the CONNECT
+// tunnels, gives the other requests in progress a few seconds to complete, and returns nil. It
+// returns a [platerrors.PlatformError] if it can't listen on listenAddr.
+func (c *Client) RunHTTPProxy(ctx context.Context, listenAddr string) error {
+	listener, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		return localProxyListenError(err, listenAddr)
+	}
+	return c.serveHTTPProxy(ctx, listener)
+}
+
+// serveHTTPProxy serves the HTTP proxy connections of listener until ctx is done.
+func (c *Client) serveHTTPProxy(ctx context.Context, listener net.Listener) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	handler := &httpProxyHandler{client: c, ctx: ctx, transport: newProxyHTTPTransport(c)}
+	defer handler.transport.CloseIdleConnections()
+	handler.reverseProxy = &httputil.ReverseProxy{
+		// The outgoing request keeps the absolute URL of the incoming one. The hop-by-hop and
+		// X-Forwarded-* headers are removed, so that the client stays anonymous.
+		Rewrite:   func(*httputil.ProxyRequest) {},
+		Transport: handler.transport,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			writeHTTPProxyError(w, err)
+		},
+	}
+	server := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: httpProxyHeaderTimeout,
+		ErrorLog:          log.New(io.Discard, "", 0),
+	}
+
+	serveDone := make(chan error, 1)
+	go func() {
+		serveDone <- server.Serve(netutil.LimitListener(listener, httpProxyMaxConns))
+	}()
+	logger().Info("HTTP proxy started")
+	select {
+	case err := <-serveDone:
+		cancel()
+		handler.tunnels.Wait()
+		logger().Warn("HTTP proxy failed")
+		return platerrors.PlatformError{
+			Code:    platerrors.InternalError,
+			Message: "failed to accept HTTP proxy connections",
+			Cause:   platerrors.ToPlatformError(err),
+		}
+	case <-ctx.Done():
+	}
+
+	// The CONNECT tunnels are hijacked from the server, and closed by their handlers with ctx.
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), httpProxyShutdownTimeout)
+	defer cancelShutdown()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		server.Close()
+	}
+	<-serveDone
+	handler.tunnels.Wait()
+	logger().Info("HTTP proxy stopped")
+	return nil
+}
+
+// httpProxyHandler is the [http.Handler] of the HTTP proxy of a [Client].
+type httpProxyHandler struct {
+	client *Client
+	// ctx is done once the proxy is stopped.
+	ctx          context.Context
+	transport    *http.Transport
+	reverseProxy *httputil.ReverseProxy
+	// tunnels tracks the CONNECT tunnels, which the server doesn't track once hijacked.
+	tunnels sync.WaitGroup
+}
+
+func (h *httpProxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodConnect {
+		h.serveConnect(w, r)
+		return
+	}
+	if !r.URL.IsAbs() || (r.URL.Scheme != "http" && r.URL.Scheme != "https") || r.URL.Host == "" {
+		http.Error(w, "proxy requests must have an absolute http URL", http.StatusBadRequest)
+		return
+	}
+	h.reverseProxy.ServeHTTP(w, r)
+}
+
+// serveConnect serves a CONNECT request: it connects to the requested host through the client
+// and relays the data between the client connection and the new connection.
+func (h *httpProxyHandler) serveConnect(w http.ResponseWriter, r *http.Request) {
+	if _, _, err := net.SplitHostPort(r.Host); err != nil {
+		http.Error(w, "CONNECT requests must be for a host:port address", http.StatusBadRequest)
+		return
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "CONNECT is not supported on this connection", http.StatusInternalServerError)
+		return
+	}
+	h.tunnels.Add(1)
+	defer h.tunnels.Done()
+
+	target, err := h.client.DialStream(r.Context(), r.Host)
+	if err != nil {
+		writeHTTPProxyError(w, err)
+		return
+	}
+	defer target.Close()
+	conn, buffered, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(h.ctx, func() {
+		conn.Close()
+		target.Close()
+	})
+	defer stop()
+
+	if _, err := io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n"); err != nil {
+		return
+	}
+	// Forward the data that the client sent right after the request, if any.
+	if n := buffered.Reader.Buffered(); n > 0 {
+		data, _ := buffered.Reader.Peek(n)
+		if _, err := target.Write(data); err != nil {
+			return
+		}
+	}
+	relayStreams(conn, target)
+}
+
+// writeHTTPProxyError replies to a proxy request that failed with err.
+func writeHTTPProxyError(w http.ResponseWriter, err error) {
+	status := http.StatusBadGateway
+	var perr platerrors.PlatformError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &perr) && perr.Code == platerrors.DestinationForbidden:
+		status = http.StatusForbidden
+	case errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()):
+		status = http.StatusGatewayTimeout
+	}
+	http.Error(w, http.StatusText(status), status)
+}
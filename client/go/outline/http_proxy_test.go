@@ -0,0 +1,205 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/stretchr/testify/require"
+)
+
+// startHTTPProxy serves an HTTP proxy through client on a local port until the test is done, and
+// returns its URL.
+func startHTTPProxy(t *testing.T, client *Client) *url.URL {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- client.serveHTTPProxy(ctx, listener) }()
+	t.Cleanup(func() {
+		cancel()
+		require.NoError(t, <-done)
+	})
+	return &url.URL{Scheme: "http", Host: listener.Addr().String()}
+}
+
+// newHTTPProxyTestClient returns an HTTP client that uses the proxy at proxyURL.
+func newHTTPProxyTestClient(proxyURL *url.URL, base *http.Transport) *http.Client {
+	if base == nil {
+		base = &http.Transport{}
+	}
+	base.Proxy = http.ProxyURL(proxyURL)
+	return &http.Client{Transport: base, Timeout: 5 * time.Second}
+}
+
+func Test_RunHTTPProxy_PlainRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Empty(t, r.Header.Get("Proxy-Authorization"))
+		require.Empty(t, r.Header.Get("X-Forwarded-For"))
+		io.WriteString(w, "hello from "+r.URL.Path)
+	}))
+	defer server.Close()
+	client := newDirectTestClient()
+	proxyURL := startHTTPProxy(t, client)
+	proxyURL.User = url.UserPassword("user", "secret")
+
+	resp, err := newHTTPProxyTestClient(proxyURL, nil).Get(server.URL + "/path")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "hello from /path", string(body))
+	require.NotZero(t, client.Stats().BytesSent)
+}
+
+func Test_RunHTTPProxy_ConnectHTTPS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "secure")
+	}))
+	defer server.Close()
+	proxyURL := startHTTPProxy(t, newDirectTestClient())
+
+	httpClient := newHTTPProxyTestClient(proxyURL, server.Client().Transport.(*http.Transport).Clone())
+	resp, err := httpClient.Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "secure", string(body))
+}
+
+func Test_RunHTTPProxy_ConnectForwardsEarlyData(t *testing.T) {
+	echoServer := newTCPEchoServer(t)
+	proxyURL := startHTTPProxy(t, newDirectTestClient())
+
+	conn, err := net.Dial("tcp", proxyURL.Host)
+	require.NoError(t, err)
+	defer conn.Close()
+	// The client sends its data without waiting for the response.
+	_, err = io.WriteString(conn, "CONNECT "+echoServer+" HTTP/1.1\r\nHost: "+echoServer+"\r\n\r\nearly")
+	require.NoError(t, err)
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, &http.Request{Method: http.MethodConnect})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	echo := make([]byte, len("early"))
+	_, err = io.ReadFull(reader, echo)
+	require.NoError(t, err)
+	require.Equal(t, "early", string(echo))
+}
+
+func Test_RunHTTPProxy_Forbidden(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	client := newDirectTestClient()
+	require.Nil(t, client.SetDialPolicy(nil, []string{"127.0.0.1"}))
+	proxyURL := startHTTPProxy(t, client)
+
+	resp, err := newHTTPProxyTestClient(proxyURL, nil).Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	conn, err := net.Dial("tcp", proxyURL.Host)
+	require.NoError(t, err)
+	defer conn.Close()
+	host := server.Listener.Addr().String()
+	_, err = io.WriteString(conn, "CONNECT "+host+" HTTP/1.1\r\nHost: "+host+"\r\n\r\n")
+	require.NoError(t, err)
+	resp, err = http.ReadResponse(bufio.NewReader(conn), &http.Request{Method: http.MethodConnect})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+func Test_RunHTTPProxy_Unreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	closedAddr := listener.Addr().String()
+	listener.Close()
+	proxyURL := startHTTPProxy(t, newDirectTestClient())
+
+	resp, err := newHTTPProxyTestClient(proxyURL, nil).Get("http://" + closedAddr)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadGateway, resp.StatusCode)
+}
+
+func Test_RunHTTPProxy_RejectsOriginRequests(t *testing.T) {
+	proxyURL := startHTTPProxy(t, newDirectTestClient())
+
+	resp, err := http.Get(proxyURL.String() + "/path")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func Test_RunHTTPProxy_StopsOnCancel(t *testing.T) {
+	echoServer := newTCPEchoServer(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	proxyAddr := listener.Addr().String()
+	listener.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- newDirectTestClient().RunHTTPProxy(ctx, proxyAddr) }()
+
+	var conn net.Conn
+	require.Eventually(t, func() bool {
+		conn, err = net.Dial("tcp", proxyAddr)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	defer conn.Close()
+	_, err = io.WriteString(conn, "CONNECT "+echoServer+" HTTP/1.1\r\nHost: "+echoServer+"\r\n\r\n")
+	require.NoError(t, err)
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, &http.Request{Method: http.MethodConnect})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	cancel()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("RunHTTPProxy didn't stop")
+	}
+	// The tunnel was closed.
+	_, err = reader.ReadByte()
+	require.Error(t, err)
+	_, err = net.Dial("tcp", proxyAddr)
+	require.Error(t, err)
+}
+
+func Test_RunHTTPProxy_AddressInUse(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	err = newDirectTestClient().RunHTTPProxy(context.Background(), listener.Addr().String())
+	var perr platerrors.PlatformError
+	require.True(t, errors.As(err, &perr))
+	require.Equal(t, platerrors.LocalAddressInUse, perr.Code)
+}
@@ -0,0 +1,131 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+)
+
+// httpsReachabilityTimeout bounds [Client.TestHTTPSReachable] when ctx has no deadline.
+const httpsReachabilityTimeout = 15 * time.Second
+
+// TestHTTPSReachable checks whether the https testURL can be fetched through the proxy. It makes
+// a GET request on a new connection, and returns nil if the response has a 2xx or 3xx status,
+// and redirects, if any, stay on the same site.
+//
+// Failures are [platerrors.PlatformError] values: [platerrors.InvalidConfig] for URLs that aren't
+// https, [platerrors.TLSHandshakeFailed] if the TLS connection fails, which may mean the traffic
+// is intercepted, [platerrors.BlockPageDetected] for error statuses and redirects to other sites,
+// which is how captive portals and block pages often respond, [platerrors.Timeout],
+// [platerrors.OperationCanceled], and otherwise [platerrors.ProxyServerUnreachable].
+func (c *Client) TestHTTPSReachable(ctx context.Context, testURL string) *platerrors.PlatformError {
+	httpTransport := newProxyHTTPTransport(c)
+	defer httpTransport.CloseIdleConnections()
+	return checkHTTPSReachable(ctx, httpTransport, testURL)
+}
+
+// checkHTTPSReachable implements [Client.TestHTTPSReachable] with requests made by httpTransport.
+func checkHTTPSReachable(ctx context.Context, httpTransport *http.Transport, testURL string) *platerrors.PlatformError {
+	parsedURL, err := url.Parse(testURL)
+	if err == nil && parsedURL.Scheme != "https" {
+		err = errors.New("URL scheme must be https")
+	}
+	if err != nil {
+		return invalidTestURLError(testURL, err)
+	}
+	tester := &speedTester{httpTransport: httpTransport}
+	req, err := tester.newRequest(ctx, http.MethodGet, testURL, nil)
+	if err != nil {
+		return invalidTestURLError(testURL, err)
+	}
+
+	httpClient := tester.newHTTPClient(httpsReachabilityTimeout)
+	// Redirects are checked, not followed, since they go wherever a portal wants.
+	httpClient.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		var perr *platerrors.PlatformError
+		switch {
+		case errors.Is(err, context.Canceled):
+			perr = &platerrors.PlatformError{
+				Code:    platerrors.OperationCanceled,
+				Message: "HTTPS reachability test was canceled",
+			}
+		case isTLSError(err):
+			perr = &platerrors.PlatformError{
+				Code:    platerrors.TLSHandshakeFailed,
+				Message: "TLS handshake with the destination failed",
+				Cause:   platerrors.ToPlatformError(err),
+			}
+		default:
+			perr = speedTestError(err, platerrors.ProxyServerUnreachable, "HTTPS request failed")
+		}
+		perr.Details = platerrors.ErrorDetails{"url": testURL}
+		return perr
+	}
+	resp.Body.Close()
+
+	blockPageError := func(message string) *platerrors.PlatformError {
+		return &platerrors.PlatformError{
+			Code:    platerrors.BlockPageDetected,
+			Message: message,
+			Details: platerrors.ErrorDetails{"url": testURL, "status": resp.StatusCode},
+		}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return blockPageError("destination responded with an error status")
+	}
+	if location, err := resp.Location(); err == nil && !isSameSiteRedirect(parsedURL, location) {
+		perr := blockPageError("destination redirected to another site")
+		perr.Details["location"] = location.String()
+		return perr
+	}
+	return nil
+}
+
+// isTLSError tells whether err is a failure of a TLS handshake.
+func isTLSError(err error) bool {
+	var certErr *tls.CertificateVerificationError
+	var recordErr tls.RecordHeaderError
+	var alertErr tls.AlertError
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidCertErr x509.CertificateInvalidError
+	return errors.As(err, &certErr) || errors.As(err, &recordErr) || errors.As(err, &alertErr) ||
+		errors.As(err, &unknownAuthorityErr) || errors.As(err, &hostnameErr) || errors.As(err, &invalidCertErr)
+}
+
+// isSameSiteRedirect tells whether a redirect from origin to location stays on https and on the
+// same host, allowing for the "www." prefix.
+func isSameSiteRedirect(origin, location *url.URL) bool {
+	if location.Scheme != "https" {
+		return false
+	}
+	trimWWW := func(host string) string {
+		return strings.TrimPrefix(strings.ToLower(host), "www.")
+	}
+	return trimWWW(origin.Hostname()) == trimWWW(location.Hostname())
+}
@@ -0,0 +1,95 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/stretchr/testify/require"
+)
+
+func newHTTPSTestServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(http.ResponseWriter, *http.Request) {})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/ok", http.StatusFound)
+	})
+	mux.HandleFunc("/portal", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://portal.example.com/login", http.StatusFound)
+	})
+	mux.HandleFunc("/blocked", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Access denied", http.StatusForbidden)
+	})
+	server := httptest.NewTLSServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newTrustingHTTPTransport returns a transport through client that trusts the certificate of server.
+func newTrustingHTTPTransport(client *Client, server *httptest.Server) *http.Transport {
+	httpTransport := newProxyHTTPTransport(client)
+	httpTransport.TLSClientConfig = server.Client().Transport.(*http.Transport).TLSClientConfig
+	return httpTransport
+}
+
+func Test_TestHTTPSReachable(t *testing.T) {
+	server := newHTTPSTestServer(t)
+	httpTransport := newTrustingHTTPTransport(newDirectTestClient(), server)
+	defer httpTransport.CloseIdleConnections()
+
+	require.Nil(t, checkHTTPSReachable(context.Background(), httpTransport, server.URL+"/ok"))
+	require.Nil(t, checkHTTPSReachable(context.Background(), httpTransport, server.URL+"/moved"))
+
+	perr := checkHTTPSReachable(context.Background(), httpTransport, server.URL+"/portal")
+	require.NotNil(t, perr)
+	require.Equal(t, platerrors.BlockPageDetected, perr.Code)
+	require.Equal(t, "http://portal.example.com/login", perr.Details["location"])
+
+	perr = checkHTTPSReachable(context.Background(), httpTransport, server.URL+"/blocked")
+	require.NotNil(t, perr)
+	require.Equal(t, platerrors.BlockPageDetected, perr.Code)
+	require.Equal(t, http.StatusForbidden, perr.Details["status"])
+}
+
+func Test_TestHTTPSReachable_UntrustedCertificate(t *testing.T) {
+	server := newHTTPSTestServer(t)
+
+	perr := newDirectTestClient().TestHTTPSReachable(context.Background(), server.URL+"/ok")
+	require.NotNil(t, perr)
+	require.Equal(t, platerrors.TLSHandshakeFailed, perr.Code)
+	require.Equal(t, server.URL+"/ok", perr.Details["url"])
+}
+
+func Test_TestHTTPSReachable_Errors(t *testing.T) {
+	client := newUnreachableTestClient("127.0.0.1:4321")
+
+	perr := client.TestHTTPSReachable(context.Background(), "http://example.com")
+	require.NotNil(t, perr)
+	require.Equal(t, platerrors.InvalidConfig, perr.Code)
+
+	perr = client.TestHTTPSReachable(context.Background(), "https://example.com")
+	require.NotNil(t, perr)
+	require.Equal(t, platerrors.ProxyServerUnreachable, perr.Code)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	perr = newDirectTestClient().TestHTTPSReachable(ctx, "https://example.com")
+	require.NotNil(t, perr)
+	require.Equal(t, platerrors.OperationCanceled, perr.Code)
+}
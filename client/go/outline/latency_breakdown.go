@@ -0,0 +1,136 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+)
+
+// LatencyBreakdown is the result of [Client.TestLatencyBreakdown]. Durations are in
+// milliseconds, and phases that didn't happen are zero.
+//
+// We use a struct instead of a tuple to preserve a strongly typed error that gobind recognizes.
+type LatencyBreakdown struct {
+	// DNSMs is the time spent resolving names on this device. Destinations are resolved by the
+	// proxy, so this is usually the lookup of the proxy server, if it's a host name.
+	DNSMs int64
+	// ConnectMs is the time to connect to the destination through the proxy, without DNSMs.
+	ConnectMs int64
+	// TLSMs is the time of the TLS handshake with the destination, for https URLs.
+	TLSMs int64
+	// TTFBMs is the time from sending the request to receiving the first byte of the response.
+	TTFBMs int64
+	// TotalMs is the time from the start of the request to the first byte of the response.
+	TotalMs int64
+	Error   *platerrors.PlatformError
+}
+
+// TestLatencyBreakdown is like [Client.TestLatency], but splits the round-trip time into the
+// phases of the request, to tell a slow proxy from a slow server. It always uses a new
+// connection, so that the connection phases are measured.
+func (c *Client) TestLatencyBreakdown(ctx context.Context, testURL string) *LatencyBreakdown {
+	httpTransport := newProxyHTTPTransport(c)
+	defer httpTransport.CloseIdleConnections()
+	return measureLatencyBreakdown(ctx, httpTransport, testURL)
+}
+
+// requestTimeline records the times of the events of an HTTP request.
+type requestTimeline struct {
+	mu                      sync.Mutex
+	getConn, gotConn        time.Time
+	dnsStart, dnsDone       time.Time
+	tlsStart, tlsDone       time.Time
+	wroteRequest, firstByte time.Time
+}
+
+// record returns a function that sets the time pointed to by event to the current time.
+func (l *requestTimeline) record(event *time.Time) func() {
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if event.IsZero() {
+			*event = time.Now()
+		}
+	}
+}
+
+func (l *requestTimeline) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GetConn:              func(string) { l.record(&l.getConn)() },
+		DNSStart:             func(httptrace.DNSStartInfo) { l.record(&l.dnsStart)() },
+		DNSDone:              func(httptrace.DNSDoneInfo) { l.record(&l.dnsDone)() },
+		TLSHandshakeStart:    l.record(&l.tlsStart),
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { l.record(&l.tlsDone)() },
+		GotConn:              func(httptrace.GotConnInfo) { l.record(&l.gotConn)() },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { l.record(&l.wroteRequest)() },
+		GotFirstResponseByte: l.record(&l.firstByte),
+	}
+}
+
+// breakdown computes the phases of the request. It must be called after the response arrived.
+func (l *requestTimeline) breakdown() *LatencyBreakdown {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	between := func(start, end time.Time) int64 {
+		if start.IsZero() || end.IsZero() {
+			return 0
+		}
+		return end.Sub(start).Milliseconds()
+	}
+	result := &LatencyBreakdown{
+		DNSMs:   between(l.dnsStart, l.dnsDone),
+		TLSMs:   between(l.tlsStart, l.tlsDone),
+		TTFBMs:  between(l.wroteRequest, l.firstByte),
+		TotalMs: between(l.getConn, l.firstByte),
+	}
+	// The connection is ready when the TLS handshake starts, if there is one.
+	connected := l.gotConn
+	if !l.tlsStart.IsZero() {
+		connected = l.tlsStart
+	}
+	result.ConnectMs = max(between(l.getConn, connected)-result.DNSMs, 0)
+	return result
+}
+
+// measureLatencyBreakdown implements [Client.TestLatencyBreakdown] with requests made by
+// httpTransport.
+func measureLatencyBreakdown(ctx context.Context, httpTransport *http.Transport, testURL string) *LatencyBreakdown {
+	timeline := &requestTimeline{}
+	tester := &speedTester{httpTransport: httpTransport}
+	req, err := tester.newRequest(httptrace.WithClientTrace(ctx, timeline.clientTrace()), http.MethodHead, testURL, nil)
+	if err != nil {
+		return &LatencyBreakdown{Error: invalidTestURLError(testURL, err)}
+	}
+
+	resp, err := tester.newHTTPClient(10 * time.Second).Do(req)
+	if err != nil {
+		if ctx.Err() == context.Canceled {
+			return &LatencyBreakdown{Error: &platerrors.PlatformError{
+				Code:    platerrors.OperationCanceled,
+				Message: "latency test was canceled",
+			}}
+		}
+		return &LatencyBreakdown{Error: speedTestError(err, platerrors.ProxyServerUnreachable, "latency request failed")}
+	}
+	resp.Body.Close()
+	return timeline.breakdown()
+}
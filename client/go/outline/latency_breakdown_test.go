@@ -0,0 +1,90 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/stretchr/testify/require"
+)
+
+// newDelayedServer returns a server that waits for delay before responding.
+func newDelayedServer(t *testing.T, delay time.Duration, useTLS bool) *httptest.Server {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+	})
+	server := httptest.NewUnstartedServer(handler)
+	if useTLS {
+		server.StartTLS()
+	} else {
+		server.Start()
+	}
+	t.Cleanup(server.Close)
+	return server
+}
+
+func Test_TestLatencyBreakdown_HTTP(t *testing.T) {
+	server := newDelayedServer(t, 100*time.Millisecond, false)
+
+	result := newDirectTestClient().TestLatencyBreakdown(context.Background(), server.URL)
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.Zero(t, result.DNSMs)
+	require.Zero(t, result.TLSMs)
+	require.GreaterOrEqual(t, result.TTFBMs, int64(100))
+	require.GreaterOrEqual(t, result.TotalMs, result.ConnectMs+result.TTFBMs)
+}
+
+func Test_TestLatencyBreakdown_HTTPS(t *testing.T) {
+	server := newDelayedServer(t, 0, true)
+	httpTransport := newProxyHTTPTransport(newDirectTestClient())
+	httpTransport.TLSClientConfig = server.Client().Transport.(*http.Transport).TLSClientConfig
+	defer httpTransport.CloseIdleConnections()
+
+	// TLS handshakes on localhost may take less than a millisecond, so run until one doesn't.
+	var result *LatencyBreakdown
+	for i := 0; i < 20; i++ {
+		result = measureLatencyBreakdown(context.Background(), httpTransport, server.URL)
+		require.Nil(t, result.Error, "Got %v", result.Error)
+		httpTransport.CloseIdleConnections()
+		if result.TLSMs > 0 {
+			break
+		}
+	}
+	require.Positive(t, result.TLSMs)
+	require.GreaterOrEqual(t, result.TotalMs, result.TLSMs)
+}
+
+func Test_TestLatencyBreakdown_Errors(t *testing.T) {
+	client := newUnreachableTestClient("127.0.0.1:4321")
+
+	result := client.TestLatencyBreakdown(context.Background(), "://invalid")
+	require.NotNil(t, result.Error)
+	require.Equal(t, platerrors.InvalidConfig, result.Error.Code)
+
+	result = client.TestLatencyBreakdown(context.Background(), "http://example.com")
+	require.NotNil(t, result.Error)
+	require.Equal(t, platerrors.ProxyServerUnreachable, result.Error.Code)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result = newDirectTestClient().TestLatencyBreakdown(ctx, "http://example.com")
+	require.NotNil(t, result.Error)
+	require.Equal(t, platerrors.OperationCanceled, result.Error.Code)
+}
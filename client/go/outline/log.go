@@ -0,0 +1,119 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+)
+
+// Levels of the logs passed to [Logger].
+const (
+	LogLevelDebug = "debug"
+	LogLevelInfo  = "info"
+	LogLevelWarn  = "warn"
+	LogLevelError = "error"
+)
+
+// Logger receives the logs of the package, such as dial failures, connectivity check results
+// and bandwidth test phases. Messages are followed by key=value pairs. They never contain
+// secrets, such as passwords and keys, nor addresses: errors are logged by code only.
+//
+// We use an interface instead of a func type so that it can be implemented by the platform code
+// through gobind. Log may be called from several goroutines at the same time.
+type Logger interface {
+	Log(level string, message string)
+}
+
+var packageLogger atomic.Pointer[slog.Logger]
+
+// SetLogger sets the logger of the package. A nil logger, the default, discards the logs.
+func SetLogger(logger Logger) {
+	if logger == nil {
+		packageLogger.Store(nil)
+		return
+	}
+	packageLogger.Store(slog.New(&platformLogHandler{logger: logger}))
+}
+
+// discardLogger is the logger used when none was set.
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError + 1}))
+
+// logger returns the logger of the package.
+func logger() *slog.Logger {
+	if logger := packageLogger.Load(); logger != nil {
+		return logger
+	}
+	return discardLogger
+}
+
+// errorCode returns the code of perr for the logs, or an empty string if perr is nil. Only the
+// code is logged, since messages and details may hold addresses.
+func errorCode(perr *platerrors.PlatformError) string {
+	if perr == nil {
+		return ""
+	}
+	return perr.Code
+}
+
+// platformLogHandler is a [slog.Handler] that formats the records for a [Logger].
+type platformLogHandler struct {
+	logger Logger
+	attrs  []slog.Attr
+}
+
+func (h *platformLogHandler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+func (h *platformLogHandler) Handle(_ context.Context, record slog.Record) error {
+	var message strings.Builder
+	message.WriteString(record.Message)
+	appendAttr := func(attr slog.Attr) bool {
+		fmt.Fprintf(&message, " %s=%v", attr.Key, attr.Value)
+		return true
+	}
+	for _, attr := range h.attrs {
+		appendAttr(attr)
+	}
+	record.Attrs(appendAttr)
+
+	level := LogLevelError
+	switch {
+	case record.Level < slog.LevelInfo:
+		level = LogLevelDebug
+	case record.Level < slog.LevelWarn:
+		level = LogLevelInfo
+	case record.Level < slog.LevelError:
+		level = LogLevelWarn
+	}
+	h.logger.Log(level, message.String())
+	return nil
+}
+
+func (h *platformLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &platformLogHandler{logger: h.logger, attrs: append(h.attrs[:len(h.attrs):len(h.attrs)], attrs...)}
+}
+
+// WithGroup ignores the group, since the package doesn't log groups.
+func (h *platformLogHandler) WithGroup(string) slog.Handler {
+	return h
+}
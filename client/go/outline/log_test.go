@@ -0,0 +1,95 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// recordingLogger keeps the logs it receives as "level: message" lines.
+type recordingLogger struct {
+	mu   sync.Mutex
+	logs []string
+}
+
+func (l *recordingLogger) Log(level string, message string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.logs = append(l.logs, level+": "+message)
+}
+
+func (l *recordingLogger) all() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return strings.Join(l.logs, "\n")
+}
+
+// setTestLogger sets a recordingLogger as the logger of the package until the end of the test.
+func setTestLogger(t *testing.T) *recordingLogger {
+	logger := &recordingLogger{}
+	SetLogger(logger)
+	t.Cleanup(func() { SetLogger(nil) })
+	return logger
+}
+
+func Test_SetLogger(t *testing.T) {
+	logger := setTestLogger(t)
+
+	result := NewClient("transport: {$type: unknown}")
+	require.NotNil(t, result.Error)
+	result = NewClient("ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpTRUNSRVQ@127.0.0.1:1/")
+	require.Nil(t, result.Error)
+	_, err := result.Client.DialStream(context.Background(), "example.com:443")
+	require.Error(t, err)
+
+	logs := logger.all()
+	require.Contains(t, logs, "warn: failed to create client code=ERR_INVALID_CONFIG")
+	require.Contains(t, logs, "info: client created streamConnType=tunneled packetConnType=tunneled")
+	require.Contains(t, logs, "debug: TCP dial failed code=ERR_PROXY_SERVER_UNREACHABLE")
+	// Neither secrets nor addresses are logged.
+	require.NotContains(t, logs, "SECRET")
+	require.NotContains(t, logs, "127.0.0.1")
+	require.NotContains(t, logs, "example.com")
+}
+
+func Test_SetLogger_Nil(t *testing.T) {
+	logger := setTestLogger(t)
+	SetLogger(nil)
+
+	NewClient("transport: {$type: unknown}")
+	require.Empty(t, logger.all())
+}
+
+func Test_SetLogger_BandwidthTestPhases(t *testing.T) {
+	logger := setTestLogger(t)
+	client := newUnreachableTestClient("127.0.0.1:4321")
+
+	client.PerformBandwidthTestWithConfig(context.Background(), &BandwidthTestConfig{
+		DownloadURL:     "http://127.0.0.1:1/down",
+		UploadURL:       "http://127.0.0.1:1/up",
+		LatencyURL:      "http://127.0.0.1:1/ping",
+		DurationSeconds: 1,
+	})
+	logs := logger.all()
+	require.Contains(t, logs, "debug: bandwidth test phase started phase=latency")
+	require.Contains(t, logs, "debug: bandwidth test phase started phase=download")
+	require.Contains(t, logs, "debug: bandwidth test phase started phase=upload")
+	require.Contains(t, logs, "info: bandwidth test done latencyMs=-1 downloadSpeedKBps=-1 uploadSpeedKBps=-1 error=ERR_PROXY_SERVER_UNREACHABLE")
+}
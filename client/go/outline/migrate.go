@@ -0,0 +1,166 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/goccy/go-yaml"
+)
+
+// MigrateConfigResult represents the result of [MigrateConfig].
+//
+// We use a struct instead of a tuple to preserve a strongly typed error that gobind recognizes.
+type MigrateConfigResult struct {
+	// Config is the migrated config, in the YAML format that [NewClient] takes.
+	Config string
+	Error  *platerrors.PlatformError
+}
+
+// legacyShadowsocksKeys lists the fields of the legacy flat Shadowsocks configs. Older clients
+// stored either "host" and "port", or "server" and "server_port".
+var legacyShadowsocksKeys = map[string]bool{
+	"host": true, "port": true, "server": true, "server_port": true,
+	"method": true, "password": true, "prefix": true,
+}
+
+// migratedShadowsocksConfig is the Shadowsocks transport a legacy config is migrated to.
+type migratedShadowsocksConfig struct {
+	Type     string `yaml:"$type"`
+	Endpoint string `yaml:"endpoint"`
+	Cipher   string `yaml:"cipher"`
+	Secret   string `yaml:"secret"`
+	Prefix   string `yaml:"prefix,omitempty"`
+}
+
+// MigrateConfig converts a config stored by older clients into the config format [NewClient]
+// takes, so that users keep their servers after an upgrade.
+//
+// The supported legacy format is the flat Shadowsocks JSON object, with the "host" and "port",
+// or "server" and "server_port", "method" and "password" fields, and an optional "prefix".
+// Other inputs fail with [platerrors.InvalidConfig].
+func MigrateConfig(oldConfig string) *MigrateConfigResult {
+	var legacy map[string]any
+	decoder := json.NewDecoder(strings.NewReader(strings.TrimSpace(oldConfig)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&legacy); err != nil || legacy == nil {
+		return &MigrateConfigResult{Error: &platerrors.PlatformError{
+			Code:    platerrors.InvalidConfig,
+			Message: "legacy config is not a JSON object",
+		}}
+	}
+
+	ssConfig, err := migrateLegacyShadowsocks(legacy)
+	if err != nil {
+		return &MigrateConfigResult{Error: &platerrors.PlatformError{
+			Code:    platerrors.InvalidConfig,
+			Message: "unsupported legacy config",
+			Cause:   platerrors.ToPlatformError(err),
+		}}
+	}
+	configBytes, err := yaml.Marshal(map[string]any{"transport": ssConfig})
+	if err != nil {
+		return &MigrateConfigResult{Error: &platerrors.PlatformError{
+			Code:    platerrors.InternalError,
+			Message: "failed to serialize the migrated config",
+			Cause:   platerrors.ToPlatformError(err),
+		}}
+	}
+
+	// Make sure the migrated config works before the caller replaces the old one with it.
+	if result := NewClient(string(configBytes)); result.Error != nil {
+		return &MigrateConfigResult{Error: result.Error}
+	}
+	return &MigrateConfigResult{Config: string(configBytes)}
+}
+
+// migrateLegacyShadowsocks returns the Shadowsocks transport for a legacy flat config.
+func migrateLegacyShadowsocks(legacy map[string]any) (*migratedShadowsocksConfig, error) {
+	var unknownKeys []string
+	for key := range legacy {
+		if !legacyShadowsocksKeys[key] {
+			unknownKeys = append(unknownKeys, key)
+		}
+	}
+	if len(unknownKeys) > 0 {
+		slices.Sort(unknownKeys)
+		return nil, fmt.Errorf("unrecognized fields %q", unknownKeys)
+	}
+
+	hostKey, portKey := "host", "port"
+	if _, ok := legacy["server"]; ok {
+		hostKey, portKey = "server", "server_port"
+	}
+	host, err := legacyStringField(legacy, hostKey)
+	if err != nil {
+		return nil, err
+	}
+	port, err := legacyPortField(legacy, portKey)
+	if err != nil {
+		return nil, err
+	}
+	method, err := legacyStringField(legacy, "method")
+	if err != nil {
+		return nil, err
+	}
+	password, err := legacyStringField(legacy, "password")
+	if err != nil {
+		return nil, err
+	}
+	var prefix string
+	if _, ok := legacy["prefix"]; ok {
+		if prefix, err = legacyStringField(legacy, "prefix"); err != nil {
+			return nil, err
+		}
+	}
+	return &migratedShadowsocksConfig{
+		Type:     "shadowsocks",
+		Endpoint: net.JoinHostPort(host, strconv.Itoa(port)),
+		Cipher:   method,
+		Secret:   password,
+		Prefix:   prefix,
+	}, nil
+}
+
+func legacyStringField(legacy map[string]any, key string) (string, error) {
+	value, ok := legacy[key].(string)
+	if !ok || value == "" {
+		return "", fmt.Errorf("field %q must be a non-empty string", key)
+	}
+	return value, nil
+}
+
+// legacyPortField returns the port in the field key, which older clients stored either as a
+// number or as a string.
+func legacyPortField(legacy map[string]any, key string) (int, error) {
+	var text string
+	switch value := legacy[key].(type) {
+	case json.Number:
+		text = value.String()
+	case string:
+		text = value
+	}
+	port, err := strconv.Atoi(text)
+	if err != nil || port <= 0 || port > 65535 {
+		return 0, fmt.Errorf("field %q must be a port number", key)
+	}
+	return port, nil
+}
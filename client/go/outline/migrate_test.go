@@ -0,0 +1,84 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"testing"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_MigrateConfig(t *testing.T) {
+	for _, legacy := range []string{
+		`{"host": "example.com", "port": 4321, "method": "chacha20-ietf-poly1305", "password": "SECRET"}`,
+		`{"host": "example.com", "port": "4321", "method": "chacha20-ietf-poly1305", "password": "SECRET"}`,
+		`{"server": "example.com", "server_port": 4321, "method": "chacha20-ietf-poly1305", "password": "SECRET"}`,
+	} {
+		result := MigrateConfig(legacy)
+		require.Nil(t, result.Error, "Got %v for %v", result.Error, legacy)
+		require.Equal(t, `transport:
+  $type: shadowsocks
+  endpoint: example.com:4321
+  cipher: chacha20-ietf-poly1305
+  secret: SECRET
+`, result.Config)
+
+		client := NewClient(result.Config)
+		require.Nil(t, client.Error)
+		require.Equal(t, "example.com:4321", client.Client.sd.FirstHop)
+	}
+}
+
+func Test_MigrateConfig_Prefix(t *testing.T) {
+	result := MigrateConfig(`{"host": "::1", "port": 443, "method": "chacha20-ietf-poly1305", "password": "SECRET", "prefix": "HTTP/1.1 "}`)
+	require.Nil(t, result.Error, "Got %v", result.Error)
+
+	client := NewClient(result.Config)
+	require.Nil(t, client.Error)
+	require.Equal(t, "[::1]:443", client.Client.sd.FirstHop)
+	require.Contains(t, result.Config, `prefix: "HTTP/1.1 "`)
+}
+
+func Test_MigrateConfig_Errors(t *testing.T) {
+	for _, legacy := range []string{
+		``,
+		`ss://example.com`,
+		`[]`,
+		`null`,
+		// Missing fields.
+		`{"host": "example.com", "method": "chacha20-ietf-poly1305", "password": "SECRET"}`,
+		`{"host": "example.com", "port": 4321, "password": "SECRET"}`,
+		// Invalid fields.
+		`{"host": "example.com", "port": 70000, "method": "chacha20-ietf-poly1305", "password": "SECRET"}`,
+		`{"host": 1, "port": 4321, "method": "chacha20-ietf-poly1305", "password": "SECRET"}`,
+		`{"host": "example.com", "port": 4321, "method": "unknown-cipher", "password": "SECRET"}`,
+		// Unknown schema.
+		`{"transport": {"$type": "shadowsocks"}}`,
+		`{"host": "example.com", "port": 4321, "method": "chacha20-ietf-poly1305", "password": "SECRET", "plugin": "obfs-local"}`,
+	} {
+		result := MigrateConfig(legacy)
+		require.Empty(t, result.Config, legacy)
+		require.NotNil(t, result.Error, legacy)
+		require.Equal(t, platerrors.InvalidConfig, result.Error.Code, legacy)
+	}
+}
+
+func Test_MigrateConfig_UnknownFieldsError(t *testing.T) {
+	result := MigrateConfig(`{"host": "example.com", "port": 4321, "method": "chacha20-ietf-poly1305", "password": "SECRET", "plugin": "obfs-local"}`)
+	require.NotNil(t, result.Error)
+	require.NotNil(t, result.Error.Cause)
+	require.Contains(t, result.Error.Cause.Message, `"plugin"`)
+}
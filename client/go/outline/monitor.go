@@ -0,0 +1,139 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/connectivity"
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+)
+
+const (
+	// minMonitorInterval is the shortest interval between two probes of a connectivity monitor.
+	minMonitorInterval = time.Second
+	// maxMonitorProbeTimeout bounds each probe of a connectivity monitor.
+	maxMonitorProbeTimeout = 10 * time.Second
+)
+
+// ConnectivityMonitorStatus is a report of a [ConnectivityMonitor].
+type ConnectivityMonitorStatus struct {
+	// Reachable tells whether the last probe got through the proxy.
+	Reachable bool
+	// LatencyMs is the duration of the last probe, or -1 if it failed.
+	LatencyMs int64
+	// ConsecutiveFailures is the number of probes in a row that failed, including the last one.
+	ConsecutiveFailures int
+	// State is the state of the tunnel after the probe, one of the ConnectionState* constants.
+	// See [Client.SetConnectionStateListener] to be notified when it changes.
+	State string
+	// Error is why the last probe failed, or nil if it succeeded.
+	Error *platerrors.PlatformError
+}
+
+// ConnectivityMonitorListener receives the reports of [Client.StartConnectivityMonitor].
+//
+// We use an interface instead of a func type so that it can be implemented by the platform code
+// through gobind. Calls are never concurrent.
+type ConnectivityMonitorListener interface {
+	OnConnectivityStatus(status *ConnectivityMonitorStatus)
+}
+
+// ConnectivityMonitor periodically checks that a [Client] can still relay traffic.
+type ConnectivityMonitor struct {
+	cancel context.CancelFunc
+}
+
+// Stop stops the monitor. A report that was being delivered when Stop is called may still reach
+// the listener, but there are no reports after that.
+func (m *ConnectivityMonitor) Stop() {
+	m.cancel()
+}
+
+// StartConnectivityMonitor probes the proxy right away and then every interval, to detect
+// tunnels that stopped working silently, and reports the outcome of each probe to listener.
+// The interval is at least one second. It stops when ctx is done or [ConnectivityMonitor.Stop]
+// is called.
+//
+// Each probe is an HTTP HEAD request through the proxy. The probes have a connection pool of
+// their own, so they don't delay user traffic, and they reuse the connection of the previous
+// probe when it's still open, so they are cheap.
+//
+// The reports also update the state of the tunnel returned by [Client.ConnectionState].
+func (c *Client) StartConnectivityMonitor(ctx context.Context, interval time.Duration, listener ConnectivityMonitorListener) *ConnectivityMonitor {
+	interval = max(interval, minMonitorInterval)
+	return c.startConnectivityMonitor(ctx, interval, listener, "http://"+connectivity.DefaultTCPProbeAddress)
+}
+
+// startConnectivityMonitor implements [Client.StartConnectivityMonitor] with probes to probeURL.
+func (c *Client) startConnectivityMonitor(ctx context.Context, interval time.Duration, listener ConnectivityMonitorListener, probeURL string) *ConnectivityMonitor {
+	ctx, cancel := context.WithCancel(ctx)
+	monitor := &ConnectivityMonitor{cancel: cancel}
+
+	httpTransport := newProxyHTTPTransport(c)
+	httpTransport.MaxConnsPerHost = 1
+	httpClient := &http.Client{Transport: httpTransport, Timeout: min(interval, maxMonitorProbeTimeout)}
+	go func() {
+		defer httpTransport.CloseIdleConnections()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		failures := 0
+		for {
+			status := probeConnectivity(ctx, httpClient, probeURL)
+			if ctx.Err() != nil {
+				return
+			}
+			if status.Reachable {
+				failures = 0
+			} else {
+				failures++
+			}
+			status.ConsecutiveFailures = failures
+			status.State = connectionStateOf(status)
+			c.connectionState.update(status.State)
+			listener.OnConnectivityStatus(status)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return monitor
+}
+
+// probeConnectivity sends a HEAD request to probeURL with httpClient.
+func probeConnectivity(ctx context.Context, httpClient *http.Client, probeURL string) *ConnectivityMonitorStatus {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, probeURL, nil)
+	if err != nil {
+		return &ConnectivityMonitorStatus{LatencyMs: -1, Error: invalidTestURLError(probeURL, err)}
+	}
+	start := time.Now()
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return &ConnectivityMonitorStatus{
+			LatencyMs: -1,
+			Error:     speedTestError(err, platerrors.ProxyServerUnreachable, "connectivity probe failed"),
+		}
+	}
+	// Drain the body so that the connection can be reused.
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return &ConnectivityMonitorStatus{Reachable: true, LatencyMs: time.Since(start).Milliseconds()}
+}
@@ -0,0 +1,101 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/stretchr/testify/require"
+)
+
+// statusRecorder is a [ConnectivityMonitorListener] that sends the reports to a channel.
+type statusRecorder chan *ConnectivityMonitorStatus
+
+func (r statusRecorder) OnConnectivityStatus(status *ConnectivityMonitorStatus) {
+	r <- status
+}
+
+func (r statusRecorder) next(t *testing.T) *ConnectivityMonitorStatus {
+	select {
+	case status := <-r:
+		return status
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "no connectivity status reported")
+		return nil
+	}
+}
+
+func Test_ConnectivityMonitor(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer server.Close()
+	client := newDirectTestClient()
+	recorder := make(statusRecorder, 10)
+
+	monitor := client.startConnectivityMonitor(context.Background(), 20*time.Millisecond, recorder, server.URL)
+	defer monitor.Stop()
+	for i := 0; i < 3; i++ {
+		status := recorder.next(t)
+		require.True(t, status.Reachable)
+		require.Nil(t, status.Error)
+		require.Equal(t, 0, status.ConsecutiveFailures)
+		require.GreaterOrEqual(t, status.LatencyMs, int64(0))
+	}
+	// The probes reuse their connection.
+	require.Equal(t, int64(1), client.Stats().ConnectionsOpened)
+}
+
+func Test_ConnectivityMonitor_Failures(t *testing.T) {
+	client := newUnreachableTestClient("127.0.0.1:4321")
+	recorder := make(statusRecorder, 10)
+
+	monitor := client.startConnectivityMonitor(context.Background(), 20*time.Millisecond, recorder, "http://example.com")
+	defer monitor.Stop()
+	for i := 1; i <= 2; i++ {
+		status := recorder.next(t)
+		require.False(t, status.Reachable)
+		require.Equal(t, int64(-1), status.LatencyMs)
+		require.Equal(t, i, status.ConsecutiveFailures)
+		require.NotNil(t, status.Error)
+		require.Equal(t, platerrors.ProxyServerUnreachable, status.Error.Code)
+	}
+}
+
+func Test_ConnectivityMonitor_Stops(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer server.Close()
+
+	for _, stopWithContext := range []bool{false, true} {
+		recorder := make(statusRecorder, 100)
+		ctx, cancel := context.WithCancel(context.Background())
+		monitor := newDirectTestClient().startConnectivityMonitor(ctx, 10*time.Millisecond, recorder, server.URL)
+		recorder.next(t)
+		if stopWithContext {
+			cancel()
+		} else {
+			monitor.Stop()
+		}
+		// Let a report in progress go through.
+		time.Sleep(50 * time.Millisecond)
+		reported := len(recorder)
+		time.Sleep(100 * time.Millisecond)
+		require.Equal(t, reported, len(recorder))
+		cancel()
+	}
+}
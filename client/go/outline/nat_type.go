@@ -0,0 +1,375 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/netip"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+)
+
+// NAT types reported by [NATTypeResult].
+const (
+	// NATTypeOpen means packets leave with the local address, without translation.
+	NATTypeOpen = "open"
+	// NATTypeFullCone means any host can send packets to the mapped address.
+	NATTypeFullCone = "full-cone"
+	// NATTypeRestricted means only the IP addresses that were sent packets can send packets
+	// to the mapped address, from any port.
+	NATTypeRestricted = "restricted"
+	// NATTypePortRestricted is like NATTypeRestricted, but only the ports that were sent packets
+	// can send packets back.
+	NATTypePortRestricted = "port-restricted"
+	// NATTypeSymmetric means each destination gets a different mapped address, which defeats
+	// most peer-to-peer connections.
+	NATTypeSymmetric = "symmetric"
+	// NATTypeBlocked means no UDP response came back.
+	NATTypeBlocked = "blocked"
+	// NATTypeUnknown means the mapped address is known, but the STUN server can't tell the NAT
+	// type, because it doesn't answer from an alternate address.
+	NATTypeUnknown = "unknown"
+)
+
+const (
+	// defaultSTUNPort is the port of STUN servers given without one.
+	defaultSTUNPort = "3478"
+	// natTypeTimeout bounds [Client.DetectNATType].
+	natTypeTimeout = 20 * time.Second
+	// stunRetransmitTimeout is the time to wait for the first STUN response before sending the
+	// request again, which doubles with each attempt. Each request is sent up to stunAttempts
+	// times.
+	stunRetransmitTimeout = 500 * time.Millisecond
+	stunAttempts          = 3
+)
+
+// NATTypeResult is the result of [Client.DetectNATType].
+//
+// We use a struct instead of a tuple to preserve a strongly typed error that gobind recognizes.
+type NATTypeResult struct {
+	// NATType is one of the NATType* constants, or empty if the test failed before the first
+	// STUN response.
+	NATType string
+	// MappedAddress is the public address of the UDP socket as seen by the STUN server, in
+	// IP:port form, or empty if unknown.
+	MappedAddress string
+	Error         *platerrors.PlatformError
+}
+
+// DetectNATType classifies the NAT that UDP traffic goes through in the tunnel, which tells
+// whether WebRTC and other peer-to-peer apps can connect directly. It runs the tests of RFC 3489
+// with the STUN server at stunServer, which may include a port (default 3478), through
+// [Client.ListenPacket].
+//
+// Telling the NAT types apart requires a STUN server that answers from an alternate address, as
+// in RFC 5780. Other servers only report the mapped address, with [NATTypeUnknown]. If the server
+// doesn't answer, the type is [NATTypeBlocked] and the error has code
+// [platerrors.STUNServerUnreachable].
+func (c *Client) DetectNATType(ctx context.Context, stunServer string) *NATTypeResult {
+	ctx, cancel := context.WithTimeout(ctx, natTypeTimeout)
+	defer cancel()
+	server, err := newUDPProbeAddr(stunServer, defaultSTUNPort)
+	if err != nil {
+		return &NATTypeResult{Error: platerrors.ToPlatformError(err)}
+	}
+	conn, err := c.ListenPacket(ctx)
+	if err != nil {
+		return &NATTypeResult{Error: udpListenError(err)}
+	}
+	defer conn.Close()
+
+	localAddr, _ := netip.ParseAddrPort(conn.LocalAddr().String())
+	stun := &stunClient{conn: conn, retransmitTimeout: stunRetransmitTimeout}
+	result := classifyNAT(ctx, stun, server, localAddr)
+	logger().Info("NAT type detected", "type", result.NATType, "error", errorCode(result.Error))
+	return result
+}
+
+// stunTransactor sends STUN binding requests.
+type stunTransactor interface {
+	// bindingRequest sends a binding request to dest with the CHANGE-REQUEST flags of change,
+	// and returns the response, or nil if none came back in time.
+	bindingRequest(ctx context.Context, dest net.Addr, change byte) (*stunResponse, error)
+}
+
+// classifyNAT runs the tests of RFC 3489, section 10.1, with the STUN server at server.
+// localAddr is the local address of the socket of the tests, which the server sees if there is
+// no NAT.
+func classifyNAT(ctx context.Context, stun stunTransactor, server net.Addr, localAddr netip.AddrPort) *NATTypeResult {
+	result := &NATTypeResult{}
+	request := func(dest net.Addr, change byte) (*stunResponse, bool) {
+		response, err := stun.bindingRequest(ctx, dest, change)
+		if err != nil {
+			if ctx.Err() != nil {
+				result.Error = &platerrors.PlatformError{
+					Code:    platerrors.OperationCanceled,
+					Message: "NAT type detection was canceled",
+				}
+			} else {
+				result.Error = &platerrors.PlatformError{
+					Code:    platerrors.ProxyServerUDPUnsupported,
+					Message: "failed to send STUN request",
+					Cause:   platerrors.ToPlatformError(err),
+				}
+			}
+			return nil, false
+		}
+		return response, true
+	}
+
+	// Test I: the mapped address.
+	response, ok := request(server, 0)
+	if !ok {
+		return result
+	}
+	if response == nil {
+		result.NATType = NATTypeBlocked
+		result.Error = &platerrors.PlatformError{
+			Code:    platerrors.STUNServerUnreachable,
+			Message: "STUN server didn't respond",
+			Details: platerrors.ErrorDetails{"server": server.String()},
+		}
+		return result
+	}
+	mapped := response.mapped
+	result.MappedAddress = mapped.String()
+	if isLocalAddress(mapped, localAddr) {
+		result.NATType = NATTypeOpen
+		return result
+	}
+	if !response.other.IsValid() {
+		result.NATType = NATTypeUnknown
+		return result
+	}
+	other := response.other
+
+	// Test II: whether other addresses can answer.
+	if response, ok = request(server, stunChangeIP|stunChangePort); !ok {
+		return result
+	}
+	if response != nil {
+		result.NATType = NATTypeFullCone
+		return result
+	}
+
+	// Test I with the alternate address: whether the mapping depends on the destination.
+	if response, ok = request(net.UDPAddrFromAddrPort(other), 0); !ok {
+		return result
+	}
+	if response == nil {
+		result.NATType = NATTypeUnknown
+		return result
+	}
+	if response.mapped != mapped {
+		result.NATType = NATTypeSymmetric
+		return result
+	}
+
+	// Test III: whether other ports of the same IP address can answer.
+	if response, ok = request(server, stunChangePort); !ok {
+		return result
+	}
+	if response != nil {
+		result.NATType = NATTypeRestricted
+	} else {
+		result.NATType = NATTypePortRestricted
+	}
+	return result
+}
+
+// isLocalAddress tells whether mapped is localAddr, the address of a local socket, whose IP
+// address may be unspecified.
+func isLocalAddress(mapped, localAddr netip.AddrPort) bool {
+	if mapped.Port() != localAddr.Port() {
+		return false
+	}
+	if !localAddr.Addr().IsUnspecified() {
+		return mapped.Addr().Unmap() == localAddr.Addr().Unmap()
+	}
+	interfaceAddrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return false
+	}
+	for _, interfaceAddr := range interfaceAddrs {
+		if prefix, err := netip.ParsePrefix(interfaceAddr.String()); err == nil && prefix.Addr().Unmap() == mapped.Addr().Unmap() {
+			return true
+		}
+	}
+	return false
+}
+
+// STUN protocol values, from RFC 5389 and the RFCs it obsoletes.
+const (
+	stunHeaderLength      = 20
+	stunMagicCookie       = 0x2112a442
+	stunBindingRequest    = 0x0001
+	stunBindingSuccess    = 0x0101
+	stunAttrMappedAddress = 0x0001
+	stunAttrChangeRequest = 0x0003
+	// stunAttrChangedAddress is the alternate address of RFC 3489 servers.
+	stunAttrChangedAddress   = 0x0005
+	stunAttrXORMappedAddress = 0x0020
+	// stunAttrOtherAddress is the alternate address of RFC 5780 servers.
+	stunAttrOtherAddress = 0x802c
+
+	stunChangeIP   = 0x04
+	stunChangePort = 0x02
+)
+
+// stunResponse is a STUN binding response.
+type stunResponse struct {
+	// mapped is the address the server saw the request come from.
+	mapped netip.AddrPort
+	// other is the alternate address of the server, or the zero value if it has none.
+	other netip.AddrPort
+}
+
+// stunClient sends STUN binding requests over conn.
+type stunClient struct {
+	conn              net.PacketConn
+	retransmitTimeout time.Duration
+}
+
+func (s *stunClient) bindingRequest(ctx context.Context, dest net.Addr, change byte) (*stunResponse, error) {
+	var transactionID [12]byte
+	rand.Read(transactionID[:])
+	request := newSTUNBindingRequest(transactionID, change)
+	buf := make([]byte, 1500)
+	for attempt := 0; attempt < stunAttempts; attempt++ {
+		if _, err := s.conn.WriteTo(request, dest); err != nil {
+			return nil, err
+		}
+		deadline := time.Now().Add(s.retransmitTimeout << attempt)
+		if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+			deadline = ctxDeadline
+		}
+		s.conn.SetReadDeadline(deadline)
+		for {
+			n, _, err := s.conn.ReadFrom(buf)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				var netErr net.Error
+				if errors.As(err, &netErr) && netErr.Timeout() {
+					break
+				}
+				return nil, err
+			}
+			// Responses to earlier requests, which came late, are ignored.
+			if response, ok := parseSTUNBindingResponse(buf[:n], transactionID); ok {
+				return response, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// newSTUNBindingRequest returns a binding request with the given transaction ID, and a
+// CHANGE-REQUEST attribute with the flags of change if they are not zero.
+func newSTUNBindingRequest(transactionID [12]byte, change byte) []byte {
+	request := make([]byte, stunHeaderLength, stunHeaderLength+8)
+	binary.BigEndian.PutUint16(request[0:], stunBindingRequest)
+	binary.BigEndian.PutUint32(request[4:], stunMagicCookie)
+	copy(request[8:], transactionID[:])
+	if change != 0 {
+		request = binary.BigEndian.AppendUint16(request, stunAttrChangeRequest)
+		request = binary.BigEndian.AppendUint16(request, 4)
+		request = append(request, 0, 0, 0, change)
+	}
+	binary.BigEndian.PutUint16(request[2:], uint16(len(request)-stunHeaderLength))
+	return request
+}
+
+// parseSTUNBindingResponse parses a successful binding response to the request with the given
+// transaction ID, and reports whether message is one.
+func parseSTUNBindingResponse(message []byte, transactionID [12]byte) (*stunResponse, bool) {
+	if len(message) < stunHeaderLength ||
+		binary.BigEndian.Uint16(message[0:]) != stunBindingSuccess ||
+		binary.BigEndian.Uint32(message[4:]) != stunMagicCookie ||
+		[12]byte(message[8:20]) != transactionID {
+		return nil, false
+	}
+	length := int(binary.BigEndian.Uint16(message[2:]))
+	if stunHeaderLength+length > len(message) {
+		return nil, false
+	}
+	response := &stunResponse{}
+	var mapped, xorMapped netip.AddrPort
+	attrs := message[stunHeaderLength : stunHeaderLength+length]
+	for len(attrs) >= 4 {
+		attrType := binary.BigEndian.Uint16(attrs[0:])
+		attrLength := int(binary.BigEndian.Uint16(attrs[2:]))
+		if 4+attrLength > len(attrs) {
+			break
+		}
+		value := attrs[4 : 4+attrLength]
+		switch attrType {
+		case stunAttrMappedAddress:
+			mapped = parseSTUNAddress(value, nil)
+		case stunAttrXORMappedAddress:
+			xorMapped = parseSTUNAddress(value, message[4:20])
+		case stunAttrOtherAddress, stunAttrChangedAddress:
+			response.other = parseSTUNAddress(value, nil)
+		}
+		// Attributes are padded to a multiple of 4 bytes.
+		attrs = attrs[min(len(attrs), 4+(attrLength+3)&^3):]
+	}
+	response.mapped = xorMapped
+	if !response.mapped.IsValid() {
+		response.mapped = mapped
+	}
+	if !response.mapped.IsValid() {
+		return nil, false
+	}
+	return response, true
+}
+
+// parseSTUNAddress parses the value of an address attribute, or returns the zero value if it's
+// not valid. xorKey is the magic cookie and the transaction ID for XOR-MAPPED-ADDRESS, and nil
+// for the other attributes.
+func parseSTUNAddress(value []byte, xorKey []byte) netip.AddrPort {
+	if len(value) < 4 {
+		return netip.AddrPort{}
+	}
+	var ipLength int
+	switch value[1] {
+	case 1:
+		ipLength = net.IPv4len
+	case 2:
+		ipLength = net.IPv6len
+	default:
+		return netip.AddrPort{}
+	}
+	if len(value) < 4+ipLength {
+		return netip.AddrPort{}
+	}
+	port := binary.BigEndian.Uint16(value[2:])
+	ip := make([]byte, ipLength)
+	copy(ip, value[4:4+ipLength])
+	if xorKey != nil {
+		port ^= uint16(stunMagicCookie >> 16)
+		for i := range ip {
+			ip[i] ^= xorKey[i]
+		}
+	}
+	addr, _ := netip.AddrFromSlice(ip)
+	return netip.AddrPortFrom(addr, port)
+}
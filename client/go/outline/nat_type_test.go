@@ -0,0 +1,212 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/stretchr/testify/require"
+)
+
+// newSTUNTestResponse returns a binding response to request with the given mapped address, and
+// the alternate address other if it's valid.
+func newSTUNTestResponse(request []byte, mapped, other netip.AddrPort) []byte {
+	response := make([]byte, stunHeaderLength)
+	binary.BigEndian.PutUint16(response[0:], stunBindingSuccess)
+	copy(response[4:], request[4:20])
+	appendAddress := func(attrType uint16, addr netip.AddrPort, xorKey []byte) {
+		ip := addr.Addr().AsSlice()
+		port := addr.Port()
+		family := byte(1)
+		if addr.Addr().Is6() {
+			family = 2
+		}
+		if xorKey != nil {
+			port ^= uint16(stunMagicCookie >> 16)
+			for i := range ip {
+				ip[i] ^= xorKey[i]
+			}
+		}
+		response = binary.BigEndian.AppendUint16(response, attrType)
+		response = binary.BigEndian.AppendUint16(response, uint16(4+len(ip)))
+		response = append(response, 0, family)
+		response = binary.BigEndian.AppendUint16(response, port)
+		response = append(response, ip...)
+	}
+	appendAddress(stunAttrXORMappedAddress, mapped, request[4:20])
+	if other.IsValid() {
+		appendAddress(stunAttrOtherAddress, other, nil)
+	}
+	binary.BigEndian.PutUint16(response[2:], uint16(len(response)-stunHeaderLength))
+	return response
+}
+
+// newSTUNTestServer starts a STUN server that answers binding requests, ignoring their
+// CHANGE-REQUEST flags, and returns its address.
+func newSTUNTestServer(t *testing.T) string {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	other := netip.MustParseAddrPort("192.0.2.2:3479")
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, source, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			conn.WriteToUDP(newSTUNTestResponse(buf[:n], source.AddrPort(), other), source)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func Test_DetectNATType_Open(t *testing.T) {
+	server := newSTUNTestServer(t)
+
+	result := newDirectTestClient().DetectNATType(context.Background(), server)
+	require.Nil(t, result.Error)
+	require.Equal(t, NATTypeOpen, result.NATType)
+	mapped, err := netip.ParseAddrPort(result.MappedAddress)
+	require.NoError(t, err)
+	require.Equal(t, "127.0.0.1", mapped.Addr().String())
+}
+
+func Test_DetectNATType_Blocked(t *testing.T) {
+	silentServer, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer silentServer.Close()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	stun := &stunClient{conn: conn, retransmitTimeout: 10 * time.Millisecond}
+	result := classifyNAT(context.Background(), stun, silentServer.LocalAddr(), netip.AddrPort{})
+	require.Equal(t, NATTypeBlocked, result.NATType)
+	require.NotNil(t, result.Error)
+	require.Equal(t, platerrors.STUNServerUnreachable, result.Error.Code)
+}
+
+func Test_DetectNATType_InvalidServer(t *testing.T) {
+	result := newDirectTestClient().DetectNATType(context.Background(), ":3478")
+	require.NotNil(t, result.Error)
+	require.Empty(t, result.NATType)
+}
+
+// fakeNAT is a [stunTransactor] that answers like a STUN server behind a NAT of a given type.
+type fakeNAT struct {
+	server, other netip.AddrPort
+	// answers tells whether the NAT lets in the response to a request with the change flags.
+	answers func(change byte) bool
+	// mapped returns the mapped address of the requests to dest.
+	mapped func(dest netip.AddrPort) netip.AddrPort
+}
+
+func (n *fakeNAT) bindingRequest(ctx context.Context, dest net.Addr, change byte) (*stunResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if !n.answers(change) {
+		return nil, nil
+	}
+	destAddr := dest.(*net.UDPAddr).AddrPort()
+	return &stunResponse{mapped: n.mapped(destAddr), other: n.other}, nil
+}
+
+func Test_ClassifyNAT(t *testing.T) {
+	server := netip.MustParseAddrPort("192.0.2.1:3478")
+	other := netip.MustParseAddrPort("192.0.2.2:3479")
+	publicAddr := netip.MustParseAddrPort("203.0.113.1:40000")
+	localAddr := netip.MustParseAddrPort("10.0.0.2:5000")
+	sameMapping := func(netip.AddrPort) netip.AddrPort { return publicAddr }
+
+	for _, test := range []struct {
+		name     string
+		nat      *fakeNAT
+		expected string
+	}{{
+		name:     "full cone",
+		nat:      &fakeNAT{other: other, answers: func(byte) bool { return true }, mapped: sameMapping},
+		expected: NATTypeFullCone,
+	}, {
+		name:     "restricted",
+		nat:      &fakeNAT{other: other, answers: func(change byte) bool { return change&stunChangeIP == 0 }, mapped: sameMapping},
+		expected: NATTypeRestricted,
+	}, {
+		name:     "port restricted",
+		nat:      &fakeNAT{other: other, answers: func(change byte) bool { return change == 0 }, mapped: sameMapping},
+		expected: NATTypePortRestricted,
+	}, {
+		name: "symmetric",
+		nat: &fakeNAT{other: other, answers: func(change byte) bool { return change == 0 }, mapped: func(dest netip.AddrPort) netip.AddrPort {
+			if dest == other {
+				return netip.AddrPortFrom(publicAddr.Addr(), publicAddr.Port()+1)
+			}
+			return publicAddr
+		}},
+		expected: NATTypeSymmetric,
+	}, {
+		name:     "no alternate address",
+		nat:      &fakeNAT{answers: func(byte) bool { return true }, mapped: sameMapping},
+		expected: NATTypeUnknown,
+	}, {
+		name:     "no NAT",
+		nat:      &fakeNAT{other: other, answers: func(byte) bool { return true }, mapped: func(netip.AddrPort) netip.AddrPort { return localAddr }},
+		expected: NATTypeOpen,
+	}} {
+		t.Run(test.name, func(t *testing.T) {
+			result := classifyNAT(context.Background(), test.nat, net.UDPAddrFromAddrPort(server), localAddr)
+			require.Nil(t, result.Error)
+			require.Equal(t, test.expected, result.NATType)
+			require.NotEmpty(t, result.MappedAddress)
+		})
+	}
+}
+
+func Test_ClassifyNAT_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	nat := &fakeNAT{answers: func(byte) bool { return true }}
+
+	result := classifyNAT(ctx, nat, &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 3478}, netip.AddrPort{})
+	require.NotNil(t, result.Error)
+	require.Equal(t, platerrors.OperationCanceled, result.Error.Code)
+}
+
+func Test_ParseSTUNBindingResponse(t *testing.T) {
+	var transactionID [12]byte
+	copy(transactionID[:], "transaction!")
+	request := newSTUNBindingRequest(transactionID, stunChangePort)
+	require.Len(t, request, stunHeaderLength+8)
+	mapped := netip.MustParseAddrPort("[2001:db8::1]:40000")
+	other := netip.MustParseAddrPort("192.0.2.2:3479")
+
+	response, ok := parseSTUNBindingResponse(newSTUNTestResponse(request, mapped, other), transactionID)
+	require.True(t, ok)
+	require.Equal(t, mapped, response.mapped)
+	require.Equal(t, other, response.other)
+
+	var otherID [12]byte
+	_, ok = parseSTUNBindingResponse(newSTUNTestResponse(request, mapped, other), otherID)
+	require.False(t, ok, "response to another request")
+	_, ok = parseSTUNBindingResponse(request, transactionID)
+	require.False(t, ok, "not a response")
+}
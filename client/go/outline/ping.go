@@ -0,0 +1,175 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+)
+
+const (
+	// minPingInterval is the shortest interval between two probes of [Client.StartPing].
+	minPingInterval = 100 * time.Millisecond
+	// minPingTimeout and maxPingTimeout bound the time a probe of [Client.StartPing] can take
+	// before it counts as lost.
+	minPingTimeout = time.Second
+	maxPingTimeout = 5 * time.Second
+)
+
+// PingSample is a report of [Client.StartPing].
+type PingSample struct {
+	// Seq is the sequence number of the probe, starting at 1.
+	Seq int
+	// RTTMs is the time from sending the request of the probe to receiving the first byte of the
+	// response through the proxy, in milliseconds, or -1 if the probe was lost.
+	RTTMs float64
+	// Lost tells whether the probe failed or timed out.
+	Lost bool
+	// Error is why the probe was lost, or nil if it wasn't.
+	Error *platerrors.PlatformError
+}
+
+// PingListener receives the samples of [Client.StartPing].
+//
+// We use an interface instead of a func type so that it can be implemented by the platform code
+// through gobind. Calls are never concurrent.
+type PingListener interface {
+	OnPingSample(sample *PingSample)
+}
+
+// Pinger sends the probes of [Client.StartPing].
+type Pinger struct {
+	cancel context.CancelFunc
+}
+
+// Stop stops the pinger. A sample that was being delivered when Stop is called may still reach
+// the listener, but there are no samples after that.
+func (p *Pinger) Stop() {
+	p.cancel()
+}
+
+// StartPingResult represents the result of [Client.StartPing].
+//
+// We use a struct instead of a tuple to preserve a strongly typed error that gobind recognizes.
+type StartPingResult struct {
+	Pinger *Pinger
+	Error  *platerrors.PlatformError
+}
+
+// StartPing probes address, in host:port form, through the proxy right away and then every
+// interval, and reports the round-trip time of each probe, or its loss, to listener. Unlike
+// [Client.TestLatency], it runs until ctx is done or [Pinger.Stop] is called, to feed a live
+// latency chart. The interval is at least 100 ms.
+//
+// Each probe opens a TCP connection to address through the proxy, sends an HTTP HEAD request and
+// times the first byte of the response, so address should be an HTTP server, such as
+// example.com:80. Since some transports only reach the destination with the first write, the
+// round trip includes the connection of the proxy to the destination. Unlike [Client.TestLatency],
+// the response isn't parsed, which keeps the probes light. Like [Client.MeasureDialTimes], the
+// probes don't reuse the streams of [Client.SetConnectionPool].
+//
+// A probe that fails, or takes longer than the interval clamped to between 1 and 5 seconds, is
+// lost. Probes don't overlap, so a slow probe delays the next one.
+func (c *Client) StartPing(ctx context.Context, address string, interval time.Duration, listener PingListener) *StartPingResult {
+	if err := validateDialAddress(address); err != nil {
+		return &StartPingResult{Error: platerrors.ToPlatformError(err)}
+	}
+	interval = max(interval, minPingInterval)
+	timeout := min(max(interval, minPingTimeout), maxPingTimeout)
+	return &StartPingResult{Pinger: c.startPing(ctx, address, interval, timeout, listener)}
+}
+
+// startPing implements [Client.StartPing] with probes that time out after timeout.
+func (c *Client) startPing(ctx context.Context, address string, interval, timeout time.Duration, listener PingListener) *Pinger {
+	ctx, cancel := context.WithCancel(ctx)
+	pinger := &Pinger{cancel: cancel}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for seq := 1; ; seq++ {
+			sample := c.ping(ctx, address, timeout)
+			if ctx.Err() != nil {
+				return
+			}
+			sample.Seq = seq
+			listener.OnPingSample(sample)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return pinger
+}
+
+// ping sends a single probe of [Client.StartPing] to address.
+func (c *Client) ping(ctx context.Context, address string, timeout time.Duration) *PingSample {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	rtt, err := c.timeFirstByte(ctx, address)
+	if err != nil {
+		return &PingSample{
+			RTTMs: -1,
+			Lost:  true,
+			Error: platerrors.ToPlatformError(dialError(err, address, platerrors.ProxyServerUnreachable, "ping probe failed")),
+		}
+	}
+	return &PingSample{RTTMs: float64(rtt) / float64(time.Millisecond)}
+}
+
+// timeFirstByte sends an HTTP HEAD request to address through the proxy, on a connection of its
+// own, and returns the time until the first byte of the response.
+func (c *Client) timeFirstByte(ctx context.Context, address string) (time.Duration, error) {
+	conn, err := c.dialUnpooled(ctx, address)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	// Abort the exchange when ctx is done.
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	// Join the host and port again to bracket IPv6 literals, as the Host header needs.
+	host, port, _ := net.SplitHostPort(address)
+	request := fmt.Sprintf("HEAD / HTTP/1.1\r\nHost: %s\r\nConnection: close\r\n\r\n", net.JoinHostPort(host, port))
+	start := time.Now()
+	if _, err := io.WriteString(conn, request); err != nil {
+		return 0, pingStoppedError(ctx, err)
+	}
+	if _, err := conn.Read(make([]byte, 1)); err != nil {
+		if err == io.EOF {
+			err = errors.New("connection closed without a response")
+		}
+		return 0, pingStoppedError(ctx, err)
+	}
+	return time.Since(start), nil
+}
+
+// pingStoppedError returns the error of ctx if it's done, since err is then the deadline set to
+// abort the probe, and err otherwise.
+func pingStoppedError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
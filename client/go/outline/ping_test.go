@@ -0,0 +1,212 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/Jigsaw-Code/outline-sdk/transport"
+	"github.com/stretchr/testify/require"
+)
+
+// pingRecorder is a [PingListener] that sends the samples to a channel.
+type pingRecorder chan *PingSample
+
+func (r pingRecorder) OnPingSample(sample *PingSample) {
+	r <- sample
+}
+
+func (r pingRecorder) next(t *testing.T) *PingSample {
+	select {
+	case sample := <-r:
+		return sample
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "no ping sample reported")
+		return nil
+	}
+}
+
+func Test_StartPing(t *testing.T) {
+	address := newTCPEchoServer(t)
+	client := newDirectTestClient()
+	recorder := make(pingRecorder, 10)
+
+	pinger := client.startPing(context.Background(), address, 20*time.Millisecond, time.Second, recorder)
+	defer pinger.Stop()
+	for seq := 1; seq <= 3; seq++ {
+		sample := recorder.next(t)
+		require.Equal(t, seq, sample.Seq)
+		require.False(t, sample.Lost)
+		require.Nil(t, sample.Error)
+		require.GreaterOrEqual(t, sample.RTTMs, 0.0)
+	}
+}
+
+func Test_StartPing_Lost(t *testing.T) {
+	client := newUnreachableTestClient("127.0.0.1:4321")
+	recorder := make(pingRecorder, 10)
+
+	pinger := client.startPing(context.Background(), "example.com:80", 20*time.Millisecond, time.Second, recorder)
+	defer pinger.Stop()
+	for seq := 1; seq <= 2; seq++ {
+		sample := recorder.next(t)
+		require.Equal(t, seq, sample.Seq)
+		require.True(t, sample.Lost)
+		require.Equal(t, -1.0, sample.RTTMs)
+		require.NotNil(t, sample.Error)
+		require.Equal(t, platerrors.ProxyServerUnreachable, sample.Error.Code)
+	}
+}
+
+// newPingTestServer returns the address of a TCP server that handles each connection with handle.
+func newPingTestServer(t *testing.T, handle func(net.Conn)) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				handle(conn)
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func Test_StartPing_SendsRequest(t *testing.T) {
+	requests := make(chan string, 10)
+	address := newPingTestServer(t, func(conn net.Conn) {
+		request, err := bufio.NewReader(conn).ReadString('\n')
+		if err != nil {
+			return
+		}
+		requests <- request
+		io.WriteString(conn, "HTTP/1.1 200 OK\r\n\r\n")
+	})
+	recorder := make(pingRecorder, 10)
+
+	pinger := newDirectTestClient().startPing(context.Background(), address, 20*time.Millisecond, time.Second, recorder)
+	defer pinger.Stop()
+	sample := recorder.next(t)
+	require.False(t, sample.Lost)
+	require.Equal(t, "HEAD / HTTP/1.1\r\n", <-requests)
+}
+
+func Test_Client_timeFirstByte_HostHeader(t *testing.T) {
+	hosts := make(chan string, 10)
+	serverAddress := newPingTestServer(t, func(conn net.Conn) {
+		request, err := http.ReadRequest(bufio.NewReader(conn))
+		if err != nil {
+			return
+		}
+		hosts <- request.Host
+		io.WriteString(conn, "HTTP/1.1 200 OK\r\n\r\n")
+	})
+	client := newDirectTestClient()
+	dial := client.sd.Dial
+	client.sd.Dial = func(ctx context.Context, _ string) (transport.StreamConn, error) {
+		return dial(ctx, serverAddress)
+	}
+
+	for address, want := range map[string]string{
+		"[2001:db8::1]:80": "[2001:db8::1]:80",
+		"example.com:8080": "example.com:8080",
+		"192.0.2.1:80":     "192.0.2.1:80",
+	} {
+		_, err := client.timeFirstByte(context.Background(), address)
+		require.NoError(t, err, address)
+		require.Equal(t, want, <-hosts)
+	}
+}
+
+func Test_StartPing_DeadDestination(t *testing.T) {
+	tests := []struct {
+		name     string
+		handle   func(net.Conn)
+		wantCode platerrors.ErrorCode
+	}{
+		// The connection succeeds, but nothing answers.
+		{"silent", func(conn net.Conn) { io.Copy(io.Discard, conn) }, platerrors.Timeout},
+		{"closed", func(net.Conn) {}, platerrors.ProxyServerUnreachable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			address := newPingTestServer(t, tt.handle)
+			recorder := make(pingRecorder, 10)
+
+			pinger := newDirectTestClient().startPing(context.Background(), address, 20*time.Millisecond, 100*time.Millisecond, recorder)
+			defer pinger.Stop()
+			sample := recorder.next(t)
+			require.True(t, sample.Lost)
+			require.Equal(t, -1.0, sample.RTTMs)
+			require.Equal(t, tt.wantCode, sample.Error.Code)
+		})
+	}
+}
+
+func Test_StartPing_Timeout(t *testing.T) {
+	client := newDirectTestClient()
+	client.sd.Dial = func(ctx context.Context, address string) (transport.StreamConn, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	recorder := make(pingRecorder, 10)
+
+	pinger := client.startPing(context.Background(), "example.com:80", 20*time.Millisecond, 50*time.Millisecond, recorder)
+	defer pinger.Stop()
+	sample := recorder.next(t)
+	require.True(t, sample.Lost)
+	require.Equal(t, platerrors.Timeout, sample.Error.Code)
+}
+
+func Test_StartPing_Stops(t *testing.T) {
+	address := newTCPEchoServer(t)
+	client := newDirectTestClient()
+	recorder := make(pingRecorder, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	client.startPing(ctx, address, 20*time.Millisecond, time.Second, recorder)
+	recorder.next(t)
+	cancel()
+	// Drain a sample that may have been in flight.
+	select {
+	case <-recorder:
+	case <-time.After(100 * time.Millisecond):
+	}
+	select {
+	case sample := <-recorder:
+		require.FailNow(t, "got a sample after the pinger stopped", "%+v", sample)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func Test_StartPing_InvalidAddress(t *testing.T) {
+	result := newDirectTestClient().StartPing(context.Background(), "example.com", time.Second, make(pingRecorder, 1))
+	require.Nil(t, result.Pinger)
+	require.NotNil(t, result.Error)
+	require.Equal(t, platerrors.InvalidConfig, result.Error.Code)
+}
@@ -43,6 +43,34 @@ const (
 const (
 	// ResolveIPFailed means that we failed to resolve the IP address of a hostname.
 	ResolveIPFailed ErrorCode = "ERR_RESOLVE_IP_FAILURE"
+
+	// Timeout means that a network operation did not complete in time.
+	Timeout ErrorCode = "ERR_TIMEOUT"
+
+	// LocalAddressInUse means that we failed to bind a socket to a local address because another
+	// socket is already bound to it.
+	LocalAddressInUse ErrorCode = "ERR_LOCAL_ADDRESS_IN_USE"
+
+	// LocalAddressPermissionDenied means that we are not allowed to bind a socket to a local
+	// address, for example to a privileged port.
+	LocalAddressPermissionDenied ErrorCode = "ERR_LOCAL_ADDRESS_PERMISSION_DENIED"
+
+	// TLSHandshakeFailed means that a TLS connection could not be established, for example
+	// because the certificate of the server was rejected. Something in the network may be
+	// intercepting the connection.
+	TLSHandshakeFailed ErrorCode = "ERR_TLS_HANDSHAKE_FAILURE"
+
+	// BlockPageDetected means that a destination answered with what looks like a block page or
+	// a captive portal, such as an error status or a redirect to another site.
+	BlockPageDetected ErrorCode = "ERR_BLOCK_PAGE_DETECTED"
+
+	// DestinationForbidden means that the dial policy of the client doesn't allow connecting to
+	// the destination.
+	DestinationForbidden ErrorCode = "ERR_DESTINATION_FORBIDDEN"
+
+	// ClientShutDown means that the client was shut down with Shutdown and makes no new
+	// connections.
+	ClientShutDown ErrorCode = "ERR_CLIENT_SHUT_DOWN"
 )
 
 //////////
@@ -89,6 +117,33 @@ const (
 	ProxyServerUDPUnsupported ErrorCode = "ERR_PROXY_SERVER_UDP_NOT_SUPPORTED"
 )
 
+//////////
+// Business logic error codes - speed test
+//////////
+
+const (
+	// SpeedTestServerUnreachable means the proxy works, but the speed test server cannot be reached
+	// through it. The server may be down or blocked.
+	SpeedTestServerUnreachable ErrorCode = "ERR_SPEED_TEST_SERVER_UNREACHABLE"
+
+	// SpeedTestServerFailed means the speed test server can be reached through the proxy, but the
+	// test failed anyway, for example because the server was too slow. Trying again may help.
+	SpeedTestServerFailed ErrorCode = "ERR_SPEED_TEST_SERVER_FAILURE"
+
+	// ExitLocationFailed means the geo-IP endpoint can be reached through the proxy, but it didn't
+	// answer with the location of the exit node.
+	ExitLocationFailed ErrorCode = "ERR_EXIT_LOCATION_FAILURE"
+
+	// STUNServerUnreachable means the STUN server of a NAT type test didn't answer through the
+	// proxy. The server may be down, or UDP traffic may not get through the proxy.
+	STUNServerUnreachable ErrorCode = "ERR_STUN_SERVER_UNREACHABLE"
+
+	// IntegrityCheckFailed means the data downloaded through the proxy doesn't have the expected
+	// hash, which suggests that something on the way, such as a transparent proxy or a censor
+	// injecting content, altered it.
+	IntegrityCheckFailed ErrorCode = "ERR_INTEGRITY_CHECK_FAILURE"
+)
+
 //////////
 // Business logic error codes - config
 //////////
@@ -97,6 +152,12 @@ const (
 	// FetchConfigFailed means we failed to fetch a config from a remote location.
 	FetchConfigFailed ErrorCode = "ERR_FETCH_CONFIG_FAILURE"
 
+	// ConfigFileNotFound means the config file to read does not exist.
+	ConfigFileNotFound ErrorCode = "ERR_CONFIG_FILE_NOT_FOUND"
+
+	// ConfigFilePermissionDenied means we are not allowed to read the config file.
+	ConfigFilePermissionDenied ErrorCode = "ERR_CONFIG_FILE_PERMISSION_DENIED"
+
 	// ProviderError indicates an error returned by the provider in the Dynamic Config.
 	ProviderError ErrorCode = "ERR_PROVIDER"
 
@@ -0,0 +1,97 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"sync"
+)
+
+// defaultMaxConcurrentProbes is the limit of [SetMaxConcurrentProbes] by default.
+const defaultMaxConcurrentProbes = 8
+
+// probeSlots limits the server probes that run at the same time across the package.
+var probeSlots = newProbeLimiter(defaultMaxConcurrentProbes)
+
+// SetMaxConcurrentProbes sets how many server probes can run at the same time, across all the
+// clients, so that apps that check many servers at once don't open hundreds of sockets. A limit
+// of zero or less restores the default of 8.
+//
+// The probes are the checks of [CheckTCPAndUDPConnectivity] and its variants, which count as one
+// probe each even though they check TCP and UDP in parallel, and the checks of the servers of
+// [NewClientWithFallback] and its variants when the client is created. The checks of a failover
+// don't wait for a slot, since they run within dials that may hold one. Probes over the limit wait for a running one to end
+// before they start, and their timeouts only start then. The new limit applies to the probes that
+// start after the call.
+func SetMaxConcurrentProbes(limit int) {
+	if limit <= 0 {
+		limit = defaultMaxConcurrentProbes
+	}
+	probeSlots.setLimit(limit)
+}
+
+// probeLimiter is a semaphore whose number of slots can change while it's in use.
+type probeLimiter struct {
+	mu     sync.Mutex
+	limit  int
+	active int
+	// changed is closed, and replaced, when a slot is released or the limit changes.
+	changed chan struct{}
+}
+
+func newProbeLimiter(limit int) *probeLimiter {
+	return &probeLimiter{limit: limit, changed: make(chan struct{})}
+}
+
+// acquire waits for a free slot and takes it. It fails with the error of ctx if ctx is done
+// first. Callers must call release once done with the slot.
+func (l *probeLimiter) acquire(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		if l.active < l.limit {
+			l.active++
+			l.mu.Unlock()
+			return nil
+		}
+		changed := l.changed
+		l.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// release frees a slot taken by acquire.
+func (l *probeLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.active--
+	l.notifyLocked()
+}
+
+func (l *probeLimiter) setLimit(limit int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limit = limit
+	l.notifyLocked()
+}
+
+// notifyLocked wakes up the callers waiting in acquire. l.mu must be held.
+func (l *probeLimiter) notifyLocked() {
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
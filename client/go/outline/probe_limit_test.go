@@ -0,0 +1,180 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/connectivity"
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/Jigsaw-Code/outline-sdk/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// concurrencyTracker records the highest number of calls between enter and exit at the same time.
+type concurrencyTracker struct {
+	active, max atomic.Int32
+}
+
+func (c *concurrencyTracker) enter() {
+	active := c.active.Add(1)
+	for {
+		current := c.max.Load()
+		if active <= current || c.max.CompareAndSwap(current, active) {
+			return
+		}
+	}
+}
+
+func (c *concurrencyTracker) exit() {
+	c.active.Add(-1)
+}
+
+func Test_probeLimiter_NeverExceedsLimit(t *testing.T) {
+	const limit = 3
+	limiter := newProbeLimiter(limit)
+	var tracker concurrencyTracker
+	var wg sync.WaitGroup
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if !assert.NoError(t, limiter.acquire(context.Background())) {
+				return
+			}
+			tracker.enter()
+			time.Sleep(5 * time.Millisecond)
+			tracker.exit()
+			limiter.release()
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, int32(limit), tracker.max.Load())
+}
+
+func Test_probeLimiter_Canceled(t *testing.T) {
+	limiter := newProbeLimiter(1)
+	require.NoError(t, limiter.acquire(context.Background()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, limiter.acquire(ctx), context.DeadlineExceeded)
+
+	// The failed acquire didn't take a slot.
+	limiter.release()
+	require.NoError(t, limiter.acquire(context.Background()))
+}
+
+func Test_probeLimiter_SetLimit(t *testing.T) {
+	limiter := newProbeLimiter(1)
+	require.NoError(t, limiter.acquire(context.Background()))
+
+	acquired := make(chan error)
+	go func() { acquired <- limiter.acquire(context.Background()) }()
+	select {
+	case <-acquired:
+		require.FailNow(t, "acquired a slot over the limit")
+	case <-time.After(20 * time.Millisecond):
+	}
+	// Raising the limit lets the waiting caller in.
+	limiter.setLimit(2)
+	select {
+	case err := <-acquired:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "the waiting caller didn't get a slot")
+	}
+}
+
+func Test_SetMaxConcurrentProbes(t *testing.T) {
+	t.Cleanup(func() { SetMaxConcurrentProbes(0) })
+	SetMaxConcurrentProbes(2)
+
+	var tracker concurrencyTracker
+	httpServer := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		tracker.enter()
+		defer tracker.exit()
+		time.Sleep(50 * time.Millisecond)
+	}))
+	defer httpServer.Close()
+	udpServer := newUDPEchoServer(t, nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := CheckTCPAndUDPConnectivityWithTargets(newDirectTestClient(), httpServer.Listener.Addr().String(), udpServer)
+			assert.Nil(t, result.TCPError, "Got %v", result.TCPError)
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, int32(2), tracker.max.Load())
+
+	SetMaxConcurrentProbes(0)
+	require.Equal(t, defaultMaxConcurrentProbes, probeSlots.limit)
+}
+
+func Test_checkTCPAndUDPConnectivity_CanceledWaitingForSlot(t *testing.T) {
+	t.Cleanup(func() { SetMaxConcurrentProbes(0) })
+	SetMaxConcurrentProbes(1)
+	require.NoError(t, probeSlots.acquire(context.Background()))
+	defer probeSlots.release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result := checkTCPAndUDPConnectivity(ctx, newDirectTestClient(), defaultConnectivityTimeout, connectivity.ProbeTargets{})
+	require.NotNil(t, result.TCPError)
+	require.Equal(t, platerrors.OperationCanceled, result.TCPError.Code)
+	require.Equal(t, result.TCPError, result.UDPError)
+}
+
+func Test_checkTCPAndUDPConnectivity_FailoverWithOneSlot(t *testing.T) {
+	t.Cleanup(func() { SetMaxConcurrentProbes(0) })
+	httpServer := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer httpServer.Close()
+	httpAddr := httpServer.Listener.Addr().String()
+	udpServer := newUDPEchoServer(t, nil)
+
+	// The candidates send all their streams to the HTTP server, including the ones of the probes.
+	first, second := &fakeCandidate{}, &fakeCandidate{}
+	candidates := []*Client{first.client("a:1"), second.client("b:2")}
+	for _, candidate := range candidates {
+		dial := candidate.sd.Dial
+		candidate.sd.Dial = func(ctx context.Context, _ string) (transport.StreamConn, error) {
+			return dial(ctx, httpAddr)
+		}
+	}
+	result := newClientWithFallback(context.Background(), candidates, make([]error, 2), withProbeSlot(probeFallbackTransport), probeFallbackTransport)
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	client := result.Client
+	client.SetFailoverEnabled(true)
+	first.broken.Store(true)
+
+	// The check holds the only slot while it dials through the client, which fails over.
+	SetMaxConcurrentProbes(1)
+	start := time.Now()
+	checkResult := CheckTCPAndUDPConnectivityWithTargets(client, httpAddr, udpServer)
+	require.Nil(t, checkResult.TCPError, "Got %v", checkResult.TCPError)
+	require.Less(t, time.Since(start), fallbackProbeTimeout)
+	require.Equal(t, 1, client.ActiveConfigIndex())
+}
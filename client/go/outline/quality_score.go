@@ -0,0 +1,118 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import "math"
+
+// Bounds of the components of [ComprehensiveTestResult.QualityScore].
+const (
+	// qualityBestLatencyMs and below get the full latency score.
+	qualityBestLatencyMs = 50
+	// qualityWorstLatencyMs and above get no latency score.
+	qualityWorstLatencyMs = 1000
+	// qualityTargetDownloadKBps, about 25 Mbps, and above get the full download score.
+	qualityTargetDownloadKBps = 3125
+	// qualityTargetUploadKBps, about 10 Mbps, and above get the full upload score.
+	qualityTargetUploadKBps = 1250
+)
+
+// QualityWeights are the weights of the components of the quality score of a
+// [ComprehensiveTestResult]. Each component scores between 0 and 1, and the score is their
+// weighted average scaled to 0–100. Only the ratios between the weights matter, and negative
+// weights count as zero.
+type QualityWeights struct {
+	// Latency weighs the round-trip time, which scores 1 up to 50 ms, 0 from 1 s on, and
+	// linearly in between.
+	Latency int
+	// Download weighs the download speed, which scores linearly up to 1 at about 25 Mbps.
+	Download int
+	// Upload weighs the upload speed, which scores linearly up to 1 at about 10 Mbps.
+	Upload int
+	// UDP weighs whether UDP works through the proxy, which scores 1 if it does and 0 otherwise.
+	UDP int
+}
+
+// DefaultQualityWeights returns the weights of [ComprehensiveTestResult.QualityScore], which can
+// be tuned and passed to [ComprehensiveTestResult.QualityScoreWithWeights].
+func DefaultQualityWeights() *QualityWeights {
+	return &QualityWeights{Latency: 30, Download: 35, Upload: 20, UDP: 15}
+}
+
+// QualityScore combines the results into a single connection quality score between 0 and 100,
+// the higher the better, weighted by [DefaultQualityWeights]. It's 0 if TCP doesn't work through
+// the proxy. Phases that failed or didn't run score 0.
+func (r *ComprehensiveTestResult) QualityScore() int {
+	return r.QualityScoreWithWeights(DefaultQualityWeights())
+}
+
+// QualityScoreWithWeights is like [ComprehensiveTestResult.QualityScore], but with the given
+// weights. Nil weights mean the defaults. It's 0 if all weights are zero.
+func (r *ComprehensiveTestResult) QualityScoreWithWeights(weights *QualityWeights) int {
+	if r == nil || r.TCPError != nil {
+		return 0
+	}
+	if weights == nil {
+		weights = DefaultQualityWeights()
+	}
+	components := []struct {
+		weight int
+		score  float64
+	}{
+		{weights.Latency, latencyQuality(r.LatencyMs)},
+		{weights.Download, speedQuality(r.DownloadSpeedKBps, qualityTargetDownloadKBps)},
+		{weights.Upload, speedQuality(r.UploadSpeedKBps, qualityTargetUploadKBps)},
+		{weights.UDP, udpQuality(r)},
+	}
+	var total, weighted float64
+	for _, c := range components {
+		weight := float64(max(c.weight, 0))
+		total += weight
+		weighted += weight * c.score
+	}
+	if total == 0 {
+		return 0
+	}
+	return int(math.Round(100 * weighted / total))
+}
+
+// latencyQuality scores latencyMs between 0 and 1. Negative values mean the phase failed.
+func latencyQuality(latencyMs int64) float64 {
+	switch {
+	case latencyMs < 0:
+		return 0
+	case latencyMs <= qualityBestLatencyMs:
+		return 1
+	case latencyMs >= qualityWorstLatencyMs:
+		return 0
+	}
+	return float64(qualityWorstLatencyMs-latencyMs) / (qualityWorstLatencyMs - qualityBestLatencyMs)
+}
+
+// speedQuality scores speedKBps between 0 and 1, relative to targetKBps. Negative values mean
+// the phase failed.
+func speedQuality(speedKBps, targetKBps int64) float64 {
+	if speedKBps <= 0 {
+		return 0
+	}
+	return min(float64(speedKBps)/float64(targetKBps), 1)
+}
+
+// udpQuality scores 1 if UDP works through the proxy, and 0 otherwise.
+func udpQuality(r *ComprehensiveTestResult) float64 {
+	if r.UDPError != nil {
+		return 0
+	}
+	return 1
+}
@@ -0,0 +1,55 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"testing"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ComprehensiveTestResult_QualityScore(t *testing.T) {
+	udpErr := &platerrors.PlatformError{Code: platerrors.ProxyServerUDPUnsupported}
+	tests := []struct {
+		name   string
+		result *ComprehensiveTestResult
+		score  int
+	}{
+		{"excellent", &ComprehensiveTestResult{LatencyMs: 20, DownloadSpeedKBps: 10000, UploadSpeedKBps: 5000}, 100},
+		{"no UDP", &ComprehensiveTestResult{UDPError: udpErr, LatencyMs: 20, DownloadSpeedKBps: 10000, UploadSpeedKBps: 5000}, 85},
+		// Latency and upload score 0.5, and download just under.
+		{"average", &ComprehensiveTestResult{LatencyMs: 525, DownloadSpeedKBps: 1562, UploadSpeedKBps: 625}, 57},
+		{"bandwidth failed", &ComprehensiveTestResult{DownloadSpeedKBps: -1, UploadSpeedKBps: -1, LatencyMs: -1}, 15},
+		{"slow latency", &ComprehensiveTestResult{UDPError: udpErr, LatencyMs: 5000, DownloadSpeedKBps: -1, UploadSpeedKBps: -1}, 0},
+		{"no TCP", &ComprehensiveTestResult{TCPError: &platerrors.PlatformError{Code: platerrors.ProxyServerUnreachable}, LatencyMs: 20, DownloadSpeedKBps: 10000, UploadSpeedKBps: 5000}, 0},
+		{"nil", nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.score, tt.result.QualityScore())
+		})
+	}
+}
+
+func Test_ComprehensiveTestResult_QualityScoreWithWeights(t *testing.T) {
+	result := &ComprehensiveTestResult{LatencyMs: 20, DownloadSpeedKBps: 1562, UploadSpeedKBps: -1}
+
+	require.Equal(t, 100, result.QualityScoreWithWeights(&QualityWeights{Latency: 1}))
+	require.Equal(t, 50, result.QualityScoreWithWeights(&QualityWeights{Download: 1}))
+	require.Equal(t, 75, result.QualityScoreWithWeights(&QualityWeights{Latency: 1, Download: 1, Upload: -5}))
+	require.Equal(t, 0, result.QualityScoreWithWeights(&QualityWeights{}))
+	require.Equal(t, result.QualityScore(), result.QualityScoreWithWeights(nil))
+}
@@ -0,0 +1,93 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import "math"
+
+// Thresholds of [likelyRateLimited]. They are strict on purpose: a false report of throttling
+// sends users and operators the wrong way, while a missed one only leaves them where they were.
+const (
+	// rateLimitMinSamples is the number of samples it takes to tell a plateau, after the first.
+	rateLimitMinSamples = 4
+	// rateLimitMinSampleMs is the length below which a sample, usually the last, is ignored.
+	rateLimitMinSampleMs = 500
+	// rateLimitMaxVariation is the highest coefficient of variation of a plateau. Links that
+	// are just saturated usually vary by 5% or more from one second to the next.
+	rateLimitMaxVariation = 0.03
+	// rateLimitMinRatio and rateLimitMaxRatio bound the ratio of the plateau to a round rate.
+	// Headers and retransmissions take a few percent of the rate that a limiter enforces, so the
+	// plateau can be a little below the round rate, but not above it.
+	rateLimitMinRatio = 0.92
+	rateLimitMaxRatio = 1.02
+)
+
+// roundRates are the rates, in bytes per second, that rate limiters are commonly set to: the
+// tiers of data plans, in Mbps, and the limits of servers, in KiB/s and MiB/s.
+var roundRates = func() []float64 {
+	var rates []float64
+	for _, mbps := range []float64{1, 2, 3, 4, 5, 8, 10, 15, 20, 25, 30, 40, 50, 60, 75, 80, 100, 150, 200, 250, 300, 400, 500, 1000} {
+		rates = append(rates, mbps*1000*1000/8)
+	}
+	for _, kib := range []float64{128, 256, 512, 1024, 2048, 4096, 5120, 8192, 10240} {
+		rates = append(rates, kib*1024)
+	}
+	return rates
+}()
+
+// likelyRateLimited tells whether the throughput of samples, the per-second samples of a
+// download, looks capped by a rate limiter rather than by the capacity of the network.
+//
+// It ignores the first sample, which covers the ramp-up of the connection, and samples shorter
+// than half a second. The rest must be at least 4 samples whose rates vary by at most 3% around
+// their mean, and the mean must be between 92% and 102% of one of [roundRates]. Networks rarely
+// deliver such a flat rate on their own, let alone at a round value.
+func likelyRateLimited(samples []ThroughputSample) bool {
+	if len(samples) == 0 {
+		return false
+	}
+	var rates []float64
+	for _, sample := range samples[1:] {
+		if sample.DurationMs < rateLimitMinSampleMs {
+			continue
+		}
+		rates = append(rates, float64(sample.Bytes)*1000/float64(sample.DurationMs))
+	}
+	if len(rates) < rateLimitMinSamples {
+		return false
+	}
+
+	var sum float64
+	for _, rate := range rates {
+		sum += rate
+	}
+	mean := sum / float64(len(rates))
+	if mean <= 0 {
+		return false
+	}
+	var squares float64
+	for _, rate := range rates {
+		squares += (rate - mean) * (rate - mean)
+	}
+	if math.Sqrt(squares/float64(len(rates)))/mean > rateLimitMaxVariation {
+		return false
+	}
+
+	for _, roundRate := range roundRates {
+		if ratio := mean / roundRate; ratio >= rateLimitMinRatio && ratio <= rateLimitMaxRatio {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newRateSamples returns one-second samples with the given rates, in bytes per second.
+func newRateSamples(rates ...int64) []ThroughputSample {
+	samples := make([]ThroughputSample, len(rates))
+	for i, rate := range rates {
+		samples[i] = ThroughputSample{OffsetMs: int64(i) * 1000, DurationMs: 1000, Bytes: rate}
+	}
+	return samples
+}
+
+func Test_likelyRateLimited(t *testing.T) {
+	const tenMbps = 1_250_000
+	tests := []struct {
+		name    string
+		samples []ThroughputSample
+		want    bool
+	}{
+		{"no samples", nil, false},
+		{"flat at 10 Mbps", newRateSamples(300_000, tenMbps, tenMbps+5_000, tenMbps-5_000, tenMbps), true},
+		{"flat below 10 Mbps with overhead", newRateSamples(300_000, 1_190_000, 1_195_000, 1_185_000, 1_190_000), true},
+		{"flat at 512 KiB/s", newRateSamples(100_000, 524_288, 524_000, 524_500, 524_288), true},
+		{"flat at an odd rate", newRateSamples(300_000, 900_000, 905_000, 895_000, 900_000), false},
+		{"above a round rate", newRateSamples(300_000, 1_300_000, 1_300_000, 1_300_000, 1_300_000), false},
+		{"noisy around 10 Mbps", newRateSamples(300_000, 1_100_000, 1_400_000, 1_200_000, 1_300_000), false},
+		{"too few samples", newRateSamples(300_000, tenMbps, tenMbps, tenMbps), false},
+		{"stalled", newRateSamples(0, 0, 0, 0, 0), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, likelyRateLimited(tt.samples))
+		})
+	}
+}
+
+func Test_likelyRateLimited_IgnoresShortSamples(t *testing.T) {
+	samples := newRateSamples(300_000, 1_250_000, 1_250_000, 1_250_000, 1_250_000)
+	// The last sample is cut short by the end of the test.
+	samples = append(samples, ThroughputSample{OffsetMs: 5000, DurationMs: 100, Bytes: 10_000})
+	require.True(t, likelyRateLimited(samples))
+
+	samples = newRateSamples(300_000, 1_250_000, 1_250_000, 1_250_000)
+	samples = append(samples, ThroughputSample{OffsetMs: 4000, DurationMs: 100, Bytes: 125_000})
+	require.False(t, likelyRateLimited(samples))
+}
@@ -0,0 +1,90 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"encoding/json"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+)
+
+// resultErrorJSON is the JSON form of the errors of test results. It only has the code and the
+// message of the error, since its details and causes may hold server addresses and URLs, and
+// the results are meant to be uploaded as anonymous diagnostics.
+type resultErrorJSON struct {
+	Code    platerrors.ErrorCode `json:"code"`
+	Message string               `json:"message"`
+}
+
+// newResultErrorJSON returns the JSON form of perr, or nil, which is serialized as null, if perr
+// is nil.
+func newResultErrorJSON(perr *platerrors.PlatformError) *resultErrorJSON {
+	if perr == nil {
+		return nil
+	}
+	return &resultErrorJSON{Code: perr.Code, Message: perr.Message}
+}
+
+// MarshalJSON serializes the result with camelCase keys. Errors are objects with their code and
+// message, or null.
+func (r BandwidthTestResult) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		DownloadSpeedKBps int64            `json:"downloadSpeedKBps"`
+		UploadSpeedKBps   int64            `json:"uploadSpeedKBps"`
+		LatencyMs         int64            `json:"latencyMs"`
+		Error             *resultErrorJSON `json:"error"`
+		LatencyError      *resultErrorJSON `json:"latencyError"`
+		DownloadError     *resultErrorJSON `json:"downloadError"`
+		UploadError       *resultErrorJSON `json:"uploadError"`
+		MaxBytesReached   bool             `json:"maxBytesReached"`
+		LikelyRateLimited bool             `json:"likelyRateLimited"`
+	}{
+		DownloadSpeedKBps: r.DownloadSpeedKBps,
+		UploadSpeedKBps:   r.UploadSpeedKBps,
+		LatencyMs:         r.LatencyMs,
+		Error:             newResultErrorJSON(r.Error),
+		LatencyError:      newResultErrorJSON(r.LatencyError),
+		DownloadError:     newResultErrorJSON(r.DownloadError),
+		UploadError:       newResultErrorJSON(r.UploadError),
+		MaxBytesReached:   r.MaxBytesReached,
+		LikelyRateLimited: r.LikelyRateLimited,
+	})
+}
+
+// MarshalJSON serializes the result with camelCase keys. Errors are objects with their code and
+// message, or null.
+func (r ComprehensiveTestResult) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		TCPError          *resultErrorJSON `json:"tcpError"`
+		UDPError          *resultErrorJSON `json:"udpError"`
+		DownloadSpeedKBps int64            `json:"downloadSpeedKBps"`
+		UploadSpeedKBps   int64            `json:"uploadSpeedKBps"`
+		LatencyMs         int64            `json:"latencyMs"`
+		BandwidthError    *resultErrorJSON `json:"bandwidthError"`
+		LatencyError      *resultErrorJSON `json:"latencyError"`
+		DownloadError     *resultErrorJSON `json:"downloadError"`
+		UploadError       *resultErrorJSON `json:"uploadError"`
+	}{
+		TCPError:          newResultErrorJSON(r.TCPError),
+		UDPError:          newResultErrorJSON(r.UDPError),
+		DownloadSpeedKBps: r.DownloadSpeedKBps,
+		UploadSpeedKBps:   r.UploadSpeedKBps,
+		LatencyMs:         r.LatencyMs,
+		BandwidthError:    newResultErrorJSON(r.BandwidthError),
+		LatencyError:      newResultErrorJSON(r.LatencyError),
+		DownloadError:     newResultErrorJSON(r.DownloadError),
+		UploadError:       newResultErrorJSON(r.UploadError),
+	})
+}
@@ -0,0 +1,76 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_BandwidthTestResult_MarshalJSON(t *testing.T) {
+	perr := &platerrors.PlatformError{
+		Code:    platerrors.ProxyServerUnreachable,
+		Message: "failed to upload",
+		Details: platerrors.ErrorDetails{"url": "https://speed.example.com"},
+		Cause:   &platerrors.PlatformError{Code: platerrors.InternalError, Message: "connection reset"},
+	}
+	result := &BandwidthTestResult{
+		DownloadSpeedKBps: 100,
+		UploadSpeedKBps:   -1,
+		LatencyMs:         20,
+		Error:             perr,
+		UploadError:       perr,
+	}
+
+	data, err := json.Marshal(result)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"downloadSpeedKBps": 100,
+		"uploadSpeedKBps": -1,
+		"latencyMs": 20,
+		"error": {"code": "ERR_PROXY_SERVER_UNREACHABLE", "message": "failed to upload"},
+		"latencyError": null,
+		"downloadError": null,
+		"uploadError": {"code": "ERR_PROXY_SERVER_UNREACHABLE", "message": "failed to upload"},
+		"maxBytesReached": false,
+		"likelyRateLimited": false
+	}`, string(data))
+}
+
+func Test_ComprehensiveTestResult_MarshalJSON(t *testing.T) {
+	result := ComprehensiveTestResult{
+		UDPError:          &platerrors.PlatformError{Code: platerrors.ProxyServerUDPUnsupported, Message: "UDP is blocked"},
+		DownloadSpeedKBps: 100,
+		UploadSpeedKBps:   50,
+		LatencyMs:         20,
+	}
+
+	data, err := json.Marshal(result)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"tcpError": null,
+		"udpError": {"code": "ERR_PROXY_SERVER_UDP_NOT_SUPPORTED", "message": "UDP is blocked"},
+		"downloadSpeedKBps": 100,
+		"uploadSpeedKBps": 50,
+		"latencyMs": 20,
+		"bandwidthError": null,
+		"latencyError": null,
+		"downloadError": null,
+		"uploadError": null
+	}`, string(data))
+}
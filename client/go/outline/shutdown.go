@@ -0,0 +1,75 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+)
+
+// errClientShutDown is the error of the dials of a client that was shut down.
+var errClientShutDown = platerrors.PlatformError{
+	Code:    platerrors.ClientShutDown,
+	Message: "the client was shut down",
+}
+
+// shutdownIdleSweepInterval is how often [Client.Shutdown] closes the idle connections of
+// [Client.HTTPClient], which requests that were in flight return to the pool.
+const shutdownIdleSweepInterval = 100 * time.Millisecond
+
+// Shutdown gracefully closes the client for the teardown of the tunnel. From the moment it's
+// called, [Client.DialStream] and [Client.ListenPacket] fail with a [platerrors.ClientShutDown]
+// error. It then waits for the connections returned by them to be closed by their users, closing
+// the idle connections of [Client.HTTPClient] and of [Client.SetConnectionPool] right away.
+//
+// If ctx is done before all the connections are closed, Shutdown closes the remaining ones and
+// fails with [platerrors.Timeout], or [platerrors.OperationCanceled] if ctx was canceled.
+// Calling Shutdown again waits for the connections again.
+func (c *Client) Shutdown(ctx context.Context) error {
+	drained := c.connections.shutdown()
+	if pool := c.pool.Swap(nil); pool != nil {
+		pool.close()
+	}
+	logger().Info("shutting down the client", "connections", len(c.connections.snapshot()))
+	httpTransport := c.proxyHTTPTransport()
+	httpTransport.CloseIdleConnections()
+	ticker := time.NewTicker(shutdownIdleSweepInterval)
+	defer ticker.Stop()
+	for ctx.Err() == nil {
+		select {
+		case <-drained:
+			logger().Info("client shut down")
+			return nil
+		case <-ticker.C:
+			httpTransport.CloseIdleConnections()
+		case <-ctx.Done():
+		}
+	}
+	closed := c.connections.closeAll()
+	logger().Warn("client shut down before its connections drained", "closed", closed)
+	perr := platerrors.PlatformError{
+		Code:    platerrors.Timeout,
+		Message: "connections didn't drain before the shutdown deadline",
+		Details: platerrors.ErrorDetails{"closedConnections": closed},
+	}
+	if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		perr.Code = platerrors.OperationCanceled
+		perr.Message = "shutdown was canceled before the connections drained"
+	}
+	return perr
+}
@@ -0,0 +1,202 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/stretchr/testify/require"
+)
+
+func requireShutDownError(t *testing.T, err error) {
+	perr := platerrors.ToPlatformError(err)
+	require.NotNil(t, perr)
+	require.Equal(t, platerrors.ClientShutDown, perr.Code)
+}
+
+func Test_Shutdown_WaitsForConnections(t *testing.T) {
+	server := newTCPEchoServer(t)
+	client := newDirectTestClient()
+	conn, err := client.DialStream(context.Background(), server)
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() { done <- client.Shutdown(context.Background()) }()
+
+	// The open connection still works, but new dials fail.
+	require.Eventually(t, client.connections.isShutDown, time.Second, time.Millisecond)
+	_, err = client.DialStream(context.Background(), server)
+	requireShutDownError(t, err)
+	_, err = conn.Write([]byte("ping"))
+	require.NoError(t, err)
+	_, err = io.ReadFull(conn, make([]byte, 4))
+	require.NoError(t, err)
+	select {
+	case <-done:
+		t.Fatal("Shutdown returned before the connection was closed")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	require.NoError(t, conn.Close())
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Shutdown didn't return after the connection was closed")
+	}
+	require.Empty(t, client.ActiveConnections())
+}
+
+func Test_Shutdown_NoConnections(t *testing.T) {
+	client := newDirectTestClient()
+	require.NoError(t, client.Shutdown(context.Background()))
+	// Shutting down again is harmless.
+	require.NoError(t, client.Shutdown(context.Background()))
+
+	_, err := client.DialStream(context.Background(), newTCPEchoServer(t))
+	requireShutDownError(t, err)
+	_, err = client.DialTCP(context.Background(), newTCPEchoServer(t))
+	requireShutDownError(t, err)
+}
+
+func Test_Shutdown_DeadlineClosesConnections(t *testing.T) {
+	server := newTCPEchoServer(t)
+	client := newDirectTestClient()
+	conn, err := client.DialStream(context.Background(), server)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = client.Shutdown(ctx)
+	perr := platerrors.ToPlatformError(err)
+	require.NotNil(t, perr)
+	require.Equal(t, platerrors.Timeout, perr.Code)
+	require.Equal(t, 1, perr.Details["closedConnections"])
+	require.Empty(t, client.ActiveConnections())
+
+	_, err = conn.Read(make([]byte, 1))
+	require.Error(t, err)
+}
+
+func Test_Shutdown_Canceled(t *testing.T) {
+	client := newDirectTestClient()
+	conn, err := client.DialStream(context.Background(), newTCPEchoServer(t))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	perr := platerrors.ToPlatformError(client.Shutdown(ctx))
+	require.NotNil(t, perr)
+	require.Equal(t, platerrors.OperationCanceled, perr.Code)
+}
+
+func Test_Shutdown_ClosesIdleHTTPConnections(t *testing.T) {
+	requestDone := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-requestDone
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	client := newDirectTestClient()
+
+	// A request in flight returns its connection to the pool after Shutdown started.
+	responses := make(chan *http.Response, 1)
+	go func() {
+		resp, err := client.HTTPClient(0).Get(server.URL)
+		if err == nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		responses <- resp
+	}()
+	require.Eventually(t, func() bool { return len(client.ActiveConnections()) == 1 }, time.Second, time.Millisecond)
+
+	done := make(chan error, 1)
+	go func() { done <- client.Shutdown(context.Background()) }()
+	require.Eventually(t, client.connections.isShutDown, time.Second, time.Millisecond)
+	close(requestDone)
+	require.NotNil(t, <-responses)
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Shutdown didn't close the idle HTTP connection")
+	}
+}
+
+func Test_Shutdown_PacketConnections(t *testing.T) {
+	server := newUDPEchoServer(t, nil)
+	serverAddr, err := net.ResolveUDPAddr("udp", server)
+	require.NoError(t, err)
+	client := newDirectTestClient()
+	conn, err := client.ListenPacket(context.Background())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	done := make(chan error, 1)
+	go func() { done <- client.Shutdown(context.Background()) }()
+
+	// The open connection still works, but new ones fail.
+	require.Eventually(t, client.connections.isShutDown, time.Second, time.Millisecond)
+	_, err = client.ListenPacket(context.Background())
+	requireShutDownError(t, err)
+	_, err = client.DialUDP(context.Background(), server)
+	requireShutDownError(t, err)
+	_, err = conn.WriteTo([]byte("ping"), serverAddr)
+	require.NoError(t, err)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _, err = conn.ReadFrom(make([]byte, 4))
+	require.NoError(t, err)
+	select {
+	case <-done:
+		t.Fatal("Shutdown returned before the packet connection was closed")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	require.NoError(t, conn.Close())
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Shutdown didn't return after the packet connection was closed")
+	}
+}
+
+func Test_Shutdown_DeadlineClosesPacketConnections(t *testing.T) {
+	client := newDirectTestClient()
+	conn, err := client.ListenPacket(context.Background())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	perr := platerrors.ToPlatformError(client.Shutdown(ctx))
+	require.NotNil(t, perr)
+	require.Equal(t, platerrors.Timeout, perr.Code)
+	require.Equal(t, 1, perr.Details["closedConnections"])
+
+	_, _, err = conn.ReadFrom(make([]byte, 1))
+	require.ErrorIs(t, err, net.ErrClosed)
+}
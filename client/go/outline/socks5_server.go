@@ -0,0 +1,465 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/Jigsaw-Code/outline-sdk/transport"
+)
+
+// SOCKS5 protocol values, from RFC 1928.
+const (
+	socks5Version         = 5
+	socks5MethodNoAuth    = 0
+	socks5MethodNoneValid = 0xff
+
+	socks5CmdConnect      = 1
+	socks5CmdUDPAssociate = 3
+
+	socks5AddrIPv4   = 1
+	socks5AddrDomain = 3
+	socks5AddrIPv6   = 4
+
+	socks5ReplySucceeded              = 0
+	socks5ReplyGeneralFailure         = 1
+	socks5ReplyNotAllowed             = 2
+	socks5ReplyNetworkUnreachable     = 3
+	socks5ReplyHostUnreachable        = 4
+	socks5ReplyConnectionRefused      = 5
+	socks5ReplyCommandNotSupported    = 7
+	socks5ReplyAddressTypeUnsupported = 8
+)
+
+// socks5HandshakeTimeout bounds the time a SOCKS5 client takes to send its request, so that idle
+// connections don't hold the server.
+const socks5HandshakeTimeout = 10 * time.Second
+
+// Delays between the attempts to accept connections after a temporary error, as in
+// [http.Server.Serve].
+const (
+	minAcceptRetryDelay = 5 * time.Millisecond
+	maxAcceptRetryDelay = time.Second
+)
+
+var errSOCKS5AddressType = errors.New("unsupported SOCKS5 address type")
+
+// RunSOCKS5 serves a local SOCKS5 proxy on listenAddr that relays the traffic through c, so that
+// browsers and other apps can use the tunnel without the VPN APIs of the platform. It handles the
+// CONNECT command with [Client.DialStream], and the UDP ASSOCIATE command with
+// [Client.ListenPacket]. The server doesn't authenticate its clients, so listenAddr should be a
+// loopback address, such as "127.0.0.1:1080".
+//
+// RunSOCKS5 blocks until ctx is done, then closes the listener and the connections it relays and
+// returns nil. It returns a [platerrors.PlatformError] if it can't listen on listenAddr.
+func (c *Client) RunSOCKS5(ctx context.Context, listenAddr string) error {
+	listener, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		return localProxyListenError(err, listenAddr)
+	}
+	return c.serveSOCKS5(ctx, listener)
+}
+
+// localProxyListenError converts err, which made listening on listenAddr fail for a local proxy
+// server, into a [platerrors.PlatformError].
+func localProxyListenError(err error, listenAddr string) error {
+	if errors.Is(err, syscall.EADDRINUSE) || errors.Is(err, syscall.EADDRNOTAVAIL) || errors.Is(err, os.ErrPermission) {
+		return listenError(err, listenAddr)
+	}
+	return platerrors.PlatformError{
+		Code:    platerrors.InvalidConfig,
+		Message: "failed to listen for proxy connections",
+		Details: platerrors.ErrorDetails{"address": listenAddr},
+		Cause:   platerrors.ToPlatformError(err),
+	}
+}
+
+// serveSOCKS5 serves the SOCKS5 connections of listener until ctx is done, and waits for them to
+// be closed. Temporary failures to accept connections, such as running out of file descriptors,
+// are retried with backoff, and other failures stop the server.
+func (c *Client) serveSOCKS5(ctx context.Context, listener net.Listener) error {
+	defer listener.Close()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(ctx, func() { listener.Close() })
+	defer stop()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	logger().Info("SOCKS5 server started")
+	var retryDelay time.Duration
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				logger().Info("SOCKS5 server stopped")
+				return nil
+			}
+			if isTemporaryAcceptError(err) {
+				retryDelay = min(max(2*retryDelay, minAcceptRetryDelay), maxAcceptRetryDelay)
+				logger().Warn("SOCKS5 server failed to accept a connection, retrying", "delay", retryDelay)
+				timer := time.NewTimer(retryDelay)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+				case <-timer.C:
+				}
+				continue
+			}
+			logger().Warn("SOCKS5 server failed")
+			return platerrors.PlatformError{
+				Code:    platerrors.InternalError,
+				Message: "failed to accept SOCKS5 connections",
+				Cause:   platerrors.ToPlatformError(err),
+			}
+		}
+		retryDelay = 0
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.serveSOCKS5Conn(ctx, conn)
+		}()
+	}
+}
+
+// isTemporaryAcceptError tells whether err, returned by Accept, may go away by itself, as
+// [http.Server.Serve] does. A closed listener is never temporary.
+func isTemporaryAcceptError(err error) bool {
+	if errors.Is(err, net.ErrClosed) {
+		return false
+	}
+	var temporary interface{ Temporary() bool }
+	return errors.As(err, &temporary) && temporary.Temporary()
+}
+
+// serveSOCKS5Conn serves the request of a SOCKS5 client until it's done or ctx is done.
+func (c *Client) serveSOCKS5Conn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	conn.SetDeadline(time.Now().Add(socks5HandshakeTimeout))
+	cmd, address, err := readSOCKS5Request(conn)
+	if err != nil {
+		if errors.Is(err, errSOCKS5AddressType) {
+			writeSOCKS5Reply(conn, socks5ReplyAddressTypeUnsupported, nil)
+		}
+		logger().Debug("SOCKS5 handshake failed")
+		return
+	}
+	conn.SetDeadline(time.Time{})
+
+	switch cmd {
+	case socks5CmdConnect:
+		c.socks5Connect(ctx, conn, address)
+	case socks5CmdUDPAssociate:
+		c.socks5UDPAssociate(ctx, conn)
+	default:
+		writeSOCKS5Reply(conn, socks5ReplyCommandNotSupported, nil)
+	}
+}
+
+// readSOCKS5Request negotiates the authentication method with a SOCKS5 client, which must accept
+// no authentication, and reads its request. It returns the command and the destination address
+// of the request.
+func readSOCKS5Request(conn net.Conn) (byte, string, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(conn, header[:]); err != nil {
+		return 0, "", err
+	}
+	if header[0] != socks5Version {
+		return 0, "", fmt.Errorf("unsupported SOCKS version %d", header[0])
+	}
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return 0, "", err
+	}
+	if !bytes.Contains(methods, []byte{socks5MethodNoAuth}) {
+		conn.Write([]byte{socks5Version, socks5MethodNoneValid})
+		return 0, "", errors.New("client requires authentication")
+	}
+	if _, err := conn.Write([]byte{socks5Version, socks5MethodNoAuth}); err != nil {
+		return 0, "", err
+	}
+
+	// VER, CMD, RSV, followed by the address.
+	var request [3]byte
+	if _, err := io.ReadFull(conn, request[:]); err != nil {
+		return 0, "", err
+	}
+	if request[0] != socks5Version {
+		return 0, "", fmt.Errorf("unsupported SOCKS version %d", request[0])
+	}
+	address, err := readSOCKS5Address(conn)
+	if err != nil {
+		return 0, "", err
+	}
+	return request[1], address, nil
+}
+
+// readSOCKS5Address reads an address in the SOCKS5 format from r, and returns it in host:port
+// form.
+func readSOCKS5Address(r io.Reader) (string, error) {
+	var addrType [1]byte
+	if _, err := io.ReadFull(r, addrType[:]); err != nil {
+		return "", err
+	}
+	var host []byte
+	switch addrType[0] {
+	case socks5AddrIPv4:
+		host = make([]byte, net.IPv4len)
+	case socks5AddrIPv6:
+		host = make([]byte, net.IPv6len)
+	case socks5AddrDomain:
+		var length [1]byte
+		if _, err := io.ReadFull(r, length[:]); err != nil {
+			return "", err
+		}
+		host = make([]byte, length[0])
+	default:
+		return "", errSOCKS5AddressType
+	}
+	if _, err := io.ReadFull(r, host); err != nil {
+		return "", err
+	}
+	var port [2]byte
+	if _, err := io.ReadFull(r, port[:]); err != nil {
+		return "", err
+	}
+	hostText := string(host)
+	if addrType[0] != socks5AddrDomain {
+		hostText = net.IP(host).String()
+	}
+	return net.JoinHostPort(hostText, strconv.Itoa(int(binary.BigEndian.Uint16(port[:])))), nil
+}
+
+// appendSOCKS5Address appends address, in host:port form, to b in the SOCKS5 format.
+func appendSOCKS5Address(b []byte, address string) ([]byte, error) {
+	host, portText, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.ParseUint(portText, 10, 16)
+	if err != nil {
+		return nil, err
+	}
+	if ip, err := netip.ParseAddr(host); err == nil {
+		ip = ip.Unmap()
+		if ip.Is4() {
+			b = append(b, socks5AddrIPv4)
+		} else {
+			b = append(b, socks5AddrIPv6)
+		}
+		b = append(b, ip.AsSlice()...)
+	} else {
+		if len(host) > 255 {
+			return nil, fmt.Errorf("host name is too long")
+		}
+		b = append(b, socks5AddrDomain, byte(len(host)))
+		b = append(b, host...)
+	}
+	return binary.BigEndian.AppendUint16(b, uint16(port)), nil
+}
+
+// writeSOCKS5Reply sends a reply with the given code to a SOCKS5 client. bound is the address
+// the server bound for the request, or nil for none.
+func writeSOCKS5Reply(w io.Writer, code byte, bound net.Addr) error {
+	address := "0.0.0.0:0"
+	if bound != nil {
+		address = bound.String()
+	}
+	reply, err := appendSOCKS5Address([]byte{socks5Version, code, 0}, address)
+	if err != nil {
+		reply, _ = appendSOCKS5Address([]byte{socks5Version, code, 0}, "0.0.0.0:0")
+	}
+	_, err = w.Write(reply)
+	return err
+}
+
+// socks5ReplyCode returns the code of the reply to a SOCKS5 client whose request failed with
+// err.
+func socks5ReplyCode(err error) byte {
+	var perr platerrors.PlatformError
+	switch {
+	case errors.As(err, &perr) && perr.Code == platerrors.DestinationForbidden:
+		return socks5ReplyNotAllowed
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return socks5ReplyConnectionRefused
+	case errors.Is(err, syscall.EHOSTUNREACH):
+		return socks5ReplyHostUnreachable
+	case errors.Is(err, syscall.ENETUNREACH):
+		return socks5ReplyNetworkUnreachable
+	default:
+		return socks5ReplyGeneralFailure
+	}
+}
+
+// socks5Connect serves a CONNECT request to address: it connects to address through c and
+// relays the data between conn and the new connection.
+func (c *Client) socks5Connect(ctx context.Context, conn net.Conn, address string) {
+	target, err := c.DialStream(ctx, address)
+	if err != nil {
+		writeSOCKS5Reply(conn, socks5ReplyCode(err), nil)
+		return
+	}
+	defer target.Close()
+	stop := context.AfterFunc(ctx, func() { target.Close() })
+	defer stop()
+	if err := writeSOCKS5Reply(conn, socks5ReplySucceeded, target.LocalAddr()); err != nil {
+		return
+	}
+	relayStreams(conn, target)
+}
+
+// relayStreams copies the data between conn and target in both directions, passing the end of
+// the data of each side to the other, until both are done.
+func relayStreams(conn net.Conn, target transport.StreamConn) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		io.Copy(target, conn)
+		target.CloseWrite()
+	}()
+	io.Copy(conn, target)
+	closeWrite(conn)
+	<-done
+}
+
+// closeWrite shuts down the writing side of conn, or closes it if it can't be half-closed.
+func closeWrite(conn net.Conn) {
+	if halfCloser, ok := conn.(interface{ CloseWrite() error }); ok {
+		halfCloser.CloseWrite()
+		return
+	}
+	conn.Close()
+}
+
+// socks5UDPAssociate serves a UDP ASSOCIATE request: it relays the packets that the client sends
+// to a local UDP socket through c, and the packets received in return back to the client. The
+// association lasts until the client closes conn.
+func (c *Client) socks5UDPAssociate(ctx context.Context, conn net.Conn) {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	localIP, _, _ := net.SplitHostPort(conn.LocalAddr().String())
+	relay, err := net.ListenPacket("udp", net.JoinHostPort(localIP, "0"))
+	if err != nil {
+		writeSOCKS5Reply(conn, socks5ReplyGeneralFailure, nil)
+		return
+	}
+	defer relay.Close()
+	proxyConn, err := c.ListenPacket(ctx)
+	if err != nil {
+		writeSOCKS5Reply(conn, socks5ReplyGeneralFailure, nil)
+		return
+	}
+	defer proxyConn.Close()
+	if err := writeSOCKS5Reply(conn, socks5ReplySucceeded, relay.LocalAddr()); err != nil {
+		return
+	}
+
+	// The client keeps conn open for as long as it uses the association.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		io.Copy(io.Discard, conn)
+		relay.Close()
+	}()
+
+	// clientAddr is the address the client sends its packets from, once known.
+	var clientAddr atomic.Pointer[net.Addr]
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		buf := make([]byte, 65535)
+		for {
+			n, source, err := proxyConn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			client := clientAddr.Load()
+			if client == nil {
+				continue
+			}
+			packet, err := appendSOCKS5Address([]byte{0, 0, 0}, source.String())
+			if err != nil {
+				continue
+			}
+			relay.WriteTo(append(packet, buf[:n]...), *client)
+		}
+	}()
+
+	clientIP, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+	buf := make([]byte, 65535)
+	for {
+		n, source, err := relay.ReadFrom(buf)
+		if err != nil {
+			conn.Close()
+			proxyConn.Close()
+			return
+		}
+		// Only the client of the association can use it.
+		if sourceIP, _, _ := net.SplitHostPort(source.String()); sourceIP != clientIP {
+			continue
+		}
+		clientAddr.Store(&source)
+		address, payload, err := parseSOCKS5UDPPacket(buf[:n])
+		if err != nil {
+			continue
+		}
+		proxyConn.WriteTo(payload, newSOCKS5UDPAddr(address))
+	}
+}
+
+// parseSOCKS5UDPPacket returns the destination address and the payload of a packet of a UDP
+// association. Fragmented packets, which are rarely used, aren't supported.
+func parseSOCKS5UDPPacket(packet []byte) (string, []byte, error) {
+	// RSV, RSV, FRAG, followed by the address.
+	if len(packet) < 3 {
+		return "", nil, errors.New("SOCKS5 UDP packet is too short")
+	}
+	if packet[2] != 0 {
+		return "", nil, errors.New("fragmented SOCKS5 UDP packets are not supported")
+	}
+	r := bytes.NewReader(packet[3:])
+	address, err := readSOCKS5Address(r)
+	if err != nil {
+		return "", nil, err
+	}
+	return address, packet[len(packet)-r.Len():], nil
+}
+
+// newSOCKS5UDPAddr returns the [net.Addr] of address, in host:port form. Host names are left for
+// the proxy to resolve.
+func newSOCKS5UDPAddr(address string) net.Addr {
+	if addrPort, err := netip.ParseAddrPort(address); err == nil {
+		return net.UDPAddrFromAddrPort(addrPort)
+	}
+	return udpProbeAddr(address)
+}
@@ -0,0 +1,263 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/Jigsaw-Code/outline-sdk/transport"
+	"github.com/Jigsaw-Code/outline-sdk/transport/socks5"
+	"github.com/stretchr/testify/require"
+)
+
+// startSOCKS5Server serves a SOCKS5 proxy through client on a local port until the test is done,
+// and returns its address.
+func startSOCKS5Server(t *testing.T, client *Client) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- client.serveSOCKS5(ctx, listener) }()
+	t.Cleanup(func() {
+		cancel()
+		require.NoError(t, <-done)
+	})
+	return listener.Addr().String()
+}
+
+func newSOCKS5TestClient(t *testing.T, proxyAddr string) *socks5.Client {
+	client, err := socks5.NewClient(&transport.TCPEndpoint{Address: proxyAddr})
+	require.NoError(t, err)
+	client.EnablePacket(&transport.UDPDialer{})
+	return client
+}
+
+func Test_RunSOCKS5_Connect(t *testing.T) {
+	server := newTCPEchoServer(t)
+	client := newDirectTestClient()
+	proxy := newSOCKS5TestClient(t, startSOCKS5Server(t, client))
+
+	conn, err := proxy.DialStream(context.Background(), server)
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("hello"))
+	require.NoError(t, err)
+	require.NoError(t, conn.CloseWrite())
+	echo, err := io.ReadAll(conn)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(echo))
+	require.Equal(t, int64(5), client.Stats().BytesSent)
+}
+
+func Test_RunSOCKS5_ConnectForbidden(t *testing.T) {
+	client := newDirectTestClient()
+	require.Nil(t, client.SetDialPolicy([]int{443}, nil))
+	proxy := newSOCKS5TestClient(t, startSOCKS5Server(t, client))
+
+	_, err := proxy.DialStream(context.Background(), newTCPEchoServer(t))
+	var replyCode socks5.ReplyCode
+	require.ErrorAs(t, err, &replyCode)
+	require.Equal(t, socks5.ErrConnectionNotAllowedByRuleset, replyCode)
+}
+
+func Test_RunSOCKS5_ConnectRefused(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	closedAddr := listener.Addr().String()
+	listener.Close()
+	proxy := newSOCKS5TestClient(t, startSOCKS5Server(t, newDirectTestClient()))
+
+	_, err = proxy.DialStream(context.Background(), closedAddr)
+	var replyCode socks5.ReplyCode
+	require.ErrorAs(t, err, &replyCode)
+	require.Equal(t, socks5.ErrConnectionRefused, replyCode)
+}
+
+func Test_RunSOCKS5_UDPAssociate(t *testing.T) {
+	server := newUDPEchoServer(t, nil)
+	serverAddr, err := net.ResolveUDPAddr("udp", server)
+	require.NoError(t, err)
+	proxy := newSOCKS5TestClient(t, startSOCKS5Server(t, newDirectTestClient()))
+
+	conn, err := proxy.ListenPacket(context.Background())
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.SetDeadline(time.Now().Add(5*time.Second)))
+	for _, payload := range []string{"first", "second"} {
+		_, err = conn.WriteTo([]byte(payload), serverAddr)
+		require.NoError(t, err)
+		buf := make([]byte, 100)
+		n, source, err := conn.ReadFrom(buf)
+		require.NoError(t, err)
+		require.Equal(t, payload, string(buf[:n]))
+		require.Equal(t, server, source.String())
+	}
+}
+
+func Test_RunSOCKS5_RequiresNoAuth(t *testing.T) {
+	conn, err := net.Dial("tcp", startSOCKS5Server(t, newDirectTestClient()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// The client only offers username and password authentication.
+	_, err = conn.Write([]byte{5, 1, 2})
+	require.NoError(t, err)
+	reply := make([]byte, 2)
+	_, err = io.ReadFull(conn, reply)
+	require.NoError(t, err)
+	require.Equal(t, []byte{5, 0xff}, reply)
+}
+
+func Test_RunSOCKS5_UnsupportedCommand(t *testing.T) {
+	conn, err := net.Dial("tcp", startSOCKS5Server(t, newDirectTestClient()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// BIND to 127.0.0.1:80.
+	_, err = conn.Write([]byte{5, 1, 0, 5, 2, 0, 1, 127, 0, 0, 1, 0, 80})
+	require.NoError(t, err)
+	reply := make([]byte, 12)
+	_, err = io.ReadFull(conn, reply)
+	require.NoError(t, err)
+	require.Equal(t, []byte{5, 0, 5, 7}, reply[:4])
+}
+
+func Test_RunSOCKS5_StopsOnCancel(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	proxyAddr := listener.Addr().String()
+	listener.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- newDirectTestClient().RunSOCKS5(ctx, proxyAddr) }()
+
+	proxy := newSOCKS5TestClient(t, proxyAddr)
+	var conn transport.StreamConn
+	require.Eventually(t, func() bool {
+		conn, err = proxy.DialStream(context.Background(), newTCPEchoServer(t))
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	defer conn.Close()
+
+	cancel()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("RunSOCKS5 didn't stop")
+	}
+	// The relayed connection was closed.
+	_, err = conn.Read(make([]byte, 1))
+	require.Error(t, err)
+	_, err = net.Dial("tcp", proxyAddr)
+	require.Error(t, err)
+}
+
+// failingListener is a [net.Listener] whose Accept fails with the errors in errs, one per call,
+// before it accepts the connections of its listener.
+type failingListener struct {
+	net.Listener
+	errs chan error
+}
+
+func (l *failingListener) Accept() (net.Conn, error) {
+	select {
+	case err := <-l.errs:
+		return nil, err
+	default:
+		return l.Listener.Accept()
+	}
+}
+
+func newFailingListener(t *testing.T, errs ...error) *failingListener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	failing := &failingListener{Listener: listener, errs: make(chan error, len(errs))}
+	for _, err := range errs {
+		failing.errs <- err
+	}
+	return failing
+}
+
+func Test_serveSOCKS5_RetriesTemporaryErrors(t *testing.T) {
+	emfile := &net.OpError{Op: "accept", Net: "tcp", Err: os.NewSyscallError("accept", syscall.EMFILE)}
+	listener := newFailingListener(t, emfile, emfile, emfile)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- newDirectTestClient().serveSOCKS5(ctx, listener) }()
+
+	conn, err := newSOCKS5TestClient(t, listener.Addr().String()).DialStream(context.Background(), newTCPEchoServer(t))
+	require.NoError(t, err)
+	conn.Close()
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func Test_serveSOCKS5_StopsOnPermanentErrors(t *testing.T) {
+	listener := newFailingListener(t, errors.New("listener is broken"))
+	err := newDirectTestClient().serveSOCKS5(context.Background(), listener)
+	var perr platerrors.PlatformError
+	require.ErrorAs(t, err, &perr)
+	require.Equal(t, platerrors.InternalError, perr.Code)
+}
+
+func Test_RunSOCKS5_AddressInUse(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	err = newDirectTestClient().RunSOCKS5(context.Background(), listener.Addr().String())
+	var perr platerrors.PlatformError
+	require.True(t, errors.As(err, &perr))
+	require.Equal(t, platerrors.LocalAddressInUse, perr.Code)
+}
+
+func Test_RunSOCKS5_InvalidAddress(t *testing.T) {
+	err := newDirectTestClient().RunSOCKS5(context.Background(), "no-port")
+	var perr platerrors.PlatformError
+	require.True(t, errors.As(err, &perr))
+	require.Equal(t, platerrors.InvalidConfig, perr.Code)
+}
+
+func Test_ParseSOCKS5UDPPacket(t *testing.T) {
+	packet, err := appendSOCKS5Address([]byte{0, 0, 0}, "example.com:53")
+	require.NoError(t, err)
+	address, payload, err := parseSOCKS5UDPPacket(append(packet, "query"...))
+	require.NoError(t, err)
+	require.Equal(t, "example.com:53", address)
+	require.Equal(t, "query", string(payload))
+	require.Equal(t, udpProbeAddr("example.com:53"), newSOCKS5UDPAddr(address))
+
+	packet, err = appendSOCKS5Address([]byte{0, 0, 0}, "[::1]:53")
+	require.NoError(t, err)
+	address, _, err = parseSOCKS5UDPPacket(packet)
+	require.NoError(t, err)
+	require.Equal(t, "[::1]:53", address)
+	require.Equal(t, "[::1]:53", newSOCKS5UDPAddr(address).String())
+
+	_, _, err = parseSOCKS5UDPPacket([]byte{0, 0, 1, 1, 127, 0, 0, 1, 0, 53})
+	require.Error(t, err, "fragments are not supported")
+	_, _, err = parseSOCKS5UDPPacket([]byte{0, 0, 0, 9})
+	require.ErrorIs(t, err, errSOCKS5AddressType)
+}
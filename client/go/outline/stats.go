@@ -0,0 +1,106 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"net"
+	"sync/atomic"
+
+	"github.com/Jigsaw-Code/outline-sdk/transport"
+)
+
+// ClientStats holds the cumulative traffic counters of a [Client].
+type ClientStats struct {
+	BytesSent         int64 // Bytes written to tunneled connections
+	BytesReceived     int64 // Bytes read from tunneled connections
+	ConnectionsOpened int64 // Stream connections dialed plus packet connections created
+}
+
+// clientStats holds the live counters of a [Client]. The zero value is ready to use.
+type clientStats struct {
+	bytesSent         atomic.Int64
+	bytesReceived     atomic.Int64
+	connectionsOpened atomic.Int64
+}
+
+// Stats returns a snapshot of the traffic that went through the [Client] since it was created
+// or since the last call to [Client.ResetStats].
+func (c *Client) Stats() *ClientStats {
+	return &ClientStats{
+		BytesSent:         c.stats.bytesSent.Load(),
+		BytesReceived:     c.stats.bytesReceived.Load(),
+		ConnectionsOpened: c.stats.connectionsOpened.Load(),
+	}
+}
+
+// ResetStats sets all the traffic counters of the [Client] to zero.
+func (c *Client) ResetStats() {
+	c.stats.bytesSent.Store(0)
+	c.stats.bytesReceived.Store(0)
+	c.stats.connectionsOpened.Store(0)
+}
+
+// copyFrom sets the counters of s to the values of the counters of other.
+func (s *clientStats) copyFrom(other *clientStats) {
+	s.bytesSent.Store(other.bytesSent.Load())
+	s.bytesReceived.Store(other.bytesReceived.Load())
+	s.connectionsOpened.Store(other.connectionsOpened.Load())
+}
+
+func (s *clientStats) wrapStreamConn(conn transport.StreamConn) transport.StreamConn {
+	s.connectionsOpened.Add(1)
+	return &countingStreamConn{StreamConn: conn, stats: s}
+}
+
+func (s *clientStats) wrapPacketConn(conn net.PacketConn) net.PacketConn {
+	s.connectionsOpened.Add(1)
+	return &countingPacketConn{PacketConn: conn, stats: s}
+}
+
+// countingStreamConn is a [transport.StreamConn] that counts the bytes read and written.
+type countingStreamConn struct {
+	transport.StreamConn
+	stats *clientStats
+}
+
+func (c *countingStreamConn) Read(b []byte) (int, error) {
+	n, err := c.StreamConn.Read(b)
+	c.stats.bytesReceived.Add(int64(n))
+	return n, err
+}
+
+func (c *countingStreamConn) Write(b []byte) (int, error) {
+	n, err := c.StreamConn.Write(b)
+	c.stats.bytesSent.Add(int64(n))
+	return n, err
+}
+
+// countingPacketConn is a [net.PacketConn] that counts the bytes read and written.
+type countingPacketConn struct {
+	net.PacketConn
+	stats *clientStats
+}
+
+func (c *countingPacketConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	n, addr, err := c.PacketConn.ReadFrom(p)
+	c.stats.bytesReceived.Add(int64(n))
+	return n, addr, err
+}
+
+func (c *countingPacketConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	n, err := c.PacketConn.WriteTo(p, addr)
+	c.stats.bytesSent.Add(int64(n))
+	return n, err
+}
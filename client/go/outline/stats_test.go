@@ -0,0 +1,186 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTCPEchoServer starts a TCP server that echoes all data back and returns its address.
+func newTCPEchoServer(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func Test_Stats_Stream(t *testing.T) {
+	server := newTCPEchoServer(t)
+	client := newDirectTestClient()
+
+	const numConns = 10
+	var wg sync.WaitGroup
+	for i := 0; i < numConns; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn, err := client.DialStream(context.Background(), server)
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer conn.Close()
+			_, err = conn.Write([]byte("hello"))
+			assert.NoError(t, err)
+			_, err = io.ReadFull(conn, make([]byte, 5))
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, &ClientStats{
+		BytesSent:         5 * numConns,
+		BytesReceived:     5 * numConns,
+		ConnectionsOpened: numConns,
+	}, client.Stats())
+}
+
+func Test_Stats_Packet(t *testing.T) {
+	server, err := net.ResolveUDPAddr("udp", newUDPEchoServer(t, nil))
+	require.NoError(t, err)
+	client := newDirectTestClient()
+
+	conn, err := client.ListenPacket(context.Background())
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.WriteTo([]byte("ping"), server)
+	require.NoError(t, err)
+	_, _, err = conn.ReadFrom(make([]byte, 10))
+	require.NoError(t, err)
+
+	require.Equal(t, &ClientStats{BytesSent: 4, BytesReceived: 4, ConnectionsOpened: 1}, client.Stats())
+}
+
+func Test_ResetStats(t *testing.T) {
+	server := newTCPEchoServer(t)
+	client := newDirectTestClient()
+
+	conn, err := client.DialStream(context.Background(), server)
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("hello"))
+	require.NoError(t, err)
+
+	client.ResetStats()
+	require.Equal(t, &ClientStats{}, client.Stats())
+
+	_, err = conn.Write([]byte("hi"))
+	require.NoError(t, err)
+	require.Equal(t, int64(2), client.Stats().BytesSent)
+}
+
+func Test_Client_WithConfig_CarriesOverStats(t *testing.T) {
+	server := newTCPEchoServer(t)
+	client := newDirectTestClient()
+	client.SetDialTimeout(5 * time.Second)
+
+	conn, err := client.DialStream(context.Background(), server)
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("hello"))
+	require.NoError(t, err)
+
+	result := client.WithConfig("ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpTRUNSRVQ@example.com:4321/")
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.Equal(t, "example.com:4321", result.Client.sd.FirstHop)
+	require.Equal(t, &ClientStats{BytesSent: 5, ConnectionsOpened: 1}, result.Client.Stats())
+	require.Equal(t, 5*time.Second, result.Client.getDialTimeout())
+
+	// Later traffic of the old client is not counted by the new one.
+	_, err = conn.Write([]byte("hi"))
+	require.NoError(t, err)
+	require.Equal(t, int64(7), client.Stats().BytesSent)
+	require.Equal(t, int64(5), result.Client.Stats().BytesSent)
+}
+
+func Test_Client_WithConfig_CarriesOverSettings(t *testing.T) {
+	result := NewClientWithOptions(`
+transport:
+  $type: tcpudp
+  tcp: ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpTRUNSRVQ@example.com:4321/
+  udp:`, &NewClientOptions{AllowDirectUDP: true})
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	client := result.Client
+	require.Nil(t, client.SetDialPolicy([]int{443}, []string{"blocked.example.com"}))
+	require.Nil(t, client.SetConnectionPool(2, time.Minute))
+	recorder := make(stateRecorder, 1)
+	client.SetConnectionStateListener(recorder)
+
+	// The new config only works with the options of client, since it doesn't tunnel UDP.
+	result = client.WithConfig(`
+transport:
+  $type: tcpudp
+  tcp: ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpTRUNSRVQ@example.org:4321/
+  udp:`)
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	newClient := result.Client
+	defer newClient.SetConnectionPool(0, 0)
+	require.Equal(t, ConnTypeDirect, newClient.PacketConnType())
+
+	var perr platerrors.PlatformError
+	_, err := newClient.DialStream(context.Background(), "example.com:80")
+	require.ErrorAs(t, err, &perr)
+	require.Equal(t, platerrors.DestinationForbidden, perr.Code)
+	_, err = newClient.DialStream(context.Background(), "blocked.example.com:443")
+	require.ErrorAs(t, err, &perr)
+	require.Equal(t, platerrors.DestinationForbidden, perr.Code)
+
+	pool := newClient.pool.Load()
+	require.NotNil(t, pool)
+	require.Equal(t, 2, pool.maxIdlePerHost)
+	require.Equal(t, time.Minute, pool.idleTimeout)
+	require.NotSame(t, client.pool.Load(), pool)
+	client.SetConnectionPool(0, 0)
+
+	newClient.connectionState.update(ConnectionStateHealthy)
+	require.Equal(t, [2]string{ConnectionStateUnknown, ConnectionStateHealthy}, recorder.next(t))
+}
+
+func Test_Client_WithConfig_InvalidConfig(t *testing.T) {
+	client := newDirectTestClient()
+	result := client.WithConfig("ss://invalid")
+	require.NotNil(t, result.Error)
+	require.Nil(t, result.Client)
+}
@@ -0,0 +1,149 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/Jigsaw-Code/outline-sdk/transport"
+)
+
+// TCPOptions tunes the TCP connections that a client makes to the proxy, or to the destinations
+// if TCP is not tunneled. Interactive apps may want NoDelay, and long transfers keepalives.
+type TCPOptions struct {
+	// KeepAliveSeconds is the interval of the TCP keepalive probes. Zero disables them.
+	KeepAliveSeconds int
+	// NoDelay sends small writes right away instead of coalescing them (TCP_NODELAY).
+	NoDelay bool
+	// ConnectTimeoutSeconds limits how long establishing a connection may take. Zero means no
+	// limit besides the deadline of the dial and the timeout of [Client.SetDialTimeout].
+	ConnectTimeoutSeconds int
+	// HappyEyeballs races the connection attempts to the IPv6 and IPv4 addresses of host names
+	// (Happy Eyeballs v2, RFC 8305), and keeps the first that connects. It avoids long stalls
+	// when one of the address families is blocked. See [newHappyEyeballsDialer].
+	HappyEyeballs bool
+	// LocalAddress is the IP address the connections originate from, for devices with several
+	// interfaces or addresses. The system picks the port. Empty lets the system pick the address,
+	// and connections to destinations of the other IP family fail.
+	LocalAddress string
+}
+
+// DefaultTCPOptions returns the options of the clients created by [NewClient]: no keepalives,
+// NoDelay, no connect timeout, no Happy Eyeballs and no local address. Callers can tune them and
+// pass them to [NewClientWithTCPOptions].
+func DefaultTCPOptions() *TCPOptions {
+	return &TCPOptions{NoDelay: true}
+}
+
+// NewClientWithTCPOptions is like [NewClient], but configures the TCP connections of the client
+// with options. Nil options mean [DefaultTCPOptions]. Negative values and malformed local
+// addresses fail with [platerrors.InvalidConfig], as do local addresses that don't belong to this
+// device. Local addresses that can't be bound to for lack of permission fail with
+// [platerrors.LocalAddressPermissionDenied].
+func NewClientWithTCPOptions(clientConfig string, options *TCPOptions) *NewClientResult {
+	tcpDialer, err := newBaseTCPDialer(options)
+	if err != nil {
+		return newClientResult(nil, err)
+	}
+	var baseDialer transport.StreamDialer = tcpDialer
+	if options != nil && options.HappyEyeballs {
+		baseDialer = newHappyEyeballsDialer(tcpDialer, net.DefaultResolver.LookupNetIP)
+	}
+	udpDialer := transport.UDPDialer{}
+	return newClientResult(NewClientWithBaseDialers(clientConfig, baseDialer, &udpDialer))
+}
+
+// newBaseTCPDialer returns the base TCP dialer of a client configured with options.
+func newBaseTCPDialer(options *TCPOptions) (*tcpOptionsDialer, error) {
+	if options == nil {
+		options = DefaultTCPOptions()
+	}
+	if options.KeepAliveSeconds < 0 || options.ConnectTimeoutSeconds < 0 {
+		return nil, platerrors.PlatformError{
+			Code:    platerrors.InvalidConfig,
+			Message: "TCP keepalive and connect timeout must not be negative",
+			Details: platerrors.ErrorDetails{
+				"keepAliveSeconds":      options.KeepAliveSeconds,
+				"connectTimeoutSeconds": options.ConnectTimeoutSeconds,
+			},
+		}
+	}
+	dialer := &tcpOptionsDialer{
+		TCPDialer: transport.TCPDialer{Dialer: net.Dialer{
+			KeepAlive: -1,
+			Timeout:   time.Duration(options.ConnectTimeoutSeconds) * time.Second,
+		}},
+		noDelay: options.NoDelay,
+	}
+	if options.KeepAliveSeconds > 0 {
+		dialer.Dialer.KeepAlive = time.Duration(options.KeepAliveSeconds) * time.Second
+	}
+	if options.LocalAddress != "" {
+		localAddr, err := parseLocalTCPAddress(options.LocalAddress)
+		if err != nil {
+			return nil, err
+		}
+		dialer.Dialer.LocalAddr = localAddr
+	}
+	return dialer, nil
+}
+
+// parseLocalTCPAddress parses [TCPOptions.LocalAddress] and checks that it can be bound to, so
+// that a wrong address fails when the client is created rather than on each dial.
+func parseLocalTCPAddress(localAddress string) (*net.TCPAddr, error) {
+	ip, err := netip.ParseAddr(localAddress)
+	if err != nil {
+		return nil, platerrors.PlatformError{
+			Code:    platerrors.InvalidConfig,
+			Message: "local TCP address must be an IP address",
+			Details: platerrors.ErrorDetails{"address": localAddress},
+			Cause:   platerrors.ToPlatformError(err),
+		}
+	}
+	localAddr := net.TCPAddrFromAddrPort(netip.AddrPortFrom(ip, 0))
+	listener, err := net.ListenTCP("tcp", localAddr)
+	if err != nil {
+		return nil, listenError(err, localAddress)
+	}
+	listener.Close()
+	return localAddr, nil
+}
+
+// tcpOptionsDialer is a [transport.TCPDialer] that also sets TCP_NODELAY, which [net.Dialer]
+// always enables, on its connections.
+type tcpOptionsDialer struct {
+	transport.TCPDialer
+	noDelay bool
+}
+
+var _ transport.StreamDialer = (*tcpOptionsDialer)(nil)
+
+func (d *tcpOptionsDialer) DialStream(ctx context.Context, raddr string) (transport.StreamConn, error) {
+	conn, err := d.TCPDialer.DialStream(ctx, raddr)
+	if err != nil {
+		return nil, err
+	}
+	if tcpConn, ok := conn.(*net.TCPConn); ok && !d.noDelay {
+		if err := tcpConn.SetNoDelay(false); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
@@ -0,0 +1,137 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_newBaseTCPDialer_Defaults(t *testing.T) {
+	// The defaults are the dialer NewClient always used.
+	for _, options := range []*TCPOptions{nil, DefaultTCPOptions()} {
+		dialer, err := newBaseTCPDialer(options)
+		require.NoError(t, err)
+		require.Equal(t, time.Duration(-1), dialer.Dialer.KeepAlive)
+		require.Zero(t, dialer.Dialer.Timeout)
+		require.True(t, dialer.noDelay)
+	}
+}
+
+func Test_newBaseTCPDialer_Options(t *testing.T) {
+	dialer, err := newBaseTCPDialer(&TCPOptions{KeepAliveSeconds: 30, ConnectTimeoutSeconds: 5})
+	require.NoError(t, err)
+	require.Equal(t, 30*time.Second, dialer.Dialer.KeepAlive)
+	require.Equal(t, 5*time.Second, dialer.Dialer.Timeout)
+	require.False(t, dialer.noDelay)
+}
+
+func Test_newBaseTCPDialer_Invalid(t *testing.T) {
+	for _, options := range []*TCPOptions{{KeepAliveSeconds: -1}, {ConnectTimeoutSeconds: -1}} {
+		_, err := newBaseTCPDialer(options)
+		perr := platerrors.ToPlatformError(err)
+		require.NotNil(t, perr)
+		require.Equal(t, platerrors.InvalidConfig, perr.Code)
+	}
+}
+
+func Test_NewClientWithTCPOptions(t *testing.T) {
+	const config = "transport: ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpTRUNSRVQ@example.com:4321/"
+	result := NewClientWithTCPOptions(config, &TCPOptions{KeepAliveSeconds: 15, NoDelay: true})
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.Equal(t, ConnTypeTunneled, result.StreamConnType)
+
+	result = NewClientWithTCPOptions(config, &TCPOptions{ConnectTimeoutSeconds: -5})
+	require.NotNil(t, result.Error)
+	require.Equal(t, platerrors.InvalidConfig, result.Error.Code)
+	require.Nil(t, result.Client)
+}
+
+func Test_tcpOptionsDialer_Dials(t *testing.T) {
+	server := newTCPEchoServer(t)
+	for _, options := range []*TCPOptions{DefaultTCPOptions(), {KeepAliveSeconds: 1, ConnectTimeoutSeconds: 1}} {
+		dialer, err := newBaseTCPDialer(options)
+		require.NoError(t, err)
+		conn, err := dialer.DialStream(context.Background(), server)
+		require.NoError(t, err)
+		_, err = conn.Write([]byte("ping"))
+		require.NoError(t, err)
+		require.NoError(t, conn.Close())
+	}
+
+	dialer, err := newBaseTCPDialer(nil)
+	require.NoError(t, err)
+	_, err = dialer.DialStream(context.Background(), "127.0.0.1:1")
+	require.Error(t, err)
+}
+
+func Test_newBaseTCPDialer_LocalAddress(t *testing.T) {
+	// Linux assigns the whole 127.0.0.0/8 block to the loopback interface.
+	const localIP = "127.0.0.2"
+	dialer, err := newBaseTCPDialer(&TCPOptions{LocalAddress: localIP})
+	if err != nil {
+		t.Skipf("%v is not assigned to this device: %v", localIP, err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	accepted := make(chan net.Addr, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		accepted <- conn.RemoteAddr()
+		conn.Close()
+	}()
+
+	conn, err := dialer.DialStream(context.Background(), listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Equal(t, localIP, conn.LocalAddr().(*net.TCPAddr).IP.String())
+	select {
+	case remote := <-accepted:
+		require.Equal(t, localIP, remote.(*net.TCPAddr).IP.String())
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "connection not accepted")
+	}
+}
+
+func Test_newBaseTCPDialer_InvalidLocalAddress(t *testing.T) {
+	tests := []struct {
+		localAddress string
+		wantMessage  string
+	}{
+		{"not an IP", "local TCP address must be an IP address"},
+		{"127.0.0.1:80", "local TCP address must be an IP address"},
+		// TEST-NET-1 addresses don't belong to any device.
+		{"192.0.2.1", "local address does not belong to this device"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.localAddress, func(t *testing.T) {
+			_, err := newBaseTCPDialer(&TCPOptions{LocalAddress: tt.localAddress})
+			var perr platerrors.PlatformError
+			require.ErrorAs(t, err, &perr)
+			require.Equal(t, platerrors.InvalidConfig, perr.Code)
+			require.Equal(t, tt.wantMessage, perr.Message)
+			require.Equal(t, tt.localAddress, perr.Details["address"])
+		})
+	}
+}
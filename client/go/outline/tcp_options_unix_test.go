@@ -0,0 +1,51 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unix
+
+package outline
+
+import (
+	"context"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// socketNoDelay returns whether TCP_NODELAY is set on conn.
+func socketNoDelay(t *testing.T, conn *net.TCPConn) bool {
+	rawConn, err := conn.SyscallConn()
+	require.NoError(t, err)
+	var value int
+	var sockErr error
+	require.NoError(t, rawConn.Control(func(fd uintptr) {
+		value, sockErr = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_NODELAY)
+	}))
+	require.NoError(t, sockErr)
+	return value != 0
+}
+
+func Test_tcpOptionsDialer_NoDelay(t *testing.T) {
+	server := newTCPEchoServer(t)
+	for _, noDelay := range []bool{true, false} {
+		dialer, err := newBaseTCPDialer(&TCPOptions{NoDelay: noDelay})
+		require.NoError(t, err)
+		conn, err := dialer.DialStream(context.Background(), server)
+		require.NoError(t, err)
+		require.Equal(t, noDelay, socketNoDelay(t, conn.(*net.TCPConn)))
+		conn.Close()
+	}
+}
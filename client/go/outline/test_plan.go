@@ -0,0 +1,92 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"time"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/connectivity"
+)
+
+// TestPlan is what [PerformComprehensiveTest] would do, as reported by [PlanComprehensiveTest].
+// Durations are in milliseconds.
+type TestPlan struct {
+	// ServerAddress is the first hop of the client, in host:port form, or empty if unknown.
+	ServerAddress string
+
+	// TCPProbeAddress and UDPProbeAddress are the destinations of the connectivity checks.
+	TCPProbeAddress, UDPProbeAddress string
+	// ConnectivityTimeoutMs is the time budget of each of the TCP and UDP checks.
+	ConnectivityTimeoutMs int64
+
+	// LatencyURL, DownloadURL and UploadURL are the endpoints of the bandwidth test phases.
+	LatencyURL, DownloadURL, UploadURL string
+	// BaselineURL is fetched when a bandwidth test phase fails, to diagnose the failure.
+	BaselineURL string
+	// UploadProtocol is how the upload phase sends its data, one of the UploadProtocol* values.
+	UploadProtocol string
+	// TransferDurationMs and WarmupMs are the measurement and warm-up times of each of the
+	// download and upload phases.
+	TransferDurationMs, WarmupMs int64
+	// Parallel tells whether the download and upload phases run at the same time.
+	Parallel bool
+	// MaxBytes caps the data of each of the download and upload phases, or is zero for no cap.
+	MaxBytes int64
+	// BandwidthTimeoutMs is the time budget of the bandwidth test, which only runs if the TCP
+	// check passes.
+	BandwidthTimeoutMs int64
+	// TotalTimeoutMs is the time budget of the whole test.
+	TotalTimeoutMs int64
+}
+
+// PlanComprehensiveTest is a dry run of [PerformComprehensiveTest]: it returns the endpoints and
+// time budgets the test would use with client, resolved against the defaults, without sending
+// anything. It helps check the test configuration before spending data on it.
+func PlanComprehensiveTest(client *Client) *TestPlan {
+	return newTestPlan(client, NewBandwidthTestConfig())
+}
+
+// newTestPlan returns the plan of a comprehensive test of client whose bandwidth phase uses cfg.
+func newTestPlan(client *Client, cfg *BandwidthTestConfig) *TestPlan {
+	durationSeconds := cfg.DurationSeconds
+	if durationSeconds == 0 {
+		durationSeconds = defaultDurationSeconds
+	}
+	baselineURL := cfg.BaselineURL
+	if baselineURL == "" {
+		baselineURL = fallbackProbeURL
+	}
+	uploadProtocol := cfg.UploadProtocol
+	if uploadProtocol == "" {
+		uploadProtocol = UploadProtocolChunked
+	}
+	return &TestPlan{
+		ServerAddress:         client.transportClient().sd.FirstHop,
+		TCPProbeAddress:       connectivity.DefaultTCPProbeAddress,
+		UDPProbeAddress:       connectivity.DefaultUDPProbeAddress,
+		ConnectivityTimeoutMs: defaultConnectivityTimeout.Milliseconds(),
+		LatencyURL:            cfg.LatencyURL,
+		DownloadURL:           cfg.DownloadURL,
+		UploadURL:             cfg.UploadURL,
+		BaselineURL:           baselineURL,
+		UploadProtocol:        uploadProtocol,
+		TransferDurationMs:    (time.Duration(durationSeconds) * time.Second).Milliseconds(),
+		WarmupMs:              (time.Duration(cfg.WarmupSeconds) * time.Second).Milliseconds(),
+		Parallel:              cfg.Parallel,
+		MaxBytes:              cfg.MaxBytes,
+		BandwidthTimeoutMs:    comprehensiveBandwidthTimeout.Milliseconds(),
+		TotalTimeoutMs:        defaultComprehensiveTestTimeout.Milliseconds(),
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"testing"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/connectivity"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanComprehensiveTest(t *testing.T) {
+	client := newUnreachableTestClient("proxy.example.com:443")
+
+	plan := PlanComprehensiveTest(client)
+	require.Equal(t, "proxy.example.com:443", plan.ServerAddress)
+	require.Equal(t, connectivity.DefaultTCPProbeAddress, plan.TCPProbeAddress)
+	require.Equal(t, connectivity.DefaultUDPProbeAddress, plan.UDPProbeAddress)
+	require.Equal(t, int64(10_000), plan.ConnectivityTimeoutMs)
+	require.Equal(t, defaultLatencyURL, plan.LatencyURL)
+	require.Equal(t, defaultDownloadURL, plan.DownloadURL)
+	require.Equal(t, defaultUploadURL, plan.UploadURL)
+	require.Equal(t, fallbackProbeURL, plan.BaselineURL)
+	require.Equal(t, UploadProtocolCloudflare, plan.UploadProtocol)
+	require.Equal(t, int64(10_000), plan.TransferDurationMs)
+	require.Zero(t, plan.WarmupMs)
+	require.False(t, plan.Parallel)
+	require.Equal(t, int64(30_000), plan.BandwidthTimeoutMs)
+	require.Equal(t, int64(40_000), plan.TotalTimeoutMs)
+	// Nothing was dialed.
+	require.Zero(t, client.Stats().BytesSent)
+}
+
+func TestNewTestPlan_ResolvesDefaults(t *testing.T) {
+	cfg := &BandwidthTestConfig{
+		DownloadURL:   "https://speed.example.com/down",
+		UploadURL:     "https://speed.example.com/up",
+		LatencyURL:    "https://speed.example.com/ping",
+		WarmupSeconds: 2,
+		Parallel:      true,
+		MaxBytes:      1 << 20,
+	}
+
+	plan := newTestPlan(newDirectTestClient(), cfg)
+	require.Equal(t, "https://speed.example.com/down", plan.DownloadURL)
+	require.Equal(t, "https://speed.example.com/up", plan.UploadURL)
+	require.Equal(t, "https://speed.example.com/ping", plan.LatencyURL)
+	require.Equal(t, fallbackProbeURL, plan.BaselineURL)
+	require.Equal(t, UploadProtocolChunked, plan.UploadProtocol)
+	require.Equal(t, int64(defaultDurationSeconds*1000), plan.TransferDurationMs)
+	require.Equal(t, int64(2000), plan.WarmupMs)
+	require.True(t, plan.Parallel)
+	require.Equal(t, int64(1<<20), plan.MaxBytes)
+}
@@ -0,0 +1,67 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"crypto/tls"
+	"errors"
+	"sync"
+)
+
+// TLSHandshakeInfo describes the TLS handshake that a transport made with its server.
+type TLSHandshakeInfo struct {
+	// ServerName is the name the certificate of the server was checked against.
+	ServerName string
+	// Version and CipherSuite are the negotiated parameters, such as "TLS 1.3" and
+	// "TLS_AES_128_GCM_SHA256". They are empty if the handshake failed.
+	Version     string
+	CipherSuite string
+	// CertificateError is why the certificate of the server was rejected, or empty. A rejected
+	// certificate may mean that something in the network intercepts the connection.
+	CertificateError string
+	// HandshakeError is why the handshake failed for other reasons, or empty.
+	HandshakeError string
+}
+
+// tlsHandshakeRecorder keeps the last TLS handshake reported to its observe method, which is a
+// [config.TLSHandshakeObserver].
+type tlsHandshakeRecorder struct {
+	mu   sync.Mutex
+	last *TLSHandshakeInfo
+}
+
+func (r *tlsHandshakeRecorder) observe(serverName string, state *tls.ConnectionState, err error) {
+	info := &TLSHandshakeInfo{ServerName: serverName}
+	var certErr *tls.CertificateVerificationError
+	switch {
+	case errors.As(err, &certErr):
+		info.CertificateError = certErr.Err.Error()
+	case err != nil:
+		info.HandshakeError = err.Error()
+	default:
+		info.Version = tls.VersionName(state.Version)
+		info.CipherSuite = tls.CipherSuiteName(state.CipherSuite)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.last = info
+}
+
+// info returns the last handshake reported, or nil if there was none.
+func (r *tlsHandshakeRecorder) info() *TLSHandshakeInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.last
+}
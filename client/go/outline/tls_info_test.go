@@ -0,0 +1,81 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_tlsHandshakeRecorder(t *testing.T) {
+	recorder := &tlsHandshakeRecorder{}
+	require.Nil(t, recorder.info())
+
+	recorder.observe("example.com", &tls.ConnectionState{Version: tls.VersionTLS13, CipherSuite: tls.TLS_AES_128_GCM_SHA256}, nil)
+	require.Equal(t, &TLSHandshakeInfo{
+		ServerName:  "example.com",
+		Version:     "TLS 1.3",
+		CipherSuite: "TLS_AES_128_GCM_SHA256",
+	}, recorder.info())
+
+	recorder.observe("example.com", nil, &tls.CertificateVerificationError{Err: x509.UnknownAuthorityError{}})
+	require.Equal(t, "example.com", recorder.info().ServerName)
+	require.Equal(t, "x509: certificate signed by unknown authority", recorder.info().CertificateError)
+	require.Empty(t, recorder.info().Version)
+
+	recorder.observe("example.com", nil, errors.New("connection reset"))
+	require.Equal(t, &TLSHandshakeInfo{ServerName: "example.com", HandshakeError: "connection reset"}, recorder.info())
+}
+
+func Test_CheckTCPAndUDPConnectivity_TLSInfo(t *testing.T) {
+	// The certificate of the server is not trusted, as with a TLS-intercepting middlebox.
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+	wsURL := "wss://" + strings.TrimPrefix(server.URL, "https://")
+	result := NewClient(`
+transport:
+  $type: tcpudp
+  tcp:
+    $type: shadowsocks
+    endpoint: {$type: websocket, url: "` + wsURL + `/tcp"}
+    cipher: chacha20-ietf-poly1305
+    secret: SECRET
+  udp:
+    $type: shadowsocks
+    endpoint: 127.0.0.1:1
+    cipher: chacha20-ietf-poly1305
+    secret: SECRET`)
+	require.Nil(t, result.Error, "Got %v", result.Error)
+
+	connectivity := CheckTCPAndUDPConnectivityWithTimeout(result.Client, 2*time.Second)
+	require.NotNil(t, connectivity.TCPError)
+	require.NotNil(t, connectivity.TLSInfo)
+	require.Equal(t, "127.0.0.1", connectivity.TLSInfo.ServerName)
+	require.NotEmpty(t, connectivity.TLSInfo.CertificateError)
+}
+
+func Test_CheckTCPAndUDPConnectivity_NoTLSInfo(t *testing.T) {
+	client := newUnreachableTestClient("127.0.0.1:4321")
+	connectivity := CheckTCPAndUDPConnectivityWithTimeout(client, time.Second)
+	require.Nil(t, connectivity.TLSInfo)
+}
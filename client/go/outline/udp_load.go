@@ -0,0 +1,221 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+)
+
+// udpLoadDiscardPort is the port of load addresses given without one, the discard service.
+const udpLoadDiscardPort = "9"
+
+// UDPLoadedLatencyResult is the result of [Client.TestUDPLoadedLatency].
+//
+// We use a struct instead of a tuple to preserve a strongly typed error that gobind recognizes.
+type UDPLoadedLatencyResult struct {
+	IdleLatencyMs     float64 // Average round-trip time of the probes with no load
+	IdleLossPercent   float64 // Percentage of the probes with no load that got no reply
+	LoadedLatencyMs   float64 // Average round-trip time of the probes under load
+	LoadedJitterMs    float64 // Mean absolute deviation of the round-trip times under load
+	LoadedLossPercent float64 // Percentage of the probes under load that got no reply
+	LatencyIncreaseMs float64 // How much the load increases the round-trip time, or zero
+	LoadKBps          int64   // Rate at which the load was sent, in KB/s
+	Error             *platerrors.PlatformError
+}
+
+// udpLoadTest is the setup of a UDP loaded latency test.
+type udpLoadTest struct {
+	// idleProbes is the number of probes with no load.
+	idleProbes int
+	// loadDuration is how long the load is sent.
+	loadDuration time.Duration
+	// loadWarmup is the time the load takes to fill the link, before the probes under load start.
+	loadWarmup time.Duration
+	// probeInterval is the time between the starts of consecutive probes under load.
+	probeInterval time.Duration
+	// packetSize is the size of the load packets, which fits in the MTU of most links with the
+	// overhead of the tunnel.
+	packetSize int
+}
+
+// defaultUDPLoadTest is the setup of [Client.TestUDPLoadedLatency].
+var defaultUDPLoadTest = udpLoadTest{
+	idleProbes:    5,
+	loadDuration:  10 * time.Second,
+	loadWarmup:    time.Second,
+	probeInterval: 200 * time.Millisecond,
+	packetSize:    1200,
+}
+
+// TestUDPLoadedLatency measures the UDP latency and loss through the proxy while UDP traffic
+// saturates the tunnel, which is what gamers and callers experience while other apps stream or
+// download over UDP. It's the UDP counterpart of [Client.TestBufferbloat].
+//
+// The probes are DNS queries to the resolver at host, which may include a port (default 53), as
+// in [Client.TestUDPQuality]. The load is a stream of packets sent as fast as the tunnel takes
+// them to loadAddress, which may include a port (default 9, the discard service), for 10
+// seconds. loadAddress should be a server that accepts the traffic, such as a discard or echo
+// server of the caller, never a third party. Echoed packets load the downlink too. The test takes
+// about 15 seconds.
+//
+// Probes under load that get no reply are counted as lost, but don't fail the test. The test
+// fails with [platerrors.ProxyServerUDPUnsupported] if no probe with no load gets a reply, and
+// with [platerrors.OperationCanceled] if ctx is canceled.
+func (c *Client) TestUDPLoadedLatency(ctx context.Context, host string, loadAddress string) *UDPLoadedLatencyResult {
+	result := c.testUDPLoadedLatency(ctx, host, loadAddress, defaultUDPLoadTest)
+	logger().Info("UDP loaded latency tested", "loadedLatencyMs", result.LoadedLatencyMs, "error", errorCode(result.Error))
+	return result
+}
+
+// testUDPLoadedLatency implements [Client.TestUDPLoadedLatency] with the given setup.
+func (c *Client) testUDPLoadedLatency(ctx context.Context, host string, loadAddress string, test udpLoadTest) *UDPLoadedLatencyResult {
+	probeDest, err := newUDPProbeAddr(host, udpProbePort)
+	if err != nil {
+		return &UDPLoadedLatencyResult{Error: platerrors.ToPlatformError(err)}
+	}
+	loadDest, err := newUDPProbeAddr(loadAddress, udpLoadDiscardPort)
+	if err != nil {
+		return &UDPLoadedLatencyResult{Error: platerrors.ToPlatformError(err)}
+	}
+	// The probes and the load use different sockets, so that the replies to the probes don't
+	// queue behind the echoed load in the client.
+	probeConn, err := c.ListenPacket(ctx)
+	if err != nil {
+		return &UDPLoadedLatencyResult{Error: udpListenError(err)}
+	}
+	defer probeConn.Close()
+	// Cancellation interrupts the probe in progress.
+	stop := context.AfterFunc(ctx, func() { probeConn.SetDeadline(time.Now()) })
+	defer stop()
+
+	buf := make([]byte, 512)
+	var id uint16
+	probe := func(ctx context.Context) (time.Duration, bool) {
+		id++
+		return probeUDP(ctx, probeConn, probeDest, id, buf)
+	}
+
+	var idle []time.Duration
+	idleSent := 0
+	for ; idleSent < test.idleProbes && ctx.Err() == nil; idleSent++ {
+		if rtt, ok := probe(ctx); ok {
+			idle = append(idle, rtt)
+		}
+	}
+	if ctx.Err() != nil {
+		return &UDPLoadedLatencyResult{Error: udpLoadCanceledError()}
+	}
+	if len(idle) == 0 {
+		return &UDPLoadedLatencyResult{Error: &platerrors.PlatformError{
+			Code:    platerrors.ProxyServerUDPUnsupported,
+			Message: "no UDP replies received",
+			Details: platerrors.ErrorDetails{"sent": idleSent},
+		}}
+	}
+
+	loadConn, err := c.ListenPacket(ctx)
+	if err != nil {
+		return &UDPLoadedLatencyResult{Error: udpListenError(err)}
+	}
+	loadCtx, stopLoad := context.WithTimeout(ctx, test.loadDuration)
+	defer stopLoad()
+	var loadBytes atomic.Int64
+	var wg sync.WaitGroup
+	wg.Add(2)
+	loadStart := time.Now()
+	go func() {
+		defer wg.Done()
+		packet := make([]byte, test.packetSize)
+		for loadCtx.Err() == nil {
+			n, err := loadConn.WriteTo(packet, loadDest)
+			loadBytes.Add(int64(n))
+			if err != nil {
+				// Packets are dropped when the buffers are full, which is expected under load.
+				// Back off a little, in case the error persists.
+				select {
+				case <-time.After(time.Millisecond):
+				case <-loadCtx.Done():
+				}
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		// Drain the echoed packets, if any, until the load conn is closed.
+		drain := make([]byte, test.packetSize)
+		for {
+			if _, _, err := loadConn.ReadFrom(drain); err != nil {
+				return
+			}
+		}
+	}()
+	stopDrain := context.AfterFunc(loadCtx, func() { loadConn.Close() })
+	defer stopDrain()
+
+	var loaded []time.Duration
+	loadedSent := 0
+	select {
+	case <-time.After(test.loadWarmup):
+	case <-loadCtx.Done():
+	}
+	for loadCtx.Err() == nil {
+		next := time.Now().Add(test.probeInterval)
+		rtt, ok := probe(loadCtx)
+		if loadCtx.Err() != nil {
+			// The load ended during the probe, so it's not a sample.
+			break
+		}
+		loadedSent++
+		if ok {
+			loaded = append(loaded, rtt)
+		}
+		select {
+		case <-time.After(time.Until(next)):
+		case <-loadCtx.Done():
+		}
+	}
+	stopLoad()
+	wg.Wait()
+	loadTime := time.Since(loadStart)
+
+	if ctx.Err() != nil {
+		return &UDPLoadedLatencyResult{Error: udpLoadCanceledError()}
+	}
+	idleSummary := summarizeUDPProbes(idle, idleSent)
+	loadedSummary := summarizeUDPProbes(loaded, loadedSent)
+	result := &UDPLoadedLatencyResult{
+		IdleLatencyMs:     idleSummary.AverageLatencyMs,
+		IdleLossPercent:   idleSummary.PacketLossPercent,
+		LoadedLatencyMs:   loadedSummary.AverageLatencyMs,
+		LoadedJitterMs:    loadedSummary.JitterMs,
+		LoadedLossPercent: loadedSummary.PacketLossPercent,
+		LoadKBps:          speedKBps(loadBytes.Load(), loadTime),
+	}
+	if len(loaded) > 0 {
+		result.LatencyIncreaseMs = max(result.LoadedLatencyMs-result.IdleLatencyMs, 0)
+	}
+	return result
+}
+
+// udpLoadCanceledError is the error of a [Client.TestUDPLoadedLatency] canceled through its
+// context.
+func udpLoadCanceledError() *platerrors.PlatformError {
+	return &platerrors.PlatformError{Code: platerrors.OperationCanceled, Message: "UDP loaded latency test was canceled"}
+}
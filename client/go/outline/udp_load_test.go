@@ -0,0 +1,76 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/stretchr/testify/require"
+)
+
+var shortUDPLoadTest = udpLoadTest{
+	idleProbes:    3,
+	loadDuration:  300 * time.Millisecond,
+	loadWarmup:    50 * time.Millisecond,
+	probeInterval: 20 * time.Millisecond,
+	packetSize:    1200,
+}
+
+func Test_TestUDPLoadedLatency(t *testing.T) {
+	probeServer := newUDPEchoServer(t, nil)
+	loadServer := newUDPEchoServer(t, nil)
+
+	result := newDirectTestClient().testUDPLoadedLatency(context.Background(), probeServer, loadServer, shortUDPLoadTest)
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.Equal(t, 0.0, result.IdleLossPercent)
+	require.Greater(t, result.IdleLatencyMs, 0.0)
+	require.Greater(t, result.LoadKBps, int64(0))
+	require.GreaterOrEqual(t, result.LatencyIncreaseMs, 0.0)
+	require.LessOrEqual(t, result.LoadedLossPercent, 100.0)
+}
+
+func Test_TestUDPLoadedLatency_NoReplies(t *testing.T) {
+	probeServer := newUDPEchoServer(t, func(int) bool { return true })
+	loadServer := newUDPEchoServer(t, nil)
+	test := shortUDPLoadTest
+	test.idleProbes = 1
+
+	result := newDirectTestClient().testUDPLoadedLatency(context.Background(), probeServer, loadServer, test)
+	require.NotNil(t, result.Error)
+	require.Equal(t, platerrors.ProxyServerUDPUnsupported, result.Error.Code)
+}
+
+func Test_TestUDPLoadedLatency_Canceled(t *testing.T) {
+	probeServer := newUDPEchoServer(t, nil)
+	loadServer := newUDPEchoServer(t, nil)
+	test := shortUDPLoadTest
+	test.loadDuration = time.Minute
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(200*time.Millisecond, cancel)
+
+	start := time.Now()
+	result := newDirectTestClient().testUDPLoadedLatency(ctx, probeServer, loadServer, test)
+	require.Less(t, time.Since(start), 5*time.Second)
+	require.NotNil(t, result.Error)
+	require.Equal(t, platerrors.OperationCanceled, result.Error.Code)
+}
+
+func Test_TestUDPLoadedLatency_InvalidHost(t *testing.T) {
+	result := newDirectTestClient().TestUDPLoadedLatency(context.Background(), ":53", "127.0.0.1:9")
+	require.NotNil(t, result.Error)
+}
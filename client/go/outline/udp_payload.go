@@ -0,0 +1,130 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+)
+
+const (
+	// udpEchoPort is the port of the Echo Protocol (RFC 862).
+	udpEchoPort = "7"
+	// minUDPProbePayload is the smallest payload probed. It fits the probe sequence number.
+	minUDPProbePayload = 8
+	// maxUDPProbePayload is the largest payload of an IPv4 UDP packet.
+	maxUDPProbePayload = 65507
+	// udpPayloadProbeAttempts is the number of packets of a size that are sent before the size is
+	// considered too large, so that random packet loss doesn't shrink the result.
+	udpPayloadProbeAttempts = 2
+)
+
+// MaxUDPPayloadResult is the result of [Client.ProbeMaxUDPPayload].
+//
+// We use a struct instead of a tuple to preserve a strongly typed error that gobind recognizes.
+type MaxUDPPayloadResult struct {
+	PayloadBytes int // Largest UDP payload that made a round trip, or 0 if none did
+	Error        *platerrors.PlatformError
+}
+
+// ProbeMaxUDPPayload finds the largest UDP payload that makes a round trip through the proxy,
+// to detect networks that clamp the MTU or drop fragmented packets.
+//
+// It binary-searches the payload size with packets sent to the UDP echo server at host, which
+// may include a port (default 7). A size is too large if none of its packets is echoed back
+// within one second. If not even the smallest payload makes it, the result is 0 with an error.
+func (c *Client) ProbeMaxUDPPayload(ctx context.Context, host string) *MaxUDPPayloadResult {
+	dest, err := newUDPProbeAddr(host, udpEchoPort)
+	if err != nil {
+		return &MaxUDPPayloadResult{Error: platerrors.ToPlatformError(err)}
+	}
+
+	conn, err := c.ListenPacket(ctx)
+	if err != nil {
+		return &MaxUDPPayloadResult{Error: &platerrors.PlatformError{
+			Code:    platerrors.ProxyServerUDPUnsupported,
+			Message: "failed to listen for UDP packets",
+			Cause:   platerrors.ToPlatformError(err),
+		}}
+	}
+	defer conn.Close()
+
+	prober := &udpPayloadProber{conn: conn, dest: dest, buf: make([]byte, maxUDPProbePayload+1)}
+	maxPayload := 0
+	if prober.roundTrips(ctx, minUDPProbePayload) {
+		// Payloads of size fits make the round trip, and payloads of size tooLarge don't.
+		fits, tooLarge := minUDPProbePayload, maxUDPProbePayload+1
+		for tooLarge-fits > 1 && ctx.Err() == nil {
+			size := fits + (tooLarge-fits)/2
+			if prober.roundTrips(ctx, size) {
+				fits = size
+			} else {
+				tooLarge = size
+			}
+		}
+		maxPayload = fits
+	}
+
+	if ctx.Err() != nil {
+		return &MaxUDPPayloadResult{Error: &platerrors.PlatformError{
+			Code:    platerrors.OperationCanceled,
+			Message: "UDP payload probe was canceled",
+		}}
+	}
+	if maxPayload == 0 {
+		return &MaxUDPPayloadResult{Error: &platerrors.PlatformError{
+			Code:    platerrors.ProxyServerUDPUnsupported,
+			Message: "no UDP probe was echoed back",
+			Details: platerrors.ErrorDetails{"host": host},
+		}}
+	}
+	return &MaxUDPPayloadResult{PayloadBytes: maxPayload}
+}
+
+// udpPayloadProber sends probes of different sizes to a UDP echo server.
+type udpPayloadProber struct {
+	conn net.PacketConn
+	dest net.Addr
+	buf  []byte
+	seq  uint64
+}
+
+// roundTrips reports whether a probe with a payload of the given size is echoed back.
+func (p *udpPayloadProber) roundTrips(ctx context.Context, size int) bool {
+	for attempt := 0; attempt < udpPayloadProbeAttempts && ctx.Err() == nil; attempt++ {
+		p.seq++
+		probe := make([]byte, size)
+		binary.BigEndian.PutUint64(probe, p.seq)
+		p.conn.SetDeadline(udpProbeDeadline(ctx))
+		if _, err := p.conn.WriteTo(probe, p.dest); err != nil {
+			// Payloads that are too large for the local network stack fail right away.
+			continue
+		}
+		for {
+			n, _, err := p.conn.ReadFrom(p.buf)
+			if err != nil {
+				break
+			}
+			// Ignore late echoes of earlier probes.
+			if n == size && binary.BigEndian.Uint64(p.buf) == p.seq {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/config"
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/Jigsaw-Code/outline-sdk/transport"
+	"github.com/stretchr/testify/require"
+)
+
+// payloadLimitListener is a [transport.PacketListener] whose connections fail to send payloads
+// larger than limit, like a network stack with a small MTU.
+type payloadLimitListener struct {
+	limit int
+}
+
+var _ transport.PacketListener = payloadLimitListener{}
+
+func (l payloadLimitListener) ListenPacket(ctx context.Context) (net.PacketConn, error) {
+	conn, err := (&transport.UDPListener{}).ListenPacket(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &payloadLimitConn{conn, l.limit}, nil
+}
+
+type payloadLimitConn struct {
+	net.PacketConn
+	limit int
+}
+
+func (c *payloadLimitConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	if len(b) > c.limit {
+		return 0, syscall.EMSGSIZE
+	}
+	return c.PacketConn.WriteTo(b, addr)
+}
+
+func Test_ProbeMaxUDPPayload(t *testing.T) {
+	server := newUDPEchoServer(t, nil)
+	client := newDirectTestClient()
+	client.pl = &config.PacketListener{PacketListener: payloadLimitListener{1372}}
+
+	result := client.ProbeMaxUDPPayload(context.Background(), server)
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.Equal(t, 1372, result.PayloadBytes)
+}
+
+func Test_ProbeMaxUDPPayload_AllLost(t *testing.T) {
+	server := newUDPEchoServer(t, func(int) bool { return true })
+
+	result := newDirectTestClient().ProbeMaxUDPPayload(context.Background(), server)
+	require.Equal(t, 0, result.PayloadBytes)
+	require.NotNil(t, result.Error)
+	require.Equal(t, platerrors.ProxyServerUDPUnsupported, result.Error.Code)
+}
+
+func Test_ProbeMaxUDPPayload_Canceled(t *testing.T) {
+	server := newUDPEchoServer(t, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	result := newDirectTestClient().ProbeMaxUDPPayload(ctx, server)
+	require.Equal(t, 0, result.PayloadBytes)
+	require.NotNil(t, result.Error)
+	require.Equal(t, platerrors.OperationCanceled, result.Error.Code)
+}
+
+func Test_ProbeMaxUDPPayload_InvalidHost(t *testing.T) {
+	result := newDirectTestClient().ProbeMaxUDPPayload(context.Background(), ":7")
+	require.NotNil(t, result.Error)
+	require.Equal(t, 0, result.PayloadBytes)
+}
@@ -0,0 +1,216 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+)
+
+const (
+	udpProbeTimeout = 1 * time.Second
+	udpProbePort    = "53"
+	// maxUDPProbeCount caps the count of [Client.TestUDPQuality].
+	maxUDPProbeCount = 100
+)
+
+// UDPQualityResult represents the result of [Client.TestUDPQuality].
+//
+// We use a struct instead of a tuple to preserve a strongly typed error that gobind recognizes.
+type UDPQualityResult struct {
+	AverageLatencyMs  float64 // Average round-trip time of the packets that got a reply
+	JitterMs          float64 // Mean absolute deviation of the round-trip times
+	PacketLossPercent float64 // Percentage of packets that got no reply, from 0 to 100
+	Error             *platerrors.PlatformError
+}
+
+// TestUDPQuality measures latency, jitter and packet loss of UDP traffic through the proxy.
+//
+// It sends count DNS queries, one at a time, to the resolver at host, which may include a port
+// (default 53). A query that gets no reply within one second is counted as lost. The count must
+// be between 1 and 100.
+func (c *Client) TestUDPQuality(ctx context.Context, host string, count int) *UDPQualityResult {
+	if count < 1 || count > maxUDPProbeCount {
+		return &UDPQualityResult{Error: &platerrors.PlatformError{
+			Code:    platerrors.InvalidConfig,
+			Message: fmt.Sprintf("UDP probe count must be between 1 and %d", maxUDPProbeCount),
+			Details: platerrors.ErrorDetails{"count": count},
+		}}
+	}
+	dest, err := newUDPProbeAddr(host, udpProbePort)
+	if err != nil {
+		return &UDPQualityResult{Error: platerrors.ToPlatformError(err)}
+	}
+
+	conn, err := c.ListenPacket(ctx)
+	if err != nil {
+		return &UDPQualityResult{Error: udpListenError(err)}
+	}
+	defer conn.Close()
+
+	var rtts []time.Duration
+	sent := 0
+	buf := make([]byte, 512)
+	for ; sent < count && !udpTestDone(ctx); sent++ {
+		if rtt, ok := probeUDP(ctx, conn, dest, uint16(sent), buf); ok {
+			rtts = append(rtts, rtt)
+		}
+	}
+
+	result := summarizeUDPProbes(rtts, sent)
+	if udpTestDone(ctx) {
+		result.Error = &platerrors.PlatformError{
+			Code:    platerrors.OperationCanceled,
+			Message: "UDP quality test was canceled",
+		}
+	} else if len(rtts) == 0 {
+		result.Error = &platerrors.PlatformError{
+			Code:    platerrors.ProxyServerUDPUnsupported,
+			Message: "no UDP replies received",
+			Details: platerrors.ErrorDetails{"sent": sent},
+		}
+	}
+	return result
+}
+
+// udpTestDone reports whether ctx is done. That includes when its deadline has passed but its
+// timer hasn't fired yet, since the probes capped at that deadline have already timed out then,
+// and would otherwise be taken for lost packets.
+func udpTestDone(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return true
+	}
+	deadline, ok := ctx.Deadline()
+	return ok && !time.Now().Before(deadline)
+}
+
+// udpListenError is the error of a UDP test that failed to listen for packets with err.
+func udpListenError(err error) *platerrors.PlatformError {
+	return &platerrors.PlatformError{
+		Code:    platerrors.ProxyServerUDPUnsupported,
+		Message: "failed to listen for UDP packets",
+		Cause:   platerrors.ToPlatformError(err),
+	}
+}
+
+// probeUDP sends a DNS query with the given id to dest and waits for the matching reply.
+// It reports the round-trip time and whether a reply was received in time.
+func probeUDP(ctx context.Context, conn net.PacketConn, dest net.Addr, id uint16, buf []byte) (time.Duration, bool) {
+	conn.SetDeadline(udpProbeDeadline(ctx))
+
+	start := time.Now()
+	if _, err := conn.WriteTo(newDNSQuery(id), dest); err != nil {
+		return 0, false
+	}
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return 0, false
+		}
+		// Ignore late replies to earlier probes.
+		if n >= 2 && binary.BigEndian.Uint16(buf) == id {
+			return time.Since(start), true
+		}
+	}
+}
+
+// udpProbeDeadline returns the deadline for the reply to a UDP probe sent now.
+func udpProbeDeadline(ctx context.Context) time.Time {
+	deadline := time.Now().Add(udpProbeTimeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	return deadline
+}
+
+func summarizeUDPProbes(rtts []time.Duration, sent int) *UDPQualityResult {
+	result := &UDPQualityResult{}
+	if sent == 0 {
+		return result
+	}
+	result.PacketLossPercent = float64(sent-len(rtts)) * 100 / float64(sent)
+	if len(rtts) == 0 {
+		return result
+	}
+	var total float64
+	for _, rtt := range rtts {
+		total += durationToMs(rtt)
+	}
+	result.AverageLatencyMs = total / float64(len(rtts))
+	var deviation float64
+	for _, rtt := range rtts {
+		deviation += math.Abs(durationToMs(rtt) - result.AverageLatencyMs)
+	}
+	result.JitterMs = deviation / float64(len(rtts))
+	return result
+}
+
+func durationToMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// udpProbeAddr is a [net.Addr] for a host name, so that it's resolved by the proxy
+// rather than locally.
+type udpProbeAddr string
+
+func (a udpProbeAddr) Network() string { return "udp" }
+
+func (a udpProbeAddr) String() string { return string(a) }
+
+// newUDPProbeAddr returns the destination address for UDP probes to host,
+// which defaults to defaultPort if no port is given.
+func newUDPProbeAddr(host string, defaultPort string) (net.Addr, error) {
+	hostPort := host
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		hostPort = net.JoinHostPort(host, defaultPort)
+	}
+	hostname, _, err := net.SplitHostPort(hostPort)
+	if err != nil || hostname == "" {
+		return nil, &platerrors.PlatformError{
+			Code:    platerrors.InvalidConfig,
+			Message: "invalid UDP probe host",
+			Details: platerrors.ErrorDetails{"host": host},
+		}
+	}
+	if net.ParseIP(hostname) != nil {
+		// IP literals don't need a DNS lookup.
+		return net.ResolveUDPAddr("udp", hostPort)
+	}
+	return udpProbeAddr(hostPort), nil
+}
+
+// newDNSQuery returns a DNS query for the "com" A record with the given query id.
+func newDNSQuery(id uint16) []byte {
+	query := []byte{
+		0, 0, // [0-1]   query ID
+		1, 0, // [2-3]   flags; byte[2] = 1 for recursion desired (RD).
+		0, 1, // [4-5]   QDCOUNT (number of queries)
+		0, 0, // [6-7]   ANCOUNT (number of answers)
+		0, 0, // [8-9]   NSCOUNT (number of name server records)
+		0, 0, // [10-11] ARCOUNT (number of additional records)
+		3, 'c', 'o', 'm',
+		0,    // null terminator of FQDN (root TLD)
+		0, 1, // QTYPE, set to A
+		0, 1, // QCLASS, set to 1 = IN (Internet)
+	}
+	binary.BigEndian.PutUint16(query, id)
+	return query
+}
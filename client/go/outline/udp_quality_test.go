@@ -0,0 +1,142 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/config"
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/Jigsaw-Code/outline-sdk/transport"
+	"github.com/stretchr/testify/require"
+)
+
+// newUDPEchoServer starts a UDP server that echoes packets back, except for the packets for which
+// drop returns true. It returns the server address.
+func newUDPEchoServer(t *testing.T, drop func(packetIndex int) bool) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		buf := make([]byte, 65535)
+		for i := 0; ; i++ {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if drop != nil && drop(i) {
+				continue
+			}
+			conn.WriteTo(buf[:n], addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func Test_TestUDPQuality(t *testing.T) {
+	server := newUDPEchoServer(t, nil)
+
+	result := newDirectTestClient().TestUDPQuality(context.Background(), server, 5)
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.Equal(t, 0.0, result.PacketLossPercent)
+	require.Greater(t, result.AverageLatencyMs, 0.0)
+	require.GreaterOrEqual(t, result.JitterMs, 0.0)
+}
+
+func Test_TestUDPQuality_PacketLoss(t *testing.T) {
+	server := newUDPEchoServer(t, func(i int) bool { return i%2 == 1 })
+
+	result := newDirectTestClient().TestUDPQuality(context.Background(), server, 4)
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.Equal(t, 50.0, result.PacketLossPercent)
+}
+
+func Test_TestUDPQuality_AllLost(t *testing.T) {
+	server := newUDPEchoServer(t, func(int) bool { return true })
+
+	result := newDirectTestClient().TestUDPQuality(context.Background(), server, 1)
+	require.NotNil(t, result.Error)
+	require.Equal(t, platerrors.ProxyServerUDPUnsupported, result.Error.Code)
+	require.Equal(t, 100.0, result.PacketLossPercent)
+}
+
+func Test_TestUDPQuality_ListenFailure(t *testing.T) {
+	client := newDirectTestClient()
+	client.pl = &config.PacketListener{PacketListener: failingPacketListener{}}
+
+	result := client.TestUDPQuality(context.Background(), "127.0.0.1", 3)
+	require.NotNil(t, result.Error)
+	require.Equal(t, platerrors.ProxyServerUDPUnsupported, result.Error.Code)
+}
+
+func Test_TestUDPQuality_Canceled(t *testing.T) {
+	server := newUDPEchoServer(t, func(int) bool { return true })
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	result := newDirectTestClient().TestUDPQuality(ctx, server, 10)
+	require.Less(t, time.Since(start), time.Second)
+	require.NotNil(t, result.Error)
+	require.Equal(t, platerrors.OperationCanceled, result.Error.Code)
+}
+
+// expiredContext is a context whose deadline has passed, but whose timer hasn't fired yet.
+type expiredContext struct {
+	context.Context
+}
+
+func (expiredContext) Deadline() (time.Time, bool) {
+	return time.Now().Add(-time.Millisecond), true
+}
+
+func Test_TestUDPQuality_DeadlinePassed(t *testing.T) {
+	server := newUDPEchoServer(t, func(int) bool { return true })
+
+	result := newDirectTestClient().TestUDPQuality(expiredContext{context.Background()}, server, 10)
+	require.NotNil(t, result.Error)
+	require.Equal(t, platerrors.OperationCanceled, result.Error.Code)
+}
+
+func Test_TestUDPQuality_InvalidInput(t *testing.T) {
+	client := newDirectTestClient()
+	for _, count := range []int{0, -1, maxUDPProbeCount + 1} {
+		result := client.TestUDPQuality(context.Background(), "127.0.0.1", count)
+		require.NotNil(t, result.Error, "count %d", count)
+		require.Equal(t, platerrors.InvalidConfig, result.Error.Code)
+	}
+	result := client.TestUDPQuality(context.Background(), ":53", 1)
+	require.NotNil(t, result.Error)
+	require.Equal(t, platerrors.InvalidConfig, result.Error.Code)
+}
+
+func Test_summarizeUDPProbes(t *testing.T) {
+	result := summarizeUDPProbes([]time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 30 * time.Millisecond}, 4)
+	require.Equal(t, 20.0, result.AverageLatencyMs)
+	require.InDelta(t, 20.0/3, result.JitterMs, 1e-9)
+	require.Equal(t, 25.0, result.PacketLossPercent)
+}
+
+type failingPacketListener struct{}
+
+var _ transport.PacketListener = failingPacketListener{}
+
+func (failingPacketListener) ListenPacket(context.Context) (net.PacketConn, error) {
+	return nil, errors.New("UDP is blocked")
+}
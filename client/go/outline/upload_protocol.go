@@ -0,0 +1,279 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+)
+
+// Protocols of [BandwidthTestConfig.UploadProtocol].
+const (
+	// UploadProtocolChunked streams the data as the body of a single POST request with chunked
+	// encoding. It suits servers that read request bodies of any length.
+	UploadProtocolChunked = "chunked"
+	// UploadProtocolRaw sends the data as the bodies of consecutive POST requests of a fixed
+	// length, for servers that don't accept chunked encoding.
+	UploadProtocolRaw = "raw"
+	// UploadProtocolCloudflare sends the data like the speed test of speed.cloudflare.com, whose
+	// __up endpoint expects text bodies of a fixed length tagged with a measurement ID. The
+	// bodies are random printable ASCII text.
+	UploadProtocolCloudflare = "cloudflare"
+)
+
+// uploadProtocol is how an upload test sends its data to a measurement backend.
+//
+// To support a new backend, implement upload, usually with a configured [fixedBodyUpload] or on
+// top of [speedTester.uploadStream], and add the implementation to uploadProtocols under a new
+// UploadProtocol* name, which [BandwidthTestConfig.UploadProtocol] then accepts.
+type uploadProtocol interface {
+	// upload sends data to testURL through t within limits, reporting the bytes sent to progress,
+	// which may be nil. Only the time spent sending the data counts toward the measurement, not
+	// the connection setup.
+	upload(ctx context.Context, t *speedTester, testURL string, limits transferLimits, progress *progressReporter) *SpeedResult
+}
+
+// uploadProtocols are the protocols of [BandwidthTestConfig.UploadProtocol], by name.
+var uploadProtocols = map[string]uploadProtocol{
+	UploadProtocolChunked: chunkedUpload{},
+	UploadProtocolRaw:     fixedBodyUpload{contentType: "application/octet-stream"},
+	UploadProtocolCloudflare: fixedBodyUpload{
+		contentType: "text/plain;charset=UTF-8",
+		text:        true,
+		measIDParam: "measId",
+	},
+}
+
+// chunkedUpload implements [UploadProtocolChunked].
+type chunkedUpload struct{}
+
+func (chunkedUpload) upload(ctx context.Context, t *speedTester, testURL string, limits transferLimits, progress *progressReporter) *SpeedResult {
+	return t.uploadStream(ctx, testURL, limits, progress)
+}
+
+// uploadRequestBytes is the body length of the requests of [fixedBodyUpload]. It's small enough
+// for a request to take a fraction of the test on slow links.
+const uploadRequestBytes = 256 * 1024
+
+// fixedBodyUpload sends the data as the bodies of consecutive POST requests of
+// [uploadRequestBytes] each, until the test time is over.
+type fixedBodyUpload struct {
+	// contentType is the Content-Type of the requests.
+	contentType string
+	// text makes the bodies printable ASCII text, for text content types, instead of random bytes.
+	text bool
+	// measIDParam is the name of the query parameter that holds a random ID shared by the
+	// requests of a test, or empty for none.
+	measIDParam string
+}
+
+func (p fixedBodyUpload) upload(ctx context.Context, t *speedTester, testURL string, limits transferLimits, progress *progressReporter) *SpeedResult {
+	requestURL, err := p.requestURL(testURL)
+	if err != nil {
+		return &SpeedResult{SpeedKBps: -1, Error: invalidTestURLError(testURL, err)}
+	}
+	httpClient := t.newHTTPClient(limits.timeout())
+	data := newUploadData(uploadRequestBytes, p.text)
+
+	// The meter cancels the requests once the test time is over.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	meter := &uploadMeter{limits: limits, progress: progress, stop: cancel}
+	defer meter.close()
+
+	capped := false
+	var requestErr error
+	for ctx.Err() == nil {
+		body := data
+		if limits.maxBytes > 0 {
+			remaining := limits.maxBytes - meter.total()
+			if remaining <= 0 {
+				capped = true
+				break
+			}
+			body = limitBuffer(data, remaining)
+		}
+		req, err := t.newRequest(ctx, http.MethodPost, requestURL, &meteredBody{Reader: bytes.NewReader(body), meter: meter})
+		if err != nil {
+			return &SpeedResult{SpeedKBps: -1, Error: invalidTestURLError(testURL, err)}
+		}
+		req.ContentLength = int64(len(body))
+		req.Header.Set("Content-Type", p.contentType)
+		sent := meter.total()
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			requestErr = err
+			break
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if meter.total() == sent {
+			// The server answered without taking the data, and would do it again.
+			break
+		}
+	}
+
+	measuredBytes, elapsed := meter.result()
+	if meter.total() == 0 {
+		if requestErr != nil && ctx.Err() == nil {
+			return &SpeedResult{SpeedKBps: -1, Error: speedTestError(requestErr, platerrors.ProxyServerUnreachable, "upload request failed")}
+		}
+		return &SpeedResult{}
+	}
+	return &SpeedResult{SpeedKBps: speedKBps(measuredBytes, elapsed), Capped: capped}
+}
+
+// uploadTextAlphabet are the characters of the text bodies of [fixedBodyUpload].
+const uploadTextAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// newUploadData returns n random bytes, or n random characters of [uploadTextAlphabet] if text is
+// set. The data is random so that no middlebox can compress it.
+func newUploadData(n int, text bool) []byte {
+	data := make([]byte, n)
+	rand.Read(data)
+	if text {
+		for i, b := range data {
+			data[i] = uploadTextAlphabet[int(b)%len(uploadTextAlphabet)]
+		}
+	}
+	return data
+}
+
+// requestURL returns testURL with the query parameters of the protocol.
+func (p fixedBodyUpload) requestURL(testURL string) (string, error) {
+	if p.measIDParam == "" {
+		return testURL, nil
+	}
+	parsed, err := url.Parse(testURL)
+	if err != nil {
+		return "", err
+	}
+	id := make([]byte, 8)
+	rand.Read(id)
+	query := parsed.Query()
+	query.Set(p.measIDParam, hex.EncodeToString(id))
+	parsed.RawQuery = query.Encode()
+	return parsed.String(), nil
+}
+
+// meteredBody is a request body that reports the bytes the HTTP transport reads to meter.
+type meteredBody struct {
+	*bytes.Reader
+	meter *uploadMeter
+}
+
+func (b *meteredBody) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	b.meter.add(n)
+	return n, err
+}
+
+// uploadMeter measures the data read from the bodies of the requests of an upload test. The test
+// time starts with the first read, which marks the end of the connection setup, and stop is
+// called once the warm-up and the measurement are over.
+type uploadMeter struct {
+	limits   transferLimits
+	progress *progressReporter
+	stop     func()
+
+	mu sync.Mutex
+	// start is the time of the first read, or zero if there was none.
+	start time.Time
+	// measureStart is the end of the warm-up, which is cut short once the warm-up has read
+	// [transferLimits.warmupMaxBytes].
+	measureStart  time.Time
+	timer         *time.Timer
+	warmupBytes   int64
+	measuredBytes int64
+	// last is the time of the last read.
+	last time.Time
+	// closed is set once the test is over, after which reads are ignored.
+	closed bool
+}
+
+// add records n bytes read by the HTTP transport. It's called by the goroutines of the transport.
+func (m *uploadMeter) add(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return
+	}
+	now := time.Now()
+	if m.start.IsZero() {
+		m.start = now
+		m.measureStart = now.Add(m.limits.warmup)
+		m.timer = time.AfterFunc(m.limits.warmup+m.limits.duration, m.stop)
+	}
+	if now.Before(m.measureStart) {
+		m.warmupBytes += int64(n)
+		if limit := m.limits.warmupMaxBytes(); limit > 0 && m.warmupBytes >= limit {
+			m.measureStart = now
+			m.timer.Reset(m.limits.duration)
+		}
+	} else {
+		m.measuredBytes += int64(n)
+	}
+	m.last = now
+	m.progress.add(int64(n))
+}
+
+// total returns the number of bytes read so far, including the warm-up.
+func (m *uploadMeter) total() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.warmupBytes + m.measuredBytes
+}
+
+// result returns the number of bytes read after the warm-up, and the time spent reading them.
+// If the upload ended during the warm-up, it returns the bytes and the time of the warm-up.
+func (m *uploadMeter) result() (int64, time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.start.IsZero() {
+		return 0, 0
+	}
+	if m.measuredBytes == 0 {
+		return m.warmupBytes, m.last.Sub(m.start)
+	}
+	measureStart := m.measureStart
+	end := m.last
+	if measureEnd := measureStart.Add(m.limits.duration); measureEnd.Before(end) {
+		end = measureEnd
+	}
+	if end.Before(measureStart) {
+		return m.measuredBytes, 0
+	}
+	return m.measuredBytes, end.Sub(measureStart)
+}
+
+// close ends the test: it stops the timer of the meter and reports the final progress.
+func (m *uploadMeter) close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	if m.timer != nil {
+		m.timer.Stop()
+	}
+	m.progress.finish()
+}
@@ -0,0 +1,169 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/stretchr/testify/require"
+)
+
+// uploadRequest is what [newUploadRecordingServer] records of a request.
+type uploadRequest struct {
+	contentLength    int64
+	transferEncoding []string
+	contentType      string
+	measID           string
+	bodyBytes        int64
+	// text tells whether the body is printable ASCII text.
+	text bool
+}
+
+// newUploadRecordingServer returns a server that reads the request bodies, and a function that
+// returns the requests received so far.
+func newUploadRecordingServer(t *testing.T) (*httptest.Server, func() []uploadRequest) {
+	var mu sync.Mutex
+	var requests []uploadRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		text := !slices.ContainsFunc(body, func(b byte) bool { return b < 0x20 || b > 0x7e })
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, uploadRequest{
+			contentLength:    r.ContentLength,
+			transferEncoding: r.TransferEncoding,
+			contentType:      r.Header.Get("Content-Type"),
+			measID:           r.URL.Query().Get("measId"),
+			bodyBytes:        int64(len(body)),
+			text:             text,
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []uploadRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]uploadRequest(nil), requests...)
+	}
+}
+
+func Test_speedTester_UploadCloudflare(t *testing.T) {
+	server, requests := newUploadRecordingServer(t)
+	tester := newDirectTestClient().newSpeedTester()
+	tester.uploadProtocol = uploadProtocols[UploadProtocolCloudflare]
+
+	start := time.Now()
+	result := tester.upload(context.Background(), server.URL+"/__up", transferLimits{duration: 500 * time.Millisecond}, nil)
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.Greater(t, result.SpeedKBps, int64(0))
+	require.Less(t, time.Since(start), 2*time.Second)
+
+	received := requests()
+	require.NotEmpty(t, received)
+	measID := received[0].measID
+	require.NotEmpty(t, measID)
+	for _, r := range received {
+		require.Equal(t, int64(uploadRequestBytes), r.contentLength)
+		require.Empty(t, r.transferEncoding)
+		require.Equal(t, "text/plain;charset=UTF-8", r.contentType)
+		require.True(t, r.text)
+		require.Equal(t, measID, r.measID)
+	}
+}
+
+func Test_speedTester_UploadRaw(t *testing.T) {
+	server, requests := newUploadRecordingServer(t)
+	tester := newDirectTestClient().newSpeedTester()
+	tester.uploadProtocol = uploadProtocols[UploadProtocolRaw]
+
+	limits := transferLimits{duration: 10 * time.Second, maxBytes: uploadRequestBytes + 1000}
+	result := tester.upload(context.Background(), server.URL, limits, nil)
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.True(t, result.Capped)
+
+	received := requests()
+	require.Len(t, received, 2)
+	require.Equal(t, int64(uploadRequestBytes), received[0].bodyBytes)
+	require.Equal(t, int64(1000), received[1].bodyBytes)
+	for _, r := range received {
+		require.Equal(t, r.bodyBytes, r.contentLength)
+		require.Equal(t, "application/octet-stream", r.contentType)
+		require.Empty(t, r.measID)
+	}
+}
+
+func Test_speedTester_UploadChunkedByDefault(t *testing.T) {
+	server, requests := newUploadRecordingServer(t)
+	tester := newDirectTestClient().newSpeedTester()
+
+	result := tester.upload(context.Background(), server.URL, transferLimits{duration: 200 * time.Millisecond}, nil)
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	received := requests()
+	require.Len(t, received, 1)
+	require.Equal(t, []string{"chunked"}, received[0].transferEncoding)
+}
+
+func Test_speedTester_UploadFixedBodyErrors(t *testing.T) {
+	tester := newDirectTestClient().newSpeedTester()
+	tester.uploadProtocol = uploadProtocols[UploadProtocolCloudflare]
+
+	// Port 1 on localhost is expected to refuse connections.
+	result := tester.upload(context.Background(), "http://127.0.0.1:1/__up", transferLimits{duration: time.Second}, nil)
+	require.Equal(t, int64(-1), result.SpeedKBps)
+	require.Equal(t, platerrors.ProxyServerUnreachable, result.Error.Code)
+}
+
+func Test_PerformBandwidthTestWithConfig_UploadProtocol(t *testing.T) {
+	server, requests := newUploadRecordingServer(t)
+	cfg := &BandwidthTestConfig{
+		DownloadURL:     server.URL,
+		UploadURL:       server.URL,
+		LatencyURL:      server.URL,
+		DurationSeconds: 1,
+		UploadProtocol:  UploadProtocolRaw,
+	}
+
+	result := newDirectTestClient().PerformBandwidthTestWithConfig(context.Background(), cfg)
+	require.Nil(t, result.Error, "Got %v", result.Error)
+	require.Greater(t, result.UploadSpeedKBps, int64(0))
+	received := requests()
+	require.Equal(t, int64(uploadRequestBytes), received[len(received)-1].contentLength)
+
+	require.Equal(t, UploadProtocolCloudflare, NewBandwidthTestConfig().UploadProtocol)
+	cfg.UploadProtocol = "carrier-pigeon"
+	result = newDirectTestClient().PerformBandwidthTestWithConfig(context.Background(), cfg)
+	require.NotNil(t, result.Error)
+	require.Equal(t, platerrors.InvalidConfig, result.Error.Code)
+}
+
+func Test_uploadMeter_EndsDuringWarmup(t *testing.T) {
+	meter := &uploadMeter{limits: transferLimits{duration: time.Second, warmup: time.Minute}, stop: func() {}}
+	defer meter.close()
+	meter.add(1024)
+	time.Sleep(20 * time.Millisecond)
+	meter.add(1024)
+
+	// The upload ended long before the measurement, so it's measured over the warm-up.
+	bytes, elapsed := meter.result()
+	require.Equal(t, int64(2048), bytes)
+	require.GreaterOrEqual(t, elapsed, 20*time.Millisecond)
+}
@@ -0,0 +1,86 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/token"
+)
+
+// invalidYAMLError converts err, which made parsing a YAML config fail, into a
+// [platerrors.InvalidConfig] error that points at the problem, so that users can fix it. Where
+// known, the message holds the line and the column, which are also in the "line" and "column"
+// details, the "field" detail holds the name of the offending field, and the cause tells what is
+// wrong there.
+//
+// The lines of the config that the YAML library quotes in its errors are left out, since they may
+// hold secrets.
+func invalidYAMLError(err error) *platerrors.PlatformError {
+	var tk *token.Token
+	var reason, field string
+	var (
+		syntaxErr       *yaml.SyntaxError
+		typeErr         *yaml.TypeError
+		overflowErr     *yaml.OverflowError
+		duplicateKeyErr *yaml.DuplicateKeyError
+		unknownFieldErr *yaml.UnknownFieldError
+		nodeTypeErr     *yaml.UnexpectedNodeTypeError
+	)
+	switch {
+	case errors.As(err, &syntaxErr):
+		tk, reason = syntaxErr.Token, syntaxErr.Message
+	case errors.As(err, &typeErr):
+		tk, reason = typeErr.Token, fmt.Sprintf("cannot use %v as %v", typeErr.SrcType, typeErr.DstType)
+		if typeErr.StructFieldName != nil {
+			field = *typeErr.StructFieldName
+		}
+	case errors.As(err, &overflowErr):
+		tk, reason = overflowErr.Token, fmt.Sprintf("number is out of the range of %v", overflowErr.DstType)
+	case errors.As(err, &duplicateKeyErr):
+		tk, reason = duplicateKeyErr.Token, duplicateKeyErr.Message
+		if duplicateKeyErr.Token != nil {
+			field = duplicateKeyErr.Token.Value
+		}
+	case errors.As(err, &unknownFieldErr):
+		tk, reason = unknownFieldErr.Token, unknownFieldErr.Message
+		if unknownFieldErr.Token != nil {
+			field = unknownFieldErr.Token.Value
+		}
+	case errors.As(err, &nodeTypeErr):
+		tk, reason = nodeTypeErr.Token, fmt.Sprintf("%s was used where %s is expected", nodeTypeErr.Actual.YAMLName(), nodeTypeErr.Expected.YAMLName())
+	}
+
+	if tk == nil || tk.Position == nil {
+		return &platerrors.PlatformError{
+			Code:    platerrors.InvalidConfig,
+			Message: "config is not valid YAML",
+			Cause:   platerrors.ToPlatformError(err),
+		}
+	}
+	perr := &platerrors.PlatformError{
+		Code:    platerrors.InvalidConfig,
+		Message: fmt.Sprintf("config is not valid YAML at line %d, column %d", tk.Position.Line, tk.Position.Column),
+		Details: platerrors.ErrorDetails{"line": tk.Position.Line, "column": tk.Position.Column},
+		Cause:   platerrors.ToPlatformError(errors.New(reason)),
+	}
+	if field != "" {
+		perr.Details["field"] = field
+	}
+	return perr
+}
@@ -0,0 +1,42 @@
+// Copyright 2025 The Outline Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outline
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Jigsaw-Code/outline-apps/client/go/outline/platerrors"
+	"github.com/goccy/go-yaml"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_invalidYAMLError_Field(t *testing.T) {
+	var clientConfig ClientConfig
+	err := yaml.UnmarshalWithOptions([]byte("transport: direct\nunknown: true"), &clientConfig, yaml.DisallowUnknownField())
+	require.Error(t, err)
+	perr := invalidYAMLError(err)
+	require.Equal(t, platerrors.InvalidConfig, perr.Code)
+	require.Equal(t, "config is not valid YAML at line 2, column 1", perr.Message)
+	require.Equal(t, platerrors.ErrorDetails{"line": 2, "column": 1, "field": "unknown"}, perr.Details)
+}
+
+func Test_invalidYAMLError_NoPosition(t *testing.T) {
+	perr := invalidYAMLError(errors.New("failed"))
+	require.Equal(t, platerrors.InvalidConfig, perr.Code)
+	require.Equal(t, "config is not valid YAML", perr.Message)
+	require.Nil(t, perr.Details)
+	require.Equal(t, "failed", perr.Cause.Message)
+}